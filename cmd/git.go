@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/cmd/git"
+)
+
+var gitCmdGroup = &cobra.Command{
+	Use:   "git",
+	Short: "A sub command group to work with git repositories",
+}
+
+func init() {
+	gitCmdGroup.AddCommand(git.CreateBundleCmd)
+}
@@ -0,0 +1,39 @@
+package git
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands/gitclone"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var CreateBundleCmd = &cobra.Command{
+	Use:   "create-bundle",
+	Short: "Create a git bundle from an existing checkout",
+	Long: `Create a git bundle from an existing checkout.
+
+The bundle can be used as the source for 'git-clone --bundle' in air-gapped environments.
+Incremental bundles can be created by passing a revision range; when cloning from a directory
+of bundles, they are applied in lexical order of their file names.`,
+	Example: `  # Bundle all refs of the repository in the current directory
+  kbc git create-bundle --output /bundles/00-full.bundle
+
+  # Create an incremental bundle with commits since v1.0
+  kbc git create-bundle --source-dir ./repo --output /bundles/01-since-v1.0.bundle --revisions v1.0..main`,
+	Run: func(cmd *cobra.Command, args []string) {
+		l.Logger.Debug("Starting git create-bundle")
+		createBundle, err := gitclone.NewCreateBundle(cmd)
+		if err != nil {
+			l.Logger.Fatal(err)
+		}
+		if err := createBundle.Run(); err != nil {
+			l.Logger.Fatal(err)
+		}
+		l.Logger.Debug("Finished git create-bundle")
+	},
+}
+
+func init() {
+	common.RegisterParameters(CreateBundleCmd, gitclone.CreateBundleParamsConfig)
+}
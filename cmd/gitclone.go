@@ -23,7 +23,13 @@ authentication, and optional merge with a target branch.`,
   kbc git-clone --url https://github.com/user/repo.git --sparse-checkout-directories "src,docs"
 
   # Clone and merge target branch (for PR testing)
-  kbc git-clone --url https://github.com/user/repo.git --revision feature-branch --merge-target-branch --target-branch main`,
+  kbc git-clone --url https://github.com/user/repo.git --revision feature-branch --merge-target-branch --target-branch main
+
+  # Clone offline from a bundle created by 'kbc git create-bundle'
+  kbc git-clone --url https://github.com/user/repo.git --bundle /bundles/repo.bundle --revision main
+
+  # Check the checked-out files against a source tree policy and write the findings report
+  kbc git-clone --url https://github.com/user/repo.git --source-policy-file policy.json --source-report-file findings.json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		l.Logger.Debug("Starting git-clone")
 		gitClone, err := gitclone.New(cmd)
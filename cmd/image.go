@@ -15,5 +15,9 @@ func init() {
 	imageCmd.AddCommand(image.ApplyTagsCmd)
 	imageCmd.AddCommand(image.BuildCmd)
 	imageCmd.AddCommand(image.BuildImageIndexCmd)
+	imageCmd.AddCommand(image.BuildSourceImageCmd)
+	imageCmd.AddCommand(image.DiffCmd)
+	imageCmd.AddCommand(image.LintCmd)
+	imageCmd.AddCommand(image.PackagesCmd)
 	imageCmd.AddCommand(image.PushContainerfileCmd)
 }
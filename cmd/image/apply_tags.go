@@ -19,6 +19,9 @@ Tags can be defined in two ways:
  - via tags parameter
  - via image label in the base image (see --tags-from-image-label parameter)
 Both ways can be used together.
+
+The image can also be in an OCI layout (--image-url oci:<dir>), the tags are then added
+to the layout as image names.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		l.Logger.Debug("Starting apply-tags")
@@ -23,10 +23,12 @@ Secret Handling:
   added as a 'buildah build --secret' argument.
 
   Accepts the following forms of arguments:
-    src=DIR_PATH[,name=BASENAME][,optional=true|false]
+    src=DIR_PATH[,name=BASENAME][,optional=true|false][,recursive=true|false]
         Makes the files from DIR_PATH available with id=<BASENAME>/<filename>.
         The BASENAME defaults to the basename of DIR_PATH.
         If optional=true and DIR_PATH doesn't exist, it is skipped.
+        If recursive=true, files in subdirectories are included as well,
+        with id=<BASENAME>/<subdir>/<filename>.
 
     DIR_PATH
         Equivalent to src=DIR_PATH
@@ -35,6 +37,44 @@ Secret Handling:
   The --mount option makes them available at /run/secrets/<basename>/<filename>
   for that particular RUN instruction.
 
+  Use --secrets to provide individual secrets:
+    id=ID,src=FILE_PATH[,optional=true|false]
+        Makes the file at FILE_PATH available with id=ID.
+    id=ID,env=ENV_VAR[,optional=true|false]
+        Makes the value of the ENV_VAR environment variable available with id=ID.
+
+  Secret IDs must be unique across --secret-dirs and --secrets. The build results
+  list the IDs and sources of the secrets, never their values.
+
+SSH Handling:
+  Use --ssh to make SSH keys available to 'RUN --mount=type=ssh[,id=ID]',
+  e.g. to fetch private git modules. Not allowed with --hermetic.
+
+  Accepts the following forms of arguments:
+    ID=KEY_PATH
+        Starts an ssh-agent loaded with the private key at KEY_PATH.
+    ID=DIR_PATH
+        Starts an ssh-agent loaded with the id_* private keys in DIR_PATH
+        (same as the ssh-directory workspace of git-clone).
+    ID
+        Forwards the ssh-agent at $SSH_AUTH_SOCK.
+
+  The ID of 'RUN --mount=type=ssh' defaults to 'default'. The agents are
+  killed when the build finishes.
+
+Network Allow-List Handling:
+  Hermetic builds (--hermetic) run without network access. Use --network-allow
+  to let them reach specific host:port destinations, e.g. a package registry
+  proxy. The build gets HTTP_PROXY/HTTPS_PROXY pointing at an egress proxy
+  that only connects to the allowed destinations and denies everything else.
+  Only tools that honor the proxy environment variables can use it.
+
+  --network-allow-package-registry-proxy allows the package registry proxies
+  from the Konflux config, if the cluster allows them.
+
+  Every connection attempt is logged. The build results count the allowed and
+  denied attempts, --network-report-output writes the full list to a file.
+
 Red Hat Subscription Management (RHSM) Handling:
   Fedora and RHEL machines typically have implicit RHSM integration, where if
   the host is subscribed, containers automatically get the subscription as well.
@@ -50,6 +90,7 @@ Red Hat Subscription Management (RHSM) Handling:
     Note that this approach may not be suitable for use in CI pipelines,
     because the subscription server regularly rotates entitlement certificates.
     If you store them long-term as CI secrets, they may become invalid.
+    The build fails early if any of the certificates is expired.
 
   2) Activation keys (--rhsm-activation-key=FILE + --rhsm-org=FILE)
 
@@ -84,6 +125,16 @@ Red Hat Subscription Management (RHSM) Handling:
       This may be less of a problem in CI pipelines (but do note the requirement
       to run as root).
 
+      Use --rhsm-fallback-activation-keys and --rhsm-fallback-orgs to provide
+      more activation keys, tried in order if registration fails. The results
+      report which activation key registered the system.
+
+      The registration is recorded in /var/lib/konflux-build-cli/rhsm-session.json.
+      If konflux-build-cli crashes before unregistering, the next pre-registration
+      unregisters the leaked system first (and fails if the process that registered
+      is still running). To do that explicitly, run
+      'konflux-build-cli internal rhsm-cleanup'.
+
     The activation keys approach is more suitable for CI pipelines, because
     unlike entitlement certificates, activation keys do not expire.
 
@@ -113,6 +164,29 @@ Examples:
   # Build and push to registry
   konflux-build-cli image build -t quay.io/myorg/myimage:latest --push
 
+  # Build and push the image to quay.io and a mirror, in parallel
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --push \
+    --push-destinations mirror.example.com/myorg/myimage:latest
+
+  # Build and push with zstd:chunked compressed layers (enables lazy pulling)
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --push \
+    --push-format oci --compression-format zstd:chunked
+
+  # Build twice and fail if the images aren't bit-for-bit identical
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest \
+    --source-date-epoch 1700000000 --rewrite-timestamp --reproducibility-check
+
+  # Fail the build (before pushing) if the image gets too big
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --push \
+    --max-image-size 2GB --max-layer-size 500MB --max-layers 40
+
+  # Build, push and write the inventory of the installed packages
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --push \
+    --package-inventory-output /workspace/package-inventory.json
+
+  # Build and write the image to an OCI layout instead of pushing it
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --output oci-layout:/workspace/layout:latest
+
   # Build with explicit Containerfile and context
   konflux-build-cli image build -f ./Containerfile -c ./myapp -t quay.io/myorg/myimage:v1.0.0
 
@@ -120,6 +194,28 @@ Examples:
   konflux-build-cli image build -t quay.io/myorg/myimage:latest \
     --secret-dirs /path/to/secrets1 src=/path/to/secrets2,name=certs
 
+  # Build with an individual secret file and a secret from an environment variable
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest \
+    --secrets id=netrc,src=/path/to/.netrc id=token,env=API_TOKEN
+
+  # Build with SSH keys for fetching private git modules
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --ssh default=/workspace/ssh-directory
+
+  # Hermetic build that can only reach the npm registry, with an audit report of the connections
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --hermetic \
+    --network-allow registry.npmjs.org:443 --network-report-output /workspace/network-report.json
+
+  # Build with additional build contexts (usable in FROM, COPY --from and RUN --mount=from)
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest \
+    --build-contexts shared=../shared base=docker-image://registry.access.redhat.com/ubi9/ubi:latest
+
+  # Fail the build if build args are unused, missing or used before declaration
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest \
+    --build-args VERSION=1.0 --strict-build-args
+
+  # Print the build plan (buildah command, mounts, Containerfile changes) without building
+  konflux-build-cli image build -t quay.io/myorg/myimage:latest --dry-run
+
   # Build with additional buildah arguments
   konflux-build-cli image build -t quay.io/myorg/myimage:latest -- --compat-volumes --force-rm
 `,
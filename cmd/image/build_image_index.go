@@ -28,6 +28,23 @@ Examples:
     --images quay.io/myorg/myapp@sha256:amd64digest... quay.io/myorg/myapp@sha256:arm64digest... \
     --additional-tags taskrun-xyz-12345 commit-abc123
 
+  # Push the image index to a mirror too, in parallel (the digests must match)
+  konflux-build-cli image build-image-index \
+    --image quay.io/myorg/myapp:latest \
+    --images quay.io/myorg/myapp@sha256:amd64digest... quay.io/myorg/myapp@sha256:arm64digest... \
+    --push-destinations mirror.example.com/myorg/myapp:latest
+
+  # Recompress the layers of all images in the index with zstd:chunked
+  konflux-build-cli image build-image-index \
+    --image quay.io/myorg/myapp:latest \
+    --images quay.io/myorg/myapp@sha256:amd64digest... quay.io/myorg/myapp@sha256:arm64digest... \
+    --compression-format zstd:chunked
+
+  # Build an image index from images written to OCI layouts by 'image build --output'
+  konflux-build-cli image build-image-index \
+    --image quay.io/myorg/myapp:latest \
+    --images oci:/layouts/amd64@sha256:amd64digest... oci:/layouts/arm64@sha256:arm64digest...
+
   # Write results to files (useful for Tekton tasks)
   konflux-build-cli image build-image-index \
     --image quay.io/myorg/myapp:latest \
@@ -0,0 +1,52 @@
+package image
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var BuildSourceImageCmd = &cobra.Command{
+	Use:   "build-source-image",
+	Short: "Build the source container image of a binary image and push it to registry.",
+	Long: `Assembles the source container image of a binary image and pushes it to the
+binary image repository, tagged <digest-tag>.src (e.g. sha256-1234567.src).
+
+The source image follows the source container layout: every layer adds a single file,
+either an SRPM in rpm_dir/ or a source archive in extra_src_dir/. It contains, in order:
+
+  - the layers of the source images of the base images, if they exist
+  - the SRPMs of the prefetched RPMs not already included by a base source image
+  - an archive of the sources of each other prefetched package manager
+  - the source code archive`,
+	Example: `
+  # Push the source image of quay.io/org/app@sha256:1234567 as quay.io/org/app:sha256-1234567.src
+  konflux-build-cli image build-source-image --image-url quay.io/org/app --image-digest sha256:1234567 \
+    --source-dir source
+
+  # Include the prefetched dependencies and the source images of the base images
+  konflux-build-cli image build-source-image --image-url quay.io/org/app --image-digest sha256:1234567 \
+    --source-dir source --prefetch-dir prefetch --resolved-base-images resolved-base-images.txt
+
+  # Use the source archive created by git-clone instead of the source directory
+  konflux-build-cli image build-source-image --image-url quay.io/org/app --image-digest sha256:1234567 \
+    --source-archive source.tar.gz
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		l.Logger.Debug("Starting build-source-image")
+		buildSourceImage, err := commands.NewBuildSourceImage(cmd)
+		if err != nil {
+			l.Logger.Fatal(err)
+		}
+		if err := buildSourceImage.Run(); err != nil {
+			l.Logger.Fatal(err)
+		}
+		l.Logger.Debug("Finished build-source-image")
+	},
+}
+
+func init() {
+	common.RegisterParameters(BuildSourceImageCmd, commands.BuildSourceImageParamsConfig)
+}
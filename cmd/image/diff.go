@@ -0,0 +1,55 @@
+package image
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff <image-a> <image-b>",
+	Short: "Compare two images",
+	Long: `Compare two images and report what changed from the first to the second.
+
+The images can be in a registry (docker://, or a plain image reference), in local
+containers storage (containers-storage:) or in an OCI layout (oci:<dir>[:<image>]).
+For multi-platform images, the image for --platform is compared.
+
+The report is printed as JSON and contains:
+  - config changes: user, working directory, entrypoint, cmd, stop signal,
+    exposed ports, volumes, and each env variable and label
+  - added and removed layers, identified by their uncompressed digest
+  - file changes in the image filesystem (all layers applied): added, removed and
+    modified files, with their size, mode and owner. Modification times are ignored.
+  - package changes, if one of the images has an rpmdb and the rpm CLI is available
+
+Layers compressed with zstd are not supported.
+
+Examples:
+  # Review a base image bump
+  konflux-build-cli image diff registry.access.redhat.com/ubi9/ubi:9.5 \
+    registry.access.redhat.com/ubi9/ubi:9.6
+
+  # Compare the arm64 images of a built image and its local rebuild
+  konflux-build-cli image diff quay.io/org/app@sha256:abc... oci:./rebuild \
+    --platform linux/arm64
+`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		l.Logger.Debug("Starting diff")
+		imageDiff, err := commands.NewImageDiff(cmd, args)
+		if err != nil {
+			l.Logger.Fatal(err)
+		}
+		if err := imageDiff.Run(); err != nil {
+			l.Logger.Fatal(err)
+		}
+		l.Logger.Debug("Finished diff")
+	},
+}
+
+func init() {
+	common.RegisterParameters(DiffCmd, commands.ImageDiffParamsConfig)
+}
@@ -0,0 +1,52 @@
+package image
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var LintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check a Containerfile for problems without building it",
+	Long: `Check a Containerfile for problems that would affect 'image build'.
+
+Finds the Containerfile the same way as 'image build' and reports build arg issues
+for the stages that would get built (see --target and --skip-unused-stages):
+  - build args that aren't declared by any ARG in the built stages
+    (buildah's predefined proxy args, e.g. HTTP_PROXY, don't need one)
+  - ARGs without a default value for which no value is provided
+  - ARGs used before their declaration in a stage, including ARGs declared
+    before the first FROM and not redeclared in the stage
+  - variables in FROM instructions that aren't declared by any ARG before
+    the first FROM and aren't provided
+
+The issues are logged and printed as JSON. By default, the command succeeds even
+if there are issues, use --strict-build-args to make it fail.
+
+Examples:
+  # Check the auto-detected Containerfile/Dockerfile in the current directory
+  konflux-build-cli image lint
+
+  # Check the build args for a specific target, fail if there are any issues
+  konflux-build-cli image lint -f ./Containerfile --target debug \
+    --build-args VERSION=1.0 --strict-build-args
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		l.Logger.Debug("Starting lint")
+		lint, err := commands.NewLint(cmd)
+		if err != nil {
+			l.Logger.Fatal(err)
+		}
+		if err := lint.Run(); err != nil {
+			l.Logger.Fatal(err)
+		}
+		l.Logger.Debug("Finished lint")
+	},
+}
+
+func init() {
+	common.RegisterParameters(LintCmd, commands.LintParamsConfig)
+}
@@ -0,0 +1,53 @@
+package image
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var PackagesCmd = &cobra.Command{
+	Use:   "packages <image>",
+	Short: "List the packages installed in an image",
+	Long: `List the packages installed in an image, identified by purls.
+
+The image is mounted with 'buildah mount' (in a user namespace, like 'image build' runs buildah),
+so it can be any reference 'buildah from' accepts. Images that are not in local containers storage
+are pulled first.
+
+The inventory is printed as JSON and includes:
+  - RPM packages from the rpmdb (sqlite, bdb or ndb), read with the rpm CLI
+  - Debian packages from /var/lib/dpkg/status and /var/lib/dpkg/status.d/
+  - Alpine packages from /lib/apk/db/installed
+  - Python distributions from .dist-info and .egg-info metadata in site-packages
+  - Go modules and the Go version from the build info of Go binaries
+
+Each package has the location it was found in (the package database, metadata file or binary).
+If the rpm CLI is not available, RPM packages are skipped with a warning.
+
+Examples:
+  # List the packages of a locally built image
+  konflux-build-cli image packages localhost/app:latest
+
+  # Write the inventory of a registry image to a file
+  konflux-build-cli image packages quay.io/org/app:v1.2 --output inventory.json
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		l.Logger.Debug("Starting packages")
+		imagePackages, err := commands.NewImagePackages(cmd, args)
+		if err != nil {
+			l.Logger.Fatal(err)
+		}
+		if err := imagePackages.Run(); err != nil {
+			l.Logger.Fatal(err)
+		}
+		l.Logger.Debug("Finished packages")
+	},
+}
+
+func init() {
+	common.RegisterParameters(PackagesCmd, commands.ImagePackagesParamsConfig)
+}
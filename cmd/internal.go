@@ -14,4 +14,6 @@ var internalCmdGroup = &cobra.Command{
 
 func init() {
 	internalCmdGroup.AddCommand(internal.InUserNamespaceCmd)
+	internalCmdGroup.AddCommand(internal.PackageInventoryCmd)
+	internalCmdGroup.AddCommand(internal.RHSMCleanupCmd)
 }
@@ -20,7 +20,8 @@ non-flag argument (or after --) is passed to the command as-is.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		loopbackUp, _ := cmd.Flags().GetBool("loopback-up")
 		disableRHSMHostIntegration, _ := cmd.Flags().GetBool("disable-rhsm-host-integration")
-		if err := commands.RunInUserNamespace(loopbackUp, disableRHSMHostIntegration, args); err != nil {
+		egressProxySocket, _ := cmd.Flags().GetString("egress-proxy-socket")
+		if err := commands.RunInUserNamespace(loopbackUp, disableRHSMHostIntegration, egressProxySocket, args); err != nil {
 			l.Logger.Fatal(err)
 		}
 	},
@@ -34,4 +35,10 @@ func init() {
 		false,
 		"If /usr/share/rhel/secrets exists, mount a tmpfs over it to disable RHSM host integration",
 	)
+	InUserNamespaceCmd.Flags().String(
+		"egress-proxy-socket",
+		"",
+		"Relay proxied connections from the loopback interface to the egress proxy listening on this unix socket\n"+
+			"and point the proxy environment variables of the command at the relay",
+	)
 }
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var PackageInventoryCmd = &cobra.Command{
+	Use:   "package-inventory --output <file> <image>",
+	Short: "Mount an image and write the inventory of its installed packages",
+	Long: `Mount an image with 'buildah mount' and write the inventory of its installed packages
+as JSON. Rootless, this must run inside 'buildah unshare' for the mount to be visible.
+
+Used by 'image build --package-inventory-output' and 'image packages'.`,
+	Example: `  buildah unshare -- konflux-build-cli internal package-inventory --output inventory.json quay.io/org/image:tag`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		if err := commands.RunPackageInventory(args[0], output); err != nil {
+			l.Logger.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	PackageInventoryCmd.Flags().String("output", "", "File to write the inventory JSON to")
+	_ = PackageInventoryCmd.MarkFlagRequired("output")
+}
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/commands"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/rhsm"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var RHSMCleanupCmd = &cobra.Command{
+	Use:   "rhsm-cleanup",
+	Short: "Unregister a system left registered with RHSM by a crashed command",
+	Long: `Check the RHSM session state file written by RHSM pre-registration and, if it
+records a registration that was never ended, unregister the system. Registrations
+of processes that are still running are left alone.
+
+'image build --rhsm-activation-preregister' and 'prefetch-dependencies' do this
+automatically before registering. Requires root permissions.`,
+	Example: `  konflux-build-cli internal rhsm-cleanup`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		stateFile, _ := cmd.Flags().GetString("state-file")
+		if err := commands.RunRHSMCleanup(stateFile); err != nil {
+			l.Logger.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RHSMCleanupCmd.Flags().String("state-file", rhsm.DefaultStateFile, "The RHSM session state file")
+}
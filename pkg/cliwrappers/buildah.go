@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/konflux-ci/konflux-build-cli/pkg/common"
@@ -25,6 +26,11 @@ type BuildahCliInterface interface {
 	Images(args *BuildahImagesArgs) (string, error)
 	ImagesJson(args *BuildahImagesArgs) ([]BuildahImagesEntry, error)
 	Version() (BuildahVersionInfo, error)
+	From(image string) (string, error)
+	Mount(container string) (string, error)
+	Umount(container string) error
+	Rm(container string) error
+	Tag(image string, names ...string) error
 	ManifestCreate(args *BuildahManifestCreateArgs) error
 	ManifestAdd(args *BuildahManifestAddArgs) error
 	ManifestInspect(args *BuildahManifestInspectArgs) (string, error)
@@ -56,6 +62,7 @@ type BuildahBuildArgs struct {
 	ContextDir       string
 	OutputRef        string
 	Secrets          []BuildahSecret
+	SSH              []BuildahSSH
 	Volumes          []BuildahVolume
 	BuildContexts    []BuildahBuildContext
 	BuildArgs        []string
@@ -71,11 +78,45 @@ type BuildahBuildArgs struct {
 	SkipUnusedStages *bool
 	ExtraArgs        []string
 	Wrapper          *WrapperCmd
+	Storage          BuildahStorage
 }
 
+// Buildah's global storage options. The zero value means the default storage.
+type BuildahStorage struct {
+	// Storage root directory (buildah --root)
+	Root string
+	// Storage state directory (buildah --runroot)
+	RunRoot string
+}
+
+// The global options to put before the buildah subcommand.
+func (s BuildahStorage) args() []string {
+	var args []string
+	if s.Root != "" {
+		args = append(args, "--root", s.Root)
+	}
+	if s.RunRoot != "" {
+		args = append(args, "--runroot", s.RunRoot)
+	}
+	return args
+}
+
+// Represents a buildah --secret argument: src=SRC,id=ID[,type=env]
 type BuildahSecret struct {
-	Src string
-	Id  string
+	// Path of the secret file, or the name of the environment variable for BuildahSecretTypeEnv
+	Src  string
+	Id   string
+	Type string
+}
+
+// The secret value comes from an environment variable rather than a file.
+const BuildahSecretTypeEnv = "env"
+
+// Represents a buildah --ssh argument: ID[=SOCKET]
+// Without a socket, buildah forwards the agent from $SSH_AUTH_SOCK.
+type BuildahSSH struct {
+	ID     string
+	Socket string
 }
 
 // Represents a buildah --volume argument: HOST-DIR:CONTAINER-DIR[:OPTIONS]
@@ -85,13 +126,19 @@ type BuildahVolume struct {
 	Options      string
 }
 
-// Represents a buildah --build-context argument: name=path
-// (buildah also supports other sources for contexts, but we only support paths for now)
+// Represents a buildah --build-context argument: name=location
+// The location is a path, docker-image://<image ref> or oci-layout://<path>[:<tag>]
+// (buildah also supports URLs, but we don't support those).
 type BuildahBuildContext struct {
 	Name     string
 	Location string
 }
 
+const (
+	BuildContextDockerImagePrefix = "docker-image://"
+	BuildContextOCILayoutPrefix   = "oci-layout://"
+)
+
 // Check that the build arguments are valid, e.g. required arguments are set.
 // Also called automatically by the BuildahCli.Build() method.
 func (args *BuildahBuildArgs) Validate() error {
@@ -140,12 +187,24 @@ func (args *BuildahBuildArgs) MakePathsAbsolute(baseDir string) error {
 	}
 
 	for i := range args.Secrets {
+		if args.Secrets[i].Type == BuildahSecretTypeEnv {
+			continue
+		}
 		err = ensureAbsolute(&args.Secrets[i].Src)
 		if err != nil {
 			return err
 		}
 	}
 
+	for i := range args.SSH {
+		if args.SSH[i].Socket == "" {
+			continue
+		}
+		if err := ensureAbsolute(&args.SSH[i].Socket); err != nil {
+			return err
+		}
+	}
+
 	for i := range args.Volumes {
 		err := ensureAbsolute(&args.Volumes[i].HostDir)
 		if err != nil {
@@ -154,7 +213,22 @@ func (args *BuildahBuildArgs) MakePathsAbsolute(baseDir string) error {
 	}
 
 	for i := range args.BuildContexts {
-		err := ensureAbsolute(&args.BuildContexts[i].Location)
+		location := &args.BuildContexts[i].Location
+		if strings.HasPrefix(*location, BuildContextDockerImagePrefix) {
+			continue
+		}
+		if layout, ok := strings.CutPrefix(*location, BuildContextOCILayoutPrefix); ok {
+			layoutPath, tag, hasTag := strings.Cut(layout, ":")
+			if err := ensureAbsolute(&layoutPath); err != nil {
+				return err
+			}
+			*location = BuildContextOCILayoutPrefix + layoutPath
+			if hasTag {
+				*location += ":" + tag
+			}
+			continue
+		}
+		err := ensureAbsolute(location)
 		if err != nil {
 			return err
 		}
@@ -170,18 +244,27 @@ func (args *BuildahBuildArgs) MakePathsAbsolute(baseDir string) error {
 	return nil
 }
 
-func (b *BuildahCli) Build(args *BuildahBuildArgs) error {
-	if err := args.Validate(); err != nil {
-		return fmt.Errorf("validating buildah args: %w", err)
-	}
-
-	buildahArgs := []string{"build", "--file", args.Containerfile, "--tag", args.OutputRef}
+// Command returns the executable and arguments of the 'buildah build' command,
+// including the wrapper commands if any. Doesn't validate the arguments.
+func (args *BuildahBuildArgs) Command() (string, []string) {
+	buildahArgs := append(args.Storage.args(), "build", "--file", args.Containerfile, "--tag", args.OutputRef)
 
 	for _, secret := range args.Secrets {
 		secretArg := "src=" + secret.Src + ",id=" + secret.Id
+		if secret.Type != "" {
+			secretArg += ",type=" + secret.Type
+		}
 		buildahArgs = append(buildahArgs, "--secret="+secretArg)
 	}
 
+	for _, ssh := range args.SSH {
+		sshArg := ssh.ID
+		if ssh.Socket != "" {
+			sshArg += "=" + ssh.Socket
+		}
+		buildahArgs = append(buildahArgs, "--ssh="+sshArg)
+	}
+
 	for _, volume := range args.Volumes {
 		volumeArg := volume.HostDir + ":" + volume.ContainerDir
 		if volume.Options != "" {
@@ -243,6 +326,15 @@ func (b *BuildahCli) Build(args *BuildahBuildArgs) error {
 	if args.Wrapper != nil {
 		executable, buildahArgs = args.Wrapper.Wrap(executable, buildahArgs)
 	}
+	return executable, buildahArgs
+}
+
+func (b *BuildahCli) Build(args *BuildahBuildArgs) error {
+	if err := args.Validate(); err != nil {
+		return fmt.Errorf("validating buildah args: %w", err)
+	}
+
+	executable, buildahArgs := args.Command()
 
 	buildahLog.Debugf("Running command:\n%s", shellJoin(executable, buildahArgs...))
 
@@ -264,6 +356,30 @@ func (b *BuildahCli) Build(args *BuildahBuildArgs) error {
 type BuildahPushArgs struct {
 	Image       string
 	Destination string
+	// Manifest type of the pushed image: oci or docker (v2s2). Empty means keep the image's format.
+	Format string
+	// Layer compression: gzip, zstd or zstd:chunked. Empty means buildah's default (gzip).
+	CompressionFormat string
+	// Compression level, 0 means the default level of the compression format
+	CompressionLevel int
+	// Recompress layers even if the destination already has them with a different compression
+	ForceCompression bool
+	Storage          BuildahStorage
+}
+
+// Arguments shared by buildah push and buildah manifest push to select the layer compression.
+func compressionArgs(format string, level int, force bool) []string {
+	var args []string
+	if format != "" {
+		args = append(args, "--compression-format", format)
+	}
+	if level != 0 {
+		args = append(args, "--compression-level", strconv.Itoa(level))
+	}
+	if force {
+		args = append(args, "--force-compression")
+	}
+	return args
 }
 
 // Push an image from local storage to the registry. Return the digest of the pushed manifest.
@@ -283,7 +399,12 @@ func (b *BuildahCli) Push(args *BuildahPushArgs) (string, error) {
 	}
 	defer func() { _ = os.Remove(digestFile) }()
 
-	buildahArgs := []string{"push", "--digestfile", digestFile, args.Image}
+	buildahArgs := append(args.Storage.args(), "push", "--digestfile", digestFile)
+	if args.Format != "" {
+		buildahArgs = append(buildahArgs, "--format", args.Format)
+	}
+	buildahArgs = append(buildahArgs, compressionArgs(args.CompressionFormat, args.CompressionLevel, args.ForceCompression)...)
+	buildahArgs = append(buildahArgs, args.Image)
 	if args.Destination != "" {
 		buildahArgs = append(buildahArgs, args.Destination)
 	}
@@ -389,7 +510,11 @@ func (b *BuildahCli) Inspect(args *BuildahInspectArgs) (string, error) {
 // The default output of Inspect() for the 'image' Type (a single image, not an image index).
 // Includes a subset of the attributes that buildah returns.
 type BuildahImageInfo struct {
-	OCIv1 ociv1.Image
+	// Digest of the image manifest in local storage.
+	FromImageDigest string
+	// The raw image manifest.
+	Manifest string
+	OCIv1    ociv1.Image
 }
 
 func (b *BuildahCli) InspectImage(name string) (BuildahImageInfo, error) {
@@ -501,6 +626,88 @@ func (b *BuildahCli) Version() (BuildahVersionInfo, error) {
 	return versionInfo, nil
 }
 
+// Create a working container from the image, pulling the image if it's not in local storage.
+// Returns the name of the container.
+func (b *BuildahCli) From(image string) (string, error) {
+	if image == "" {
+		return "", errors.New("image arg is empty")
+	}
+	stdout, err := b.runContainerCommand("from", image)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// Mount the root filesystem of the container and return the mount point.
+// Rootless, the mount is only visible inside 'buildah unshare'.
+func (b *BuildahCli) Mount(container string) (string, error) {
+	if container == "" {
+		return "", errors.New("container arg is empty")
+	}
+	stdout, err := b.runContainerCommand("mount", container)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+func (b *BuildahCli) Umount(container string) error {
+	if container == "" {
+		return errors.New("container arg is empty")
+	}
+	_, err := b.runContainerCommand("umount", container)
+	return err
+}
+
+func (b *BuildahCli) Rm(container string) error {
+	if container == "" {
+		return errors.New("container arg is empty")
+	}
+	_, err := b.runContainerCommand("rm", container)
+	return err
+}
+
+// Tag adds additional names to a local image.
+func (b *BuildahCli) Tag(image string, names ...string) error {
+	if image == "" {
+		return errors.New("image arg is empty")
+	}
+	if len(names) == 0 {
+		return errors.New("no names to tag the image with")
+	}
+
+	buildahArgs := append([]string{"tag", image}, names...)
+
+	buildahLog.Debugf("Running command:\n%s", shellJoin("buildah", buildahArgs...))
+
+	_, stderr, _, err := b.Executor.Execute(Command("buildah", buildahArgs...))
+	if err != nil {
+		buildahLog.Errorf("buildah tag failed: %s", err.Error())
+		if stderr != "" {
+			buildahLog.Errorf("stderr:\n%s", stderr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *BuildahCli) runContainerCommand(subcommand, arg string) (string, error) {
+	buildahArgs := []string{subcommand, arg}
+
+	buildahLog.Debugf("Running command:\n%s", shellJoin("buildah", buildahArgs...))
+
+	stdout, stderr, _, err := b.Executor.Execute(Command("buildah", buildahArgs...))
+	if err != nil {
+		buildahLog.Errorf("buildah %s failed: %s", subcommand, err.Error())
+		if stderr != "" {
+			buildahLog.Errorf("stderr:\n%s", stderr)
+		}
+		return "", err
+	}
+	return stdout, nil
+}
+
 type BuildahManifestCreateArgs struct {
 	ManifestName string
 }
@@ -590,6 +797,10 @@ type BuildahManifestPushArgs struct {
 	Destination  string
 	Format       string
 	TLSVerify    bool
+	// See BuildahPushArgs
+	CompressionFormat string
+	CompressionLevel  int
+	ForceCompression  bool
 }
 
 // ManifestPush pushes a manifest list to a registry and returns the digest
@@ -617,6 +828,8 @@ func (b *BuildahCli) ManifestPush(args *BuildahManifestPushArgs) (string, error)
 		buildahArgs = append(buildahArgs, "--format", args.Format)
 	}
 
+	buildahArgs = append(buildahArgs, compressionArgs(args.CompressionFormat, args.CompressionLevel, args.ForceCompression)...)
+
 	if args.TLSVerify {
 		buildahArgs = append(buildahArgs, "--tls-verify=true")
 	} else {
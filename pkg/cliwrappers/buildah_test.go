@@ -104,6 +104,7 @@ func TestBuildahCli_Build(t *testing.T) {
 			Secrets: []cliwrappers.BuildahSecret{
 				{Src: "/some/file", Id: "mysecret_1"},
 				{Src: "/other/file", Id: "mysecret_2"},
+				{Src: "MY_TOKEN", Id: "mysecret_3", Type: cliwrappers.BuildahSecretTypeEnv},
 			},
 		}
 
@@ -112,6 +113,32 @@ func TestBuildahCli_Build(t *testing.T) {
 
 		g.Expect(capturedArgs).To(ContainElement("--secret=src=/some/file,id=mysecret_1"))
 		g.Expect(capturedArgs).To(ContainElement("--secret=src=/other/file,id=mysecret_2"))
+		g.Expect(capturedArgs).To(ContainElement("--secret=src=MY_TOKEN,id=mysecret_3,type=env"))
+	})
+
+	t.Run("should turn SSH into --ssh params", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			capturedArgs = cmd.Args
+			return "", "", 0, nil
+		}
+
+		buildArgs := &cliwrappers.BuildahBuildArgs{
+			Containerfile: containerfile,
+			ContextDir:    contextDir,
+			OutputRef:     outputRef,
+			SSH: []cliwrappers.BuildahSSH{
+				{ID: "default", Socket: "/tmp/ssh/agent.sock"},
+				{ID: "gitlab"},
+			},
+		}
+
+		err := buildahCli.Build(buildArgs)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(capturedArgs).To(ContainElement("--ssh=default=/tmp/ssh/agent.sock"))
+		g.Expect(capturedArgs).To(ContainElement("--ssh=gitlab"))
 	})
 
 	t.Run("should turn Volumes into --volume params", func(t *testing.T) {
@@ -244,6 +271,27 @@ func TestBuildahCli_Build(t *testing.T) {
 		// Context directory should be the last argument
 		g.Expect(capturedArgs[len(capturedArgs)-1]).To(Equal(contextDir))
 	})
+
+	t.Run("should put storage options before the build subcommand", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			capturedArgs = cmd.Args
+			return "", "", 0, nil
+		}
+
+		buildArgs := &cliwrappers.BuildahBuildArgs{
+			Containerfile: containerfile,
+			ContextDir:    contextDir,
+			OutputRef:     outputRef,
+			Storage:       cliwrappers.BuildahStorage{Root: "/tmp/check/root", RunRoot: "/tmp/check/runroot"},
+		}
+
+		err := buildahCli.Build(buildArgs)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(capturedArgs[:5]).To(Equal([]string{"--root", "/tmp/check/root", "--runroot", "/tmp/check/runroot", "build"}))
+	})
 }
 
 func findDigestFile(args []string) string {
@@ -379,6 +427,45 @@ func TestBuildahCli_Push(t *testing.T) {
 		g.Expect(capturedArgs[len(capturedArgs)-2]).To(Equal(image))
 		g.Expect(capturedArgs[len(capturedArgs)-1]).To(Equal(destination))
 	})
+
+	t.Run("should include format and compression options when provided", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs []string
+		executor.executeFunc = mockSuccessfulPush(&capturedArgs)
+
+		pushArgs := &cliwrappers.BuildahPushArgs{
+			Image:             image,
+			Format:            "oci",
+			CompressionFormat: "zstd:chunked",
+			CompressionLevel:  15,
+			ForceCompression:  true,
+		}
+
+		_, err := buildahCli.Push(pushArgs)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		expectArgAndValue(g, capturedArgs, "--format", "oci")
+		expectArgAndValue(g, capturedArgs, "--compression-format", "zstd:chunked")
+		expectArgAndValue(g, capturedArgs, "--compression-level", "15")
+		g.Expect(capturedArgs).To(ContainElement("--force-compression"))
+		g.Expect(capturedArgs[len(capturedArgs)-1]).To(Equal(image))
+	})
+
+	t.Run("should put storage options before the push subcommand", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs []string
+		executor.executeFunc = mockSuccessfulPush(&capturedArgs)
+
+		pushArgs := &cliwrappers.BuildahPushArgs{
+			Image:   image,
+			Storage: cliwrappers.BuildahStorage{Root: "/tmp/check/root"},
+		}
+
+		_, err := buildahCli.Push(pushArgs)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(capturedArgs[:3]).To(Equal([]string{"--root", "/tmp/check/root", "push"}))
+	})
 }
 
 func TestBuildahCli_Pull(t *testing.T) {
@@ -674,6 +761,7 @@ func TestBuildahBuildArgs_MakePathsAbsolute(t *testing.T) {
 			Secrets: []cliwrappers.BuildahSecret{
 				{Src: "secret1/file", Id: "secret1"},
 				{Src: "/absolute/secret2/file", Id: "secret2"},
+				{Src: "SECRET3_VAR", Id: "secret3", Type: cliwrappers.BuildahSecretTypeEnv},
 			},
 			Volumes: []cliwrappers.BuildahVolume{
 				{HostDir: "volume1/dir", ContainerDir: "/container/dir1", Options: ""},
@@ -682,6 +770,9 @@ func TestBuildahBuildArgs_MakePathsAbsolute(t *testing.T) {
 			BuildContexts: []cliwrappers.BuildahBuildContext{
 				{Name: "additional-context", Location: "/absolute/additional-context"},
 				{Name: "additional-context", Location: "relative/additional-context"},
+				{Name: "image-context", Location: "docker-image://quay.io/org/image:tag"},
+				{Name: "oci-context", Location: "oci-layout://relative/layout:tag"},
+				{Name: "oci-context-absolute", Location: "oci-layout:///absolute/layout"},
 			},
 		}
 
@@ -691,10 +782,14 @@ func TestBuildahBuildArgs_MakePathsAbsolute(t *testing.T) {
 		g.Expect(args.ContextDir).To(Equal("/base/dir/context"))
 		g.Expect(args.Secrets[0].Src).To(Equal("/base/dir/secret1/file"))
 		g.Expect(args.Secrets[1].Src).To(Equal("/absolute/secret2/file"))
+		g.Expect(args.Secrets[2].Src).To(Equal("SECRET3_VAR"))
 		g.Expect(args.Volumes[0].HostDir).To(Equal("/base/dir/volume1/dir"))
 		g.Expect(args.Volumes[1].HostDir).To(Equal("/absolute/volume2/dir"))
 		g.Expect(args.BuildContexts[0].Location).To(Equal("/absolute/additional-context"))
 		g.Expect(args.BuildContexts[1].Location).To(Equal("/base/dir/relative/additional-context"))
+		g.Expect(args.BuildContexts[2].Location).To(Equal("docker-image://quay.io/org/image:tag"))
+		g.Expect(args.BuildContexts[3].Location).To(Equal("oci-layout:///base/dir/relative/layout:tag"))
+		g.Expect(args.BuildContexts[4].Location).To(Equal("oci-layout:///absolute/layout"))
 	})
 
 	t.Run("should use current working directory when baseDir is relative", func(t *testing.T) {
@@ -1077,6 +1172,26 @@ func TestBuildahCli_ManifestPush(t *testing.T) {
 		g.Expect(capturedArgs).To(ContainElement("--tls-verify=false"))
 	})
 
+	t.Run("should push manifest with compression options", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs []string
+		executor.executeFunc = mockSuccessfulManifestPush(&capturedArgs)
+
+		args := &cliwrappers.BuildahManifestPushArgs{
+			ManifestName:      manifestName,
+			Destination:       destination,
+			CompressionFormat: "zstd",
+			ForceCompression:  true,
+		}
+
+		_, err := buildahCli.ManifestPush(args)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		expectArgAndValue(g, capturedArgs, "--compression-format", "zstd")
+		g.Expect(capturedArgs).ToNot(ContainElement("--compression-level"))
+		g.Expect(capturedArgs).To(ContainElement("--force-compression"))
+	})
+
 	t.Run("should error if manifest name is empty", func(t *testing.T) {
 		buildahCli, _ := setupBuildahCli()
 		args := &cliwrappers.BuildahManifestPushArgs{
@@ -1308,3 +1423,104 @@ func TestBuildahCli_ImagesJson(t *testing.T) {
 		g.Expect(entries).To(BeNil())
 	})
 }
+
+func TestBuildahCli_Containers(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should create, mount, unmount and remove a container", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs [][]string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			g.Expect(cmd.Name).To(Equal("buildah"))
+			capturedArgs = append(capturedArgs, cmd.Args)
+			switch cmd.Args[0] {
+			case "from":
+				return "image-working-container\n", "", 0, nil
+			case "mount":
+				return "/var/lib/containers/storage/overlay/abc/merged\n", "", 0, nil
+			}
+			return "", "", 0, nil
+		}
+
+		container, err := buildahCli.From("quay.io/org/image:tag")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(container).To(Equal("image-working-container"))
+
+		mountPoint, err := buildahCli.Mount(container)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(mountPoint).To(Equal("/var/lib/containers/storage/overlay/abc/merged"))
+
+		g.Expect(buildahCli.Umount(container)).To(Succeed())
+		g.Expect(buildahCli.Rm(container)).To(Succeed())
+
+		g.Expect(capturedArgs).To(Equal([][]string{
+			{"from", "quay.io/org/image:tag"},
+			{"mount", "image-working-container"},
+			{"umount", "image-working-container"},
+			{"rm", "image-working-container"},
+		}))
+	})
+
+	t.Run("should error on empty arguments", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			t.Fatal("executor must not be called")
+			return "", "", 0, nil
+		}
+
+		_, err := buildahCli.From("")
+		g.Expect(err).To(MatchError(ContainSubstring("image arg is empty")))
+		_, err = buildahCli.Mount("")
+		g.Expect(err).To(MatchError(ContainSubstring("container arg is empty")))
+		g.Expect(buildahCli.Umount("")).To(MatchError(ContainSubstring("container arg is empty")))
+		g.Expect(buildahCli.Rm("")).To(MatchError(ContainSubstring("container arg is empty")))
+	})
+
+	t.Run("should return the error of a failed command", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "", "image not known", 125, errors.New("exit status 125")
+		}
+
+		_, err := buildahCli.From("quay.io/org/image:tag")
+		g.Expect(err).To(MatchError("exit status 125"))
+	})
+}
+
+func TestBuildahCli_Tag(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should tag the image with all the names", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		var capturedArgs []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			g.Expect(cmd.Name).To(Equal("buildah"))
+			capturedArgs = cmd.Args
+			return "", "", 0, nil
+		}
+
+		err := buildahCli.Tag("quay.io/org/image:v1", "quay.io/org/image:latest", "localhost/image")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(capturedArgs).To(Equal([]string{"tag", "quay.io/org/image:v1", "quay.io/org/image:latest", "localhost/image"}))
+	})
+
+	t.Run("should error on empty arguments", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			t.Fatal("executor must not be called")
+			return "", "", 0, nil
+		}
+
+		g.Expect(buildahCli.Tag("", "name")).To(MatchError(ContainSubstring("image arg is empty")))
+		g.Expect(buildahCli.Tag("quay.io/org/image:v1")).To(MatchError(ContainSubstring("no names to tag the image with")))
+	})
+
+	t.Run("should return the error of a failed command", func(t *testing.T) {
+		buildahCli, executor := setupBuildahCli()
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "", "image not known", 125, errors.New("exit status 125")
+		}
+
+		g.Expect(buildahCli.Tag("quay.io/org/image:v1", "name")).To(MatchError("exit status 125"))
+	})
+}
@@ -42,6 +42,30 @@ type GitCliInterface interface {
 	FetchTags() ([]string, error)
 	// Log returns formatted git log output. Runs: git log [--pretty=<format>] [-N]
 	Log(format string, count int) (string, error)
+	// BundleVerify checks that a bundle is valid and applies to the repository. Runs: git bundle verify <file>
+	BundleVerify(bundlePath string) error
+	// BundleUnbundle stores the objects from a bundle in the repository. Runs: git bundle unbundle <file>
+	BundleUnbundle(bundlePath string) error
+	// BundleCreate creates a bundle from the given rev-list arguments. Runs: git bundle create <file> <revs...>
+	BundleCreate(bundlePath string, revs []string) error
+	// BundleListHeads lists the refs recorded in a bundle. Runs: git bundle list-heads <file>
+	BundleListHeads(bundlePath string) (map[string]string, error)
+	// DescribeTags describes a ref using the most recent tag reachable from it. Runs: git describe --tags <ref>
+	DescribeTags(ref string) (string, error)
+	// TagsPointingAt lists tags pointing at a ref. Runs: git tag --points-at <ref>
+	TagsPointingAt(ref string) ([]string, error)
+	// SubmoduleList lists the checked out submodules recursively. Runs: git submodule foreach --quiet --recursive ...
+	SubmoduleList() ([]GitSubmodule, error)
+}
+
+// GitSubmodule describes a checked out submodule.
+type GitSubmodule struct {
+	// Path relative to the top level repository
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
+	URL    string `json:"url"`
+	// URL after applying url rewrite rules, filled in by callers that configure them
+	EffectiveURL string `json:"effectiveUrl,omitempty"`
 }
 
 // GitFetchOptions contains the options for FetchWithRefspec.
@@ -341,3 +365,116 @@ func (g *GitCli) Log(format string, count int) (string, error) {
 
 	return g.run(gitArgs...)
 }
+
+// DescribeTags returns the most recent tag reachable from ref, suffixed with the number of
+// additional commits and the abbreviated commit SHA if the tag doesn't point at ref.
+// Runs: git describe --tags <ref>
+func (g *GitCli) DescribeTags(ref string) (string, error) {
+	if ref == "" {
+		return "", errors.New("ref must not be empty")
+	}
+	return g.run("describe", "--tags", ref)
+}
+
+// TagsPointingAt returns the tags pointing at the given ref.
+// Runs: git tag --points-at <ref>
+func (g *GitCli) TagsPointingAt(ref string) ([]string, error) {
+	if ref == "" {
+		return nil, errors.New("ref must not be empty")
+	}
+	stdout, err := g.run("tag", "--points-at", ref)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, tag := range strings.Split(stdout, "\n") {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// submoduleListScript prints tab separated path, commit and URL of each submodule.
+// The URL is read from the .gitmodules of the superproject which declares the submodule.
+const submoduleListScript = `printf '%s\t%s\t%s\n' "$displaypath" "$sha1" "$(git config -f "$toplevel/.gitmodules" "submodule.$name.url")"`
+
+// SubmoduleList returns the checked out submodules, including nested ones.
+// Runs: git submodule foreach --quiet --recursive <script>
+func (g *GitCli) SubmoduleList() ([]GitSubmodule, error) {
+	stdout, err := g.run("submodule", "foreach", "--quiet", "--recursive", submoduleListScript)
+	if err != nil {
+		return nil, err
+	}
+
+	submodules := []GitSubmodule{}
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		submodules = append(submodules, GitSubmodule{Path: fields[0], Commit: fields[1], URL: fields[2]})
+	}
+	return submodules, nil
+}
+
+// --- Bundle operations ---
+
+// BundleVerify checks that the bundle file is valid and that all its prerequisite
+// commits are present in the repository.
+// Runs: git bundle verify --quiet <file>
+func (g *GitCli) BundleVerify(bundlePath string) error {
+	if bundlePath == "" {
+		return errors.New("bundle path must not be empty")
+	}
+	_, err := g.run("bundle", "verify", "--quiet", bundlePath)
+	return err
+}
+
+// BundleUnbundle stores the objects from the bundle in the repository without updating any refs.
+// Runs: git bundle unbundle <file>
+func (g *GitCli) BundleUnbundle(bundlePath string) error {
+	if bundlePath == "" {
+		return errors.New("bundle path must not be empty")
+	}
+	_, err := g.run("bundle", "unbundle", bundlePath)
+	return err
+}
+
+// BundleCreate creates a bundle file containing the commits selected by the rev-list arguments,
+// e.g. --all, a branch name or a range like v1.0..main.
+// Runs: git bundle create <file> <revs...>
+func (g *GitCli) BundleCreate(bundlePath string, revs []string) error {
+	if bundlePath == "" {
+		return errors.New("bundle path must not be empty")
+	}
+	if len(revs) == 0 {
+		return errors.New("at least one revision must be specified")
+	}
+	gitArgs := append([]string{"bundle", "create", bundlePath}, revs...)
+	_, err := g.run(gitArgs...)
+	return err
+}
+
+// BundleListHeads returns the refs recorded in the bundle, mapping ref name to commit SHA.
+// Runs: git bundle list-heads <file>
+func (g *GitCli) BundleListHeads(bundlePath string) (map[string]string, error) {
+	if bundlePath == "" {
+		return nil, errors.New("bundle path must not be empty")
+	}
+	stdout, err := g.run("bundle", "list-heads", bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	heads := map[string]string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		sha, ref, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		heads[ref] = sha
+	}
+	return heads, nil
+}
@@ -717,3 +717,156 @@ func Test_SetEnv(t *testing.T) {
 		g.Expect(capturedEnv).To(BeNil())
 	})
 }
+
+func Test_BundleVerify(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should run git bundle verify", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args).To(Equal([]string{"bundle", "verify", "--quiet", "/bundles/repo.bundle"}))
+			return "", "", 0, nil
+		})
+
+		err := cli.BundleVerify("/bundles/repo.bundle")
+
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should reject empty path", func(t *testing.T) {
+		cli := newTestGitCli(nil)
+
+		err := cli.BundleVerify("")
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("bundle path must not be empty"))
+	})
+
+	t.Run("should return error on failure", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			return "", "error: Repository lacks these prerequisite commits", 1, errors.New("verify failed")
+		})
+
+		err := cli.BundleVerify("/bundles/repo.bundle")
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("git bundle failed"))
+	})
+}
+
+func Test_BundleUnbundle(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should run git bundle unbundle", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args).To(Equal([]string{"bundle", "unbundle", "/bundles/repo.bundle"}))
+			return "", "", 0, nil
+		})
+
+		err := cli.BundleUnbundle("/bundles/repo.bundle")
+
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
+func Test_BundleCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should run git bundle create with revisions", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args).To(Equal([]string{"bundle", "create", "/bundles/repo.bundle", "v1.0..main", "--tags"}))
+			return "", "", 0, nil
+		})
+
+		err := cli.BundleCreate("/bundles/repo.bundle", []string{"v1.0..main", "--tags"})
+
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should reject empty revisions", func(t *testing.T) {
+		cli := newTestGitCli(nil)
+
+		err := cli.BundleCreate("/bundles/repo.bundle", nil)
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("at least one revision must be specified"))
+	})
+}
+
+func Test_BundleListHeads(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should parse bundle refs", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args).To(Equal([]string{"bundle", "list-heads", "/bundles/repo.bundle"}))
+			return "abc123 refs/heads/main\ndef456 refs/tags/v1.0\n", "", 0, nil
+		})
+
+		heads, err := cli.BundleListHeads("/bundles/repo.bundle")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(heads).To(Equal(map[string]string{
+			"refs/heads/main": "abc123",
+			"refs/tags/v1.0":  "def456",
+		}))
+	})
+}
+
+func Test_DescribeTags(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should run git describe --tags", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args).To(Equal([]string{"describe", "--tags", "HEAD"}))
+			return "v1.0.0-2-gabc123d\n", "", 0, nil
+		})
+
+		describe, err := cli.DescribeTags("HEAD")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(describe).To(Equal("v1.0.0-2-gabc123d"))
+	})
+}
+
+func Test_TagsPointingAt(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should list tags pointing at ref", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args).To(Equal([]string{"tag", "--points-at", "HEAD"}))
+			return "v1.0.0\nlatest\n", "", 0, nil
+		})
+
+		tags, err := cli.TagsPointingAt("HEAD")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(tags).To(Equal([]string{"v1.0.0", "latest"}))
+	})
+
+	t.Run("should return empty list when no tags", func(t *testing.T) {
+		cli := newTestGitCli(nil)
+
+		tags, err := cli.TagsPointingAt("HEAD")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(tags).To(BeEmpty())
+	})
+}
+
+func Test_SubmoduleList(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should parse submodules", func(t *testing.T) {
+		cli := newTestGitCli(func(workdir, command string, args ...string) (string, string, int, error) {
+			g.Expect(args[:4]).To(Equal([]string{"submodule", "foreach", "--quiet", "--recursive"}))
+			return "lib\tabc123\thttps://git.test/lib.git\nlib/nested dir\tdef456\t../nested.git\n", "", 0, nil
+		})
+
+		submodules, err := cli.SubmoduleList()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(submodules).To(Equal([]cliwrappers.GitSubmodule{
+			{Path: "lib", Commit: "abc123", URL: "https://git.test/lib.git"},
+			{Path: "lib/nested dir", Commit: "def456", URL: "../nested.git"},
+		}))
+	})
+}
@@ -0,0 +1,66 @@
+package cliwrappers
+
+import (
+	"errors"
+	"os"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var packageInventoryLog = l.Logger.WithField("logger", "PackageInventoryCli")
+
+// Scans the installed packages of an image with 'konflux-build-cli internal package-inventory'.
+// The scan mounts the image with 'buildah mount', so it needs to run in a separate process,
+// wrapped in a user namespace in which the mount is visible.
+type PackageInventoryCliInterface interface {
+	Scan(args *PackageInventoryScanArgs) error
+}
+
+var _ PackageInventoryCliInterface = &PackageInventoryCli{}
+
+type PackageInventoryCli struct {
+	Executor CliExecutorInterface
+	// Path of the konflux-build-cli executable
+	SelfPath string
+}
+
+func NewPackageInventoryCli(executor CliExecutorInterface) (*PackageInventoryCli, error) {
+	selfPath, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return &PackageInventoryCli{Executor: executor, SelfPath: selfPath}, nil
+}
+
+type PackageInventoryScanArgs struct {
+	// Image in local containers storage (or any reference 'buildah from' accepts), required
+	Image string
+	// File to write the inventory JSON to, required
+	OutputFile string
+	// Typically creates the user namespace in which buildah can mount the image
+	Wrapper *WrapperCmd
+}
+
+func (p *PackageInventoryCli) Scan(args *PackageInventoryScanArgs) error {
+	if args.Image == "" {
+		return errors.New("image is empty")
+	}
+	if args.OutputFile == "" {
+		return errors.New("output file is empty")
+	}
+
+	executable := p.SelfPath
+	cliArgs := []string{"internal", "package-inventory", "--output", args.OutputFile, args.Image}
+	if args.Wrapper != nil {
+		executable, cliArgs = args.Wrapper.Wrap(executable, cliArgs)
+	}
+
+	packageInventoryLog.Debugf("Running command:\n%s", shellJoin(executable, cliArgs...))
+
+	cmd := Cmd{Name: executable, Args: cliArgs, LogOutput: true, NameInLogs: "package-inventory"}
+	if _, _, _, err := p.Executor.Execute(cmd); err != nil {
+		packageInventoryLog.Errorf("package inventory scan failed: %s", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package cliwrappers_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func TestPackageInventoryCli_Scan(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should run the internal command in the wrapper", func(t *testing.T) {
+		executor := &mockExecutor{}
+		cli := &cliwrappers.PackageInventoryCli{Executor: executor, SelfPath: "/usr/bin/konflux-build-cli"}
+		var captured cliwrappers.Cmd
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			captured = cmd
+			return "", "", 0, nil
+		}
+
+		wrapper := cliwrappers.NewWrapperCmd("buildah", "unshare")
+		err := cli.Scan(&cliwrappers.PackageInventoryScanArgs{
+			Image:      "quay.io/org/image:tag",
+			OutputFile: "/tmp/inventory.json",
+			Wrapper:    &wrapper,
+		})
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(captured.Name).To(Equal("buildah"))
+		g.Expect(captured.Args).To(Equal([]string{
+			"unshare", "--", "/usr/bin/konflux-build-cli", "internal", "package-inventory",
+			"--output", "/tmp/inventory.json", "quay.io/org/image:tag",
+		}))
+	})
+
+	t.Run("should error on missing arguments", func(t *testing.T) {
+		cli := &cliwrappers.PackageInventoryCli{Executor: &mockExecutor{}, SelfPath: "konflux-build-cli"}
+		g.Expect(cli.Scan(&cliwrappers.PackageInventoryScanArgs{OutputFile: "out.json"})).To(MatchError("image is empty"))
+		g.Expect(cli.Scan(&cliwrappers.PackageInventoryScanArgs{Image: "image"})).To(MatchError("output file is empty"))
+	})
+
+	t.Run("should return the error of the scan", func(t *testing.T) {
+		executor := &mockExecutor{executeFunc: func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "", "", 1, errors.New("exit status 1")
+		}}
+		cli := &cliwrappers.PackageInventoryCli{Executor: executor, SelfPath: "konflux-build-cli"}
+		err := cli.Scan(&cliwrappers.PackageInventoryScanArgs{Image: "image", OutputFile: "out.json"})
+		g.Expect(err).To(MatchError("exit status 1"))
+	})
+}
@@ -0,0 +1,82 @@
+package cliwrappers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var rpmLog = l.Logger.WithField("logger", "RpmCli")
+
+type RpmCliInterface interface {
+	QueryAll(dbPath string) ([]RpmPackage, error)
+}
+
+var _ RpmCliInterface = &RpmCli{}
+
+type RpmCli struct {
+	Executor CliExecutorInterface
+}
+
+func NewRpmCli(executor CliExecutorInterface) (*RpmCli, error) {
+	available, err := CheckCliToolAvailable("rpm")
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		return nil, errors.New("rpm CLI is not available")
+	}
+	return &RpmCli{Executor: executor}, nil
+}
+
+type RpmPackage struct {
+	Name string
+	// "0" if the package has no epoch
+	Epoch   string
+	Version string
+	Release string
+	Arch    string
+}
+
+// The epoch:version-release of the package.
+func (p RpmPackage) EVR() string {
+	return p.Epoch + ":" + p.Version + "-" + p.Release
+}
+
+const rpmQueryFormat = "%{NAME}\\t%{EPOCHNUM}\\t%{VERSION}\\t%{RELEASE}\\t%{ARCH}\\n"
+
+// List the packages in the rpmdb in the dbPath directory (e.g. an rpmdb extracted from an image).
+func (r *RpmCli) QueryAll(dbPath string) ([]RpmPackage, error) {
+	if dbPath == "" {
+		return nil, errors.New("rpmdb path is empty")
+	}
+
+	args := []string{"--dbpath", dbPath, "--query", "--all", "--queryformat", rpmQueryFormat}
+	rpmLog.Debugf("Running command:\n%s", shellJoin("rpm", args...))
+
+	stdout, stderr, _, err := r.Executor.Execute(Command("rpm", args...))
+	if err != nil {
+		rpmLog.Errorf("rpm query failed: %s", err.Error())
+		if stderr != "" {
+			rpmLog.Errorf("stderr:\n%s", stderr)
+		}
+		return nil, err
+	}
+
+	var packages []RpmPackage
+	for _, line := range strings.Split(stdout, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected rpm query output: %q", line)
+		}
+		packages = append(packages, RpmPackage{
+			Name: fields[0], Epoch: fields[1], Version: fields[2], Release: fields[3], Arch: fields[4],
+		})
+	}
+	return packages, nil
+}
@@ -0,0 +1,59 @@
+package cliwrappers_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func TestRpmCli_QueryAll(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should list packages in the rpmdb", func(t *testing.T) {
+		executor := &mockExecutor{}
+		rpmCli := &cliwrappers.RpmCli{Executor: executor}
+
+		var capturedArgs []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			g.Expect(cmd.Name).To(Equal("rpm"))
+			capturedArgs = cmd.Args
+			return "bash\t0\t5.2.26\t3.fc40\tx86_64\nopenssl-libs\t1\t3.2.2\t1.fc40\tx86_64\n", "", 0, nil
+		}
+
+		packages, err := rpmCli.QueryAll("/tmp/rootfs/var/lib/rpm")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		expectArgAndValue(g, capturedArgs, "--dbpath", "/tmp/rootfs/var/lib/rpm")
+		g.Expect(capturedArgs).To(ContainElement("--all"))
+		g.Expect(packages).To(Equal([]cliwrappers.RpmPackage{
+			{Name: "bash", Epoch: "0", Version: "5.2.26", Release: "3.fc40", Arch: "x86_64"},
+			{Name: "openssl-libs", Epoch: "1", Version: "3.2.2", Release: "1.fc40", Arch: "x86_64"},
+		}))
+		g.Expect(packages[1].EVR()).To(Equal("1:3.2.2-1.fc40"))
+	})
+
+	t.Run("should error on unexpected output", func(t *testing.T) {
+		executor := &mockExecutor{}
+		rpmCli := &cliwrappers.RpmCli{Executor: executor}
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "bash 5.2.26\n", "", 0, nil
+		}
+
+		_, err := rpmCli.QueryAll("/rpmdb")
+		g.Expect(err).To(MatchError(ContainSubstring("unexpected rpm query output")))
+	})
+
+	t.Run("should error if rpm fails", func(t *testing.T) {
+		executor := &mockExecutor{}
+		rpmCli := &cliwrappers.RpmCli{Executor: executor}
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "", "error: cannot open Packages database", 1, errors.New("exit status 1")
+		}
+
+		_, err := rpmCli.QueryAll("/rpmdb")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -3,8 +3,11 @@ package cliwrappers
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
 	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
 )
 
@@ -76,8 +79,7 @@ func (s *SkopeoCli) Copy(args *SkopeoCopyArgs) error {
 		scopeoArgs = append(scopeoArgs, args.ExtraArgs...)
 	}
 
-	dockerPrefix := "docker://"
-	scopeoArgs = append(scopeoArgs, dockerPrefix+args.SourceImage, dockerPrefix+args.DestinationImage)
+	scopeoArgs = append(scopeoArgs, skopeoImageRef(args.SourceImage), skopeoImageRef(args.DestinationImage))
 
 	skopeoLog.Debugf("Running command:\n%s", shellJoin("skopeo", scopeoArgs...))
 
@@ -99,6 +101,19 @@ func (s *SkopeoCli) Copy(args *SkopeoCopyArgs) error {
 	return nil
 }
 
+// Transports that skopeo can use instead of a registry, and the explicit registry transport.
+var skopeoTransports = []string{"oci:", "oci-archive:", "docker-archive:", "containers-storage:", "docker://"}
+
+// Add the docker:// transport to image refs, unless they already specify a transport.
+func skopeoImageRef(imageRef string) string {
+	for _, transport := range skopeoTransports {
+		if strings.HasPrefix(imageRef, transport) {
+			return imageRef
+		}
+	}
+	return "docker://" + imageRef
+}
+
 type SkopeoInspectArgs struct {
 	ImageRef   string
 	RetryTimes int
@@ -106,6 +121,9 @@ type SkopeoInspectArgs struct {
 	NoTags     bool
 	Format     string
 	ExtraArgs  []string
+	// Proxy settings for registry access, see common.ProxyEnvVars
+	HttpProxy string
+	NoProxy   string
 }
 
 func (s *SkopeoCli) Inspect(args *SkopeoInspectArgs) (string, error) {
@@ -132,15 +150,22 @@ func (s *SkopeoCli) Inspect(args *SkopeoInspectArgs) (string, error) {
 		scopeoArgs = append(scopeoArgs, args.ExtraArgs...)
 	}
 
-	dockerPrefix := "docker://"
-	scopeoArgs = append(scopeoArgs, dockerPrefix+args.ImageRef)
+	scopeoArgs = append(scopeoArgs, skopeoImageRef(args.ImageRef))
 
 	skopeoLog.Debugf("Running command:\n%s", shellJoin("skopeo", scopeoArgs...))
 
+	cmd := Command("skopeo", scopeoArgs...)
+	if env := common.ProxyEnvVars(args.HttpProxy, args.NoProxy); len(env) > 0 {
+		// Note: this overrides proxy vars already set in the environment, if any (last value wins)
+		cmd.Env = append(os.Environ(), env...)
+	}
+
 	retryer := NewRetryer(func() (string, string, int, error) {
-		return s.Executor.Execute(Command("skopeo", scopeoArgs...))
+		return s.Executor.Execute(cmd)
 	}).WithImageRegistryPreset().
 		StopIfOutputContains("unauthorized").
+		// The image doesn't exist, retrying won't help
+		StopIfOutputContains("manifest unknown").
 		// Stop on unsupported config media type
 		StopIfOutputContains(UnsupportedOCIConfigMediaType)
 
@@ -114,6 +114,44 @@ func TestSkopeoCli_Copy(t *testing.T) {
 		g.Expect(capturedArgs).To(ContainElement("--someflag"))
 	})
 
+	t.Run("should keep local transports", func(t *testing.T) {
+		skopeoCli, executor := setupSkopeoCli()
+		var capturedArgs []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			capturedArgs = cmd.Args
+			return "", "", 0, nil
+		}
+
+		copyArgs := &cliwrappers.SkopeoCopyArgs{
+			SourceImage:      "oci:/path/to/layout:@0",
+			DestinationImage: "oci-archive:/path/to/archive.tar",
+		}
+
+		err := skopeoCli.Copy(copyArgs)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(capturedArgs).To(Equal([]string{"copy", "oci:/path/to/layout:@0", "oci-archive:/path/to/archive.tar"}))
+	})
+
+	t.Run("should keep containers-storage and explicit docker transports", func(t *testing.T) {
+		skopeoCli, executor := setupSkopeoCli()
+		var capturedArgs []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			capturedArgs = cmd.Args
+			return "", "", 0, nil
+		}
+
+		copyArgs := &cliwrappers.SkopeoCopyArgs{
+			SourceImage:      "containers-storage:localhost/image:tag",
+			DestinationImage: "docker://quay.io/org/image:tag",
+		}
+
+		err := skopeoCli.Copy(copyArgs)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(capturedArgs).To(Equal([]string{"copy", "containers-storage:localhost/image:tag", "docker://quay.io/org/image:tag"}))
+	})
+
 	t.Run("should error if skopeo execution fails", func(t *testing.T) {
 		skopeoCli, executor := setupSkopeoCli()
 		isExecuteCalled := false
@@ -249,6 +287,30 @@ func TestSkopeoCli_Inspect(t *testing.T) {
 		g.Expect(stdout).To(Equal(output))
 	})
 
+	t.Run("should set proxy env vars", func(t *testing.T) {
+		skopeoCli, executor := setupSkopeoCli()
+		var capturedEnv []string
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			capturedEnv = cmd.Env
+			return output, "", 0, nil
+		}
+
+		inspectArgs := &cliwrappers.SkopeoInspectArgs{
+			ImageRef:  imageRef,
+			HttpProxy: "proxy.example.com:3128",
+			NoProxy:   "localhost",
+		}
+
+		_, err := skopeoCli.Inspect(inspectArgs)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(capturedEnv).To(ContainElements(
+			"HTTP_PROXY=proxy.example.com:3128",
+			"https_proxy=proxy.example.com:3128",
+			"NO_PROXY=localhost",
+		))
+	})
+
 	t.Run("should error if skopeo execution fails", func(t *testing.T) {
 		skopeoCli, executor := setupSkopeoCli()
 		isExecuteCalled := false
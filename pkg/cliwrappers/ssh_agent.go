@@ -0,0 +1,92 @@
+package cliwrappers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var sshAgentLog = l.Logger.WithField("logger", "SSHAgentCli")
+
+type SSHAgentCliInterface interface {
+	Start(socketPath string) (pid int, err error)
+	AddKey(socketPath, keyPath string) error
+	Kill(socketPath string, pid int)
+}
+
+var _ SSHAgentCliInterface = &SSHAgentCli{}
+
+type SSHAgentCli struct {
+	Executor CliExecutorInterface
+}
+
+func NewSSHAgentCli(executor CliExecutorInterface) (*SSHAgentCli, error) {
+	for _, tool := range []string{"ssh-agent", "ssh-add"} {
+		available, err := CheckCliToolAvailable(tool)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			return nil, fmt.Errorf("%s CLI is not available", tool)
+		}
+	}
+	return &SSHAgentCli{Executor: executor}, nil
+}
+
+var sshAgentPidPattern = regexp.MustCompile(`SSH_AGENT_PID=([0-9]+);`)
+
+// Start an ssh-agent listening on the socket. The agent daemonizes, returns its pid.
+func (s *SSHAgentCli) Start(socketPath string) (int, error) {
+	args := []string{"-a", socketPath, "-s"}
+	sshAgentLog.Debugf("Running command:\n%s", shellJoin("ssh-agent", args...))
+
+	stdout, stderr, _, err := s.Executor.Execute(Command("ssh-agent", args...))
+	if err != nil {
+		sshAgentLog.Errorf("ssh-agent failed: %s", err.Error())
+		if stderr != "" {
+			sshAgentLog.Errorf("stderr:\n%s", stderr)
+		}
+		return 0, err
+	}
+
+	match := sshAgentPidPattern.FindStringSubmatch(stdout)
+	if match == nil {
+		return 0, fmt.Errorf("unexpected ssh-agent output: %q", stdout)
+	}
+	return strconv.Atoi(match[1])
+}
+
+// Load the private key into the agent listening on the socket.
+func (s *SSHAgentCli) AddKey(socketPath, keyPath string) error {
+	sshAgentLog.Debugf("Running command:\n%s", shellJoin("ssh-add", keyPath))
+
+	cmd := Command("ssh-add", keyPath)
+	cmd.Env = append(os.Environ(), "SSH_AUTH_SOCK="+socketPath)
+	_, stderr, _, err := s.Executor.Execute(cmd)
+	if err != nil {
+		sshAgentLog.Errorf("ssh-add failed: %s", err.Error())
+		if stderr != "" {
+			sshAgentLog.Errorf("stderr:\n%s", stderr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Kill the agent (best-effort).
+func (s *SSHAgentCli) Kill(socketPath string, pid int) {
+	sshAgentLog.Debugf("Running command: ssh-agent -k (SSH_AGENT_PID=%d)", pid)
+
+	cmd := Command("ssh-agent", "-k")
+	cmd.Env = append(os.Environ(), "SSH_AUTH_SOCK="+socketPath, "SSH_AGENT_PID="+strconv.Itoa(pid))
+	_, stderr, _, err := s.Executor.Execute(cmd)
+	if err != nil {
+		sshAgentLog.Warnf("Failed to kill ssh-agent (pid %d): %s", pid, err.Error())
+		if stderr != "" {
+			sshAgentLog.Warnf("stderr:\n%s", stderr)
+		}
+	}
+}
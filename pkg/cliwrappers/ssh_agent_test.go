@@ -0,0 +1,70 @@
+package cliwrappers_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func TestSSHAgentCli(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should start the agent and parse its pid", func(t *testing.T) {
+		executor := &mockExecutor{}
+		sshAgent := &cliwrappers.SSHAgentCli{Executor: executor}
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			g.Expect(cmd.Name).To(Equal("ssh-agent"))
+			g.Expect(cmd.Args).To(Equal([]string{"-a", "/tmp/ssh/agent.sock", "-s"}))
+			return "SSH_AUTH_SOCK=/tmp/ssh/agent.sock; export SSH_AUTH_SOCK;\nSSH_AGENT_PID=4242; export SSH_AGENT_PID;\necho Agent pid 4242;\n", "", 0, nil
+		}
+
+		pid, err := sshAgent.Start("/tmp/ssh/agent.sock")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(pid).To(Equal(4242))
+	})
+
+	t.Run("should fail on unexpected output", func(t *testing.T) {
+		executor := &mockExecutor{}
+		sshAgent := &cliwrappers.SSHAgentCli{Executor: executor}
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "nothing useful", "", 0, nil
+		}
+
+		_, err := sshAgent.Start("/tmp/ssh/agent.sock")
+		g.Expect(err).To(MatchError(ContainSubstring("unexpected ssh-agent output")))
+	})
+
+	t.Run("should add keys and kill the agent through the socket", func(t *testing.T) {
+		executor := &mockExecutor{}
+		sshAgent := &cliwrappers.SSHAgentCli{Executor: executor}
+		var commands []cliwrappers.Cmd
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			commands = append(commands, cmd)
+			return "", "", 0, nil
+		}
+
+		g.Expect(sshAgent.AddKey("/tmp/ssh/agent.sock", "/tmp/ssh/id_rsa")).To(Succeed())
+		sshAgent.Kill("/tmp/ssh/agent.sock", 4242)
+
+		g.Expect(commands).To(HaveLen(2))
+		g.Expect(commands[0].Name).To(Equal("ssh-add"))
+		g.Expect(commands[0].Args).To(Equal([]string{"/tmp/ssh/id_rsa"}))
+		g.Expect(commands[0].Env).To(ContainElement("SSH_AUTH_SOCK=/tmp/ssh/agent.sock"))
+		g.Expect(commands[1].Name).To(Equal("ssh-agent"))
+		g.Expect(commands[1].Args).To(Equal([]string{"-k"}))
+		g.Expect(commands[1].Env).To(ContainElements("SSH_AUTH_SOCK=/tmp/ssh/agent.sock", "SSH_AGENT_PID=4242"))
+	})
+
+	t.Run("should return ssh-add errors", func(t *testing.T) {
+		executor := &mockExecutor{}
+		sshAgent := &cliwrappers.SSHAgentCli{Executor: executor}
+		executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+			return "", "Error loading key", 1, errors.New("exit status 1")
+		}
+
+		g.Expect(sshAgent.AddKey("/tmp/ssh/agent.sock", "/tmp/ssh/id_rsa")).To(MatchError("exit status 1"))
+	})
+}
@@ -60,7 +60,10 @@ func (sm *SubscriptionManagerCli) Register(params *SubscriptionManagerRegisterPa
 		return sm.Executor.Execute(Cmd{Name: "subscription-manager", Args: args})
 	}
 
-	retryer := NewRetryer(command).StopIfOutputContains("unauthorized")
+	// Retrying doesn't help with invalid credentials, fail fast to let the caller fall back to other ones
+	retryer := NewRetryer(command).
+		StopIfOutputContains("unauthorized").
+		StopIfOutputMatches(`(?i)activation keys? specified|organization .* does not exist`)
 	_, stderr, _, err := retryer.Run()
 	if err != nil {
 		submanLog.Errorf("subscription-manager register failed: %s", err.Error())
@@ -142,3 +142,20 @@ func TestSubscriptionManagerCli_Unregister(t *testing.T) {
 		g.Expect(logOutput).To(ContainSubstring("subscription-manager unregister command failed"))
 	})
 }
+
+func TestSubscriptionManagerCli_Register_InvalidCredentials(t *testing.T) {
+	g := NewWithT(t)
+	setGetUIDForTest(t, 0)
+
+	smCli, executor := setupSubscriptionManagerCli()
+	attempts := 0
+	executor.executeFunc = func(cmd cliwrappers.Cmd) (string, string, int, error) {
+		attempts++
+		return "", "None of the activation keys specified exist for this org.", 70, errors.New("exit status 70")
+	}
+
+	err := smCli.Register(&cliwrappers.SubscriptionManagerRegisterParams{Org: "my-org", ActivationKey: "my-key"})
+
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(attempts).To(Equal(1), "should not retry with invalid credentials")
+}
@@ -20,7 +20,7 @@ var ApplyTagsParamsConfig = map[string]common.Parameter{
 		ShortName:  "i",
 		EnvVarName: "KBC_APPLY_TAGS_IMAGE_URL",
 		TypeKind:   reflect.String,
-		Usage:      "Image name to add tags to. Tag and digest are ignored. Required.",
+		Usage:      "Image name to add tags to, or an OCI layout (oci:<dir>). Tag and digest are ignored. Required.",
 		Required:   true,
 	},
 	"digest": {
@@ -72,6 +72,8 @@ type ApplyTags struct {
 
 	imageName     string
 	imageByDigest string
+	// Set if the image is in an OCI layout rather than in a registry
+	ociLayoutDir string
 }
 
 func NewApplyTags(cmd *cobra.Command) (*ApplyTags, error) {
@@ -107,12 +109,29 @@ func (c *ApplyTags) initCliWrappers() error {
 func (c *ApplyTags) Run() error {
 	common.LogParameters(ApplyTagsParamsConfig, c.Params)
 
-	c.imageName = common.GetImageName(c.Params.ImageUrl)
+	if common.IsOCILayoutRef(c.Params.ImageUrl) {
+		layoutRef, err := common.ParseOCILayoutRef(c.Params.ImageUrl)
+		if err != nil {
+			return fmt.Errorf("image-url is invalid: %w", err)
+		}
+		c.ociLayoutDir = layoutRef.Dir
+	} else {
+		c.imageName = common.GetImageName(c.Params.ImageUrl)
+	}
 	if err := c.validateParams(); err != nil {
 		return err
 	}
 
-	c.imageByDigest = c.imageName + "@" + c.Params.Digest
+	if c.ociLayoutDir != "" {
+		// The oci: transport can't select images by digest, refer to the image by its position in the index
+		imageByDigest, err := common.OCILayoutRef{Dir: c.ociLayoutDir, Digest: c.Params.Digest}.SourceRef()
+		if err != nil {
+			return err
+		}
+		c.imageByDigest = imageByDigest
+	} else {
+		c.imageByDigest = c.imageName + "@" + c.Params.Digest
+	}
 
 	tagsFromLabel, err := c.retrieveTagsFromImageLabel(c.Params.LabelWithTags)
 	if err != nil {
@@ -193,7 +212,13 @@ func (c *ApplyTags) retrieveTagsFromImageLabel(labelName string) ([]string, erro
 			l.Logger.Warnf("image index %s does not contain an image manifest", c.imageByDigest)
 			return nil, nil
 		}
-		targetImageReference = c.imageName + "@" + digest
+		if c.ociLayoutDir != "" {
+			// Manifests within an index in an OCI layout can't be referenced directly,
+			// let skopeo choose the manifest for the current platform.
+			targetImageReference = c.imageByDigest
+		} else {
+			targetImageReference = c.imageName + "@" + digest
+		}
 	} else if strings.Contains(imageIndex.MediaType, ".manifest.") {
 		// Provided by user reference is image manifest, e.g. "application/vnd.docker.distribution.manifest.v2+json"
 		targetImageReference = c.imageByDigest
@@ -258,7 +283,11 @@ func (c *ApplyTags) applyTags(tags []string) error {
 	for _, tag := range tags {
 		l.Logger.Debugf("Creating tag: %s", tag)
 
-		args.DestinationImage = c.imageName + ":" + tag
+		if c.ociLayoutDir != "" {
+			args.DestinationImage = common.OCILayoutRef{Dir: c.ociLayoutDir, Image: tag}.String()
+		} else {
+			args.DestinationImage = c.imageName + ":" + tag
+		}
 		if err := c.CliWrappers.SkopeoCli.Copy(args); err != nil {
 			l.Logger.Errorf("failed to push '%s' tag: %s", tag, err.Error())
 			return err
@@ -272,7 +301,7 @@ func (c *ApplyTags) applyTags(tags []string) error {
 
 func (c *ApplyTags) validateParams() error {
 	// Validate imageName instead of Params.ImageUrl to avoid calling normalizeImageName second time.
-	if !common.IsImageNameValid(c.imageName) {
+	if c.ociLayoutDir == "" && !common.IsImageNameValid(c.imageName) {
 		return fmt.Errorf("image '%s' is invalid", c.imageName)
 	}
 
@@ -3,6 +3,8 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
@@ -671,6 +673,33 @@ func Test_Run(t *testing.T) {
 		g.Expect(isCreateResultJsonCalled).To(BeTrue())
 	})
 
+	t.Run("should apply tags to an image in an OCI layout", func(t *testing.T) {
+		beforeEach()
+		layoutDir := t.TempDir()
+		indexJson := `{"schemaVersion": 2, "manifests": [
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:0000000000000000000000000000000000000000000000000000000000000000", "size": 1},
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + c.Params.Digest + `", "size": 1}
+		]}`
+		g.Expect(os.WriteFile(filepath.Join(layoutDir, "index.json"), []byte(indexJson), 0644)).To(Succeed())
+		c.Params.ImageUrl = "oci:" + layoutDir + ":latest"
+		c.Params.NewTags = []string{"tag1"}
+
+		var copyArgs []cliwrappers.SkopeoCopyArgs
+		_mockSkopeoCli.CopyFunc = func(args *cliwrappers.SkopeoCopyArgs) error {
+			copyArgs = append(copyArgs, *args)
+			return nil
+		}
+		_mockResultsWriter.CreateResultJsonFunc = func(result any) (string, error) {
+			return "", nil
+		}
+
+		err := c.Run()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(copyArgs).To(HaveLen(1))
+		g.Expect(copyArgs[0].SourceImage).To(Equal("oci:" + layoutDir + ":@1"))
+		g.Expect(copyArgs[0].DestinationImage).To(Equal("oci:" + layoutDir + ":tag1"))
+	})
+
 	t.Run("should successfully run apply-tags with tags from label only", func(t *testing.T) {
 		beforeEach()
 		const labelWithTagsValue = "l1tag l2tag"
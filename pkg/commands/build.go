@@ -20,6 +20,7 @@ import (
 	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
 	"github.com/konflux-ci/konflux-build-cli/pkg/common"
 	dfeditor "github.com/konflux-ci/konflux-build-cli/pkg/common/containerfile_editor"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/rhsm"
 	"github.com/opencontainers/go-digest"
 	"github.com/package-url/packageurl-go"
 	"github.com/spf13/cobra"
@@ -61,6 +62,20 @@ var BuildParamsConfig = map[string]common.Parameter{
 		DefaultValue: "",
 		Usage:        "Path to a directory containing the source code.\nIf specified, the --containerfile and --context are treated as (and verified to be) relative to the source.",
 	},
+	"source-policy-file": {
+		Name:       "source-policy-file",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_POLICY_FILE",
+		TypeKind:   reflect.String,
+		Usage:      "Path to a JSON source tree policy to check the build context against before building.\nSee the source-policy-file parameter of git-clone for the format. Findings with the error severity fail the build.",
+	},
+	"source-report-file": {
+		Name:       "source-report-file",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_REPORT_FILE",
+		TypeKind:   reflect.String,
+		Usage:      "Path to a file to write the findings of the build context checks to, as JSON.\nThe build context is checked with the default rules if only this is set.",
+	},
 	"output-ref": {
 		Name:       "output-ref",
 		ShortName:  "t",
@@ -77,6 +92,93 @@ var BuildParamsConfig = map[string]common.Parameter{
 		DefaultValue: "false",
 		Usage:        "Push the built image to the registry.",
 	},
+	"push-destinations": {
+		Name:       "push-destinations",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_PUSH_DESTINATIONS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Additional image references (e.g. mirrors) to push the built image to, in parallel with the output-ref.\nThe pushed digests must match. Requires --push.",
+	},
+	"reproducibility-check": {
+		Name:         "reproducibility-check",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_REPRODUCIBILITY_CHECK",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Build the image a second time in separate storage and fail if the images differ, reporting the differing\nconfig fields and files. Use with source-date-epoch and rewrite-timestamp. Pin base images by digest,\nthey are pulled again for the second build.\nThe second build generates its inputs (the processed Containerfile, yum repos, RHSM files, prefetch dir copy)\nagain in a separate temporary workdir.",
+	},
+	"max-image-size": {
+		Name:       "max-image-size",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_MAX_IMAGE_SIZE",
+		TypeKind:   reflect.String,
+		Usage:      "Maximum uncompressed size of the built image, e.g. 2GB or 1.5GiB.\nChecked after the build, before pushing. See size-budget-action.",
+	},
+	"max-layers": {
+		Name:       "max-layers",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_MAX_LAYERS",
+		TypeKind:   reflect.Int,
+		Usage:      "Maximum number of layers of the built image, including the base image layers.\nChecked after the build, before pushing. See size-budget-action.",
+	},
+	"max-layer-size": {
+		Name:       "max-layer-size",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_MAX_LAYER_SIZE",
+		TypeKind:   reflect.String,
+		Usage:      "Maximum uncompressed size of each layer of the built image, e.g. 500MB.\nChecked after the build, before pushing. See size-budget-action.",
+	},
+	"size-budget-action": {
+		Name:         "size-budget-action",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_SIZE_BUDGET_ACTION",
+		DefaultValue: "fail",
+		TypeKind:     reflect.String,
+		Usage:        "What to do when the image exceeds max-image-size, max-layers or max-layer-size: 'fail' or 'warn'.",
+	},
+	"push-format": {
+		Name:       "push-format",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_PUSH_FORMAT",
+		TypeKind:   reflect.String,
+		Usage:      "Manifest type of the pushed (or written) image: oci or docker. Defaults to the format of the built image.",
+	},
+	"compression-format": {
+		Name:       "compression-format",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_COMPRESSION_FORMAT",
+		TypeKind:   reflect.String,
+		Usage:      "Layer compression of the pushed (or written) image: gzip (default), zstd or zstd:chunked.\nzstd:chunked allows lazy pulling and partial pulls. zstd formats require the oci push-format.",
+	},
+	"compression-level": {
+		Name:       "compression-level",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_COMPRESSION_LEVEL",
+		TypeKind:   reflect.Int,
+		Usage:      "Compression level for the compression-format (1-9 for gzip, 1-22 for zstd). Defaults to the format's default level.",
+	},
+	"force-compression": {
+		Name:         "force-compression",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_FORCE_COMPRESSION",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Recompress layers that already exist in the registry with a different compression.\nAlways done by buildah if compression-format is set.",
+	},
+	"output": {
+		Name:       "output",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_OUTPUT",
+		TypeKind:   reflect.String,
+		Usage:      "Write the built image to a local destination instead of pushing it to the registry:\noci-layout:<dir>[:<name>], oci-archive:<file>[:<name>] or docker-archive:<file>.\nConflicts with --push.",
+	},
+	"dry-run": {
+		Name:       "dry-run",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_DRY_RUN",
+		TypeKind:   reflect.Bool,
+		Usage:      "Prepare the build, but instead of building and pushing the image, print the build plan as JSON.\nThe plan includes the buildah command, volumes, secrets, build contexts, the Containerfile modifications,\nthe injected buildinfo files and the base images that would be pre-pulled.\nBase images are not pre-pulled, but the final base image may still be pulled to determine the injected labels.\nRHSM pre-registration is skipped.",
+	},
 	"secret-dirs": {
 		Name:       "secret-dirs",
 		ShortName:  "",
@@ -84,6 +186,20 @@ var BuildParamsConfig = map[string]common.Parameter{
 		TypeKind:   reflect.Slice,
 		Usage:      "Directories containing secret files to make available during build.",
 	},
+	"secrets": {
+		Name:       "secrets",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SECRETS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Individual secrets to make available during build, id=ID,src=FILE_PATH or id=ID,env=ENV_VAR.",
+	},
+	"ssh": {
+		Name:       "ssh",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SSH",
+		TypeKind:   reflect.Slice,
+		Usage:      "SSH keys to make available with 'RUN --mount=type=ssh', in ID=PATH format (PATH is a key file or a directory\nwith id_* keys) or just ID to forward the agent from SSH_AUTH_SOCK. Not allowed with hermetic.",
+	},
 	"workdir-mount": {
 		Name:         "workdir-mount",
 		ShortName:    "",
@@ -106,6 +222,13 @@ var BuildParamsConfig = map[string]common.Parameter{
 		TypeKind:   reflect.String,
 		Usage:      "Path to a file with build arguments, see https://www.mankier.com/1/buildah-build#--build-arg-file",
 	},
+	"build-contexts": {
+		Name:       "build-contexts",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_BUILD_CONTEXTS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Additional build contexts to pass to buildah's --build-context option, in the name=location format.\nThe location is a directory, docker-image://<image> or oci-layout://<directory>[:<tag>].\nDirectories are relative to --source if set, same as --context.",
+	},
 	"envs": {
 		Name:       "envs",
 		ShortName:  "",
@@ -231,6 +354,14 @@ var BuildParamsConfig = map[string]common.Parameter{
 		DefaultValue: "true",
 		Usage:        "Skip stages in multi-stage builds which don't affect the target stage.",
 	},
+	"strict-build-args": {
+		Name:         "strict-build-args",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_STRICT_BUILD_ARGS",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Fail the build on build arg issues: build args not declared by any ARG in the built stages,\nARGs without a default value that aren't provided, ARGs used before their declaration\nand undefined variables in FROM. By default, the issues are only reported as warnings.",
+	},
 	"hermetic": {
 		Name:         "hermetic",
 		ShortName:    "",
@@ -239,6 +370,28 @@ var BuildParamsConfig = map[string]common.Parameter{
 		DefaultValue: "false",
 		Usage:        "Prevent network access while building the containerfile.",
 	},
+	"network-allow": {
+		Name:       "network-allow",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_NETWORK_ALLOW",
+		TypeKind:   reflect.Slice,
+		Usage:      "host:port destinations that a hermetic build can still reach, through an egress proxy\n(the build gets HTTP_PROXY/HTTPS_PROXY pointing at it). All other destinations are denied. Requires hermetic.\nYum repos whose URLs are all allowed stay enabled and get their proxy set to it.",
+	},
+	"network-allow-package-registry-proxy": {
+		Name:         "network-allow-package-registry-proxy",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_NETWORK_ALLOW_PACKAGE_REGISTRY_PROXY",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Add the package registry proxies from the Konflux config to network-allow,\nif the cluster allows them. Requires hermetic.",
+	},
+	"network-report-output": {
+		Name:       "network-report-output",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_NETWORK_REPORT_OUTPUT",
+		TypeKind:   reflect.String,
+		Usage:      "Write every connection attempt that went through the network-allow egress proxy\n(allowed and denied) to this file, as a JSON array.",
+	},
 	"image-pull-proxy": {
 		Name:       "image-pull-proxy",
 		ShortName:  "",
@@ -253,6 +406,14 @@ var BuildParamsConfig = map[string]common.Parameter{
 		TypeKind:   reflect.String,
 		Usage:      "Set NO_PROXY for base image pulls.",
 	},
+	"pull-concurrency": {
+		Name:         "pull-concurrency",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_PULL_CONCURRENCY",
+		TypeKind:     reflect.Int,
+		DefaultValue: "4",
+		Usage:        "Maximum number of base images to pull at the same time, 0 means no limit.",
+	},
 	"yum-repos-d-sources": {
 		Name:       "yum-repos-d-sources",
 		ShortName:  "",
@@ -268,6 +429,36 @@ var BuildParamsConfig = map[string]common.Parameter{
 		DefaultValue: "",
 		Usage:        "Set an alternative mount destination for the merged yum-repos-d-sources dir (default is /etc/yum.repos.d).",
 	},
+	"yum-repos-require-gpgcheck": {
+		Name:         "yum-repos-require-gpgcheck",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_YUM_REPOS_REQUIRE_GPGCHECK",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Fail if any enabled repo in the merged yum.repos.d sets gpgcheck=0.",
+	},
+	"yum-repos-require-sslverify": {
+		Name:         "yum-repos-require-sslverify",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_YUM_REPOS_REQUIRE_SSLVERIFY",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Fail if any enabled repo in the merged yum.repos.d sets sslverify=0.",
+	},
+	"yum-repos-mirrors": {
+		Name:       "yum-repos-mirrors",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_YUM_REPOS_MIRRORS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Rewrite baseurl, metalink and mirrorlist URLs in the merged yum.repos.d, in PREFIX=REPLACEMENT format.\nURLs starting with PREFIX start with REPLACEMENT instead. The longest matching prefix wins.",
+	},
+	"yum-repos-proxy": {
+		Name:       "yum-repos-proxy",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_YUM_REPOS_PROXY",
+		TypeKind:   reflect.String,
+		Usage:      "Set the proxy (e.g. the cache proxy) for the enabled network repos in the merged yum.repos.d.\nRepos that already set a proxy keep it.",
+	},
 	"prefetch-dir": {
 		Name:       "prefetch-dir",
 		ShortName:  "",
@@ -303,6 +494,13 @@ var BuildParamsConfig = map[string]common.Parameter{
 		TypeKind:   reflect.String,
 		Usage:      "Take the set of images that the containerfile depends on and write them to the file at the specified path.\nEach line in the file is \"<ref-from-containerfile> <canonical-ref>\",\nwhere canonical-ref includes the fully qualified name, digest and optionaly tag (if ref-from-containerfile has a tag).",
 	},
+	"package-inventory-output": {
+		Name:       "package-inventory-output",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_PACKAGE_INVENTORY_OUTPUT",
+		TypeKind:   reflect.String,
+		Usage:      "Scan the packages installed in the built image and write the inventory JSON to the file at the specified path.\nThe image is mounted with 'buildah mount' and the scan reads the rpmdb, dpkg status, apk database,\nPython package metadata and the build info of Go binaries. Every package is identified by a purl.\nSee 'konflux-build-cli image packages' for the format.",
+	},
 	"rhsm-entitlements": {
 		Name:       "rhsm-entitlements",
 		ShortName:  "",
@@ -338,6 +536,20 @@ var BuildParamsConfig = map[string]common.Parameter{
 		TypeKind:   reflect.Bool,
 		Usage:      "Pre-register with RHSM using the provided activation key and org ID.\nWARNING: unregisters your host system if already registered. Requires root permissions.\nSee 'Red Hat Subscription Management' in the help text for more details.",
 	},
+	"rhsm-fallback-activation-keys": {
+		Name:       "rhsm-fallback-activation-keys",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_RHSM_FALLBACK_ACTIVATION_KEYS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Files containing activation keys to pre-register with, in order, if registering with rhsm-activation-key fails.\nPaired with rhsm-fallback-orgs by position. Requires rhsm-activation-preregister.",
+	},
+	"rhsm-fallback-orgs": {
+		Name:       "rhsm-fallback-orgs",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_RHSM_FALLBACK_ORGS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Files containing the organization IDs of the rhsm-fallback-activation-keys.",
+	},
 	"rhsm-mount-ca-certs": {
 		Name:         "rhsm-mount-ca-certs",
 		ShortName:    "",
@@ -349,49 +561,78 @@ var BuildParamsConfig = map[string]common.Parameter{
 }
 
 type BuildParams struct {
-	Containerfile              string   `paramName:"containerfile"`
-	Context                    string   `paramName:"context"`
-	Source                     string   `paramName:"source"`
-	OutputRef                  string   `paramName:"output-ref"`
-	Push                       bool     `paramName:"push"`
-	SecretDirs                 []string `paramName:"secret-dirs"`
-	WorkdirMount               string   `paramName:"workdir-mount"`
-	BuildArgs                  []string `paramName:"build-args"`
-	BuildArgsFile              string   `paramName:"build-args-file"`
-	Envs                       []string `paramName:"envs"`
-	Labels                     []string `paramName:"labels"`
-	Annotations                []string `paramName:"annotations"`
-	AnnotationsFile            string   `paramName:"annotations-file"`
-	ImageSource                string   `paramName:"image-source"`
-	ImageRevision              string   `paramName:"image-revision"`
-	LegacyBuildTimestamp       string   `paramName:"legacy-build-timestamp"`
-	SourceDateEpoch            string   `paramName:"source-date-epoch"`
-	RewriteTimestamp           bool     `paramName:"rewrite-timestamp"`
-	QuayImageExpiresAfter      string   `paramName:"quay-image-expires-after"`
-	AddLegacyLabels            bool     `paramName:"add-legacy-labels"`
-	ContainerfileJsonOutput    string   `paramName:"containerfile-json-output"`
-	SkipInjections             bool     `paramName:"skip-injections"`
-	InheritLabels              bool     `paramName:"inherit-labels"`
-	IncludeLegacyBuildinfoPath bool     `paramName:"include-legacy-buildinfo-path"`
-	Target                     string   `paramName:"target"`
-	SkipUnusedStages           bool     `paramName:"skip-unused-stages"`
-	Hermetic                   bool     `paramName:"hermetic"`
-	ImagePullProxy             string   `paramName:"image-pull-proxy"`
-	ImagePullNoProxy           string   `paramName:"image-pull-noproxy"`
-	YumReposDSources           []string `paramName:"yum-repos-d-sources"`
-	YumReposDTarget            string   `paramName:"yum-repos-d-target"`
-	PrefetchDir                string   `paramName:"prefetch-dir"`
-	PrefetchDirCopy            string   `paramName:"prefetch-dir-copy"`
-	PrefetchOutputMount        string   `paramName:"prefetch-output-mount"`
-	PrefetchEnvMount           string   `paramName:"prefetch-env-mount"`
-	ResolvedBaseImagesOutput   string   `paramName:"resolved-base-images-output"`
-	RHSMEntitlements           string   `paramName:"rhsm-entitlements"`
-	RHSMActivationKey          string   `paramName:"rhsm-activation-key"`
-	RHSMOrg                    string   `paramName:"rhsm-org"`
-	RHSMActivationMount        string   `paramName:"rhsm-activation-mount"`
-	RHSMActivationPreregister  bool     `paramName:"rhsm-activation-preregister"`
-	RHSMMountCACerts           string   `paramName:"rhsm-mount-ca-certs"`
-	ExtraArgs                  []string // Additional arguments to pass to buildah build
+	Containerfile                    string   `paramName:"containerfile"`
+	Context                          string   `paramName:"context"`
+	Source                           string   `paramName:"source"`
+	SourcePolicyFile                 string   `paramName:"source-policy-file"`
+	SourceReportFile                 string   `paramName:"source-report-file"`
+	OutputRef                        string   `paramName:"output-ref"`
+	Push                             bool     `paramName:"push"`
+	PushDestinations                 []string `paramName:"push-destinations"`
+	ReproducibilityCheck             bool     `paramName:"reproducibility-check"`
+	MaxImageSize                     string   `paramName:"max-image-size"`
+	MaxLayers                        int      `paramName:"max-layers"`
+	MaxLayerSize                     string   `paramName:"max-layer-size"`
+	SizeBudgetAction                 string   `paramName:"size-budget-action"`
+	PushFormat                       string   `paramName:"push-format"`
+	CompressionFormat                string   `paramName:"compression-format"`
+	CompressionLevel                 int      `paramName:"compression-level"`
+	ForceCompression                 bool     `paramName:"force-compression"`
+	Output                           string   `paramName:"output"`
+	DryRun                           bool     `paramName:"dry-run"`
+	SecretDirs                       []string `paramName:"secret-dirs"`
+	Secrets                          []string `paramName:"secrets"`
+	SSH                              []string `paramName:"ssh"`
+	WorkdirMount                     string   `paramName:"workdir-mount"`
+	BuildArgs                        []string `paramName:"build-args"`
+	BuildArgsFile                    string   `paramName:"build-args-file"`
+	BuildContexts                    []string `paramName:"build-contexts"`
+	Envs                             []string `paramName:"envs"`
+	Labels                           []string `paramName:"labels"`
+	Annotations                      []string `paramName:"annotations"`
+	AnnotationsFile                  string   `paramName:"annotations-file"`
+	ImageSource                      string   `paramName:"image-source"`
+	ImageRevision                    string   `paramName:"image-revision"`
+	LegacyBuildTimestamp             string   `paramName:"legacy-build-timestamp"`
+	SourceDateEpoch                  string   `paramName:"source-date-epoch"`
+	RewriteTimestamp                 bool     `paramName:"rewrite-timestamp"`
+	QuayImageExpiresAfter            string   `paramName:"quay-image-expires-after"`
+	AddLegacyLabels                  bool     `paramName:"add-legacy-labels"`
+	ContainerfileJsonOutput          string   `paramName:"containerfile-json-output"`
+	SkipInjections                   bool     `paramName:"skip-injections"`
+	InheritLabels                    bool     `paramName:"inherit-labels"`
+	IncludeLegacyBuildinfoPath       bool     `paramName:"include-legacy-buildinfo-path"`
+	Target                           string   `paramName:"target"`
+	SkipUnusedStages                 bool     `paramName:"skip-unused-stages"`
+	StrictBuildArgs                  bool     `paramName:"strict-build-args"`
+	Hermetic                         bool     `paramName:"hermetic"`
+	NetworkAllow                     []string `paramName:"network-allow"`
+	NetworkAllowPackageRegistryProxy bool     `paramName:"network-allow-package-registry-proxy"`
+	NetworkReportOutput              string   `paramName:"network-report-output"`
+	ImagePullProxy                   string   `paramName:"image-pull-proxy"`
+	ImagePullNoProxy                 string   `paramName:"image-pull-noproxy"`
+	PullConcurrency                  int      `paramName:"pull-concurrency"`
+	YumReposDSources                 []string `paramName:"yum-repos-d-sources"`
+	YumReposDTarget                  string   `paramName:"yum-repos-d-target"`
+	YumReposRequireGPGCheck          bool     `paramName:"yum-repos-require-gpgcheck"`
+	YumReposRequireSSLVerify         bool     `paramName:"yum-repos-require-sslverify"`
+	YumReposMirrors                  []string `paramName:"yum-repos-mirrors"`
+	YumReposProxy                    string   `paramName:"yum-repos-proxy"`
+	PrefetchDir                      string   `paramName:"prefetch-dir"`
+	PrefetchDirCopy                  string   `paramName:"prefetch-dir-copy"`
+	PrefetchOutputMount              string   `paramName:"prefetch-output-mount"`
+	PrefetchEnvMount                 string   `paramName:"prefetch-env-mount"`
+	ResolvedBaseImagesOutput         string   `paramName:"resolved-base-images-output"`
+	PackageInventoryOutput           string   `paramName:"package-inventory-output"`
+	RHSMEntitlements                 string   `paramName:"rhsm-entitlements"`
+	RHSMActivationKey                string   `paramName:"rhsm-activation-key"`
+	RHSMOrg                          string   `paramName:"rhsm-org"`
+	RHSMActivationMount              string   `paramName:"rhsm-activation-mount"`
+	RHSMActivationPreregister        bool     `paramName:"rhsm-activation-preregister"`
+	RHSMFallbackActivationKeys       []string `paramName:"rhsm-fallback-activation-keys"`
+	RHSMFallbackOrgs                 []string `paramName:"rhsm-fallback-orgs"`
+	RHSMMountCACerts                 string   `paramName:"rhsm-mount-ca-certs"`
+	ExtraArgs                        []string // Additional arguments to pass to buildah build
 }
 
 type BuildCliWrappers struct {
@@ -400,11 +641,33 @@ type BuildCliWrappers struct {
 	Unshare             cliWrappers.WrapperCmd
 	SelfInUserNamespace cliWrappers.WrapperCmd
 	SubscriptionManager cliWrappers.SubscriptionManagerCliInterface
+	PackageInventoryCli cliWrappers.PackageInventoryCliInterface
+	SSHAgentCli         cliWrappers.SSHAgentCliInterface
+	// Optional, used to resolve base image tags to digests before pulling
+	SkopeoCli cliWrappers.SkopeoCliInterface
 }
 
 type BuildResults struct {
 	ImageUrl string `json:"image_url"`
 	Digest   string `json:"digest,omitempty"`
+	// Digests per push destination (the output-ref and --push-destinations), if any --push-destinations are used
+	PushDestinations []PushedDestination `json:"push_destinations,omitempty"`
+	// Compressed layer sizes of the pushed image, as stored in the registry
+	PushedLayers []PushedImageLayers `json:"pushed_layers,omitempty"`
+	// Outcome of the reproducibility check, if enabled
+	Reproducibility *ReproducibilityCheck `json:"reproducibility,omitempty"`
+	// Size of the built image, if any of the size budgets is set
+	ImageSize *ImageSizeReport `json:"image_size,omitempty"`
+	// Stats about the pulled base images
+	BaseImagePulls []BaseImagePull `json:"base_image_pulls,omitempty"`
+	// The activation key file that pre-registered with RHSM, if pre-registration is enabled
+	RHSMActivationKey string `json:"rhsm_activation_key,omitempty"`
+	// The secrets made available to the build, never includes the values
+	Secrets []BuildSecret `json:"secrets,omitempty"`
+	// The repos in the merged yum.repos.d, as mounted into the build
+	YumRepos []YumRepo `json:"yum_repos,omitempty"`
+	// Connection attempts through the network-allow egress proxy, if enabled
+	NetworkAccess *NetworkAccessSummary `json:"network_access,omitempty"`
 }
 
 type Build struct {
@@ -417,6 +680,7 @@ type Build struct {
 
 	// pre-computed buildah arguments
 	buildahSecrets        []cliWrappers.BuildahSecret
+	buildahSSH            []cliWrappers.BuildahSSH
 	buildahVolumes        []cliWrappers.BuildahVolume
 	mergedLabels          []string
 	mergedAnnotations     []string
@@ -428,12 +692,28 @@ type Build struct {
 
 	// temporary files/directories that could not be placed inside the tempWorkdir
 	tempFilesOutsideWorkdir []string
+	prefetchDirCopy         string
+
+	// ssh-agents started for --ssh, killed in cleanup
+	sshAgents []sshAgent
+
+	// egress proxy for --network-allow, stopped in cleanup
+	networkAllow []string
+	egressSocket string
+	egressProxy  *egressProxy
+	// network-report-output was written, see stopEgressProxy
+	networkReportWritten bool
+
+	// pulls base images, shared by the label inspection and pre-pull
+	imagePuller imagePuller
 
 	registeredWithRHSM bool
+	rhsmSession        *rhsm.Session
 	// these are constants, but they need to be mockable for tests
 	hostEntitlements  string
 	hostConsumerCerts string
 	hostRHSMcaCerts   string
+	rhsmStateFile     string
 }
 
 func NewBuild(cmd *cobra.Command, extraArgs []string) (*Build, error) {
@@ -441,6 +721,7 @@ func NewBuild(cmd *cobra.Command, extraArgs []string) (*Build, error) {
 		hostEntitlements:  "/etc/pki/entitlement",
 		hostConsumerCerts: "/etc/pki/consumer",
 		hostRHSMcaCerts:   "/etc/rhsm/ca",
+		rhsmStateFile:     rhsm.DefaultStateFile,
 	}
 
 	params := &BuildParams{}
@@ -469,6 +750,8 @@ func (c *Build) effectiveContextDir() string {
 }
 
 func (c *Build) cleanup() {
+	c.killSSHAgents()
+	c.stopEgressProxy()
 	if c.tempWorkdir != "" {
 		if err := os.RemoveAll(c.tempWorkdir); err != nil {
 			l.Logger.Warnf("Failed to clean up temporary workdir %s: %s", c.tempWorkdir, err)
@@ -480,7 +763,7 @@ func (c *Build) cleanup() {
 		}
 	}
 	if c.registeredWithRHSM {
-		c.CliWrappers.SubscriptionManager.Unregister()
+		c.rhsmSession.Unregister()
 	}
 }
 
@@ -502,6 +785,21 @@ func (c *Build) initCliWrappers() error {
 		return err
 	}
 	c.CliWrappers.SelfInUserNamespace = cliWrappers.NewWrapperCmd(selfPath, "internal", "in-user-namespace")
+	c.CliWrappers.PackageInventoryCli = &cliWrappers.PackageInventoryCli{Executor: executor, SelfPath: selfPath}
+
+	if skopeoCli, err := cliWrappers.NewSkopeoCli(executor); err == nil {
+		c.CliWrappers.SkopeoCli = skopeoCli
+	} else {
+		l.Logger.Warnf("Base image tags won't be resolved to digests, refs are compared as-is: %s", err)
+	}
+
+	if len(c.Params.SSH) > 0 {
+		sshAgentCli, err := cliWrappers.NewSSHAgentCli(executor)
+		if err != nil {
+			return err
+		}
+		c.CliWrappers.SSHAgentCli = sshAgentCli
+	}
 
 	if c.Params.RHSMActivationPreregister {
 		subman, err := cliWrappers.NewSubscriptionManagerCli(executor)
@@ -526,6 +824,17 @@ func (c *Build) ensureTempWorkdirExists() error {
 	return nil
 }
 
+// Create a new temporary directory for unix sockets. Unix socket paths are limited to
+// ~100 characters and the temp workdir may be too deep, so it goes directly to the system temp dir.
+func (c *Build) createSocketDir(prefix string) (string, error) {
+	dir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return "", err
+	}
+	c.tempFilesOutsideWorkdir = append(c.tempFilesOutsideWorkdir, dir)
+	return dir, nil
+}
+
 func (c *Build) ensureContainerfileCopied() error {
 	if c.containerfileCopyPath != "" {
 		return nil
@@ -585,10 +894,19 @@ func (c *Build) Run() error {
 		return err
 	}
 
+	if err := c.checkContext(); err != nil {
+		return fmt.Errorf("checking build context: %w", err)
+	}
+
 	if err := c.detectContainerfile(); err != nil {
 		return err
 	}
 
+	// Before parsing, which expands the build args
+	if err := c.checkBuildArgs(); err != nil {
+		return err
+	}
+
 	containerfile, err := c.parseContainerfile()
 	if err != nil {
 		return err
@@ -602,31 +920,38 @@ func (c *Build) Run() error {
 		return err
 	}
 
-	prefetchResources, err := c.integrateWithPrefetch()
-	if err != nil {
-		return fmt.Errorf("setting up prefetch integration: %w", err)
+	if err := c.setSSHArgs(); err != nil {
+		return err
 	}
 
-	if err := c.prepareYumReposMount(prefetchResources); err != nil {
-		return fmt.Errorf("preparing yum.repos.d mount: %w", err)
+	if err := c.setupEgressProxy(); err != nil {
+		return fmt.Errorf("setting up network-allow egress proxy: %w", err)
 	}
 
-	if err := c.integrateWithRHSM(); err != nil {
-		return fmt.Errorf("setting up RHSM integration: %w", err)
+	if err := c.prepareBuildInputs(containerfile); err != nil {
+		return err
 	}
 
-	if !c.Params.SkipInjections {
-		if c.Params.Target != "" {
-			l.Logger.Warnf("Injecting buildinfo is not supported with --target. Skipping.")
-		} else if err := c.injectBuildinfo(containerfile, c.mergedLabels, prefetchResources); err != nil {
-			return fmt.Errorf("injecting buildinfo metadata: %w", err)
+	if c.Params.DryRun {
+		plan, err := c.createBuildPlan(containerfile)
+		if err != nil {
+			return fmt.Errorf("creating build plan: %w", err)
+		}
+		logBuildPlan(plan)
+		planJson, err := c.ResultsWriter.CreateResultJson(plan)
+		if err != nil {
+			l.Logger.Errorf("failed to create build plan json: %s", err.Error())
+			return err
 		}
+		fmt.Print(planJson)
+		return nil
 	}
 
 	pulledImages, err := c.prePullBaseImages(containerfile)
 	if err != nil {
 		return err
 	}
+	c.Results.BaseImagePulls = c.pulledImageStats()
 
 	if err := c.buildImage(); err != nil {
 		return err
@@ -634,12 +959,74 @@ func (c *Build) Run() error {
 
 	c.Results.ImageUrl = c.Params.OutputRef
 
+	if c.Params.ReproducibilityCheck {
+		check, err := c.checkReproducibility(containerfile)
+		if err != nil {
+			return err
+		}
+		c.Results.Reproducibility = check
+		if !check.Reproducible {
+			report, _ := json.MarshalIndent(check, "", "  ")
+			l.Logger.Errorf("Image differences:\n%s", report)
+			return fmt.Errorf("image is not reproducible: manifest digests %s and %s differ",
+				check.ManifestDigests[0], check.ManifestDigests[1])
+		}
+		l.Logger.Infof("Image is reproducible, manifest digest: %s", check.ManifestDigests[0])
+	}
+
+	// After the last build, the reproducibility check builds with network access too
+	if c.egressProxy != nil {
+		c.Results.NetworkAccess = c.egressProxy.Summary()
+		if err := c.writeNetworkReport(); err != nil {
+			return err
+		}
+	}
+
+	budgets, err := c.parseSizeBudgets()
+	if err != nil {
+		return err
+	}
+	if budgets.isSet() {
+		report, err := c.checkSizeBudgets(budgets)
+		if err != nil {
+			return err
+		}
+		c.Results.ImageSize = report
+		logImageSizeReport(report)
+		if len(report.ExceededBudgets) > 0 {
+			if c.Params.SizeBudgetAction == "warn" {
+				for _, exceeded := range report.ExceededBudgets {
+					l.Logger.Warnf("Size budget exceeded: %s", exceeded)
+				}
+			} else {
+				return fmt.Errorf("image exceeds size budgets: %s", strings.Join(report.ExceededBudgets, "; "))
+			}
+		}
+	}
+
+	if c.Params.PackageInventoryOutput != "" {
+		if err := c.writePackageInventory(c.Params.PackageInventoryOutput); err != nil {
+			return err
+		}
+	}
+
 	if c.Params.Push {
 		digest, err := c.pushImage()
 		if err != nil {
 			return err
 		}
 		c.Results.Digest = digest
+		c.Results.PushedLayers = c.pushedLayers(digest)
+		if c.Results.ImageSize != nil {
+			c.Results.ImageSize.addCompressedSizes(c.Results.PushedLayers)
+		}
+	} else if c.Params.Output != "" {
+		imageUrl, digest, err := c.writeOutput()
+		if err != nil {
+			return err
+		}
+		c.Results.ImageUrl = imageUrl
+		c.Results.Digest = digest
 	}
 
 	if c.Params.ContainerfileJsonOutput != "" {
@@ -663,6 +1050,31 @@ func (c *Build) Run() error {
 	return nil
 }
 
+// Generate the build inputs: the prefetch dir copy, the processed Containerfile
+// and the yum.repos.d, RHSM and buildinfo files in the tempWorkdir.
+// The reproducibility check generates them again for the second build, see regenerateBuildInputs.
+func (c *Build) prepareBuildInputs(containerfile *dockerfile.Dockerfile) error {
+	prefetchResources, err := c.integrateWithPrefetch()
+	if err != nil {
+		return fmt.Errorf("setting up prefetch integration: %w", err)
+	}
+
+	if err := c.prepareYumReposMount(prefetchResources); err != nil {
+		return fmt.Errorf("preparing yum.repos.d mount: %w", err)
+	}
+
+	if err := c.integrateWithRHSM(); err != nil {
+		return fmt.Errorf("setting up RHSM integration: %w", err)
+	}
+
+	if !c.Params.SkipInjections {
+		if err := c.injectBuildinfo(containerfile, c.mergedLabels, prefetchResources); err != nil {
+			return fmt.Errorf("injecting buildinfo metadata: %w", err)
+		}
+	}
+
+	return nil
+}
 func (c *Build) validateParams() error {
 	if !common.IsImageNameValid(common.GetImageName(c.Params.OutputRef)) {
 		return fmt.Errorf("output-ref '%s' is invalid", c.Params.OutputRef)
@@ -698,6 +1110,27 @@ func (c *Build) validateParams() error {
 	if c.Params.YumReposDTarget != "" && !filepath.IsAbs(c.Params.YumReposDTarget) {
 		return fmt.Errorf("yum-repos-d-target must be an absolute path, got '%s'", c.Params.YumReposDTarget)
 	}
+	if _, err := parseYumRepoMirrors(c.Params.YumReposMirrors); err != nil {
+		return err
+	}
+
+	if len(c.Params.SSH) > 0 {
+		if c.Params.Hermetic {
+			return fmt.Errorf("ssh and hermetic are mutually exclusive, hermetic builds have no network access")
+		}
+		if _, err := parseSSHArgs(c.Params.SSH); err != nil {
+			return err
+		}
+	}
+
+	if len(c.Params.NetworkAllow) > 0 || c.Params.NetworkAllowPackageRegistryProxy {
+		if !c.Params.Hermetic {
+			return fmt.Errorf("network-allow requires hermetic, non-hermetic builds have unrestricted network access")
+		}
+		if _, err := parseNetworkDestinations(c.Params.NetworkAllow); err != nil {
+			return err
+		}
+	}
 
 	if c.Params.PrefetchDirCopy != "" {
 		if _, err := os.Lstat(c.Params.PrefetchDirCopy); !os.IsNotExist(err) {
@@ -705,6 +1138,46 @@ func (c *Build) validateParams() error {
 		}
 	}
 
+	if c.Params.Output != "" {
+		if _, err := parseBuildOutput(c.Params.Output); err != nil {
+			return err
+		}
+		if c.Params.Push {
+			return fmt.Errorf("push and output are mutually exclusive")
+		}
+	}
+
+	if len(c.Params.PushDestinations) > 0 {
+		if !c.Params.Push {
+			return fmt.Errorf("push-destinations requires push")
+		}
+		if err := validatePushDestinations(append([]string{c.Params.OutputRef}, c.Params.PushDestinations...)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.parseSizeBudgets(); err != nil {
+		return err
+	}
+	if c.Params.SizeBudgetAction != "" && c.Params.SizeBudgetAction != "fail" && c.Params.SizeBudgetAction != "warn" {
+		return fmt.Errorf("size-budget-action must be 'fail' or 'warn', got '%s'", c.Params.SizeBudgetAction)
+	}
+
+	if c.Params.PushFormat != "" && c.Params.PushFormat != "oci" && c.Params.PushFormat != "docker" {
+		return fmt.Errorf("push-format must be 'oci' or 'docker', got '%s'", c.Params.PushFormat)
+	}
+	if err := validateCompression(c.Params.PushFormat, c.Params.CompressionFormat, c.Params.CompressionLevel); err != nil {
+		return err
+	}
+
+	if err := c.validateBuildContexts(); err != nil {
+		return err
+	}
+
+	if c.Params.PullConcurrency < 0 {
+		return fmt.Errorf("pull-concurrency must not be negative, got %d", c.Params.PullConcurrency)
+	}
+
 	if c.Params.RHSMEntitlements != "" && c.Params.RHSMActivationKey != "" {
 		return fmt.Errorf("rhsm-entitlements and rhsm-activation-key are mutually exclusive")
 	}
@@ -717,6 +1190,14 @@ func (c *Build) validateParams() error {
 		return fmt.Errorf("rhsm-activation-preregister requires rhsm-activation-key and rhsm-org")
 	}
 
+	if len(c.Params.RHSMFallbackActivationKeys) != len(c.Params.RHSMFallbackOrgs) {
+		return fmt.Errorf("rhsm-fallback-activation-keys and rhsm-fallback-orgs must have the same number of values")
+	}
+
+	if len(c.Params.RHSMFallbackActivationKeys) > 0 && !c.Params.RHSMActivationPreregister {
+		return fmt.Errorf("rhsm-fallback-activation-keys requires rhsm-activation-preregister")
+	}
+
 	if c.Params.RHSMActivationMount != "" && c.Params.RHSMActivationKey == "" {
 		return fmt.Errorf("rhsm-activation-mount requires rhsm-activation-key and rhsm-org")
 	}
@@ -744,6 +1225,39 @@ func (c *Build) validateParams() error {
 	return nil
 }
 
+// checkContext runs the source tree checks on the build context if a policy or report file is set.
+// Unlike in git-clone, the .git directory is scanned, so that policies can forbid it in the context.
+func (c *Build) checkContext() error {
+	if c.Params.SourcePolicyFile == "" && c.Params.SourceReportFile == "" {
+		return nil
+	}
+
+	policy := &common.SourceTreePolicy{}
+	if c.Params.SourcePolicyFile != "" {
+		var err error
+		if policy, err = common.LoadSourceTreePolicy(c.Params.SourcePolicyFile); err != nil {
+			return err
+		}
+	}
+
+	scanner, err := common.NewSourceTreeScanner(policy)
+	if err != nil {
+		return err
+	}
+
+	report, err := scanner.Scan(c.effectiveContextDir())
+	if err != nil {
+		return err
+	}
+
+	if c.Params.SourceReportFile != "" {
+		if err := report.WriteFile(c.Params.SourceReportFile); err != nil {
+			return err
+		}
+	}
+	return report.Err()
+}
+
 func (c *Build) detectContainerfile() error {
 	source := c.Params.Source
 	if source == "" {
@@ -784,18 +1298,49 @@ func (c *Build) setSecretArgs() error {
 	if err != nil {
 		return fmt.Errorf("parsing --secret-dirs: %w", err)
 	}
-	buildahSecrets, err := c.processSecretDirs(secretDirs)
+	secrets, err := parseSecrets(c.Params.Secrets)
+	if err != nil {
+		return fmt.Errorf("parsing --secrets: %w", err)
+	}
+
+	// Secret IDs must be unique across all the sources
+	usedIDs := make(map[string]bool)
+
+	buildahSecrets, err := c.processSecretDirs(secretDirs, usedIDs)
 	if err != nil {
 		return fmt.Errorf("processing --secret-dirs: %w", err)
 	}
-	c.buildahSecrets = buildahSecrets
+	individualSecrets, err := c.processSecrets(secrets, usedIDs)
+	if err != nil {
+		return fmt.Errorf("processing --secrets: %w", err)
+	}
+	c.buildahSecrets = append(buildahSecrets, individualSecrets...)
+
+	c.Results.Secrets = nil
+	for _, secret := range c.buildahSecrets {
+		secretType := secret.Type
+		if secretType == "" {
+			secretType = "file"
+		}
+		c.Results.Secrets = append(c.Results.Secrets, BuildSecret{ID: secret.Id, Type: secretType, Source: secret.Src})
+	}
 	return nil
 }
 
+// A secret made available to the build. Records where the value comes from, not the value.
+type BuildSecret struct {
+	ID string `json:"id"`
+	// "file" or "env"
+	Type string `json:"type"`
+	// Path of the secret file or name of the environment variable
+	Source string `json:"source"`
+}
+
 type secretDir struct {
-	src      string
-	name     string
-	optional bool
+	src       string
+	name      string
+	optional  bool
+	recursive bool
 }
 
 func parseSecretDirs(secretDirArgs []string) ([]secretDir, error) {
@@ -815,23 +1360,22 @@ func parseSecretDirs(secretDirArgs []string) ([]secretDir, error) {
 				key = "src"
 			}
 
+			var err error
 			switch key {
 			case "src":
 				secretDir.src = value
 			case "name":
 				secretDir.name = value
 			case "optional":
-				switch value {
-				case "true":
-					secretDir.optional = true
-				case "false":
-					secretDir.optional = false
-				default:
-					return nil, fmt.Errorf("invalid argument: optional=%s (expected true|false)", value)
-				}
+				secretDir.optional, err = parseSecretBool(key, value)
+			case "recursive":
+				secretDir.recursive, err = parseSecretBool(key, value)
 			default:
 				return nil, fmt.Errorf("invalid attribute: %s", key)
 			}
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		secretDirs = append(secretDirs, secretDir)
@@ -840,10 +1384,73 @@ func parseSecretDirs(secretDirArgs []string) ([]secretDir, error) {
 	return secretDirs, nil
 }
 
+func parseSecretBool(key, value string) (bool, error) {
+	switch value {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid argument: %s=%s (expected true|false)", key, value)
+	}
+}
+
+// An individual secret from --secrets, either a file (src) or an environment variable (env).
+type secretSource struct {
+	id       string
+	src      string
+	env      string
+	optional bool
+}
+
+func parseSecrets(secretArgs []string) ([]secretSource, error) {
+	var secrets []secretSource
+
+	for _, arg := range secretArgs {
+		secret := secretSource{}
+
+		for _, kv := range strings.Split(arg, ",") {
+			key, value, hasSep := strings.Cut(kv, "=")
+			key = strings.TrimSpace(key)
+			value = strings.TrimSpace(value)
+			if !hasSep {
+				return nil, fmt.Errorf("invalid argument: %s (expected key=value)", kv)
+			}
+
+			var err error
+			switch key {
+			case "id":
+				secret.id = value
+			case "src":
+				secret.src = value
+			case "env":
+				secret.env = value
+			case "optional":
+				secret.optional, err = parseSecretBool(key, value)
+			default:
+				return nil, fmt.Errorf("invalid attribute: %s", key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if secret.id == "" {
+			return nil, fmt.Errorf("missing id in secret '%s'", arg)
+		}
+		if (secret.src == "") == (secret.env == "") {
+			return nil, fmt.Errorf("secret '%s' must have exactly one of src and env", secret.id)
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
 // processSecretDirs processes secret directories and returns buildah --secret arguments.
-func (c *Build) processSecretDirs(secretDirs []secretDir) ([]cliWrappers.BuildahSecret, error) {
+func (c *Build) processSecretDirs(secretDirs []secretDir, usedIDs map[string]bool) ([]cliWrappers.BuildahSecret, error) {
 	var buildahSecrets []cliWrappers.BuildahSecret
-	usedIDs := make(map[string]bool)
 
 	for _, secretDir := range secretDirs {
 		idPrefix := secretDir.name
@@ -851,25 +1458,17 @@ func (c *Build) processSecretDirs(secretDirs []secretDir) ([]cliWrappers.Buildah
 			idPrefix = filepath.Base(secretDir.src)
 		}
 
-		entries, err := os.ReadDir(secretDir.src)
+		if _, err := os.Stat(secretDir.src); os.IsNotExist(err) && secretDir.optional {
+			l.Logger.Debugf("secret directory %s doesn't exist but is marked optional, skipping", secretDir.src)
+			continue
+		}
+
+		filenames, err := listSecretFiles(secretDir.src, "", secretDir.recursive)
 		if err != nil {
-			if os.IsNotExist(err) && secretDir.optional {
-				l.Logger.Debugf("secret directory %s doesn't exist but is marked optional, skipping", secretDir.src)
-				continue
-			}
-			return nil, fmt.Errorf("failed to read secret directory %s: %w", secretDir.src, err)
+			return nil, err
 		}
 
-		for _, entry := range entries {
-			isFile, err := isRegular(entry, secretDir.src)
-			if err != nil {
-				return nil, err
-			}
-			if !isFile {
-				continue
-			}
-
-			filename := entry.Name()
+		for _, filename := range filenames {
 			fullID := filepath.Join(idPrefix, filename)
 
 			// Check for ID conflicts
@@ -890,6 +1489,85 @@ func (c *Build) processSecretDirs(secretDirs []secretDir) ([]cliWrappers.Buildah
 	return buildahSecrets, nil
 }
 
+// List the regular files (or symlinks to regular files) in the secret directory, relative to it.
+// With recursive, descends into subdirectories, but not into symlinks to directories
+// or into the ..data-style directories that back Kubernetes secret volumes
+// (their content is already reachable through the symlinks in the volume root).
+func listSecretFiles(root, relDir string, recursive bool) ([]string, error) {
+	dir := filepath.Join(root, relDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret directory %s: %w", dir, err)
+	}
+
+	var filenames []string
+	for _, entry := range entries {
+		relPath := filepath.Join(relDir, entry.Name())
+		if entry.IsDir() {
+			if recursive && !strings.HasPrefix(entry.Name(), "..") {
+				nested, err := listSecretFiles(root, relPath, recursive)
+				if err != nil {
+					return nil, err
+				}
+				filenames = append(filenames, nested...)
+			}
+			continue
+		}
+
+		isFile, err := isRegular(entry, dir)
+		if err != nil {
+			return nil, err
+		}
+		if isFile {
+			filenames = append(filenames, relPath)
+		}
+	}
+
+	return filenames, nil
+}
+
+// processSecrets processes individual secrets and returns buildah --secret arguments.
+func (c *Build) processSecrets(secrets []secretSource, usedIDs map[string]bool) ([]cliWrappers.BuildahSecret, error) {
+	var buildahSecrets []cliWrappers.BuildahSecret
+
+	for _, secret := range secrets {
+		var buildahSecret cliWrappers.BuildahSecret
+		if secret.env != "" {
+			if _, ok := os.LookupEnv(secret.env); !ok {
+				if secret.optional {
+					l.Logger.Debugf("secret %s: environment variable %s is not set but the secret is marked optional, skipping", secret.id, secret.env)
+					continue
+				}
+				return nil, fmt.Errorf("secret %s: environment variable %s is not set", secret.id, secret.env)
+			}
+			buildahSecret = cliWrappers.BuildahSecret{Src: secret.env, Id: secret.id, Type: cliWrappers.BuildahSecretTypeEnv}
+		} else {
+			stat, err := os.Stat(secret.src)
+			if err != nil {
+				if os.IsNotExist(err) && secret.optional {
+					l.Logger.Debugf("secret %s: file %s doesn't exist but the secret is marked optional, skipping", secret.id, secret.src)
+					continue
+				}
+				return nil, fmt.Errorf("secret %s: %w", secret.id, err)
+			}
+			if !stat.Mode().IsRegular() {
+				return nil, fmt.Errorf("secret %s: %s is not a regular file", secret.id, secret.src)
+			}
+			buildahSecret = cliWrappers.BuildahSecret{Src: secret.src, Id: secret.id}
+		}
+
+		if usedIDs[secret.id] {
+			return nil, fmt.Errorf("duplicate secret ID '%s'", secret.id)
+		}
+		usedIDs[secret.id] = true
+
+		buildahSecrets = append(buildahSecrets, buildahSecret)
+		l.Logger.Infof("Adding secret %s to the build, available with 'RUN --mount=type=secret,id=%s'", secret.id, secret.id)
+	}
+
+	return buildahSecrets, nil
+}
+
 func isRegular(entry os.DirEntry, dir string) (bool, error) {
 	t := entry.Type()
 	if t.IsRegular() {
@@ -1047,6 +1725,7 @@ func (c *Build) copyPrefetchDir() (string, error) {
 		prefetchDirCopy = pdcopy
 	}
 	c.tempFilesOutsideWorkdir = append(c.tempFilesOutsideWorkdir, prefetchDirCopy)
+	c.prefetchDirCopy = prefetchDirCopy
 
 	l.Logger.Debugf("Copying prefetch resources to %s", prefetchDirCopy)
 
@@ -1145,8 +1824,9 @@ func copyFile(srcPath, dstPath string) (err error) {
 }
 
 // Modifies RUN instructions in the Containerfile to source the env file at the beginning,
-// after any options like --mount. Skips exec-form RUN instructions and bare heredocs
-// ('RUN sh <<EOF' is supported, 'RUN <<EOF' isn't).
+// after any options like --mount. For bare heredocs ('RUN <<EOF'), sources the env file at the
+// beginning of the heredoc body. Exec-form RUN instructions get wrapped in '/bin/sh -c'.
+// Skips heredocs with a non-shell interpreter in the shebang.
 func (c *Build) injectPrefetchEnvToContainerfile(envMountPath string) error {
 	if err := c.ensureContainerfileCopied(); err != nil {
 		return err
@@ -1162,11 +1842,9 @@ func (c *Build) injectPrefetchEnvToContainerfile(envMountPath string) error {
 		case errors.Is(err, dfeditor.ErrRunNoOp):
 			l.Logger.Warnf("Applying prefetch env: skipping RUN instruction on line %d, appears effectively empty", lineno)
 		case errors.Is(err, dfeditor.ErrRunHeredoc):
-			l.Logger.Warnf("Applying prefetch env: skipping unsupported RUN instruction on line %d (heredoc). "+
-				"Please specify the interpreter explicitly (e.g. '/bin/sh <<EOF' instead of just '<<EOF').", lineno)
-		case errors.Is(err, dfeditor.ErrRunExec):
-			l.Logger.Warnf("Applying prefetch env: skipping unsupported RUN instruction on line %d (exec form). "+
-				"Please use the shell form instead if possible (not a JSON array).", lineno)
+			l.Logger.Warnf("Applying prefetch env: skipping unsupported RUN instruction on line %d "+
+				"(heredoc with a non-shell interpreter). Please run the interpreter from a shell instead "+
+				"(e.g. 'RUN python3 <<EOF').", lineno)
 		default:
 			l.Logger.Warnf("Applying prefetch.env: skipping RUN instruction on line %d due to unexpected error: %s", lineno, err)
 		}
@@ -1187,6 +1865,7 @@ func (c *Build) injectPrefetchEnvToContainerfile(envMountPath string) error {
 
 // Copies regular files from all yum-repos-d-sources to a subdirectory in the tempWorkdir.
 // On filename conflict, the file found later replaces the one found earlier.
+// The merged .repo files are then validated and rewritten, see processYumRepos.
 //
 // Adds a buildahVolumes mount that mounts the subdirectory at yum-repos-d-target.
 func (c *Build) prepareYumReposMount(prefetchResources *prefetchResources) error {
@@ -1247,6 +1926,10 @@ func (c *Build) prepareYumReposMount(prefetchResources *prefetchResources) error
 		}
 	}
 
+	if err := c.processYumRepos(mergedDir, seen); err != nil {
+		return err
+	}
+
 	// We already attempt to use 777 for the directory and 666 for the files,
 	// but if we're inside a container, umask will likely strip the write bits for group and other.
 	// Chmod again to fix the permissions.
@@ -1297,17 +1980,24 @@ func (c *Build) integrateWithRHSM() error {
 		return nil
 	}
 
-	if c.Params.RHSMActivationPreregister {
+	if c.Params.RHSMActivationPreregister && c.Params.DryRun {
+		l.Logger.Warn("Dry run, skipping RHSM pre-registration")
+	} else if c.Params.RHSMActivationPreregister && !c.registeredWithRHSM {
 		if err := c.registerRHSM(); err != nil {
 			return fmt.Errorf("registering with subscription-manager: %w", err)
 		}
 		c.registeredWithRHSM = true
 	}
 
-	rhsm, err := c.gatherRHSMresources()
+	resources, err := c.gatherRHSMresources()
 	if err != nil {
 		return err
 	}
+	if resources.entitlementCerts != "" {
+		if err := rhsm.CheckEntitlementCerts(resources.entitlementCerts, time.Now()); err != nil {
+			return fmt.Errorf("invalid entitlement certificates: %w", err)
+		}
+	}
 
 	maybeMount := func(src string, dest string) {
 		if src != "" {
@@ -1315,30 +2005,28 @@ func (c *Build) integrateWithRHSM() error {
 			c.buildahVolumes = append(c.buildahVolumes, volume)
 		}
 	}
-	maybeMount(rhsm.entitlementCerts, "/etc/pki/entitlement")
-	maybeMount(rhsm.consumerCerts, "/etc/pki/consumer")
-	maybeMount(rhsm.caCerts, "/etc/rhsm/ca")
-	maybeMount(rhsm.activationSecrets, c.Params.RHSMActivationMount)
+	maybeMount(resources.entitlementCerts, "/etc/pki/entitlement")
+	maybeMount(resources.consumerCerts, "/etc/pki/consumer")
+	maybeMount(resources.caCerts, "/etc/rhsm/ca")
+	maybeMount(resources.activationSecrets, c.Params.RHSMActivationMount)
 
 	return nil
 }
 
+// Register with the activation key, falling back to the fallback keys in order.
 func (c *Build) registerRHSM() error {
-	key, err := os.ReadFile(c.Params.RHSMActivationKey)
-	if err != nil {
-		return err
-	}
-	org, err := os.ReadFile(c.Params.RHSMOrg)
+	credentials, err := rhsm.CredentialsWithFallbacks(c.Params.RHSMActivationKey, c.Params.RHSMOrg,
+		c.Params.RHSMFallbackActivationKeys, c.Params.RHSMFallbackOrgs)
 	if err != nil {
 		return err
 	}
 
-	params := &cliWrappers.SubscriptionManagerRegisterParams{
-		Org:           strings.TrimSpace(string(org)),
-		ActivationKey: strings.TrimSpace(string(key)),
-		Force:         true,
+	c.rhsmSession = &rhsm.Session{SubscriptionManager: c.CliWrappers.SubscriptionManager, StateFile: c.rhsmStateFile}
+	if err := c.rhsmSession.Register(credentials); err != nil {
+		return err
 	}
-	return c.CliWrappers.SubscriptionManager.Register(params)
+	c.Results.RHSMActivationKey = c.rhsmSession.Registered.ActivationKeyFile
+	return nil
 }
 
 type rhsmResources struct {
@@ -1386,7 +2074,7 @@ func (c *Build) gatherRHSMresources() (*rhsmResources, error) {
 			return nil, err
 		}
 
-		if c.Params.RHSMActivationPreregister {
+		if c.Params.RHSMActivationPreregister && !c.Params.DryRun {
 			if err := copyRegularFiles(c.hostEntitlements, rhsm.entitlementCerts); err != nil {
 				return nil, fmt.Errorf("copying %s: %w", c.hostEntitlements, err)
 			}
@@ -1490,10 +2178,31 @@ func (c *Build) parseContainerfile() (*dockerfile.Dockerfile, error) {
 }
 
 func (c *Build) createBuildArgExpander() (dockerfile.SingleWordExpander, error) {
-	// Define built-in ARG variables
-	// See https://docs.docker.com/build/building/variables/#multi-platform-build-arguments
+	args := builtinBuildArgs()
+
+	// User-provided args can override built-in args
+	userArgs, err := c.userBuildArgs()
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(args, userArgs)
+
+	// Return the kind of "expander" function expected by the dockerfile-json API
+	// (takes the name of a build arg, returns the value or error for undefined build args)
+	argExp := func(word string) (string, error) {
+		if value, ok := args[word]; ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("not defined: $%s", word)
+	}
+	return argExp, nil
+}
+
+// Define built-in ARG variables
+// See https://docs.docker.com/build/building/variables/#multi-platform-build-arguments
+func builtinBuildArgs() map[string]string {
 	platform := platforms.Normalize(platforms.DefaultSpec())
-	args := map[string]string{
+	return map[string]string{
 		// We current don't explicitly expose the --platform flag, so the TARGET* values always
 		// match the BUILD* values. If we add --platform handling, we would want to respect it here.
 		"TARGETPLATFORM": platforms.Format(platform),
@@ -1505,8 +2214,12 @@ func (c *Build) createBuildArgExpander() (dockerfile.SingleWordExpander, error)
 		"BUILDARCH":      platform.Architecture,
 		"BUILDVARIANT":   platform.Variant,
 	}
+}
+
+// Get the values of build args from --build-args-file and --build-args, the latter take precedence.
+func (c *Build) userBuildArgs() (map[string]string, error) {
+	args := make(map[string]string)
 
-	// Load from --build-args-file, can override built-in args
 	if c.Params.BuildArgsFile != "" {
 		fileArgs, err := buildargs.ParseBuildArgFile(c.Params.BuildArgsFile)
 		if err != nil {
@@ -1515,19 +2228,10 @@ func (c *Build) createBuildArgExpander() (dockerfile.SingleWordExpander, error)
 		maps.Copy(args, fileArgs)
 	}
 
-	// CLI --build-args take precedence over everything else
 	cliArgs := processKeyValueEnvs(c.Params.BuildArgs)
 	maps.Copy(args, cliArgs)
 
-	// Return the kind of "expander" function expected by the dockerfile-json API
-	// (takes the name of a build arg, returns the value or error for undefined build args)
-	argExp := func(word string) (string, error) {
-		if value, ok := args[word]; ok {
-			return value, nil
-		}
-		return "", fmt.Errorf("not defined: $%s", word)
-	}
-	return argExp, nil
+	return args, nil
 }
 
 // Parse an array of key[=value] args. If '=' is missing, look up the value in
@@ -1673,7 +2377,8 @@ func goArchToRpmArch(goarch string) string {
 	}
 }
 
-// Injects metadata into the image at /usr/share/buildinfo.
+// Injects metadata into the image at /usr/share/buildinfo. The files are copied at the end
+// of the target stage (see --target), the last stage by default.
 //
 // Injected files:
 // - labels.json: contains the labels of the resulting image, needed for the Clair scanning tool
@@ -1684,10 +2389,17 @@ func (c *Build) injectBuildinfo(df *dockerfile.Dockerfile, userLabels []string,
 	if err != nil {
 		return fmt.Errorf("creating buildinfo dir: %w", err)
 	}
-	c.buildinfoBuildContext = &cliWrappers.BuildahBuildContext{Name: ".konflux-buildinfo", Location: buildinfoDir}
+	c.buildinfoBuildContext = &cliWrappers.BuildahBuildContext{Name: buildinfoBuildContextName, Location: buildinfoDir}
+
+	targetStage := -1
+	if df != nil && len(df.Stages) > 0 {
+		if targetStage, err = c.targetStageIndex(df); err != nil {
+			return err
+		}
+	}
 
 	// Create labels.json in buildinfo dir
-	labels, err := c.determineFinalLabels(df, userLabels)
+	labels, err := c.determineFinalLabels(df, targetStage, userLabels)
 	if err != nil {
 		return fmt.Errorf("determining labels for labels.json: %w", err)
 	}
@@ -1714,17 +2426,29 @@ func (c *Build) injectBuildinfo(df *dockerfile.Dockerfile, userLabels []string,
 		l.Logger.Info("Injecting buildinfo: no prefetch SBOM found, not adding content-sets.json")
 	}
 
-	appendLines := []string{"COPY --from=.konflux-buildinfo . /usr/share/buildinfo/"}
+	copyInstructions := []string{"COPY --from=.konflux-buildinfo . /usr/share/buildinfo/"}
 	if c.Params.IncludeLegacyBuildinfoPath {
-		appendLines = append(appendLines, "COPY --from=.konflux-buildinfo . /root/buildinfo/")
+		copyInstructions = append(copyInstructions, "COPY --from=.konflux-buildinfo . /root/buildinfo/")
 	}
-	for _, line := range appendLines {
-		l.Logger.Debugf("Appending to containerfile: %s", line)
+	for _, instruction := range copyInstructions {
+		l.Logger.Debugf("Appending to containerfile: %s", instruction)
 	}
-	// prepend a newline in case the input containerfile doesn't end with one
-	appendContent := "\n" + strings.Join(appendLines, "\n") + "\n"
 
-	if err := appendToFile(c.containerfileCopyPath, appendContent); err != nil {
+	content, err := os.ReadFile(c.containerfileCopyPath)
+	if err != nil {
+		return fmt.Errorf("reading containerfile copy: %w", err)
+	}
+	editor, err := dfeditor.NewEditor(string(content))
+	if err != nil {
+		return fmt.Errorf("parsing containerfile copy: %w", err)
+	}
+	if targetStage < 0 {
+		targetStage = len(editor.Stages()) - 1
+	}
+	if err := editor.InsertAtStageEnd(targetStage, copyInstructions...); err != nil {
+		return fmt.Errorf("modifying containerfile copy: %w", err)
+	}
+	if err := os.WriteFile(c.containerfileCopyPath, []byte(editor.String()), 0644); err != nil {
 		return fmt.Errorf("writing to containerfile copy: %w", err)
 	}
 
@@ -1761,19 +2485,6 @@ func writeBuildinfoJSON(buildinfoDir string, value any, filename string) error {
 	return nil
 }
 
-func appendToFile(filePath, content string) error {
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
-	if err != nil {
-		return err
-	}
-	if _, err := file.WriteString(content); err != nil {
-		// we already have an error and want to return that one
-		_ = file.Close()
-		return err
-	}
-	return file.Close()
-}
-
 // Collect all repository_id qualifiers from the purls in the prefetch SBOM (the "content sets").
 // Return them wrapped in a "content manifest" (a deprecated format still needed by Clair).
 // https://github.com/konflux-ci/buildah-container/blob/5fd8a4b1163079c7978e79100ec51b41504e0f20/scripts/icm-injection-scripts/inject-icm.sh
@@ -1845,17 +2556,17 @@ func determineContentSets(prefetchSbomFile string) (map[string]any, error) {
 	return contentManifest, nil
 }
 
-func (c *Build) determineFinalLabels(df *dockerfile.Dockerfile, userLabels []string) (map[string]string, error) {
+func (c *Build) determineFinalLabels(df *dockerfile.Dockerfile, targetStage int, userLabels []string) (map[string]string, error) {
 	labels := make(map[string]string)
 
 	var baseImage string
 	var containerfileLabels map[string]string
 	if c.Params.InheritLabels {
-		baseImage, containerfileLabels = processUntilBaseStage(df)
-	} else if df != nil && len(df.Stages) > 0 {
+		baseImage, containerfileLabels = processUntilBaseStage(df, targetStage)
+	} else if df != nil && 0 <= targetStage && targetStage < len(df.Stages) {
 		// Label inheritance disabled, don't get base image labels
 		// or labels from any stage except the target stage
-		containerfileLabels = getStageLabels(df.Stages[len(df.Stages)-1])
+		containerfileLabels = getStageLabels(df.Stages[targetStage])
 	}
 
 	// Base image labels
@@ -1894,15 +2605,15 @@ func (c *Build) determineFinalLabels(df *dockerfile.Dockerfile, userLabels []str
 	return labels, nil
 }
 
-// Resolves the base stage of the final stage by following FROM references
+// Resolves the base stage of the target stage by following FROM references
 // through intermediate stages. Collects LABELs from each stage in the chain.
 // Returns the base image for the base stage and the collected labels.
-func processUntilBaseStage(df *dockerfile.Dockerfile) (string, map[string]string) {
-	if df == nil || len(df.Stages) == 0 {
+func processUntilBaseStage(df *dockerfile.Dockerfile, targetStage int) (string, map[string]string) {
+	if df == nil || targetStage < 0 || targetStage >= len(df.Stages) {
 		return "", nil
 	}
 
-	stage := df.Stages[len(df.Stages)-1]
+	stage := df.Stages[targetStage]
 	stageChain := []*dockerfile.Stage{}
 
 	for stage != nil {
@@ -1998,80 +2709,117 @@ func splitTransport(imageRef string) (string, string) {
 
 func (c *Build) getImageLabels(imageRef string) (map[string]string, error) {
 	l.Logger.Debugf("Pulling image %s to read labels...", imageRef)
-	err := c.CliWrappers.BuildahCli.Pull(&cliWrappers.BuildahPullArgs{
-		Image:     imageRef,
-		HttpProxy: c.Params.ImagePullProxy,
-		NoProxy:   c.Params.ImagePullNoProxy,
-	})
+	image, err := c.pullImage(imageRef)
 	if err != nil {
-		return nil, fmt.Errorf("pulling image %s: %w", imageRef, err)
-	}
-
-	// buildah inspect doesn't support the <transport>: prefix, strip it
-	_, inspectableRef := splitTransport(imageRef)
-	info, err := c.CliWrappers.BuildahCli.InspectImage(inspectableRef)
-	if err != nil {
-		return nil, fmt.Errorf("inspecting image %s: %w", inspectableRef, err)
+		return nil, err
 	}
-
-	return info.OCIv1.Config.Labels, nil
+	return image.info.OCIv1.Config.Labels, nil
 }
 
 // Pull all images referenced by the target stage and its dependencies.
 // Primarily needed for hermetic builds where network access is disabled,
 // but also useful to ensure image pulls use our retry logic instead of relying on buildah.
+// Pulls up to --pull-concurrency images at a time, images already pulled for other purposes
+// (e.g. reading base image labels) or resolving to the same digest are not pulled again.
 // Returns the list of pulled base images.
 func (c *Build) prePullBaseImages(df *dockerfile.Dockerfile) ([]string, error) {
+	images, err := c.baseImagesToPull(df)
+	if err != nil {
+		return nil, err
+	}
+
+	l.Logger.Debugf("Pre-pulling base images: %v", images)
+	if err := c.pullImages(images); err != nil {
+		return nil, fmt.Errorf("pre-pulling base images: %w", err)
+	}
+
+	return images, nil
+}
+
+// Determine the images to pre-pull for the target stage, skipping images that can't be pulled.
+func (c *Build) baseImagesToPull(df *dockerfile.Dockerfile) ([]string, error) {
 	if df == nil || len(df.Stages) == 0 {
 		return nil, nil
 	}
 
-	var targetStage int
-	if c.Params.Target != "" {
-		if stages, ok := findMatchingStages(df.Stages, c.Params.Target); ok {
-			// buildah's --target matches the first stage with a matching name
-			targetStage = stages[0]
-		} else {
-			return nil, fmt.Errorf("target stage %q not found", c.Params.Target)
-		}
-	} else {
-		targetStage = len(df.Stages) - 1
+	targetStage, err := c.targetStageIndex(df)
+	if err != nil {
+		return nil, err
 	}
 
-	var pulledImages []string
+	var images []string
 
 	for _, image := range c.collectBaseImages(df, targetStage) {
 		if !isPullableImage(image) {
 			l.Logger.Warnf("Skipping pre-pull of %s: unsupported transport", image)
 			continue
 		}
-		l.Logger.Debugf("Pre-pulling base image: %s", image)
-		if err := c.CliWrappers.BuildahCli.Pull(&cliWrappers.BuildahPullArgs{
-			Image:     image,
-			HttpProxy: c.Params.ImagePullProxy,
-			NoProxy:   c.Params.ImagePullNoProxy,
-		}); err != nil {
-			return nil, fmt.Errorf("pre-pulling image %s: %w", image, err)
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// Determine the index of the stage that gets built, the --target stage or the last stage.
+func (c *Build) targetStageIndex(df *dockerfile.Dockerfile) (int, error) {
+	if c.Params.Target == "" {
+		return len(df.Stages) - 1, nil
+	}
+	if stages, ok := findMatchingStages(df.Stages, c.Params.Target); ok {
+		// buildah's --target matches the first stage with a matching name
+		return stages[0], nil
+	}
+	return -1, fmt.Errorf("target stage %q not found", c.Params.Target)
+}
+
+// Collect all images needed to build the target stage, i.e. the images referenced by
+// the stages that get built (see stagesToBuild) which don't refer to other stages.
+func (c *Build) collectBaseImages(df *dockerfile.Dockerfile, targetStage int) []string {
+	baseImageSet := make(map[string]struct{})
+
+	// A ref that isn't a stage is either a --build-contexts entry or an image.
+	// Build contexts that point to images need those images, others don't need any.
+	addImageOrBuildContext := func(ref string) {
+		if buildContext, ok := c.findBuildContext(ref); ok {
+			if image, ok := buildContextImage(buildContext); ok {
+				baseImageSet[image] = struct{}{}
+			}
+			return
+		}
+		baseImageSet[ref] = struct{}{}
+	}
+
+	for _, stageIdx := range c.stagesToBuild(df, targetStage) {
+		stage := df.Stages[stageIdx]
+		if stage.From.Image != nil {
+			addImageOrBuildContext(*stage.From.Image)
+		}
+
+		// 'From' refs can only reference earlier stages. If they reference a later stage
+		// (or the same stage in which they appear), buildah treats them as external images.
+		// Note: for FROM instructions, dockerfile-json handles this on its own.
+		precedingStages := df.Stages[:stageIdx]
+
+		for _, ref := range getFromRefsInCommands(stage) {
+			if _, ok := findMatchingStages(precedingStages, ref); !ok {
+				addImageOrBuildContext(ref)
+			}
 		}
-		pulledImages = append(pulledImages, image)
 	}
 
-	return pulledImages, nil
+	return slices.Sorted(maps.Keys(baseImageSet))
 }
 
-// Collect all images needed to build the target stage.
+// Determine the indexes of all the stages that get built for the target stage, sorted.
 //
-// For all the "stages of interest":
+// Starting from the "stages of interest":
 //   - For all the instructions that support a 'from' reference:
 //     (those being 'FROM <ref>', 'COPY --from=<ref>', 'RUN --mount=from=<ref>'):
-//     -- If <ref> is an image, collect this image
-//     -- If <ref> is an earlier stage in the containerfile, also collect images for that stage
+//     -- If <ref> is an earlier stage in the containerfile, that stage also gets built
 //
 // With skip-unused-stages=true (the default), there is one stage of interest - the target stage.
 // With skip-unused-stages=false, it's all the stages up to and including the target stage.
-func (c *Build) collectBaseImages(df *dockerfile.Dockerfile, targetStage int) []string {
-	baseImageSet := make(map[string]struct{})
-
+func (c *Build) stagesToBuild(df *dockerfile.Dockerfile, targetStage int) []int {
 	stagesToProcess := []int{}
 	stagesSeen := make(map[int]struct{})
 
@@ -2098,32 +2846,20 @@ func (c *Build) collectBaseImages(df *dockerfile.Dockerfile, targetStage int) []
 		stage := df.Stages[stageIdx]
 		stagesToProcess = stagesToProcess[1:]
 
-		if stage.From.Image != nil {
-			baseImageSet[*stage.From.Image] = struct{}{}
-		} else if stage.From.Stage != nil {
+		if stage.From.Stage != nil {
 			enqueue(stage.From.Stage.Index)
 		}
 
-		// 'From' refs can only reference earlier stages. If they reference a later stage
-		// (or the same stage in which they appear), buildah treats them as external images.
-		// Note: for FROM instructions, dockerfile-json handles this on its own.
 		precedingStages := df.Stages[:stageIdx]
-
 		for _, ref := range getFromRefsInCommands(stage) {
 			if stages, ok := findMatchingStages(precedingStages, ref); ok {
-				// ref matches one or more stages
-				// buildah builds all matching stages, we have to pre-pull all the images
+				// ref matches one or more stages, buildah builds all of them
 				enqueue(stages...)
-			} else {
-				// ref is an image
-				// (the third option is that ref is a --build-context,
-				//  but we don't expose any way to add build contexts)
-				baseImageSet[ref] = struct{}{}
 			}
 		}
 	}
 
-	return slices.Sorted(maps.Keys(baseImageSet))
+	return slices.Sorted(maps.Keys(stagesSeen))
 }
 
 // Given a list of containerfile stages and a string ref, determine if the ref matches any stage(s).
@@ -2166,9 +2902,24 @@ func getFromRefsInCommands(stage *dockerfile.Stage) []string {
 	return refs
 }
 
-func (c *Build) buildImage() (err error) {
+func (c *Build) buildImage() error {
 	l.Logger.Info("Building container image...")
 
+	buildArgs, err := c.createBuildahBuildArgs()
+	if err != nil {
+		return err
+	}
+
+	if err := c.runBuildahBuild(buildArgs); err != nil {
+		return err
+	}
+
+	l.Logger.Info("Build completed successfully")
+	return nil
+}
+
+// Run 'buildah build' inside the context directory.
+func (c *Build) runBuildahBuild(buildArgs *cliWrappers.BuildahBuildArgs) (err error) {
 	var originalCwd string
 	originalCwd, err = os.Getwd()
 	if err != nil {
@@ -2183,6 +2934,16 @@ func (c *Build) buildImage() (err error) {
 		}
 	}()
 
+	return c.CliWrappers.BuildahCli.Build(buildArgs)
+}
+
+// Create the 'buildah build' arguments from the pre-computed values, with all paths made absolute.
+func (c *Build) createBuildahBuildArgs() (*cliWrappers.BuildahBuildArgs, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
 	containerfilePath := c.containerfilePath
 	if c.containerfileCopyPath != "" {
 		containerfilePath = c.containerfileCopyPath
@@ -2193,6 +2954,7 @@ func (c *Build) buildImage() (err error) {
 		ContextDir:       c.effectiveContextDir(),
 		OutputRef:        c.Params.OutputRef,
 		Secrets:          c.buildahSecrets,
+		SSH:              c.buildahSSH,
 		Volumes:          c.buildahVolumes,
 		BuildArgs:        c.Params.BuildArgs,
 		BuildArgsFile:    c.Params.BuildArgsFile,
@@ -2211,20 +2973,18 @@ func (c *Build) buildImage() (err error) {
 		buildArgs.Volumes = append(buildArgs.Volumes, cliWrappers.BuildahVolume{
 			HostDir: c.effectiveContextDir(), ContainerDir: c.Params.WorkdirMount, Options: "z"})
 	}
-	if c.buildinfoBuildContext != nil {
-		buildArgs.BuildContexts = []cliWrappers.BuildahBuildContext{*c.buildinfoBuildContext}
+	if buildArgs.BuildContexts, err = c.userBuildContexts(); err != nil {
+		return nil, err
 	}
-
-	if err := buildArgs.MakePathsAbsolute(originalCwd); err != nil {
-		return err
+	if c.buildinfoBuildContext != nil {
+		buildArgs.BuildContexts = append(buildArgs.BuildContexts, *c.buildinfoBuildContext)
 	}
 
-	if err := c.CliWrappers.BuildahCli.Build(buildArgs); err != nil {
-		return err
+	if err := buildArgs.MakePathsAbsolute(cwd); err != nil {
+		return nil, err
 	}
 
-	l.Logger.Info("Build completed successfully")
-	return nil
+	return buildArgs, nil
 }
 
 // Choose how to wrap the 'buildah build' command.
@@ -2245,6 +3005,33 @@ func (c *Build) buildImage() (err error) {
 //     so the root inside the container build is the actual root from the host.
 //     Creating a user namespace manually slightly improves security.
 func (c *Build) chooseBuildahWrappers() *cliWrappers.WrapperCmd {
+	wrapper := userNamespaceWrapper(c.CliWrappers.Unshare, c.CliWrappers.BuildahUnshare)
+
+	inUserNamespaceArgs := []string{"--disable-rhsm-host-integration"}
+
+	if c.Params.Hermetic {
+		wrapper = cliWrappers.JoinWrappers(
+			wrapper,
+			// Create an isolated network namespace
+			c.CliWrappers.Unshare.WithArgs("--net"))
+		// But bring up the loopback interface inside this namespace.
+		// Mainly needed for Bazel builds, Bazel runs a server on localhost.
+		inUserNamespaceArgs = append(inUserNamespaceArgs, "--loopback-up")
+		if c.egressSocket != "" {
+			// Relay the allowed connections out of the namespace, see egressProxy
+			inUserNamespaceArgs = append(inUserNamespaceArgs, "--egress-proxy-socket", c.egressSocket)
+		}
+	}
+
+	wrapper = cliWrappers.JoinWrappers(
+		wrapper, c.CliWrappers.SelfInUserNamespace.WithArgs(inUserNamespaceArgs...))
+
+	return &wrapper
+}
+
+// Wrap a command in a user namespace in which it runs as root and buildah can manipulate
+// container filesystems (build images, mount containers).
+func userNamespaceWrapper(unshare, buildahUnshare cliWrappers.WrapperCmd) cliWrappers.WrapperCmd {
 	var wrapper cliWrappers.WrapperCmd
 
 	if os.Getuid() == 0 {
@@ -2255,7 +3042,7 @@ func (c *Build) chooseBuildahWrappers() *cliWrappers.WrapperCmd {
 		//             Buildah needs more UIDs available to manipulate container filesystems.
 		// --mount: Create a new mount namespace.
 		//          Without this, buildah would fail to mount /var/lib/containers/storage/overlay.
-		wrapper = c.CliWrappers.Unshare.WithArgs("--map-root-user", "--map-auto", "--mount")
+		wrapper = unshare.WithArgs("--map-root-user", "--map-auto", "--mount")
 	} else {
 		// Buildah doesn't work under regular unshare as non-root, use 'buildah unshare'.
 		// It does mostly the same things as the raw unshare that we use for root,
@@ -2265,35 +3052,20 @@ func (c *Build) chooseBuildahWrappers() *cliWrappers.WrapperCmd {
 		// Unlike the root case, 'buildah unshare' doesn't provide any meaningful security benefits;
 		// buildah always creates a userns for non-root users.
 		// But becoming root in this outer namespace is necessary for 'unshare --net' to work.
-		wrapper = c.CliWrappers.BuildahUnshare
-	}
-
-	inUserNamespaceArgs := []string{"--disable-rhsm-host-integration"}
-
-	if c.Params.Hermetic {
-		wrapper = cliWrappers.JoinWrappers(
-			wrapper,
-			// Create an isolated network namespace
-			c.CliWrappers.Unshare.WithArgs("--net"))
-		// But bring up the loopback interface inside this namespace.
-		// Mainly needed for Bazel builds, Bazel runs a server on localhost.
-		inUserNamespaceArgs = append(inUserNamespaceArgs, "--loopback-up")
+		wrapper = buildahUnshare
 	}
 
-	wrapper = cliWrappers.JoinWrappers(
-		wrapper, c.CliWrappers.SelfInUserNamespace.WithArgs(inUserNamespaceArgs...))
-
-	return &wrapper
+	return wrapper
 }
 
 func (c *Build) pushImage() (string, error) {
 	l.Logger.Infof("Pushing image to registry: %s", c.Params.OutputRef)
 
-	pushArgs := &cliWrappers.BuildahPushArgs{
-		Image: c.Params.OutputRef,
+	if len(c.Params.PushDestinations) > 0 {
+		return c.pushToAllDestinations()
 	}
 
-	digest, err := c.CliWrappers.BuildahCli.Push(pushArgs)
+	digest, err := c.CliWrappers.BuildahCli.Push(c.pushArgs(""))
 	if err != nil {
 		return "", err
 	}
@@ -2304,6 +3076,57 @@ func (c *Build) pushImage() (string, error) {
 	return digest, nil
 }
 
+// Push the image to the output-ref and all the --push-destinations in parallel.
+func (c *Build) pushToAllDestinations() (string, error) {
+	destinations := append([]string{c.Params.OutputRef}, c.Params.PushDestinations...)
+
+	pushed, err := pushToDestinations(destinations, func(destination string) (string, error) {
+		if destination == c.Params.OutputRef {
+			return c.CliWrappers.BuildahCli.Push(c.pushArgs(""))
+		}
+		return c.CliWrappers.BuildahCli.Push(c.pushArgs("docker://" + destination))
+	})
+	if err != nil {
+		return "", err
+	}
+	c.Results.PushDestinations = pushed
+
+	l.Logger.Info("Push completed successfully")
+	l.Logger.Infof("Image digest: %s", pushed[0].Digest)
+
+	return pushed[0].Digest, nil
+}
+
+// The buildah push arguments for pushing the built image to the destination
+// (empty for the output-ref), with the requested format and compression.
+func (c *Build) pushArgs(destination string) *cliWrappers.BuildahPushArgs {
+	return &cliWrappers.BuildahPushArgs{
+		Image:             c.Params.OutputRef,
+		Destination:       destination,
+		Format:            c.Params.PushFormat,
+		CompressionFormat: c.Params.CompressionFormat,
+		CompressionLevel:  c.Params.CompressionLevel,
+		ForceCompression:  c.Params.ForceCompression,
+	}
+}
+
+// Read the compressed layer sizes of the pushed image from the registry. This is informational,
+// failures are logged and don't fail the build.
+func (c *Build) pushedLayers(digest string) []PushedImageLayers {
+	imageName := common.GetImageName(c.Params.OutputRef)
+	rawManifest, err := inspectPushedManifest(c.CliWrappers.BuildahCli, imageName, digest)
+	if err != nil {
+		l.Logger.Warnf("Failed to read the pushed manifest: %s", err.Error())
+		return nil
+	}
+	layers, err := pushedLayers(c.CliWrappers.BuildahCli, imageName, digest, rawManifest)
+	if err != nil {
+		l.Logger.Warnf("Failed to read the layer sizes of the pushed image: %s", err.Error())
+		return nil
+	}
+	return layers
+}
+
 func (c *Build) writeContainerfileJson(containerfile *dockerfile.Dockerfile, outputPath string) error {
 	l.Logger.Infof("Writing parsed Containerfile to: %s", outputPath)
 
@@ -2349,6 +3172,26 @@ func (c *Build) writeResolvedBaseImages(pulledImages []string, outputPath string
 	return nil
 }
 
+func (c *Build) writePackageInventory(outputPath string) error {
+	l.Logger.Infof("Writing package inventory to: %s", outputPath)
+
+	wrapper := userNamespaceWrapper(c.CliWrappers.Unshare, c.CliWrappers.BuildahUnshare)
+	inventory, err := scanImagePackages(c.CliWrappers.PackageInventoryCli, wrapper, c.Params.OutputRef)
+	if err != nil {
+		return fmt.Errorf("scanning installed packages: %w", err)
+	}
+
+	content, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, content, 0644); err != nil {
+		return fmt.Errorf("writing package inventory: %w", err)
+	}
+	l.Logger.Infof("Package inventory written successfully, found %d packages", len(inventory.Packages))
+	return nil
+}
+
 func (c *Build) resolveBaseImages(pulledImages []string) ([]string, error) {
 	var resolvedImages []string
 
@@ -0,0 +1,330 @@
+package commands
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/keilerkonzept/dockerfile-json/pkg/dockerfile"
+	dfparser "github.com/moby/buildkit/frontend/dockerfile/parser"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// Kinds of build arg issues, see lintBuildArgs.
+const (
+	BuildArgUnused                = "unused"
+	BuildArgMissing               = "missing"
+	BuildArgUsedBeforeDeclaration = "used-before-declaration"
+	BuildArgUndefined             = "undefined"
+)
+
+// Build args that buildah predefines, they don't need an ARG instruction to be used in RUN.
+var predefinedProxyBuildArgs = map[string]bool{
+	"HTTP_PROXY": true, "http_proxy": true,
+	"HTTPS_PROXY": true, "https_proxy": true,
+	"NO_PROXY": true, "no_proxy": true,
+	"FTP_PROXY": true, "ftp_proxy": true,
+	"ALL_PROXY": true, "all_proxy": true,
+}
+
+type BuildArgIssue struct {
+	Kind string `json:"kind"`
+	Arg  string `json:"arg"`
+	// Line of the relevant instruction in the Containerfile, not set for unused build args
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i BuildArgIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+	}
+	return i.Message
+}
+
+// Matches $NAME and ${NAME...} references, the preceding character is checked separately for escapes.
+var varReferenceRegex = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Report build arg issues for the stages that get built:
+//   - unused: args passed to the build that aren't declared by any ARG in the built stages
+//     (or by a global ARG before the first FROM), except for buildah's predefined proxy args
+//   - missing: ARGs without a default value for which no value is provided
+//   - used before declaration: references to ARGs that are declared later in the stage, or
+//     global ARGs not redeclared in the stage (those are only available in FROM instructions)
+//   - undefined: references in FROM instructions to args that are neither declared before the
+//     first FROM nor provided, they expand to an empty string
+//
+// The ast is the parsed Containerfile, builtStages are indexes of the stages that get built.
+// The passedArgs are the names of user-provided build args, providedArgs contains all the args
+// that have a value, including built-in args.
+func lintBuildArgs(ast *dfparser.Node, escapeToken rune, builtStages []int, passedArgs []string, providedArgs map[string]string) []BuildArgIssue {
+	var issues []BuildArgIssue
+
+	type stageInfo struct {
+		name string
+		// Vars set by ENV at the end of the stage, inherited by stages based on this one
+		envs map[string]bool
+	}
+	var stages []*stageInfo
+
+	// Global ARGs (before the first FROM), name => has a default value
+	metaArgs := make(map[string]bool)
+	declaredArgs := make(map[string]bool)
+
+	var stageNodes [][]*dfparser.Node
+	for _, node := range ast.Children {
+		if strings.EqualFold(node.Value, "FROM") {
+			stageNodes = append(stageNodes, []*dfparser.Node{node})
+			continue
+		}
+		if len(stageNodes) == 0 {
+			if strings.EqualFold(node.Value, "ARG") {
+				for _, arg := range parseArgInstruction(node) {
+					metaArgs[arg.name] = arg.hasDefault
+				}
+			}
+			continue
+		}
+		stageNodes[len(stageNodes)-1] = append(stageNodes[len(stageNodes)-1], node)
+	}
+
+	for stageIdx, nodes := range stageNodes {
+		from := nodes[0]
+		stage := &stageInfo{envs: make(map[string]bool)}
+		fromArgs := nodeValues(from)
+		if len(fromArgs) == 3 && strings.EqualFold(fromArgs[1], "as") {
+			stage.name = strings.ToLower(fromArgs[2])
+		}
+		if len(fromArgs) > 0 {
+			for _, other := range stages {
+				if other.name != "" && other.name == strings.ToLower(fromArgs[0]) {
+					for env := range other.envs {
+						stage.envs[env] = true
+					}
+				}
+			}
+		}
+		stages = append(stages, stage)
+
+		if !slices.Contains(builtStages, stageIdx) {
+			continue
+		}
+
+		// FROM can use global ARGs
+		for _, name := range uniqueVarReferences(from.Original, escapeToken) {
+			hasDefault, isMetaArg := metaArgs[name]
+			_, provided := providedArgs[name]
+			switch {
+			case isMetaArg && !hasDefault && !provided:
+				issues = append(issues, BuildArgIssue{
+					Kind: BuildArgMissing, Arg: name, Line: from.StartLine,
+					Message: fmt.Sprintf("ARG %s has no default value and no value was provided", name),
+				})
+			case !isMetaArg && !provided:
+				issues = append(issues, BuildArgIssue{
+					Kind: BuildArgUndefined, Arg: name, Line: from.StartLine,
+					Message: fmt.Sprintf("%s is not defined, declare it with an ARG before the first FROM", name),
+				})
+			}
+		}
+
+		// ARGs declared anywhere in this stage, to detect use before declaration
+		stageArgs := make(map[string]bool)
+		for _, node := range nodes[1:] {
+			if strings.EqualFold(node.Value, "ARG") {
+				for _, arg := range parseArgInstruction(node) {
+					stageArgs[arg.name] = true
+				}
+			}
+		}
+
+		declaredSoFar := make(map[string]bool)
+		reported := make(map[string]bool)
+		for _, node := range nodes[1:] {
+			instruction := strings.ToUpper(node.Value)
+
+			var declaredHere []argDeclaration
+			if instruction == "ARG" {
+				declaredHere = parseArgInstruction(node)
+			}
+
+			for _, name := range uniqueVarReferences(instructionText(node), escapeToken) {
+				// ARG A=${A:-default} refers to the value being declared
+				isDeclaredHere := slices.ContainsFunc(declaredHere, func(arg argDeclaration) bool { return arg.name == name })
+				if declaredSoFar[name] || stage.envs[name] || reported[name] || isDeclaredHere {
+					continue
+				}
+				var message string
+				if stageArgs[name] {
+					message = fmt.Sprintf("ARG %s is used before its declaration in the stage", name)
+				} else if _, isMetaArg := metaArgs[name]; isMetaArg {
+					message = fmt.Sprintf("ARG %s is declared before the first FROM, but not in the stage. "+
+						"Add 'ARG %s' to the stage to use it.", name, name)
+				} else {
+					continue
+				}
+				reported[name] = true
+				issues = append(issues, BuildArgIssue{
+					Kind: BuildArgUsedBeforeDeclaration, Arg: name, Line: node.StartLine, Message: message,
+				})
+			}
+
+			switch instruction {
+			case "ARG":
+				for _, arg := range declaredHere {
+					declaredSoFar[arg.name] = true
+					declaredArgs[arg.name] = true
+					if _, ok := providedArgs[arg.name]; ok || arg.hasDefault || metaArgs[arg.name] {
+						continue
+					}
+					issues = append(issues, BuildArgIssue{
+						Kind: BuildArgMissing, Arg: arg.name, Line: node.StartLine,
+						Message: fmt.Sprintf("ARG %s has no default value and no value was provided", arg.name),
+					})
+				}
+			case "ENV":
+				values := nodeValues(node)
+				// ENV key value [=] triples, see buildkit's parseNameVal
+				for i := 0; i < len(values); i += 3 {
+					stage.envs[values[i]] = true
+				}
+			}
+		}
+	}
+
+	for _, name := range passedArgs {
+		if _, isMetaArg := metaArgs[name]; isMetaArg || declaredArgs[name] || predefinedProxyBuildArgs[name] {
+			continue
+		}
+		issues = append(issues, BuildArgIssue{
+			Kind: BuildArgUnused, Arg: name,
+			Message: fmt.Sprintf("build arg %s is not declared by any ARG in the stages that get built", name),
+		})
+	}
+
+	return issues
+}
+
+type argDeclaration struct {
+	name       string
+	hasDefault bool
+}
+
+// Get the ARGs declared by an ARG instruction, in order.
+func parseArgInstruction(node *dfparser.Node) []argDeclaration {
+	var args []argDeclaration
+	for _, word := range nodeValues(node) {
+		name, _, hasDefault := strings.Cut(word, "=")
+		args = append(args, argDeclaration{name: name, hasDefault: hasDefault})
+	}
+	return args
+}
+
+func nodeValues(node *dfparser.Node) []string {
+	var values []string
+	for n := node.Next; n != nil; n = n.Next {
+		values = append(values, n.Value)
+	}
+	return values
+}
+
+// The text of an instruction in which variables may be referenced, including heredoc bodies
+// (RUN heredocs get expanded by the shell even if the heredoc marker is quoted).
+func instructionText(node *dfparser.Node) string {
+	text := node.Original
+	for _, heredoc := range node.Heredocs {
+		if heredoc.Expand || strings.EqualFold(node.Value, "RUN") {
+			text += "\n" + heredoc.Content
+		}
+	}
+	return text
+}
+
+// Find the names of variables referenced in text, in order of first appearance, skipping escaped '$'.
+func uniqueVarReferences(text string, escapeToken rune) []string {
+	var names []string
+	for _, match := range varReferenceRegex.FindAllStringSubmatchIndex(text, -1) {
+		if start := match[0]; start > 0 && rune(text[start-1]) == escapeToken {
+			continue
+		}
+		if name := text[match[2]:match[3]]; !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Analyze the build args for the stages that get built, see lintBuildArgs.
+// Works on the Containerfile as written, before any build arg expansion.
+func (c *Build) findBuildArgIssues() ([]BuildArgIssue, error) {
+	content, err := os.ReadFile(c.containerfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading containerfile: %w", err)
+	}
+	parsed, err := dfparser.Parse(strings.NewReader(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", c.containerfilePath, err)
+	}
+	// Only used to find the stages that get built, which doesn't need the args expanded
+	df, err := dockerfile.ParseReader(strings.NewReader(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", c.containerfilePath, err)
+	}
+	if len(df.Stages) == 0 {
+		return nil, nil
+	}
+
+	targetStage, err := c.targetStageIndex(df)
+	if err != nil {
+		return nil, err
+	}
+
+	providedArgs := builtinBuildArgs()
+	userArgs, err := c.userBuildArgs()
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(providedArgs, userArgs)
+
+	// Args passed without a value (and not set in the environment) count as passed, but not provided
+	passedArgs := make(map[string]struct{})
+	for name := range userArgs {
+		passedArgs[name] = struct{}{}
+	}
+	for _, arg := range c.Params.BuildArgs {
+		name, _, _ := strings.Cut(arg, "=")
+		passedArgs[name] = struct{}{}
+	}
+	passedNames := slices.Sorted(maps.Keys(passedArgs))
+
+	return lintBuildArgs(parsed.AST, parsed.EscapeToken, c.stagesToBuild(df, targetStage), passedNames, providedArgs), nil
+}
+
+// Report build arg issues as warnings, or fail with --strict-build-args.
+func (c *Build) checkBuildArgs() error {
+	issues, err := c.findBuildArgIssues()
+	if err != nil {
+		return fmt.Errorf("checking build args: %w", err)
+	}
+	return reportBuildArgIssues(issues, c.Params.StrictBuildArgs)
+}
+
+// Log the issues, as errors in strict mode (which also returns an error if there are any issues).
+func reportBuildArgIssues(issues []BuildArgIssue, strict bool) error {
+	for _, issue := range issues {
+		if strict {
+			l.Logger.Errorf("Build args: %s", issue)
+		} else {
+			l.Logger.Warnf("Build args: %s", issue)
+		}
+	}
+
+	if strict && len(issues) > 0 {
+		return fmt.Errorf("found %d build arg issue(s), see the log for details", len(issues))
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dfparser "github.com/moby/buildkit/frontend/dockerfile/parser"
+	. "github.com/onsi/gomega"
+)
+
+func Test_lintBuildArgs(t *testing.T) {
+	tests := []struct {
+		name          string
+		containerfile []string
+		builtStages   []int
+		passedArgs    []string
+		providedArgs  map[string]string
+		expected      []BuildArgIssue
+	}{
+		{
+			name: "no issues",
+			containerfile: []string{
+				"ARG BASE=alpine",
+				"FROM $BASE",
+				"ARG BASE",
+				"ARG VERSION",
+				"ARG RELEASE=1",
+				"RUN echo $BASE $VERSION ${RELEASE}",
+			},
+			builtStages:  []int{0},
+			passedArgs:   []string{"VERSION"},
+			providedArgs: map[string]string{"VERSION": "1.0"},
+		},
+		{
+			name: "unused build arg",
+			containerfile: []string{
+				"FROM alpine AS builder",
+				"ARG BUILDER_ONLY",
+				"",
+				"FROM scratch",
+				"ARG VERSION=1",
+			},
+			builtStages:  []int{1},
+			passedArgs:   []string{"BUILDER_ONLY", "HTTPS_PROXY", "UNKNOWN", "VERSION", "no_proxy"},
+			providedArgs: map[string]string{"BUILDER_ONLY": "x", "HTTPS_PROXY": "x", "UNKNOWN": "x", "VERSION": "2", "no_proxy": "x"},
+			expected: []BuildArgIssue{
+				{Kind: BuildArgUnused, Arg: "BUILDER_ONLY", Message: "build arg BUILDER_ONLY is not declared by any ARG in the stages that get built"},
+				{Kind: BuildArgUnused, Arg: "UNKNOWN", Message: "build arg UNKNOWN is not declared by any ARG in the stages that get built"},
+			},
+		},
+		{
+			name: "missing values",
+			containerfile: []string{
+				"ARG BASE",
+				"ARG GLOBAL_DEFAULT=1",
+				"FROM ${BASE}",
+				"ARG GLOBAL_DEFAULT",
+				"ARG NO_DEFAULT EMPTY_DEFAULT=",
+				"ARG TARGETARCH",
+			},
+			builtStages:  []int{0},
+			providedArgs: map[string]string{"TARGETARCH": "amd64"},
+			expected: []BuildArgIssue{
+				{Kind: BuildArgMissing, Arg: "BASE", Line: 3, Message: "ARG BASE has no default value and no value was provided"},
+				{Kind: BuildArgMissing, Arg: "NO_DEFAULT", Line: 5, Message: "ARG NO_DEFAULT has no default value and no value was provided"},
+			},
+		},
+		{
+			name: "undefined in FROM",
+			containerfile: []string{
+				"ARG DEFINED=alpine",
+				"FROM ${DEFINED} AS first",
+				"FROM ${UNDEFINED}",
+				"FROM ${PROVIDED}",
+				"FROM docker.io/library/alpine:${TARGETARCH}",
+			},
+			builtStages:  []int{0, 1, 2, 3},
+			providedArgs: map[string]string{"PROVIDED": "alpine", "TARGETARCH": "amd64"},
+			expected: []BuildArgIssue{
+				{Kind: BuildArgUndefined, Arg: "UNDEFINED", Line: 3, Message: "UNDEFINED is not defined, declare it with an ARG before the first FROM"},
+			},
+		},
+		{
+			name: "used before declaration",
+			containerfile: []string{
+				"ARG GLOBAL=1",
+				"FROM alpine",
+				"ENV FROM_ENV=1",
+				"RUN echo $VERSION $FROM_ENV \\$ESCAPED && \\",
+				"    echo ${VERSION} $GLOBAL",
+				"ARG VERSION=1 SELF=${SELF:-x}",
+				"RUN <<'EOF'",
+				"echo $LATER",
+				"EOF",
+				"ARG LATER=1",
+				"RUN echo $VERSION",
+			},
+			builtStages: []int{0},
+			expected: []BuildArgIssue{
+				{Kind: BuildArgUsedBeforeDeclaration, Arg: "VERSION", Line: 4, Message: "ARG VERSION is used before its declaration in the stage"},
+				{Kind: BuildArgUsedBeforeDeclaration, Arg: "GLOBAL", Line: 4, Message: "ARG GLOBAL is declared before the first FROM, but not in the stage. Add 'ARG GLOBAL' to the stage to use it."},
+				{Kind: BuildArgUsedBeforeDeclaration, Arg: "LATER", Line: 7, Message: "ARG LATER is used before its declaration in the stage"},
+			},
+		},
+		{
+			name: "env inherited from base stage",
+			containerfile: []string{
+				"FROM alpine AS base",
+				"ENV VERSION=1",
+				"",
+				"FROM base",
+				"RUN echo $VERSION",
+				"ARG VERSION=2",
+			},
+			builtStages: []int{0, 1},
+		},
+		{
+			name: "stages that don't get built are skipped",
+			containerfile: []string{
+				"FROM alpine AS unused",
+				"ARG MISSING",
+				"RUN echo $LATER",
+				"ARG LATER=1",
+				"",
+				"FROM scratch",
+			},
+			builtStages: []int{1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			parsed, err := dfparser.Parse(strings.NewReader(strings.Join(tc.containerfile, "\n")))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			issues := lintBuildArgs(parsed.AST, parsed.EscapeToken, tc.builtStages, tc.passedArgs, tc.providedArgs)
+			g.Expect(issues).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_Build_checkBuildArgs(t *testing.T) {
+	content := strings.Join([]string{
+		"FROM alpine AS builder",
+		"ARG BUILDER_VERSION",
+		"",
+		"FROM alpine AS debug",
+		"ARG DEBUG",
+		"",
+		"FROM scratch",
+		"COPY --from=builder /out /out",
+	}, "\n")
+
+	tests := []struct {
+		name             string
+		params           BuildParams
+		expectedErr      string
+		expectedIssueLen int
+	}{
+		{
+			name:   "only reports issues",
+			params: BuildParams{SkipUnusedStages: true, BuildArgs: []string{"DEBUG=1"}},
+			// DEBUG unused (debug stage not built), BUILDER_VERSION missing
+			expectedIssueLen: 2,
+		},
+		{
+			name:             "target changes the built stages",
+			params:           BuildParams{SkipUnusedStages: true, Target: "debug", BuildArgs: []string{"DEBUG=1"}},
+			expectedIssueLen: 0,
+		},
+		{
+			name:             "all stages up to the target are built without skip-unused-stages",
+			params:           BuildParams{SkipUnusedStages: false, BuildArgs: []string{"DEBUG=1", "BUILDER_VERSION=1"}},
+			expectedIssueLen: 0,
+		},
+		{
+			name:             "strict mode fails",
+			params:           BuildParams{SkipUnusedStages: true, StrictBuildArgs: true, BuildArgs: []string{"DEBUG"}},
+			expectedIssueLen: 2,
+			expectedErr:      "found 2 build arg issue(s), see the log for details",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			containerfile := filepath.Join(t.TempDir(), "Containerfile")
+			g.Expect(os.WriteFile(containerfile, []byte(content), 0644)).To(Succeed())
+
+			c := &Build{Params: &tc.params, containerfilePath: containerfile}
+
+			issues, err := c.findBuildArgIssues()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(issues).To(HaveLen(tc.expectedIssueLen))
+
+			err = c.checkBuildArgs()
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(tc.expectedErr))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func Test_Lint_Run(t *testing.T) {
+	g := NewWithT(t)
+
+	contextDir := t.TempDir()
+	content := "FROM alpine\nARG VERSION\nRUN echo $VERSION\n"
+	g.Expect(os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte(content), 0644)).To(Succeed())
+
+	var results LintResults
+	lint := &Lint{
+		Params: &LintParams{Context: contextDir, SkipUnusedStages: true},
+		ResultsWriter: &mockResultsWriter{
+			CreateResultJsonFunc: func(result any) (string, error) {
+				results = result.(LintResults)
+				resultJson, err := json.Marshal(result)
+				return string(resultJson), err
+			},
+		},
+	}
+
+	g.Expect(lint.Run()).To(Succeed())
+	g.Expect(results.BuildArgIssues).To(Equal([]BuildArgIssue{
+		{Kind: BuildArgMissing, Arg: "VERSION", Line: 2, Message: "ARG VERSION has no default value and no value was provided"},
+	}))
+
+	lint.Params.StrictBuildArgs = true
+	g.Expect(lint.Run()).To(MatchError("found 1 build arg issue(s), see the log for details"))
+
+	lint.Params.BuildArgs = []string{"VERSION=1"}
+	g.Expect(lint.Run()).To(Succeed())
+	g.Expect(results.BuildArgIssues).To(BeEmpty())
+
+	// Reported by the lint rather than failing in the build arg expansion
+	lint.Params.BuildArgs = nil
+	content = "FROM ${BASE_IMAGE}\nRUN echo hello\n"
+	g.Expect(os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte(content), 0644)).To(Succeed())
+	g.Expect(lint.Run()).To(MatchError("found 1 build arg issue(s), see the log for details"))
+	g.Expect(results.BuildArgIssues).To(Equal([]BuildArgIssue{
+		{Kind: BuildArgUndefined, Arg: "BASE_IMAGE", Line: 1, Message: "BASE_IMAGE is not defined, declare it with an ARG before the first FROM"},
+	}))
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+)
+
+// Name of the build context used for injecting .konflux-buildinfo, reserved for internal use.
+const buildinfoBuildContextName = ".konflux-buildinfo"
+
+// Parse the --build-contexts values (name=path|docker-image://ref|oci-layout://path).
+// Relative paths are resolved the same way as --context, i.e. relative to --source if set.
+func (c *Build) userBuildContexts() ([]cliWrappers.BuildahBuildContext, error) {
+	var buildContexts []cliWrappers.BuildahBuildContext
+	seen := make(map[string]bool)
+
+	for _, value := range c.Params.BuildContexts {
+		name, location, ok := strings.Cut(value, "=")
+		if !ok || name == "" || location == "" {
+			return nil, fmt.Errorf("invalid build context '%s', expected name=location", value)
+		}
+		if name == buildinfoBuildContextName {
+			return nil, fmt.Errorf("build context name '%s' is reserved", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate build context '%s'", name)
+		}
+		seen[name] = true
+
+		if strings.HasPrefix(location, cliWrappers.BuildContextDockerImagePrefix) {
+			buildContexts = append(buildContexts, cliWrappers.BuildahBuildContext{Name: name, Location: location})
+			continue
+		}
+
+		prefix := ""
+		path := location
+		tag := ""
+		if layout, ok := strings.CutPrefix(location, cliWrappers.BuildContextOCILayoutPrefix); ok {
+			prefix = cliWrappers.BuildContextOCILayoutPrefix
+			path, tag, _ = strings.Cut(layout, ":")
+			if tag != "" {
+				tag = ":" + tag
+			}
+		} else if strings.Contains(location, "://") {
+			return nil, fmt.Errorf("build context '%s': unsupported location '%s'", name, location)
+		}
+
+		if c.Params.Source != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(c.Params.Source, path)
+		}
+		buildContexts = append(buildContexts, cliWrappers.BuildahBuildContext{Name: name, Location: prefix + path + tag})
+	}
+
+	return buildContexts, nil
+}
+
+// Check that build context images are valid and build context directories exist
+// (and are inside the source directory, if --source is set).
+func (c *Build) validateBuildContexts() error {
+	buildContexts, err := c.userBuildContexts()
+	if err != nil {
+		return err
+	}
+
+	for _, buildContext := range buildContexts {
+		if image, ok := buildContextImage(buildContext); ok {
+			if _, err := reference.ParseNormalizedNamed(image); err != nil {
+				return fmt.Errorf("build context '%s': invalid image '%s': %w", buildContext.Name, image, err)
+			}
+			continue
+		}
+
+		path := buildContext.Location
+		if layout, ok := strings.CutPrefix(path, cliWrappers.BuildContextOCILayoutPrefix); ok {
+			path, _, _ = strings.Cut(layout, ":")
+		}
+
+		if stat, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("build context '%s': directory '%s' does not exist", buildContext.Name, path)
+			}
+			return fmt.Errorf("build context '%s': failed to stat directory: %w", buildContext.Name, err)
+		} else if !stat.IsDir() {
+			return fmt.Errorf("build context '%s': path '%s' is not a directory", buildContext.Name, path)
+		}
+
+		if c.Params.Source != "" {
+			resolvedSource, err := common.ResolvePath(c.Params.Source)
+			if err != nil {
+				return fmt.Errorf("resolving source directory: %w", err)
+			}
+			resolvedPath, err := common.ResolvePath(path)
+			if err != nil {
+				return fmt.Errorf("resolving build context '%s': %w", buildContext.Name, err)
+			}
+			if !resolvedPath.IsRelativeTo(resolvedSource) {
+				return fmt.Errorf("build context '%s' is outside source directory '%s'", buildContext.Name, c.Params.Source)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Find the --build-contexts entry with the given name. Invalid entries are ignored,
+// they get reported by validateBuildContexts.
+func (c *Build) findBuildContext(name string) (cliWrappers.BuildahBuildContext, bool) {
+	for _, value := range c.Params.BuildContexts {
+		if contextName, location, _ := strings.Cut(value, "="); contextName == name {
+			return cliWrappers.BuildahBuildContext{Name: contextName, Location: location}, true
+		}
+	}
+	return cliWrappers.BuildahBuildContext{}, false
+}
+
+// If the build context refers to an image (docker-image://<ref>), return the image ref.
+func buildContextImage(buildContext cliWrappers.BuildahBuildContext) (string, bool) {
+	return strings.CutPrefix(buildContext.Location, cliWrappers.BuildContextDockerImagePrefix)
+}
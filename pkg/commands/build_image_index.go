@@ -28,7 +28,7 @@ var BuildImageIndexParamsConfig = map[string]common.Parameter{
 		ShortName:  "",
 		EnvVarName: "KBC_BUILD_IMAGE_INDEX_IMAGES",
 		TypeKind:   reflect.Slice,
-		Usage:      "List of Image Manifests to be referenced by the Image Index.",
+		Usage:      "List of Image Manifests to be referenced by the Image Index.\nImages in OCI layouts can be referenced as oci:<dir>[:<name>][@<digest>].",
 		Required:   true,
 	},
 	"tls-verify": {
@@ -62,6 +62,35 @@ var BuildImageIndexParamsConfig = map[string]common.Parameter{
 		TypeKind:   reflect.Slice,
 		Usage:      "Additional tags to push the image index to (e.g., taskrun name, commit sha).",
 	},
+	"push-destinations": {
+		Name:       "push-destinations",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_IMAGE_INDEX_PUSH_DESTINATIONS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Additional image references (e.g. mirrors) to push the image index to, in parallel with the image.\nThe pushed digests must match.",
+	},
+	"compression-format": {
+		Name:       "compression-format",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_IMAGE_INDEX_COMPRESSION_FORMAT",
+		TypeKind:   reflect.String,
+		Usage:      "Recompress the layers of the images in the index: gzip, zstd or zstd:chunked.\nzstd formats require the oci buildah-format. The pushed images get new digests.",
+	},
+	"compression-level": {
+		Name:       "compression-level",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_IMAGE_INDEX_COMPRESSION_LEVEL",
+		TypeKind:   reflect.Int,
+		Usage:      "Compression level for the compression-format (1-9 for gzip, 1-22 for zstd). Defaults to the format's default level.",
+	},
+	"force-compression": {
+		Name:         "force-compression",
+		ShortName:    "",
+		EnvVarName:   "KBC_BUILD_IMAGE_INDEX_FORCE_COMPRESSION",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Recompress layers that already exist in the registry with a different compression.\nAlways done by buildah if compression-format is set.",
+	},
 	"output-manifest-path": {
 		Name:       "output-manifest-path",
 		ShortName:  "",
@@ -106,6 +135,10 @@ type BuildImageIndexParams struct {
 	BuildahFormat         string   `paramName:"buildah-format"`
 	AlwaysBuildIndex      bool     `paramName:"always-build-index"`
 	AdditionalTags        []string `paramName:"additional-tags"`
+	PushDestinations      []string `paramName:"push-destinations"`
+	CompressionFormat     string   `paramName:"compression-format"`
+	CompressionLevel      int      `paramName:"compression-level"`
+	ForceCompression      bool     `paramName:"force-compression"`
 	OutputManifestPath    string   `paramName:"output-manifest-path"`
 	ResultPathImageDigest string   `paramName:"result-path-image-digest"`
 	ResultPathImageURL    string   `paramName:"result-path-image-url"`
@@ -122,6 +155,10 @@ type BuildImageIndexResults struct {
 	ImageRef string `json:"image_ref"`
 	// Comma-separated list of all referenced image manifests with digests (e.g., "repo@sha256:aaa,repo@sha256:bbb")
 	Images string `json:"images"`
+	// Digests per push destination (the image and --push-destinations), if any --push-destinations are used
+	PushDestinations []PushedDestination `json:"push_destinations,omitempty"`
+	// Compressed layer sizes of the images in the pushed index, as stored in the registry
+	PushedLayers []PushedImageLayers `json:"pushed_layers,omitempty"`
 }
 
 type BuildImageIndexCliWrappers struct {
@@ -233,6 +270,14 @@ func (c *BuildImageIndex) buildManifestIndex() error {
 	}
 
 	for _, imageRef := range c.Params.Images {
+		if common.IsOCILayoutRef(imageRef) {
+			done, err := c.addOCILayoutImage(imageRef)
+			if err != nil || done {
+				return err
+			}
+			continue
+		}
+
 		// Normalize the image reference to strip the tag when both tag and digest are present.
 		// buildah does not support the repository:tag@digest format unless the image is available locally.
 		normalizedRef := common.NormalizeImageRefWithDigest(imageRef)
@@ -271,12 +316,7 @@ func (c *BuildImageIndex) buildManifestIndex() error {
 
 	l.Logger.Infof("Pushing image index to registry: %s", c.Params.Image)
 
-	digest, err := c.CliWrappers.BuildahCli.ManifestPush(&cliwrappers.BuildahManifestPushArgs{
-		ManifestName: c.Params.Image,
-		Destination:  "docker://" + c.Params.Image,
-		Format:       c.Params.BuildahFormat,
-		TLSVerify:    c.Params.TLSVerify,
-	})
+	digest, err := c.pushManifest()
 	if err != nil {
 		return fmt.Errorf("failed to push manifest: %w", err)
 	}
@@ -284,17 +324,24 @@ func (c *BuildImageIndex) buildManifestIndex() error {
 	c.imageDigest = digest
 	l.Logger.Infof("Manifest pushed successfully with digest: %s", digest)
 
+	if c.recompresses() {
+		// The pushed images have recompressed layers and thus different digests than the local manifest list
+		manifestJson, err = inspectPushedManifest(c.CliWrappers.BuildahCli, c.imageName, digest)
+		if err != nil {
+			return fmt.Errorf("failed to inspect pushed manifest: %w", err)
+		}
+	}
+	c.Results.PushedLayers, err = pushedLayers(c.CliWrappers.BuildahCli, c.imageName, digest, manifestJson)
+	if err != nil {
+		l.Logger.Warnf("Failed to read the layer sizes of the pushed images: %s", err.Error())
+	}
+
 	if len(c.Params.AdditionalTags) > 0 {
 		for _, tag := range c.Params.AdditionalTags {
 			additionalImage := c.imageName + ":" + tag
 			l.Logger.Infof("Pushing manifest to additional tag: %s", additionalImage)
 
-			_, err := c.CliWrappers.BuildahCli.ManifestPush(&cliwrappers.BuildahManifestPushArgs{
-				ManifestName: c.Params.Image,
-				Destination:  "docker://" + additionalImage,
-				Format:       c.Params.BuildahFormat,
-				TLSVerify:    c.Params.TLSVerify,
-			})
+			_, err := c.CliWrappers.BuildahCli.ManifestPush(c.manifestPushArgs(additionalImage))
 			if err != nil {
 				return fmt.Errorf("failed to push manifest to additional tag %s: %w", additionalImage, err)
 			}
@@ -318,6 +365,81 @@ func (c *BuildImageIndex) buildManifestIndex() error {
 	return nil
 }
 
+// Push the manifest list to the image and, in parallel, to all the --push-destinations.
+// Returns the pushed digest.
+func (c *BuildImageIndex) pushManifest() (string, error) {
+	push := func(destination string) (string, error) {
+		return c.CliWrappers.BuildahCli.ManifestPush(c.manifestPushArgs(destination))
+	}
+
+	if len(c.Params.PushDestinations) == 0 {
+		return push(c.Params.Image)
+	}
+
+	pushed, err := pushToDestinations(append([]string{c.Params.Image}, c.Params.PushDestinations...), push)
+	if err != nil {
+		return "", err
+	}
+	c.Results.PushDestinations = pushed
+	return pushed[0].Digest, nil
+}
+
+func (c *BuildImageIndex) manifestPushArgs(destination string) *cliwrappers.BuildahManifestPushArgs {
+	return &cliwrappers.BuildahManifestPushArgs{
+		ManifestName:      c.Params.Image,
+		Destination:       "docker://" + destination,
+		Format:            c.Params.BuildahFormat,
+		TLSVerify:         c.Params.TLSVerify,
+		CompressionFormat: c.Params.CompressionFormat,
+		CompressionLevel:  c.Params.CompressionLevel,
+		ForceCompression:  c.Params.ForceCompression,
+	}
+}
+
+// Whether pushing recompresses the layers, see --force-compression in buildah-manifest-push(1).
+func (c *BuildImageIndex) recompresses() bool {
+	return c.Params.CompressionFormat != "" || c.Params.ForceCompression
+}
+
+// Add an image from an OCI layout (oci:<dir>[:<name>][@<digest>]) to the manifest list.
+// Returns true if there is nothing more to do, i.e. for a single image with always-build-index=false.
+func (c *BuildImageIndex) addOCILayoutImage(imageRef string) (bool, error) {
+	layoutRef, err := common.ParseOCILayoutRef(imageRef)
+	if err != nil {
+		return false, err
+	}
+
+	// Special case: single image with always-build-index=false
+	if !c.Params.AlwaysBuildIndex && len(c.Params.Images) == 1 {
+		l.Logger.Info("Skipping image index generation. Returning results for single image.")
+		digest, err := layoutRef.ResolveDigest()
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve image %s: %w", imageRef, err)
+		}
+		c.images = []string{layoutRef.String() + "@" + digest}
+		c.imageDigest = digest
+		c.imageURL = layoutRef.String()
+		return true, nil
+	}
+
+	// The oci: transport can't select images by digest, SourceRef refers to the image by its position instead
+	sourceRef, err := layoutRef.SourceRef()
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve image %s: %w", imageRef, err)
+	}
+
+	l.Logger.Infof("Adding image to manifest: %s", sourceRef)
+	err = c.CliWrappers.BuildahCli.ManifestAdd(&cliwrappers.BuildahManifestAddArgs{
+		ManifestName: c.Params.Image,
+		ImageRef:     sourceRef,
+		All:          true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to add image %s: %w", imageRef, err)
+	}
+	return false, nil
+}
+
 func (c *BuildImageIndex) validateParams() error {
 	imageName := common.GetImageName(c.Params.Image)
 	if !common.IsImageNameValid(imageName) {
@@ -335,13 +457,19 @@ func (c *BuildImageIndex) validateParams() error {
 	// Validate each image reference and check for duplicates
 	seenImages := make(map[string]bool)
 	for _, img := range c.Params.Images {
-		imgName := common.GetImageName(img)
-		if !common.IsImageNameValid(imgName) {
-			return fmt.Errorf("invalid image reference: %s", img)
-		}
+		if common.IsOCILayoutRef(img) {
+			if _, err := common.ParseOCILayoutRef(img); err != nil {
+				return fmt.Errorf("invalid image reference: %w", err)
+			}
+		} else {
+			imgName := common.GetImageName(img)
+			if !common.IsImageNameValid(imgName) {
+				return fmt.Errorf("invalid image reference: %s", img)
+			}
 
-		if err := common.ValidateImageHasTagOrDigest(img); err != nil {
-			return fmt.Errorf("invalid image parameter: %w", err)
+			if err := common.ValidateImageHasTagOrDigest(img); err != nil {
+				return fmt.Errorf("invalid image parameter: %w", err)
+			}
 		}
 
 		// Check for duplicates
@@ -357,11 +485,21 @@ func (c *BuildImageIndex) validateParams() error {
 		}
 	}
 
+	if len(c.Params.PushDestinations) > 0 {
+		if err := validatePushDestinations(append([]string{c.Params.Image}, c.Params.PushDestinations...)); err != nil {
+			return err
+		}
+	}
+
 	validFormats := map[string]bool{"oci": true, "docker": true}
 	if !validFormats[c.Params.BuildahFormat] {
 		return fmt.Errorf("format must be 'oci' or 'docker', got '%s'", c.Params.BuildahFormat)
 	}
 
+	if err := validateCompression(c.Params.BuildahFormat, c.Params.CompressionFormat, c.Params.CompressionLevel); err != nil {
+		return err
+	}
+
 	return nil
 }
 
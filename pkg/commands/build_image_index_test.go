@@ -1,9 +1,15 @@
 package commands
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
 )
 
 func Test_BuildImageIndex_validateParams(t *testing.T) {
@@ -153,6 +159,60 @@ func Test_BuildImageIndex_validateParams(t *testing.T) {
 			errExpected:  true,
 			errSubstring: "duplicate image reference",
 		},
+		{
+			name: "should allow images in OCI layouts",
+			params: BuildImageIndexParams{
+				Image: "quay.io/org/myapp:latest",
+				Images: []string{
+					"oci:/layouts/amd64@" + validDigest1,
+					"oci:/layouts/arm64:v1",
+				},
+				BuildahFormat: "oci",
+			},
+			errExpected: false,
+		},
+		{
+			name: "should fail on invalid OCI layout reference",
+			params: BuildImageIndexParams{
+				Image:         "quay.io/org/myapp:latest",
+				Images:        []string{"oci:/layouts/amd64@sha256:abc"},
+				BuildahFormat: "oci",
+			},
+			errExpected:  true,
+			errSubstring: "invalid image reference: invalid digest in oci:/layouts/amd64@sha256:abc",
+		},
+		{
+			name: "should allow push destinations",
+			params: BuildImageIndexParams{
+				Image:            "quay.io/org/myapp:latest",
+				Images:           []string{"quay.io/org/myapp@" + validDigest1},
+				BuildahFormat:    "oci",
+				PushDestinations: []string{"mirror.example.com/org/myapp:latest"},
+			},
+			errExpected: false,
+		},
+		{
+			name: "should fail on invalid push destination",
+			params: BuildImageIndexParams{
+				Image:            "quay.io/org/myapp:latest",
+				Images:           []string{"quay.io/org/myapp@" + validDigest1},
+				BuildahFormat:    "oci",
+				PushDestinations: []string{"mirror.example.com/org/myapp:"},
+			},
+			errExpected:  true,
+			errSubstring: "push destination 'mirror.example.com/org/myapp:' is invalid",
+		},
+		{
+			name: "should fail on zstd compression with docker format",
+			params: BuildImageIndexParams{
+				Image:             "quay.io/org/myapp:latest",
+				Images:            []string{"quay.io/org/myapp@" + validDigest1},
+				BuildahFormat:     "docker",
+				CompressionFormat: "zstd:chunked",
+			},
+			errExpected:  true,
+			errSubstring: "compression-format zstd:chunked requires the oci format",
+		},
 	}
 
 	for _, tc := range tests {
@@ -341,3 +401,188 @@ func Test_BuildImageIndex_extractPlatformImages(t *testing.T) {
 		})
 	}
 }
+
+func Test_BuildImageIndex_buildManifestIndex_ociLayout(t *testing.T) {
+	const (
+		digestAmd64 = "sha256:1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"
+		digestArm64 = "sha256:fedcba0987654321fedcba0987654321fedcba0987654321fedcba0987654321"
+	)
+
+	layoutDir := t.TempDir()
+	indexJson := `{"schemaVersion": 2, "manifests": [
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestAmd64 + `", "size": 1},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestArm64 + `", "size": 1,
+		 "annotations": {"org.opencontainers.image.ref.name": "arm64"}}
+	]}`
+	if err := os.WriteFile(filepath.Join(layoutDir, "index.json"), []byte(indexJson), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should add images from OCI layouts", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var addedImages []string
+		c := &BuildImageIndex{
+			Params: &BuildImageIndexParams{
+				Image:            "quay.io/org/myapp:latest",
+				Images:           []string{"oci:" + layoutDir + "@" + digestAmd64, "oci:" + layoutDir + ":arm64"},
+				BuildahFormat:    "oci",
+				AlwaysBuildIndex: true,
+			},
+			CliWrappers: BuildImageIndexCliWrappers{BuildahCli: &mockBuildahCli{
+				ManifestAddFunc: func(args *cliwrappers.BuildahManifestAddArgs) error {
+					addedImages = append(addedImages, args.ImageRef)
+					return nil
+				},
+				ManifestInspectFunc: func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+					return `{"manifests": []}`, nil
+				},
+			}},
+		}
+		c.imageName = "quay.io/org/myapp"
+
+		g.Expect(c.buildManifestIndex()).To(Succeed())
+		g.Expect(addedImages).To(Equal([]string{"oci:" + layoutDir + ":@0", "oci:" + layoutDir + ":arm64"}))
+	})
+
+	t.Run("should resolve the digest of a single image without building an index", func(t *testing.T) {
+		g := NewWithT(t)
+
+		c := &BuildImageIndex{
+			Params: &BuildImageIndexParams{
+				Image:            "quay.io/org/myapp:latest",
+				Images:           []string{"oci:" + layoutDir + ":arm64"},
+				BuildahFormat:    "oci",
+				AlwaysBuildIndex: false,
+			},
+			CliWrappers: BuildImageIndexCliWrappers{BuildahCli: &mockBuildahCli{}},
+		}
+
+		g.Expect(c.buildManifestIndex()).To(Succeed())
+		g.Expect(c.imageDigest).To(Equal(digestArm64))
+		g.Expect(c.imageURL).To(Equal("oci:" + layoutDir + ":arm64"))
+		g.Expect(c.images).To(Equal([]string{"oci:" + layoutDir + ":arm64@" + digestArm64}))
+	})
+}
+
+func Test_BuildImageIndex_buildManifestIndex_pushDestinations(t *testing.T) {
+	const (
+		digestAmd64 = "sha256:1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"
+		digestIndex = "sha256:fedcba0987654321fedcba0987654321fedcba0987654321fedcba0987654321"
+	)
+
+	newBuildImageIndex := func(pushFunc func(args *cliwrappers.BuildahManifestPushArgs) (string, error)) *BuildImageIndex {
+		c := &BuildImageIndex{
+			Params: &BuildImageIndexParams{
+				Image:            "quay.io/org/myapp:latest",
+				Images:           []string{"quay.io/org/myapp@" + digestAmd64},
+				BuildahFormat:    "oci",
+				AlwaysBuildIndex: true,
+				PushDestinations: []string{"mirror.example.com/org/myapp:latest"},
+			},
+			CliWrappers: BuildImageIndexCliWrappers{BuildahCli: &mockBuildahCli{
+				ManifestInspectFunc: func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+					return `{"manifests": []}`, nil
+				},
+				ManifestPushFunc: pushFunc,
+			}},
+		}
+		c.imageName = "quay.io/org/myapp"
+		return c
+	}
+
+	t.Run("should push the index to all push destinations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var mu sync.Mutex
+		var destinations []string
+		c := newBuildImageIndex(func(args *cliwrappers.BuildahManifestPushArgs) (string, error) {
+			g.Expect(args.ManifestName).To(Equal("quay.io/org/myapp:latest"))
+			mu.Lock()
+			defer mu.Unlock()
+			destinations = append(destinations, args.Destination)
+			return digestIndex, nil
+		})
+
+		g.Expect(c.buildManifestIndex()).To(Succeed())
+		g.Expect(destinations).To(ConsistOf("docker://quay.io/org/myapp:latest", "docker://mirror.example.com/org/myapp:latest"))
+		g.Expect(c.imageDigest).To(Equal(digestIndex))
+		g.Expect(c.Results.PushDestinations).To(Equal([]PushedDestination{
+			{Image: "quay.io/org/myapp:latest", Digest: digestIndex},
+			{Image: "mirror.example.com/org/myapp:latest", Digest: digestIndex},
+		}))
+	})
+
+	t.Run("should fail if the pushed digests differ", func(t *testing.T) {
+		g := NewWithT(t)
+
+		c := newBuildImageIndex(func(args *cliwrappers.BuildahManifestPushArgs) (string, error) {
+			if args.Destination == "docker://mirror.example.com/org/myapp:latest" {
+				return digestAmd64, nil
+			}
+			return digestIndex, nil
+		})
+
+		err := c.buildManifestIndex()
+		g.Expect(err).To(MatchError(ContainSubstring("digests differ across push destinations")))
+	})
+}
+
+func Test_BuildImageIndex_buildManifestIndex_compression(t *testing.T) {
+	const (
+		digestLocal  = "sha256:1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"
+		digestPushed = "sha256:fedcba0987654321fedcba0987654321fedcba0987654321fedcba0987654321"
+		digestIndex  = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	)
+
+	t.Run("should recompress and report the images in the pushed index", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var pushArgs []*cliwrappers.BuildahManifestPushArgs
+		c := &BuildImageIndex{
+			Params: &BuildImageIndexParams{
+				Image:             "quay.io/org/myapp:latest",
+				Images:            []string{"quay.io/org/myapp@" + digestLocal},
+				BuildahFormat:     "oci",
+				AlwaysBuildIndex:  true,
+				AdditionalTags:    []string{"v1"},
+				CompressionFormat: "zstd:chunked",
+			},
+			CliWrappers: BuildImageIndexCliWrappers{BuildahCli: &mockBuildahCli{
+				ManifestInspectFunc: func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+					switch args.ManifestName {
+					case "quay.io/org/myapp:latest":
+						return `{"manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestLocal + `"}]}`, nil
+					case "docker://quay.io/org/myapp@" + digestIndex:
+						return `{"manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestPushed + `",
+							"platform": {"os": "linux", "architecture": "amd64"}}]}`, nil
+					case "docker://quay.io/org/myapp@" + digestPushed:
+						return `{"layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+zstd", "digest": "sha256:aaaa", "size": 42}]}`, nil
+					}
+					return "", fmt.Errorf("unexpected manifest %s", args.ManifestName)
+				},
+				ManifestPushFunc: func(args *cliwrappers.BuildahManifestPushArgs) (string, error) {
+					pushArgs = append(pushArgs, args)
+					return digestIndex, nil
+				},
+			}},
+		}
+		c.imageName = "quay.io/org/myapp"
+
+		g.Expect(c.buildManifestIndex()).To(Succeed())
+
+		g.Expect(pushArgs).To(HaveLen(2))
+		for _, args := range pushArgs {
+			g.Expect(args.CompressionFormat).To(Equal("zstd:chunked"))
+		}
+		g.Expect(pushArgs[1].Destination).To(Equal("docker://quay.io/org/myapp:v1"))
+
+		g.Expect(c.images).To(Equal([]string{"quay.io/org/myapp@" + digestPushed}))
+		g.Expect(c.Results.PushedLayers).To(Equal([]PushedImageLayers{{
+			Image:     "quay.io/org/myapp@" + digestPushed,
+			Platform:  "linux/amd64",
+			Layers:    []PushedLayer{{Digest: "sha256:aaaa", MediaType: "application/vnd.oci.image.layer.v1.tar+zstd", Size: 42}},
+			TotalSize: 42,
+		}}))
+	})
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/config"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// The host:port destinations of the package registry proxies from the Konflux config,
+// empty if the cluster doesn't allow them.
+func packageRegistryProxyDestinations() ([]string, error) {
+	konfluxConfig, err := config.GetKonfluxConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxyConfig := konfluxConfig.HermetoProxy
+	if proxyConfig == nil || !proxyConfig.PackageRegistryProxyAllowed {
+		l.Logger.Info("Not allowing the package registry proxy because allow-package-registry-proxy " +
+			"is not set to `true` on the cluster level")
+		return nil, nil
+	}
+
+	var destinations []string
+	for _, proxyURL := range []string{proxyConfig.NpmProxy, proxyConfig.YarnProxy} {
+		if proxyURL == "" {
+			continue
+		}
+		destination, err := urlDestination(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid package registry proxy URL '%s'", proxyURL)
+		}
+		if !slices.Contains(destinations, destination) {
+			destinations = append(destinations, destination)
+		}
+	}
+	return destinations, nil
+}
+
+// Collect the network-allow destinations and start the egress proxy for them.
+// Does nothing unless network-allow is used. In dry run, the proxy is not started.
+func (c *Build) setupEgressProxy() error {
+	if len(c.Params.NetworkAllow) == 0 && !c.Params.NetworkAllowPackageRegistryProxy {
+		return nil
+	}
+
+	destinations, err := parseNetworkDestinations(c.Params.NetworkAllow)
+	if err != nil {
+		return err
+	}
+	if c.Params.NetworkAllowPackageRegistryProxy {
+		proxyDestinations, err := packageRegistryProxyDestinations()
+		if err != nil {
+			return fmt.Errorf("getting package registry proxy configuration: %w", err)
+		}
+		for _, destination := range proxyDestinations {
+			if !slices.Contains(destinations, destination) {
+				destinations = append(destinations, destination)
+			}
+		}
+	}
+	c.networkAllow = destinations
+
+	if len(destinations) == 0 {
+		l.Logger.Warnf("network-allow: no destinations to allow, the build has no network access")
+		return nil
+	}
+
+	if c.Params.DryRun {
+		l.Logger.Warnf("Dry run, not starting the egress proxy")
+		return nil
+	}
+
+	proxyDir, err := c.createSocketDir("kbc-egress-")
+	if err != nil {
+		return fmt.Errorf("creating egress proxy directory: %w", err)
+	}
+	c.egressSocket = filepath.Join(proxyDir, "egress.sock")
+
+	proxy, err := startEgressProxy(c.egressSocket, destinations)
+	if err != nil {
+		return err
+	}
+	c.egressProxy = proxy
+	l.Logger.Infof("Started egress proxy, the hermetic build can reach: %v", destinations)
+	return nil
+}
+
+// Stop the egress proxy. The network report is normally written after the build,
+// for failed builds it gets written here.
+func (c *Build) stopEgressProxy() {
+	if c.egressProxy == nil {
+		return
+	}
+	if err := c.egressProxy.Close(); err != nil {
+		l.Logger.Warnf("Failed to stop the egress proxy: %s", err)
+	}
+
+	if !c.networkReportWritten {
+		if err := c.writeNetworkReport(); err != nil {
+			l.Logger.Warnf("Failed to write network report: %s", err)
+		}
+	}
+	c.egressProxy = nil
+}
+
+// Write the connection attempts through the egress proxy to network-report-output.
+func (c *Build) writeNetworkReport() error {
+	if c.Params.NetworkReportOutput == "" {
+		return nil
+	}
+	c.networkReportWritten = true
+
+	report, err := json.MarshalIndent(c.egressProxy.Attempts(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.Params.NetworkReportOutput, report, 0644); err != nil {
+		return fmt.Errorf("writing network report to %s: %w", c.Params.NetworkReportOutput, err)
+	}
+	return nil
+}
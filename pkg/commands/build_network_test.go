@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/config"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_Build_setupEgressProxy(t *testing.T) {
+	originalGetKonfluxConfig := config.GetKonfluxConfig
+	defer func() { config.GetKonfluxConfig = originalGetKonfluxConfig }()
+
+	mockKonfluxConfig := func(hermetoProxy *config.HermetoProxyConfig) {
+		config.GetKonfluxConfig = func() (*config.KonfluxConfig, error) {
+			return &config.KonfluxConfig{HermetoProxy: hermetoProxy}, nil
+		}
+	}
+
+	t.Run("should do nothing without network-allow", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &Build{Params: &BuildParams{Hermetic: true}}
+
+		g.Expect(c.setupEgressProxy()).To(Succeed())
+		g.Expect(c.egressSocket).To(BeEmpty())
+		g.Expect(c.egressProxy).To(BeNil())
+	})
+
+	t.Run("should add the package registry proxies when allowed", func(t *testing.T) {
+		g := NewWithT(t)
+		mockKonfluxConfig(&config.HermetoProxyConfig{
+			PackageRegistryProxyAllowed: true,
+			NpmProxy:                    "https://npm-proxy.example.com/npm",
+			YarnProxy:                   "http://yarn-proxy.example.com:8080",
+		})
+		c := &Build{Params: &BuildParams{
+			Hermetic:                         true,
+			DryRun:                           true,
+			NetworkAllow:                     []string{"npm-proxy.example.com:443", "pypi.org:443"},
+			NetworkAllowPackageRegistryProxy: true,
+		}}
+		defer c.cleanup()
+
+		g.Expect(c.setupEgressProxy()).To(Succeed())
+		g.Expect(c.networkAllow).To(Equal([]string{
+			"npm-proxy.example.com:443", "pypi.org:443", "yarn-proxy.example.com:8080",
+		}))
+		// Not started in dry run
+		g.Expect(c.egressSocket).To(BeEmpty())
+		g.Expect(c.egressProxy).To(BeNil())
+		g.Expect(c.tempFilesOutsideWorkdir).To(BeEmpty())
+	})
+
+	t.Run("should not add the package registry proxies when the cluster doesn't allow them", func(t *testing.T) {
+		g := NewWithT(t)
+		mockKonfluxConfig(&config.HermetoProxyConfig{NpmProxy: "https://npm-proxy.example.com"})
+		c := &Build{Params: &BuildParams{Hermetic: true, NetworkAllowPackageRegistryProxy: true}}
+		defer c.cleanup()
+
+		g.Expect(c.setupEgressProxy()).To(Succeed())
+		g.Expect(c.networkAllow).To(BeEmpty())
+		g.Expect(c.egressSocket).To(BeEmpty())
+	})
+
+	t.Run("should start the proxy and write the report in cleanup", func(t *testing.T) {
+		g := NewWithT(t)
+		reportPath := filepath.Join(t.TempDir(), "network-report.json")
+		c := &Build{
+			Params: &BuildParams{
+				Hermetic:            true,
+				NetworkAllow:        []string{"pypi.org:443"},
+				NetworkReportOutput: reportPath,
+			},
+			CliWrappers: BuildCliWrappers{
+				Unshare:             cliWrappers.NewWrapperCmd("unshare"),
+				BuildahUnshare:      cliWrappers.NewWrapperCmd("buildah", "unshare"),
+				SelfInUserNamespace: cliWrappers.NewWrapperCmd("kbc", "internal", "in-user-namespace"),
+			},
+		}
+
+		g.Expect(c.setupEgressProxy()).To(Succeed())
+		g.Expect(c.egressProxy).ToNot(BeNil())
+		_, args := c.chooseBuildahWrappers().Wrap("buildah", []string{"build"})
+		g.Expect(args).To(ContainElements("--loopback-up", "--egress-proxy-socket", c.egressSocket))
+
+		c.cleanup()
+		g.Expect(c.egressSocket).ToNot(BeAnExistingFile())
+
+		content, err := os.ReadFile(reportPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		var attempts []NetworkAccessAttempt
+		g.Expect(json.Unmarshal(content, &attempts)).To(Succeed())
+		g.Expect(attempts).To(BeEmpty())
+	})
+}
+
+func Test_Build_Run_networkAccess(t *testing.T) {
+	epoch := time.Unix(1700000000, 0).UTC()
+
+	newBuild := func(t *testing.T, reportPath string) *Build {
+		g := NewWithT(t)
+
+		contextDir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte("FROM scratch"), 0644)).To(Succeed())
+
+		var c *Build
+		mockBuildah := &mockBuildahCli{
+			// Every build tries to reach a denied destination through the egress proxy
+			BuildFunc: func(args *cliWrappers.BuildahBuildArgs) error {
+				proxyURL, _ := url.Parse("http://egress")
+				client := &http.Client{Transport: &http.Transport{
+					Proxy: http.ProxyURL(proxyURL),
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						return (&net.Dialer{}).DialContext(ctx, "unix", c.egressSocket)
+					},
+				}}
+				resp, err := client.Get("http://denied.example.com/")
+				g.Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				g.Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				return nil
+			},
+			PushFunc: func(args *cliWrappers.BuildahPushArgs) (string, error) {
+				if dir, ok := strings.CutPrefix(args.Destination, "oci:"); ok {
+					writeTestOCILayout(t, dir, "2023-11-14T22:13:20Z",
+						[]testLayerFile{{name: "file", content: "same", mode: 0644, modTime: epoch}})
+				}
+				return "sha256:1234567890abcdef", nil
+			},
+		}
+
+		c = &Build{
+			Params: &BuildParams{
+				OutputRef:            "quay.io/org/image:tag",
+				Context:              contextDir,
+				Hermetic:             true,
+				NetworkAllow:         []string{"pypi.org:443"},
+				NetworkReportOutput:  reportPath,
+				SourceDateEpoch:      "1700000000",
+				RewriteTimestamp:     true,
+				ReproducibilityCheck: true,
+				SkipInjections:       true,
+			},
+			CliWrappers: BuildCliWrappers{
+				BuildahCli:          mockBuildah,
+				Unshare:             cliWrappers.NewWrapperCmd("unshare"),
+				BuildahUnshare:      cliWrappers.NewWrapperCmd("buildah", "unshare"),
+				SelfInUserNamespace: cliWrappers.NewWrapperCmd("kbc", "internal", "in-user-namespace"),
+			},
+		}
+		return c
+	}
+
+	t.Run("should report the network access of both builds", func(t *testing.T) {
+		g := NewWithT(t)
+		reportPath := filepath.Join(t.TempDir(), "network-report.json")
+		c := newBuild(t, reportPath)
+		c.ResultsWriter = &mockResultsWriter{
+			CreateResultJsonFunc: func(result any) (string, error) {
+				// Written before the results, not in cleanup
+				g.Expect(reportPath).To(BeARegularFile())
+				return "", nil
+			},
+		}
+
+		g.Expect(c.Run()).To(Succeed())
+		// The attempts of the reproducibility check build are included
+		g.Expect(c.Results.NetworkAccess).To(Equal(&NetworkAccessSummary{
+			Denied:             2,
+			DeniedDestinations: []string{"denied.example.com:80"},
+		}))
+
+		content, err := os.ReadFile(reportPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		var attempts []NetworkAccessAttempt
+		g.Expect(json.Unmarshal(content, &attempts)).To(Succeed())
+		g.Expect(attempts).To(HaveLen(2))
+	})
+
+	t.Run("should fail when the network report can't be written", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newBuild(t, filepath.Join(t.TempDir(), "missing", "network-report.json"))
+		c.ResultsWriter = &mockResultsWriter{}
+
+		g.Expect(c.Run()).To(MatchError(ContainSubstring("writing network report")))
+	})
+}
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// Kinds of --output destinations.
+const (
+	outputOCILayout     = "oci-layout"
+	outputOCIArchive    = "oci-archive"
+	outputDockerArchive = "docker-archive"
+)
+
+// A parsed --output value: <kind>:<path>[:<name>]
+type buildOutput struct {
+	kind string
+	path string
+	// Value of the org.opencontainers.image.ref.name annotation, only for OCI layouts and archives
+	name string
+}
+
+func parseBuildOutput(output string) (buildOutput, error) {
+	kind, location, ok := strings.Cut(output, ":")
+	if !ok {
+		return buildOutput{}, fmt.Errorf("output '%s' is invalid, expected <type>:<path>", output)
+	}
+
+	var parsed buildOutput
+	switch kind {
+	case outputOCILayout, outputOCIArchive:
+		parsed = buildOutput{kind: kind}
+		parsed.path, parsed.name, _ = strings.Cut(location, ":")
+	case outputDockerArchive:
+		parsed = buildOutput{kind: kind, path: location}
+		if strings.Contains(location, ":") {
+			return buildOutput{}, fmt.Errorf("output '%s' is invalid, docker-archive doesn't support a name (the output-ref is used)", output)
+		}
+	default:
+		return buildOutput{}, fmt.Errorf("output '%s' is invalid, the type must be one of %s, %s, %s",
+			output, outputOCILayout, outputOCIArchive, outputDockerArchive)
+	}
+
+	if parsed.path == "" {
+		return buildOutput{}, fmt.Errorf("output '%s' is invalid, the path is empty", output)
+	}
+	return parsed, nil
+}
+
+// The buildah push destination (in the containers-transports(5) format).
+func (o buildOutput) destination(outputRef string) string {
+	switch o.kind {
+	case outputOCILayout:
+		return common.OCILayoutRef{Dir: o.path, Image: o.name}.String()
+	case outputDockerArchive:
+		// Include the image name in the archive, so that 'docker load' / 'podman load' tags the image
+		return "docker-archive:" + o.path + ":" + outputRef
+	default:
+		if o.name == "" {
+			return o.kind + ":" + o.path
+		}
+		return o.kind + ":" + o.path + ":" + o.name
+	}
+}
+
+// Write the built image to the --output destination.
+// Returns the image URL for the build results and the digest of the written image.
+//
+// For OCI layouts, the image URL is an oci: reference that other commands (e.g. build-image-index)
+// accept as input. For archives, it's the --output value.
+func (c *Build) writeOutput() (string, string, error) {
+	output, err := parseBuildOutput(c.Params.Output)
+	if err != nil {
+		return "", "", err
+	}
+
+	destination := output.destination(c.Params.OutputRef)
+	l.Logger.Infof("Writing image to: %s", destination)
+
+	reportedDigest, err := c.CliWrappers.BuildahCli.Push(c.pushArgs(destination))
+	if err != nil {
+		return "", "", err
+	}
+
+	digest, err := output.writtenDigest(reportedDigest)
+	if err != nil {
+		return "", "", fmt.Errorf("determining digest of the written image: %w", err)
+	}
+	if digest != reportedDigest {
+		l.Logger.Warnf("Digest reported by buildah (%s) differs from the written manifest digest (%s)", reportedDigest, digest)
+	}
+
+	l.Logger.Info("Image written successfully")
+	l.Logger.Infof("Image digest: %s", digest)
+
+	imageUrl := c.Params.Output
+	if output.kind == outputOCILayout {
+		imageUrl = common.OCILayoutRef{Dir: output.path, Image: output.name}.String()
+	}
+	return imageUrl, digest, nil
+}
+
+// Determine the digest of the manifest written to the output, by looking it up in the index.json
+// of the OCI layout or archive. If the output doesn't have a name, there may be more images in
+// the layout, in which case the lookup only verifies that the reported digest is there.
+//
+// Docker archives don't store the manifest, so the digest reported by buildah is used as is.
+func (o buildOutput) writtenDigest(reportedDigest string) (string, error) {
+	lookupDigest := ""
+	if o.name == "" {
+		lookupDigest = reportedDigest
+	}
+
+	switch o.kind {
+	case outputOCILayout:
+		return common.OCILayoutRef{Dir: o.path, Image: o.name, Digest: lookupDigest}.ResolveDigest()
+	case outputOCIArchive:
+		index, err := common.ReadOCIArchiveIndex(o.path)
+		if err != nil {
+			return "", err
+		}
+		_, descriptor, err := common.FindOCIIndexDescriptor(index, o.name, lookupDigest)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", o.path, err)
+		}
+		return descriptor.Digest.String(), nil
+	default:
+		return reportedDigest, nil
+	}
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func Test_parseBuildOutput(t *testing.T) {
+	tests := []struct {
+		name                string
+		output              string
+		expected            buildOutput
+		expectedDestination string
+		expectedErr         string
+	}{
+		{
+			name:                "oci layout",
+			output:              "oci-layout:/out/layout",
+			expected:            buildOutput{kind: outputOCILayout, path: "/out/layout"},
+			expectedDestination: "oci:/out/layout",
+		},
+		{
+			name:                "oci layout with name",
+			output:              "oci-layout:out:v1",
+			expected:            buildOutput{kind: outputOCILayout, path: "out", name: "v1"},
+			expectedDestination: "oci:out:v1",
+		},
+		{
+			name:                "oci archive with name",
+			output:              "oci-archive:/out/image.tar:v1",
+			expected:            buildOutput{kind: outputOCIArchive, path: "/out/image.tar", name: "v1"},
+			expectedDestination: "oci-archive:/out/image.tar:v1",
+		},
+		{
+			name:                "docker archive",
+			output:              "docker-archive:/out/image.tar",
+			expected:            buildOutput{kind: outputDockerArchive, path: "/out/image.tar"},
+			expectedDestination: "docker-archive:/out/image.tar:quay.io/org/image:tag",
+		},
+		{
+			name:        "docker archive with name",
+			output:      "docker-archive:/out/image.tar:v1",
+			expectedErr: "output 'docker-archive:/out/image.tar:v1' is invalid, docker-archive doesn't support a name (the output-ref is used)",
+		},
+		{
+			name:        "unknown type",
+			output:      "dir:/out",
+			expectedErr: "output 'dir:/out' is invalid, the type must be one of oci-layout, oci-archive, docker-archive",
+		},
+		{
+			name:        "missing type",
+			output:      "/out",
+			expectedErr: "output '/out' is invalid, expected <type>:<path>",
+		},
+		{
+			name:        "missing path",
+			output:      "oci-layout::v1",
+			expectedErr: "output 'oci-layout::v1' is invalid, the path is empty",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			output, err := parseBuildOutput(tc.output)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(tc.expectedErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(output).To(Equal(tc.expected))
+			g.Expect(output.destination("quay.io/org/image:tag")).To(Equal(tc.expectedDestination))
+		})
+	}
+}
+
+func Test_Build_writeOutput(t *testing.T) {
+	const (
+		digestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		digestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+
+	indexJson := `{"schemaVersion": 2, "manifests": [
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestA + `", "size": 1,
+		 "annotations": {"org.opencontainers.image.ref.name": "v1"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestB + `", "size": 1}
+	]}`
+
+	tests := []struct {
+		name           string
+		output         string
+		reportedDigest string
+		expectedUrl    string
+		expectedDigest string
+		expectedErr    string
+	}{
+		{
+			name:           "named image in oci layout",
+			output:         "oci-layout:%s:v1",
+			reportedDigest: digestB,
+			expectedUrl:    "oci:%s:v1",
+			expectedDigest: digestA,
+		},
+		{
+			name:           "unnamed image in oci layout",
+			output:         "oci-layout:%s",
+			reportedDigest: digestB,
+			expectedUrl:    "oci:%s",
+			expectedDigest: digestB,
+		},
+		{
+			name:           "reported digest not in oci layout",
+			output:         "oci-layout:%s",
+			reportedDigest: "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc",
+			expectedErr:    "no image matches digest",
+		},
+		{
+			name:           "docker archive",
+			output:         "docker-archive:%s/image.tar",
+			reportedDigest: digestB,
+			expectedUrl:    "docker-archive:%s/image.tar",
+			expectedDigest: digestB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			outputDir := t.TempDir()
+			g.Expect(os.WriteFile(filepath.Join(outputDir, "index.json"), []byte(indexJson), 0644)).To(Succeed())
+			expand := func(s string) string { return strings.ReplaceAll(s, "%s", outputDir) }
+
+			var pushArgs *cliwrappers.BuildahPushArgs
+			c := &Build{
+				Params: &BuildParams{OutputRef: "quay.io/org/image:tag", Output: expand(tc.output)},
+				CliWrappers: BuildCliWrappers{BuildahCli: &mockBuildahCli{
+					PushFunc: func(args *cliwrappers.BuildahPushArgs) (string, error) {
+						pushArgs = args
+						return tc.reportedDigest, nil
+					},
+				}},
+			}
+
+			imageUrl, digest, err := c.writeOutput()
+			g.Expect(pushArgs.Image).To(Equal("quay.io/org/image:tag"))
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(imageUrl).To(Equal(expand(tc.expectedUrl)))
+			g.Expect(digest).To(Equal(tc.expectedDigest))
+		})
+	}
+}
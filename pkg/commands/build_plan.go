@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keilerkonzept/dockerfile-json/pkg/dockerfile"
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// BuildPlan describes what 'image build' would do, see the --dry-run parameter.
+type BuildPlan struct {
+	// The 'buildah build' command, including the wrapper commands
+	Command       []string                `json:"command"`
+	Volumes       []BuildPlanVolume       `json:"volumes"`
+	Secrets       []BuildPlanSecret       `json:"secrets"`
+	BuildContexts []BuildPlanBuildContext `json:"buildContexts"`
+	// Unified diff between the original Containerfile and the modified copy used for the build,
+	// empty if the Containerfile is used as is
+	ContainerfileDiff string `json:"containerfileDiff,omitempty"`
+	// Content of the injected buildinfo files
+	Labels      json.RawMessage `json:"labels,omitempty"`
+	ContentSets json.RawMessage `json:"contentSets,omitempty"`
+	// Images that would be pre-pulled before the build
+	BaseImages []string `json:"baseImages"`
+	// Destinations the hermetic build could reach through the egress proxy, see --network-allow
+	NetworkAllow []string `json:"networkAllow,omitempty"`
+}
+
+type BuildPlanVolume struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Options string `json:"options,omitempty"`
+}
+
+type BuildPlanSecret struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	// "env" if the source is an environment variable, empty for files
+	Type string `json:"type,omitempty"`
+}
+
+type BuildPlanBuildContext struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// Create the plan from the state prepared by Run. Must be called before cleanup,
+// the buildinfo files and the Containerfile copy live in the temporary workdir.
+func (c *Build) createBuildPlan(df *dockerfile.Dockerfile) (*BuildPlan, error) {
+	buildArgs, err := c.createBuildahBuildArgs()
+	if err != nil {
+		return nil, err
+	}
+	executable, args := buildArgs.Command()
+
+	plan := &BuildPlan{
+		Command:       append([]string{executable}, args...),
+		Volumes:       []BuildPlanVolume{},
+		Secrets:       []BuildPlanSecret{},
+		BuildContexts: []BuildPlanBuildContext{},
+		BaseImages:    []string{},
+	}
+	for _, volume := range buildArgs.Volumes {
+		plan.Volumes = append(plan.Volumes, BuildPlanVolume{Source: volume.HostDir, Target: volume.ContainerDir, Options: volume.Options})
+	}
+	for _, secret := range buildArgs.Secrets {
+		plan.Secrets = append(plan.Secrets, BuildPlanSecret{ID: secret.Id, Source: secret.Src, Type: secret.Type})
+	}
+	for _, buildContext := range buildArgs.BuildContexts {
+		plan.BuildContexts = append(plan.BuildContexts, BuildPlanBuildContext{Name: buildContext.Name, Source: buildContext.Location})
+	}
+
+	if c.containerfileCopyPath != "" {
+		original, err := os.ReadFile(c.containerfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading containerfile: %w", err)
+		}
+		modified, err := os.ReadFile(c.containerfileCopyPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading containerfile copy: %w", err)
+		}
+		plan.ContainerfileDiff = unifiedDiff(c.containerfilePath, c.containerfileCopyPath, string(original), string(modified))
+	}
+
+	if c.buildinfoBuildContext != nil {
+		if plan.Labels, err = readBuildinfoJSON(c.buildinfoBuildContext.Location, "labels.json"); err != nil {
+			return nil, err
+		}
+		if plan.ContentSets, err = readBuildinfoJSON(c.buildinfoBuildContext.Location, "content-sets.json"); err != nil {
+			return nil, err
+		}
+	}
+
+	baseImages, err := c.baseImagesToPull(df)
+	if err != nil {
+		return nil, err
+	}
+	plan.BaseImages = append(plan.BaseImages, baseImages...)
+	plan.NetworkAllow = c.networkAllow
+
+	return plan, nil
+}
+
+// Read a file written by writeBuildinfoJSON, returns nil if the file doesn't exist.
+func readBuildinfoJSON(buildinfoDir string, filename string) (json.RawMessage, error) {
+	content, err := os.ReadFile(filepath.Join(buildinfoDir, filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s from buildinfo dir: %w", filename, err)
+	}
+	return json.RawMessage(content), nil
+}
+
+func logBuildPlan(plan *BuildPlan) {
+	quoted := make([]string, len(plan.Command))
+	for i, arg := range plan.Command {
+		quoted[i] = cliWrappers.ShellQuote(arg)
+	}
+	l.Logger.Infof("Dry run, would run:\n%s", strings.Join(quoted, " "))
+	if plan.ContainerfileDiff != "" {
+		l.Logger.Infof("Dry run, containerfile modifications:\n%s", plan.ContainerfileDiff)
+	}
+	for _, image := range plan.BaseImages {
+		l.Logger.Infof("Dry run, would pre-pull: %s", image)
+	}
+}
+
+// Number of unchanged lines shown around each change in unifiedDiff.
+const diffContextLines = 3
+
+// Compute a unified diff of two texts, in the format of 'diff -u'. Returns an empty string
+// if the texts are equal. Uses a simple LCS table, which is fine for Containerfile-sized inputs.
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a := splitLines(from)
+	b := splitLines(to)
+
+	// lcs[i][j] = length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte // ' ', '-' or '+'
+		text string
+		// line numbers (0-based) in a and b before this line
+		aPos, bPos int
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(lines); {
+		// find the next change
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		hunkStart := max(start-diffContextLines, 0)
+		// extend the hunk while changes are close enough to share context
+		end := start
+		for k := start; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k
+			} else if k-end > 2*diffContextLines {
+				break
+			}
+		}
+		hunkEnd := min(end+diffContextLines+1, len(lines))
+
+		var aCount, bCount int
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(lines[hunkStart].aPos, aCount), hunkRange(lines[hunkStart].bPos, bCount))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+
+	return out.String()
+}
+
+// Format a hunk range, empty ranges refer to the line before them as in 'diff -u'.
+func hunkRange(pos, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", pos)
+	case 1:
+		return fmt.Sprintf("%d", pos+1)
+	default:
+		return fmt.Sprintf("%d,%d", pos+1, count)
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/testutil"
+	. "github.com/onsi/gomega"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected string
+	}{
+		{
+			name:     "equal texts",
+			from:     "FROM scratch\n",
+			to:       "FROM scratch\n",
+			expected: "",
+		},
+		{
+			name: "appended lines",
+			from: "FROM scratch\nRUN echo hello\n",
+			to:   "FROM scratch\nRUN echo hello\n\nCOPY --from=.konflux-buildinfo . /usr/share/buildinfo/\n",
+			expected: "--- a/Containerfile\n+++ b/Containerfile\n" +
+				"@@ -1,2 +1,4 @@\n" +
+				" FROM scratch\n" +
+				" RUN echo hello\n" +
+				"+\n" +
+				"+COPY --from=.konflux-buildinfo . /usr/share/buildinfo/\n",
+		},
+		{
+			name: "changed line keeps three lines of context",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			to:   "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- a/Containerfile\n+++ b/Containerfile\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "distant changes in separate hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n",
+			to:   "1\nX\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\nY\n15\n",
+			expected: "--- a/Containerfile\n+++ b/Containerfile\n" +
+				"@@ -1,5 +1,5 @@\n" +
+				" 1\n-2\n+X\n 3\n 4\n 5\n" +
+				"@@ -11,5 +11,5 @@\n" +
+				" 11\n 12\n 13\n-14\n+Y\n 15\n",
+		},
+		{
+			name: "removed line",
+			from: "a\nb\nc\n",
+			to:   "b\nc\n",
+			expected: "--- a/Containerfile\n+++ b/Containerfile\n" +
+				"@@ -1,3 +1,2 @@\n" +
+				"-a\n b\n c\n",
+		},
+		{
+			name: "empty original",
+			from: "",
+			to:   "a\n",
+			expected: "--- a/Containerfile\n+++ b/Containerfile\n" +
+				"@@ -0,0 +1 @@\n" +
+				"+a\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(unifiedDiff("a/Containerfile", "b/Containerfile", tc.from, tc.to)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_Build_Run_dryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	tempDir := t.TempDir()
+	testutil.WriteFileTree(t, tempDir, map[string]string{
+		"context/Containerfile": "FROM registry.io/base:1\nRUN echo hello\n",
+		"secrets/token":         "secret-token",
+	})
+	contextDir := filepath.Join(tempDir, "context")
+
+	_mockBuildahCli := &mockBuildahCli{
+		PullFunc: func(args *cliwrappers.BuildahPullArgs) error {
+			// Pulling the final base image to determine labels is allowed
+			g.Expect(args.Image).To(Equal("registry.io/base:1"))
+			return nil
+		},
+		InspectImageFunc: func(name string) (cliwrappers.BuildahImageInfo, error) {
+			return cliwrappers.BuildahImageInfo{}, nil
+		},
+		BuildFunc: func(args *cliwrappers.BuildahBuildArgs) error {
+			t.Fatal("buildah build must not be called in dry run")
+			return nil
+		},
+		PushFunc: func(args *cliwrappers.BuildahPushArgs) (string, error) {
+			t.Fatal("buildah push must not be called in dry run")
+			return "", nil
+		},
+	}
+
+	var plan *BuildPlan
+	c := &Build{
+		CliWrappers: BuildCliWrappers{BuildahCli: _mockBuildahCli},
+		Params: &BuildParams{
+			OutputRef:       "quay.io/org/image:tag",
+			Context:         contextDir,
+			Push:            true,
+			DryRun:          true,
+			SecretDirs:      []string{filepath.Join(tempDir, "secrets")},
+			WorkdirMount:    "/workdir",
+			SourceDateEpoch: "0",
+		},
+		ResultsWriter: &mockResultsWriter{
+			CreateResultJsonFunc: func(result any) (string, error) {
+				var ok bool
+				plan, ok = result.(*BuildPlan)
+				g.Expect(ok).To(BeTrue())
+				planJson, err := json.Marshal(result)
+				return string(planJson), err
+			},
+		},
+	}
+
+	g.Expect(c.Run()).To(Succeed())
+	g.Expect(plan).ToNot(BeNil())
+
+	containerfile := filepath.Join(contextDir, "Containerfile")
+	g.Expect(plan.Command).To(ContainElements(
+		"build",
+		"--file",
+		"--secret=src="+filepath.Join(tempDir, "secrets", "token")+",id=secrets/token",
+		"--volume="+contextDir+":/workdir:z",
+		"--source-date-epoch=0",
+	))
+	g.Expect(plan.Command[len(plan.Command)-1]).To(Equal(contextDir))
+
+	g.Expect(plan.Secrets).To(Equal([]BuildPlanSecret{
+		{ID: "secrets/token", Source: filepath.Join(tempDir, "secrets", "token")},
+	}))
+	g.Expect(plan.Volumes).To(Equal([]BuildPlanVolume{
+		{Source: contextDir, Target: "/workdir", Options: "z"},
+	}))
+	g.Expect(plan.BuildContexts).To(HaveLen(1))
+	g.Expect(plan.BuildContexts[0].Name).To(Equal(".konflux-buildinfo"))
+
+	g.Expect(plan.ContainerfileDiff).To(Equal(
+		"--- " + containerfile + "\n+++ " + c.containerfileCopyPath + "\n" +
+			"@@ -1,2 +1,3 @@\n" +
+			" FROM registry.io/base:1\n" +
+			" RUN echo hello\n" +
+			"+COPY --from=.konflux-buildinfo . /usr/share/buildinfo/\n",
+	))
+	g.Expect(plan.Labels).To(MatchJSON(`{"org.opencontainers.image.created": "1970-01-01T00:00:00Z"}`))
+	g.Expect(plan.ContentSets).To(BeNil())
+	g.Expect(plan.BaseImages).To(Equal([]string{"registry.io/base:1"}))
+}
@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// Stats about a pulled base image, reported in the build results.
+type BaseImagePull struct {
+	Image string `json:"image"`
+	// Other refs that resolve to the same image, which did not need a separate pull
+	Aliases []string `json:"aliases,omitempty"`
+	Digest  string   `json:"digest,omitempty"`
+	// Size of the image config and layers as listed in the manifest, i.e. the compressed size.
+	// Layers already present in local storage don't actually get downloaded again.
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// The result of pulling (and inspecting) an image, shared by all refs that resolve to it.
+type pulledImage struct {
+	done  chan struct{}
+	stats BaseImagePull
+	info  cliWrappers.BuildahImageInfo
+	err   error
+}
+
+// Pulls images at most once per repo@digest (or pullKey), can be used from multiple goroutines.
+type imagePuller struct {
+	mu     sync.Mutex
+	images map[string]*pulledImage
+}
+
+// Determine the key under which an image gets pulled. Refs pinned to the same digest
+// in the same repository resolve to the same image, regardless of the tag. Otherwise,
+// the key is the normalized name:tag (e.g. alpine => docker.io/library/alpine:latest).
+func pullKey(imageRef string) string {
+	transport, bareImage := splitTransport(imageRef)
+	if transport == "docker://" {
+		transport = ""
+	}
+
+	named, err := reference.ParseNormalizedNamed(bareImage)
+	if err != nil {
+		return imageRef
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		return transport + named.Name() + "@" + digested.Digest().String()
+	}
+	return transport + reference.TagNameOnly(named).String()
+}
+
+// Resolve a tagged registry ref to repo@digest by inspecting the manifest in the registry
+// (without pulling), so that different tags of the same image share the pull.
+// Returns "" if the ref doesn't need resolving or can't be resolved, the caller then
+// falls back to the pullKey.
+func (c *Build) resolveDigest(imageRef string) string {
+	if c.CliWrappers.SkopeoCli == nil {
+		return ""
+	}
+	transport, bareImage := splitTransport(imageRef)
+	if transport != "" && transport != "docker://" {
+		return ""
+	}
+	named, err := reference.ParseNormalizedNamed(bareImage)
+	if err != nil {
+		return ""
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return ""
+	}
+
+	output, err := c.CliWrappers.SkopeoCli.Inspect(&cliWrappers.SkopeoInspectArgs{
+		ImageRef:  reference.TagNameOnly(named).String(),
+		NoTags:    true,
+		Format:    "{{.Digest}}",
+		HttpProxy: c.Params.ImagePullProxy,
+		NoProxy:   c.Params.ImagePullNoProxy,
+	})
+	if err != nil {
+		l.Logger.Warnf("Failed to resolve the digest of %s, deduplicating by tag: %s", imageRef, err)
+		return ""
+	}
+	dgst, err := digest.Parse(strings.TrimSpace(output))
+	if err != nil {
+		l.Logger.Warnf("Failed to resolve the digest of %s, deduplicating by tag: %s", imageRef, err)
+		return ""
+	}
+	return named.Name() + "@" + dgst.String()
+}
+
+// Pull the image unless an image with the same digest (or pullKey, if the digest can't
+// be resolved) was already pulled, or is being pulled by another goroutine, in which case
+// wait for the result. Tags that resolve to an already pulled image get added to the local
+// image, so that they can be used without pulling.
+func (c *Build) pullImage(imageRef string) (*pulledImage, error) {
+	key := c.resolveDigest(imageRef)
+	if key == "" {
+		key = pullKey(imageRef)
+	}
+
+	puller := &c.imagePuller
+	puller.mu.Lock()
+	if puller.images == nil {
+		puller.images = make(map[string]*pulledImage)
+	}
+	image, found := puller.images[key]
+	isNewAlias := false
+	if !found {
+		image = &pulledImage{done: make(chan struct{}), stats: BaseImagePull{Image: imageRef}}
+		puller.images[key] = image
+	} else if image.stats.Image != imageRef && !slices.Contains(image.stats.Aliases, imageRef) {
+		image.stats.Aliases = append(image.stats.Aliases, imageRef)
+		isNewAlias = true
+	}
+	puller.mu.Unlock()
+
+	if found {
+		l.Logger.Debugf("Image %s resolves to %s, not pulling again", imageRef, key)
+		<-image.done
+		if image.err != nil {
+			return image, image.err
+		}
+		if isNewAlias && pullKey(imageRef) != pullKey(image.stats.Image) {
+			if err := c.tagPulledImage(image.stats.Image, imageRef); err != nil {
+				return image, err
+			}
+		}
+		return image, nil
+	}
+
+	defer close(image.done)
+
+	l.Logger.Infof("Pulling image %s", imageRef)
+	start := time.Now()
+	err := c.CliWrappers.BuildahCli.Pull(&cliWrappers.BuildahPullArgs{
+		Image:     imageRef,
+		HttpProxy: c.Params.ImagePullProxy,
+		NoProxy:   c.Params.ImagePullNoProxy,
+	})
+	duration := time.Since(start)
+	if err != nil {
+		image.err = fmt.Errorf("pulling image %s: %w", imageRef, err)
+		return image, image.err
+	}
+
+	// buildah inspect doesn't support the <transport>: prefix, strip it
+	_, inspectableRef := splitTransport(imageRef)
+	info, err := c.CliWrappers.BuildahCli.InspectImage(inspectableRef)
+	if err != nil {
+		image.err = fmt.Errorf("inspecting image %s: %w", inspectableRef, err)
+		return image, image.err
+	}
+
+	image.info = info
+	image.stats.Digest = info.FromImageDigest
+	image.stats.Bytes = manifestSize(info.Manifest)
+	image.stats.DurationSeconds = duration.Seconds()
+	l.Logger.Infof("Pulled image %s in %.1fs (%d bytes)", imageRef, duration.Seconds(), image.stats.Bytes)
+
+	return image, nil
+}
+
+// Add the alias to the local image pulled as pulledRef. Only tags need this, a local
+// image can be looked up by its digest (or by the name it was pulled as) already.
+func (c *Build) tagPulledImage(pulledRef string, alias string) error {
+	_, bareAlias := splitTransport(alias)
+	named, err := reference.ParseNormalizedNamed(bareAlias)
+	if err != nil {
+		return nil
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return nil
+	}
+
+	_, bareImage := splitTransport(pulledRef)
+	name := reference.TagNameOnly(named).String()
+	l.Logger.Infof("Tagging image %s as %s", bareImage, name)
+	if err := c.CliWrappers.BuildahCli.Tag(bareImage, name); err != nil {
+		return fmt.Errorf("tagging image %s as %s: %w", bareImage, name, err)
+	}
+	return nil
+}
+
+// Pull the images, running at most --pull-concurrency pulls at the same time (0 means no limit).
+// Returns the first error (by the order of images), if any.
+func (c *Build) pullImages(images []string) error {
+	concurrency := c.Params.PullConcurrency
+	if concurrency <= 0 {
+		concurrency = max(len(images), 1)
+	}
+
+	errs := make([]error, len(images))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, image := range images {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			_, errs[i] = c.pullImage(image)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the stats of all the successfully pulled images, sorted by image ref.
+func (c *Build) pulledImageStats() []BaseImagePull {
+	puller := &c.imagePuller
+	puller.mu.Lock()
+	defer puller.mu.Unlock()
+
+	var stats []BaseImagePull
+	for _, image := range puller.images {
+		select {
+		case <-image.done:
+			if image.err == nil {
+				imageStats := image.stats
+				imageStats.Aliases = slices.Sorted(slices.Values(image.stats.Aliases))
+				stats = append(stats, imageStats)
+			}
+		default:
+			// still in progress, shouldn't happen after pullImages returns
+		}
+	}
+	slices.SortFunc(stats, func(a, b BaseImagePull) int { return strings.Compare(a.Image, b.Image) })
+	return stats
+}
+
+// Sum up the sizes of the config and layers in an image manifest. Returns 0 if the manifest
+// can't be parsed (e.g. it's not an OCI or Docker v2s2 manifest).
+func manifestSize(rawManifest string) int64 {
+	var manifest ociv1.Manifest
+	if err := json.Unmarshal([]byte(rawManifest), &manifest); err != nil {
+		return 0
+	}
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func Test_pullKey(t *testing.T) {
+	const digest = "sha256:e7afdb605d0685d214876ae9d13ae0cc15da3a766be86e919fecee4032b9783b"
+
+	tests := []struct {
+		name     string
+		imageRef string
+		expected string
+	}{
+		{name: "short name", imageRef: "alpine", expected: "docker.io/library/alpine:latest"},
+		{name: "tagged", imageRef: "quay.io/org/image:v1", expected: "quay.io/org/image:v1"},
+		{name: "docker transport", imageRef: "docker://quay.io/org/image:v1", expected: "quay.io/org/image:v1"},
+		{name: "digest wins over tag", imageRef: "quay.io/org/image:v1@" + digest, expected: "quay.io/org/image@" + digest},
+		{name: "other transport", imageRef: "containers-storage:quay.io/org/image", expected: "containers-storage:quay.io/org/image:latest"},
+		{name: "unparseable", imageRef: "oci:/some/dir", expected: "oci:/some/dir"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(pullKey(tc.imageRef)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_manifestSize(t *testing.T) {
+	g := NewWithT(t)
+
+	manifest := `{
+		"schemaVersion": 2,
+		"config": {"mediaType": "application/vnd.oci.image.config.v1+json", "size": 100},
+		"layers": [{"size": 1000}, {"size": 2000}]
+	}`
+	g.Expect(manifestSize(manifest)).To(Equal(int64(3100)))
+	g.Expect(manifestSize("")).To(BeZero())
+}
+
+func Test_Build_pullImages(t *testing.T) {
+	const digest = "sha256:e7afdb605d0685d214876ae9d13ae0cc15da3a766be86e919fecee4032b9783b"
+	const manifest = `{"schemaVersion": 2, "config": {"size": 10}, "layers": [{"size": 90}]}`
+
+	newBuild := func(buildahCli *mockBuildahCli, concurrency int) *Build {
+		buildahCli.InspectImageFunc = func(name string) (cliwrappers.BuildahImageInfo, error) {
+			return cliwrappers.BuildahImageInfo{FromImageDigest: digest, Manifest: manifest}, nil
+		}
+		return &Build{
+			CliWrappers: BuildCliWrappers{BuildahCli: buildahCli},
+			Params:      &BuildParams{PullConcurrency: concurrency},
+		}
+	}
+
+	t.Run("should pull refs that resolve to the same image only once", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var mu sync.Mutex
+		var pulled []string
+		buildahCli := &mockBuildahCli{
+			PullFunc: func(args *cliwrappers.BuildahPullArgs) error {
+				mu.Lock()
+				defer mu.Unlock()
+				pulled = append(pulled, args.Image)
+				return nil
+			},
+		}
+		c := newBuild(buildahCli, 2)
+
+		err := c.pullImages([]string{
+			"alpine",
+			"docker.io/library/alpine:latest",
+			"quay.io/org/image:v1@" + digest,
+			"quay.io/org/image:v2@" + digest,
+			"registry.io/other:v1",
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(pulled).To(HaveLen(3))
+
+		stats := c.pulledImageStats()
+		g.Expect(stats).To(HaveLen(3))
+		for _, s := range stats {
+			g.Expect(s.Digest).To(Equal(digest))
+			g.Expect(s.Bytes).To(Equal(int64(100)))
+		}
+		g.Expect(stats[0].Image).To(BeElementOf("alpine", "docker.io/library/alpine:latest"))
+		g.Expect(stats[0].Aliases).To(HaveLen(1))
+		g.Expect(stats[1].Image).To(BeElementOf("quay.io/org/image:v1@"+digest, "quay.io/org/image:v2@"+digest))
+		g.Expect(stats[1].Aliases).To(HaveLen(1))
+		g.Expect(stats[2].Image).To(Equal("registry.io/other:v1"))
+		g.Expect(stats[2].Aliases).To(BeEmpty())
+	})
+
+	t.Run("should pull tags that resolve to the same digest only once", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var mu sync.Mutex
+		var pulled []string
+		buildahCli := &mockBuildahCli{
+			PullFunc: func(args *cliwrappers.BuildahPullArgs) error {
+				mu.Lock()
+				defer mu.Unlock()
+				pulled = append(pulled, args.Image)
+				return nil
+			},
+		}
+		var tagged [][]string
+		buildahCli.TagFunc = func(image string, names ...string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			tagged = append(tagged, append([]string{image}, names...))
+			return nil
+		}
+		var inspected []string
+		skopeoCli := &mockSkopeoCli{
+			InspectFunc: func(args *cliwrappers.SkopeoInspectArgs) (string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				inspected = append(inspected, args.ImageRef)
+				g.Expect(args.Format).To(Equal("{{.Digest}}"))
+				if args.ImageRef == "registry.io/other:v1" {
+					return "", errors.New("manifest unknown")
+				}
+				return digest + "\n", nil
+			},
+		}
+		c := newBuild(buildahCli, 1)
+		c.CliWrappers.SkopeoCli = skopeoCli
+
+		err := c.pullImages([]string{
+			"quay.io/org/image:1",
+			"docker://quay.io/org/image:latest",
+			"quay.io/org/image@" + digest,
+			"registry.io/other:v1",
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(pulled).To(HaveLen(2))
+		g.Expect(pulled).To(ContainElement("registry.io/other:v1"))
+		// digest-pinned refs don't need resolving
+		g.Expect(inspected).To(ConsistOf("quay.io/org/image:1", "quay.io/org/image:latest", "registry.io/other:v1"))
+
+		stats := c.pulledImageStats()
+		g.Expect(stats).To(HaveLen(2))
+		imageRefs := append([]string{stats[0].Image}, stats[0].Aliases...)
+		g.Expect(imageRefs).To(ConsistOf("quay.io/org/image:1", "docker://quay.io/org/image:latest", "quay.io/org/image@"+digest))
+		g.Expect(stats[1].Image).To(Equal("registry.io/other:v1"))
+		g.Expect(stats[1].Aliases).To(BeEmpty())
+
+		// the tags that weren't pulled must be available locally, e.g. for hermetic builds
+		_, pulledImage := splitTransport(stats[0].Image)
+		var expectedTags [][]string
+		for _, tag := range []string{"quay.io/org/image:1", "quay.io/org/image:latest"} {
+			if pulledImage != tag {
+				expectedTags = append(expectedTags, []string{pulledImage, tag})
+			}
+		}
+		g.Expect(tagged).To(ConsistOf(expectedTags))
+	})
+
+	t.Run("should share the pull with label inspection", func(t *testing.T) {
+		g := NewWithT(t)
+
+		pullCount := 0
+		buildahCli := &mockBuildahCli{
+			PullFunc: func(args *cliwrappers.BuildahPullArgs) error {
+				pullCount++
+				return nil
+			},
+		}
+		c := newBuild(buildahCli, 1)
+		buildahCli.InspectImageFunc = func(name string) (cliwrappers.BuildahImageInfo, error) {
+			info := cliwrappers.BuildahImageInfo{FromImageDigest: digest}
+			info.OCIv1.Config.Labels = map[string]string{"name": "base"}
+			return info, nil
+		}
+
+		labels, err := c.getImageLabels("quay.io/org/base:1")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(labels).To(Equal(map[string]string{"name": "base"}))
+
+		g.Expect(c.pullImages([]string{"docker://quay.io/org/base:1"})).To(Succeed())
+		g.Expect(pullCount).To(Equal(1))
+		g.Expect(c.pulledImageStats()).To(HaveLen(1))
+	})
+
+	t.Run("should respect the concurrency limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var running, maxRunning atomic.Int32
+		buildahCli := &mockBuildahCli{
+			PullFunc: func(args *cliwrappers.BuildahPullArgs) error {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					current := maxRunning.Load()
+					if n <= current || maxRunning.CompareAndSwap(current, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			},
+		}
+		c := newBuild(buildahCli, 2)
+
+		images := []string{"registry.io/a", "registry.io/b", "registry.io/c", "registry.io/d", "registry.io/e"}
+		g.Expect(c.pullImages(images)).To(Succeed())
+		g.Expect(maxRunning.Load()).To(BeNumerically("<=", 2))
+		g.Expect(c.pulledImageStats()).To(HaveLen(len(images)))
+	})
+
+	t.Run("should return the first error by image order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		buildahCli := &mockBuildahCli{
+			PullFunc: func(args *cliwrappers.BuildahPullArgs) error {
+				if args.Image == "registry.io/ok" {
+					return nil
+				}
+				return errors.New("pull failed: " + args.Image)
+			},
+		}
+		c := newBuild(buildahCli, 0)
+
+		err := c.pullImages([]string{"registry.io/ok", "registry.io/bad1", "registry.io/bad2"})
+		g.Expect(err).To(MatchError("pulling image registry.io/bad1: pull failed: registry.io/bad1"))
+		g.Expect(c.pulledImageStats()).To(HaveLen(1))
+	})
+}
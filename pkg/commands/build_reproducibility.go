@@ -0,0 +1,288 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/keilerkonzept/dockerfile-json/pkg/dockerfile"
+	"github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// The outcome of --reproducibility-check. The first image is the regular build,
+// the second one is the rebuild in separate storage.
+type ReproducibilityCheck struct {
+	Reproducible    bool      `json:"reproducible"`
+	ManifestDigests [2]string `json:"manifest_digests"`
+	ConfigDigests   [2]string `json:"config_digests"`
+	// Paths of the config fields that differ (e.g. "created", "config.Labels")
+	ConfigDifferences []string          `json:"config_differences,omitempty"`
+	LayerDifferences  []LayerDifference `json:"layer_differences,omitempty"`
+}
+
+// A layer that differs between the two builds. If one of the images has fewer layers,
+// the missing digest is empty and there are no file differences.
+type LayerDifference struct {
+	Index   int              `json:"index"`
+	Digests [2]string        `json:"digests"`
+	Files   []FileDifference `json:"files,omitempty"`
+}
+
+// A file that differs between the two versions of a layer.
+type FileDifference struct {
+	Path string `json:"path"`
+	// The attributes that differ: missing, type, mode, owner, mtime, size, content, linkname
+	Differences []string   `json:"differences"`
+	First       *LayerFile `json:"first,omitempty"`
+	Second      *LayerFile `json:"second,omitempty"`
+}
+
+// Build the image a second time, with the build inputs generated again in a fresh tempWorkdir
+// and in separate storage, and compare the two images. Both images are written to OCI layouts
+// in a temporary directory for the comparison.
+//
+// Base images are pulled again into the separate storage. Unless they're referenced by digest,
+// a base image update between the builds shows up as a difference.
+func (c *Build) checkReproducibility(containerfile *dockerfile.Dockerfile) (*ReproducibilityCheck, error) {
+	l.Logger.Info("Building the image again to check reproducibility")
+
+	checkDir, err := os.MkdirTemp("", "kbc-reproducibility-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(checkDir); err != nil {
+			l.Logger.Warnf("Failed to clean up reproducibility check directory %s: %s", checkDir, err)
+		}
+	}()
+
+	if err := c.regenerateBuildInputs(containerfile); err != nil {
+		return nil, fmt.Errorf("reproducibility check build inputs: %w", err)
+	}
+
+	storage := cliWrappers.BuildahStorage{
+		Root:    filepath.Join(checkDir, "root"),
+		RunRoot: filepath.Join(checkDir, "runroot"),
+	}
+
+	buildArgs, err := c.createBuildahBuildArgs()
+	if err != nil {
+		return nil, err
+	}
+	buildArgs.Storage = storage
+	if err := c.runBuildahBuild(buildArgs); err != nil {
+		return nil, fmt.Errorf("reproducibility check build: %w", err)
+	}
+
+	layouts := [2]string{filepath.Join(checkDir, "first"), filepath.Join(checkDir, "second")}
+	for i, pushStorage := range []cliWrappers.BuildahStorage{{}, storage} {
+		_, err := c.CliWrappers.BuildahCli.Push(&cliWrappers.BuildahPushArgs{
+			Image:       c.Params.OutputRef,
+			Destination: common.OCILayoutTransport + layouts[i],
+			Storage:     pushStorage,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("writing image to OCI layout for comparison: %w", err)
+		}
+	}
+
+	check, err := compareOCILayouts(layouts)
+	if err != nil {
+		return nil, fmt.Errorf("comparing images: %w", err)
+	}
+	return check, nil
+}
+
+// Discard the build inputs of the first build and generate them again in a fresh tempWorkdir.
+// The first build could have modified them, e.g. the prefetch dir copy is mounted read-write.
+// The inputs that don't depend on the workdir (secrets, ssh-agents, egress proxy, RHSM registration)
+// stay the same.
+func (c *Build) regenerateBuildInputs(containerfile *dockerfile.Dockerfile) error {
+	for _, dir := range []string{c.tempWorkdir, c.prefetchDirCopy} {
+		if dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("removing the inputs of the first build: %w", err)
+		}
+	}
+
+	c.tempWorkdir = ""
+	c.prefetchDirCopy = ""
+	c.containerfileCopyPath = ""
+	c.buildahVolumes = nil
+	c.buildinfoBuildContext = nil
+	c.Results.YumRepos = nil
+
+	return c.prepareBuildInputs(containerfile)
+}
+
+// An image read from an OCI layout.
+type layoutImage struct {
+	dir            string
+	manifestDigest string
+	manifest       ociv1.Manifest
+	config         map[string]any
+}
+
+func readLayoutImage(dir string) (*layoutImage, error) {
+	index, err := common.ReadOCILayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	_, descriptor, err := common.FindOCIIndexDescriptor(index, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	image := &layoutImage{dir: dir, manifestDigest: descriptor.Digest.String()}
+	if err := image.readJSONBlob(descriptor.Digest, &image.manifest); err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	if err := image.readJSONBlob(image.manifest.Config.Digest, &image.config); err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	return image, nil
+}
+
+func (i *layoutImage) blobPath(d digest.Digest) string {
+	return filepath.Join(i.dir, ociv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+func (i *layoutImage) readJSONBlob(d digest.Digest, v any) error {
+	content, err := os.ReadFile(i.blobPath(d))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+// Compare the images in the two OCI layouts (each containing a single image).
+func compareOCILayouts(dirs [2]string) (*ReproducibilityCheck, error) {
+	var images [2]*layoutImage
+	for i, dir := range dirs {
+		image, err := readLayoutImage(dir)
+		if err != nil {
+			return nil, err
+		}
+		images[i] = image
+	}
+
+	check := &ReproducibilityCheck{
+		ManifestDigests: [2]string{images[0].manifestDigest, images[1].manifestDigest},
+		ConfigDigests:   [2]string{images[0].manifest.Config.Digest.String(), images[1].manifest.Config.Digest.String()},
+	}
+	check.Reproducible = check.ManifestDigests[0] == check.ManifestDigests[1]
+	if check.Reproducible {
+		return check, nil
+	}
+
+	check.ConfigDifferences = diffJSON("", images[0].config, images[1].config)
+
+	layers := [2][]ociv1.Descriptor{images[0].manifest.Layers, images[1].manifest.Layers}
+	for i := 0; i < max(len(layers[0]), len(layers[1])); i++ {
+		var difference LayerDifference
+		difference.Index = i
+		if i < len(layers[0]) {
+			difference.Digests[0] = layers[0][i].Digest.String()
+		}
+		if i < len(layers[1]) {
+			difference.Digests[1] = layers[1][i].Digest.String()
+		}
+		if difference.Digests[0] == difference.Digests[1] {
+			continue
+		}
+
+		if difference.Digests[0] != "" && difference.Digests[1] != "" {
+			files, err := diffLayers(images[0], layers[0][i], images[1], layers[1][i])
+			if err != nil {
+				return nil, fmt.Errorf("layer %d: %w", i, err)
+			}
+			difference.Files = files
+		}
+		check.LayerDifferences = append(check.LayerDifferences, difference)
+	}
+	return check, nil
+}
+
+// Find the paths of the JSON values that differ. Objects are compared field by field,
+// other values (including arrays) as a whole.
+func diffJSON(path string, a, b any) []string {
+	objectA, okA := a.(map[string]any)
+	objectB, okB := b.(map[string]any)
+	if !okA || !okB {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{path}
+	}
+
+	keys := make(map[string]bool)
+	for key := range objectA {
+		keys[key] = true
+	}
+	for key := range objectB {
+		keys[key] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	var differences []string
+	for _, key := range sortedKeys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		differences = append(differences, diffJSON(keyPath, objectA[key], objectB[key])...)
+	}
+	return differences
+}
+
+func diffLayers(imageA *layoutImage, layerA ociv1.Descriptor, imageB *layoutImage, layerB ociv1.Descriptor) ([]FileDifference, error) {
+	filesA, err := readLayerFiles(imageA.blobPath(layerA.Digest), layerA.MediaType)
+	if err != nil {
+		return nil, err
+	}
+	filesB, err := readLayerFiles(imageB.blobPath(layerB.Digest), layerB.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for path := range filesA {
+		paths[path] = true
+	}
+	for path := range filesB {
+		paths[path] = true
+	}
+	sortedPaths := make([]string, 0, len(paths))
+	for path := range paths {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	var differences []FileDifference
+	for _, path := range sortedPaths {
+		fileA, fileB := filesA[path], filesB[path]
+		if d := diffLayerFiles(fileA, fileB); len(d) > 0 {
+			differences = append(differences, FileDifference{Path: path, Differences: d, First: fileA, Second: fileB})
+		}
+	}
+
+	if len(differences) == 0 {
+		// Same files, so the difference is in the tarball itself (e.g. entry order or tar headers)
+		differences = append(differences, FileDifference{Path: "", Differences: []string{"tarball"}})
+	}
+	return differences, nil
+}
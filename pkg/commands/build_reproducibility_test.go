@@ -0,0 +1,307 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+type testLayerFile struct {
+	name    string
+	content string
+	mode    int64
+	modTime time.Time
+}
+
+// Write an OCI layout with a single image, made of gzipped layers with the given files.
+func writeTestOCILayout(t *testing.T, dir string, created string, layers ...[]testLayerFile) {
+	t.Helper()
+	writeTestOCILayoutWithConfig(t, dir, func([]digest.Digest) []byte {
+		return []byte(`{"created": "` + created + `", "config": {"Labels": {"a": "b"}}, "architecture": "amd64"}`)
+	}, layers...)
+}
+
+// Like writeTestOCILayout, with the image config created from the diff IDs of the layers.
+func writeTestOCILayoutWithConfig(t *testing.T, dir string, config func(diffIDs []digest.Digest) []byte, layers ...[]testLayerFile) {
+	t.Helper()
+	g := NewWithT(t)
+
+	writeBlob := func(content []byte) digest.Digest {
+		d := digest.FromBytes(content)
+		blobDir := filepath.Join(dir, "blobs", "sha256")
+		g.Expect(os.MkdirAll(blobDir, 0755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(blobDir, d.Encoded()), content, 0644)).To(Succeed())
+		return d
+	}
+
+	var manifest ociv1.Manifest
+	manifest.SchemaVersion = 2
+	manifest.MediaType = ociv1.MediaTypeImageManifest
+
+	var diffIDs []digest.Digest
+	for _, files := range layers {
+		var buf, tarBuf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(io.MultiWriter(gw, &tarBuf))
+		for _, file := range files {
+			g.Expect(tw.WriteHeader(&tar.Header{
+				Name: file.name, Mode: file.mode, Size: int64(len(file.content)), ModTime: file.modTime, Typeflag: tar.TypeReg,
+			})).To(Succeed())
+			_, err := tw.Write([]byte(file.content))
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+		g.Expect(tw.Close()).To(Succeed())
+		g.Expect(gw.Close()).To(Succeed())
+		diffIDs = append(diffIDs, digest.FromBytes(tarBuf.Bytes()))
+		manifest.Layers = append(manifest.Layers, ociv1.Descriptor{
+			MediaType: ociv1.MediaTypeImageLayerGzip, Digest: writeBlob(buf.Bytes()), Size: int64(buf.Len()),
+		})
+	}
+
+	configJson := config(diffIDs)
+	manifest.Config = ociv1.Descriptor{MediaType: ociv1.MediaTypeImageConfig, Digest: writeBlob(configJson), Size: int64(len(configJson))}
+
+	manifestJson, err := json.Marshal(manifest)
+	g.Expect(err).ToNot(HaveOccurred())
+	index := ociv1.Index{Manifests: []ociv1.Descriptor{{
+		MediaType: ociv1.MediaTypeImageManifest, Digest: writeBlob(manifestJson), Size: int64(len(manifestJson)),
+	}}}
+	index.SchemaVersion = 2
+	indexJson, err := json.Marshal(index)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.WriteFile(filepath.Join(dir, "index.json"), indexJson, 0644)).To(Succeed())
+}
+
+func Test_compareOCILayouts(t *testing.T) {
+	epoch := time.Unix(1700000000, 0).UTC()
+	baseLayer := []testLayerFile{{name: "etc/os-release", content: "ID=test", mode: 0644, modTime: epoch}}
+
+	t.Run("should report identical images as reproducible", func(t *testing.T) {
+		g := NewWithT(t)
+		dirs := [2]string{t.TempDir(), t.TempDir()}
+		for _, dir := range dirs {
+			writeTestOCILayout(t, dir, "2023-11-14T22:13:20Z", baseLayer)
+		}
+
+		check, err := compareOCILayouts(dirs)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(check.Reproducible).To(BeTrue())
+		g.Expect(check.ManifestDigests[0]).To(Equal(check.ManifestDigests[1]))
+		g.Expect(check.ConfigDifferences).To(BeEmpty())
+		g.Expect(check.LayerDifferences).To(BeEmpty())
+	})
+
+	t.Run("should explain the differences", func(t *testing.T) {
+		g := NewWithT(t)
+		dirs := [2]string{t.TempDir(), t.TempDir()}
+		writeTestOCILayout(t, dirs[0], "2023-11-14T22:13:20Z", baseLayer,
+			[]testLayerFile{
+				{name: "app/bin", content: "binary", mode: 0755, modTime: epoch},
+				{name: "app/build-id", content: "1111", mode: 0644, modTime: epoch},
+			})
+		writeTestOCILayout(t, dirs[1], "2024-01-01T00:00:00Z", baseLayer,
+			[]testLayerFile{
+				{name: "app/bin", content: "binary", mode: 0755, modTime: epoch.Add(time.Hour)},
+				{name: "app/build-id", content: "2222", mode: 0600, modTime: epoch},
+			},
+			[]testLayerFile{{name: "extra", content: "x", mode: 0644, modTime: epoch}})
+
+		check, err := compareOCILayouts(dirs)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(check.Reproducible).To(BeFalse())
+		g.Expect(check.ConfigDifferences).To(Equal([]string{"created"}))
+
+		g.Expect(check.LayerDifferences).To(HaveLen(2))
+
+		changed := check.LayerDifferences[0]
+		g.Expect(changed.Index).To(Equal(1))
+		g.Expect(changed.Files).To(HaveLen(2))
+		g.Expect(changed.Files[0].Path).To(Equal("/app/bin"))
+		g.Expect(changed.Files[0].Differences).To(Equal([]string{"mtime"}))
+		g.Expect(changed.Files[1].Path).To(Equal("/app/build-id"))
+		g.Expect(changed.Files[1].Differences).To(Equal([]string{"mode", "content"}))
+		g.Expect(changed.Files[1].First.Mode).To(Equal("0644"))
+		g.Expect(changed.Files[1].Second.Mode).To(Equal("0600"))
+
+		extra := check.LayerDifferences[1]
+		g.Expect(extra.Index).To(Equal(2))
+		g.Expect(extra.Digests[0]).To(BeEmpty())
+		g.Expect(extra.Digests[1]).ToNot(BeEmpty())
+		g.Expect(extra.Files).To(BeEmpty())
+	})
+}
+
+func Test_diffJSON(t *testing.T) {
+	g := NewWithT(t)
+
+	var a, b map[string]any
+	g.Expect(json.Unmarshal([]byte(`{"created": "1", "config": {"Env": ["A=1"], "Labels": {"x": "1"}}, "os": "linux"}`), &a)).To(Succeed())
+	g.Expect(json.Unmarshal([]byte(`{"created": "2", "config": {"Env": ["A=1"], "Labels": {"x": "2", "y": "1"}}, "os": "linux"}`), &b)).To(Succeed())
+
+	g.Expect(diffJSON("", a, b)).To(Equal([]string{"config.Labels.x", "config.Labels.y", "created"}))
+	g.Expect(diffJSON("", a, a)).To(BeEmpty())
+}
+
+func Test_Build_checkReproducibility(t *testing.T) {
+	epoch := time.Unix(1700000000, 0).UTC()
+
+	for _, reproducible := range []bool{true, false} {
+		name := "should pass for a reproducible image"
+		if !reproducible {
+			name = "should fail for a non-reproducible image"
+		}
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			contextDir := t.TempDir()
+			g.Expect(os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte("FROM scratch"), 0644)).To(Succeed())
+
+			var builds []*cliwrappers.BuildahBuildArgs
+			var pushes []*cliwrappers.BuildahPushArgs
+			mockBuildah := &mockBuildahCli{
+				BuildFunc: func(args *cliwrappers.BuildahBuildArgs) error {
+					builds = append(builds, args)
+					return nil
+				},
+				PushFunc: func(args *cliwrappers.BuildahPushArgs) (string, error) {
+					pushes = append(pushes, args)
+					if dir, ok := strings.CutPrefix(args.Destination, "oci:"); ok {
+						content := "same"
+						if !reproducible && args.Storage.Root != "" {
+							content = "SAME"
+						}
+						writeTestOCILayout(t, dir, "2023-11-14T22:13:20Z",
+							[]testLayerFile{{name: "file", content: content, mode: 0644, modTime: epoch}})
+					}
+					return "sha256:1234567890abcdef", nil
+				},
+			}
+			mockWriter := &mockResultsWriter{
+				CreateResultJsonFunc: func(result any) (string, error) {
+					g.Expect(result.(BuildResults).Reproducibility.Reproducible).To(BeTrue())
+					return "", nil
+				},
+			}
+
+			c := &Build{
+				Params: &BuildParams{
+					OutputRef:            "quay.io/org/image:tag",
+					Context:              contextDir,
+					SourceDateEpoch:      "1700000000",
+					RewriteTimestamp:     true,
+					ReproducibilityCheck: true,
+					SkipInjections:       true,
+				},
+				CliWrappers:   BuildCliWrappers{BuildahCli: mockBuildah},
+				ResultsWriter: mockWriter,
+			}
+
+			err := c.Run()
+			if reproducible {
+				g.Expect(err).ToNot(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring("image is not reproducible")))
+				g.Expect(c.Results.Reproducibility.LayerDifferences[0].Files[0].Differences).To(Equal([]string{"content"}))
+			}
+
+			// The rebuild uses the same arguments, only in separate storage
+			g.Expect(builds).To(HaveLen(2))
+			g.Expect(builds[0].Storage).To(BeZero())
+			g.Expect(builds[1].Storage.Root).ToNot(BeEmpty())
+			g.Expect(builds[1].Storage.RunRoot).ToNot(BeEmpty())
+			secondBuild := *builds[1]
+			secondBuild.Storage = cliwrappers.BuildahStorage{}
+			g.Expect(secondBuild).To(Equal(*builds[0]))
+
+			g.Expect(pushes).To(HaveLen(2))
+			g.Expect(pushes[0].Storage).To(BeZero())
+			g.Expect(pushes[1].Storage).To(Equal(builds[1].Storage))
+
+			// The temporary storage is cleaned up
+			g.Expect(builds[1].Storage.Root).ToNot(BeADirectory())
+		})
+	}
+
+	t.Run("should generate the inputs of the rebuild in a fresh workdir", func(t *testing.T) {
+		g := NewWithT(t)
+
+		contextDir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte("FROM scratch"), 0644)).To(Succeed())
+		reposDir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(reposDir, "test.repo"), []byte("[test]\nbaseurl=https://example.com/repo\n"), 0644)).To(Succeed())
+
+		type buildInputs struct {
+			containerfile string
+			volumes       []cliwrappers.BuildahVolume
+			contexts      []cliwrappers.BuildahBuildContext
+			repoFiles     []string
+		}
+		var builds []buildInputs
+		mockBuildah := &mockBuildahCli{
+			BuildFunc: func(args *cliwrappers.BuildahBuildArgs) error {
+				containerfile, err := os.ReadFile(args.Containerfile)
+				g.Expect(err).ToNot(HaveOccurred())
+				repoFiles, err := filepath.Glob(filepath.Join(args.Volumes[0].HostDir, "*"))
+				g.Expect(err).ToNot(HaveOccurred())
+				builds = append(builds, buildInputs{string(containerfile), args.Volumes, args.BuildContexts, repoFiles})
+				// The first build modifies its inputs, the rebuild must not see that
+				g.Expect(os.WriteFile(filepath.Join(args.Volumes[0].HostDir, "added-by-build.repo"), nil, 0644)).To(Succeed())
+				return nil
+			},
+			PushFunc: func(args *cliwrappers.BuildahPushArgs) (string, error) {
+				if dir, ok := strings.CutPrefix(args.Destination, "oci:"); ok {
+					writeTestOCILayout(t, dir, "2023-11-14T22:13:20Z",
+						[]testLayerFile{{name: "file", content: "same", mode: 0644, modTime: epoch}})
+				}
+				return "sha256:1234567890abcdef", nil
+			},
+		}
+
+		c := &Build{
+			Params: &BuildParams{
+				OutputRef:            "quay.io/org/image:tag",
+				Context:              contextDir,
+				YumReposDSources:     []string{reposDir},
+				ReproducibilityCheck: true,
+			},
+			CliWrappers: BuildCliWrappers{BuildahCli: mockBuildah},
+			ResultsWriter: &mockResultsWriter{
+				CreateResultJsonFunc: func(result any) (string, error) { return "", nil },
+			},
+		}
+
+		g.Expect(c.Run()).To(Succeed())
+
+		g.Expect(builds).To(HaveLen(2))
+		first, second := builds[0], builds[1]
+		g.Expect(second.containerfile).To(Equal(first.containerfile))
+		g.Expect(second.containerfile).To(ContainSubstring("COPY --from=.konflux-buildinfo"))
+		g.Expect(second.repoFiles).To(HaveLen(1))
+		g.Expect(filepath.Base(second.repoFiles[0])).To(Equal("test.repo"))
+
+		g.Expect(second.volumes).To(HaveLen(1))
+		g.Expect(second.volumes[0].HostDir).ToNot(Equal(first.volumes[0].HostDir))
+		g.Expect(second.contexts).To(HaveLen(1))
+		g.Expect(second.contexts[0].Location).ToNot(Equal(first.contexts[0].Location))
+
+		// The repos are reported once
+		g.Expect(c.Results.YumRepos).To(HaveLen(1))
+
+		// Both workdirs are cleaned up
+		g.Expect(first.volumes[0].HostDir).ToNot(BeADirectory())
+		g.Expect(second.volumes[0].HostDir).ToNot(BeADirectory())
+	})
+}
@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// How many of the biggest layers are reported.
+const biggestLayersCount = 5
+
+// The size of the built image, reported if any of the size budgets is set.
+type ImageSizeReport struct {
+	// Sum of the uncompressed layer sizes
+	UncompressedSize int64 `json:"uncompressed_size"`
+	// Sum of the compressed layer sizes, only known once the image is pushed
+	CompressedSize int64 `json:"compressed_size,omitempty"`
+	LayerCount     int   `json:"layer_count"`
+	// The biggest layers by uncompressed size, biggest first
+	BiggestLayers []LayerSize `json:"biggest_layers"`
+	// Descriptions of the budgets the image exceeds
+	ExceededBudgets []string `json:"exceeded_budgets,omitempty"`
+}
+
+type LayerSize struct {
+	Index            int    `json:"index"`
+	Digest           string `json:"digest"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+	CompressedSize   int64  `json:"compressed_size,omitempty"`
+	// The instruction that created the layer, from the image history. Empty for layers
+	// without a history entry.
+	Instruction string `json:"instruction,omitempty"`
+}
+
+type sizeBudgets struct {
+	maxImageSize int64
+	maxLayers    int
+	maxLayerSize int64
+}
+
+var sizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)$`)
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// Parse a size in bytes, with an optional decimal (kB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit.
+func parseSize(size string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s'", size)
+	}
+	multiplier, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size '%s': unknown unit '%s'", size, match[2])
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %w", size, err)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// Format a size in bytes with a binary unit, e.g. 1.5 GiB.
+func formatSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+func (c *Build) parseSizeBudgets() (*sizeBudgets, error) {
+	budgets := &sizeBudgets{maxLayers: c.Params.MaxLayers}
+	if c.Params.MaxLayers < 0 {
+		return nil, fmt.Errorf("max-layers must not be negative, got %d", c.Params.MaxLayers)
+	}
+	if c.Params.MaxImageSize != "" {
+		size, err := parseSize(c.Params.MaxImageSize)
+		if err != nil {
+			return nil, fmt.Errorf("max-image-size: %w", err)
+		}
+		budgets.maxImageSize = size
+	}
+	if c.Params.MaxLayerSize != "" {
+		size, err := parseSize(c.Params.MaxLayerSize)
+		if err != nil {
+			return nil, fmt.Errorf("max-layer-size: %w", err)
+		}
+		budgets.maxLayerSize = size
+	}
+	return budgets, nil
+}
+
+func (b *sizeBudgets) isSet() bool {
+	return b.maxImageSize > 0 || b.maxLayers > 0 || b.maxLayerSize > 0
+}
+
+// Compute the uncompressed sizes of the built image from its manifest in local storage
+// (buildah stores the layers uncompressed) and check them against the budgets.
+func (c *Build) checkSizeBudgets(budgets *sizeBudgets) (*ImageSizeReport, error) {
+	imageInfo, err := c.CliWrappers.BuildahCli.InspectImage(c.Params.OutputRef)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting built image: %w", err)
+	}
+	var manifest ociv1.Manifest
+	if err := json.Unmarshal([]byte(imageInfo.Manifest), &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest of built image: %w", err)
+	}
+
+	report := newImageSizeReport(manifest.Layers, imageInfo.OCIv1.History)
+
+	if budgets.maxImageSize > 0 && report.UncompressedSize > budgets.maxImageSize {
+		report.ExceededBudgets = append(report.ExceededBudgets, fmt.Sprintf("image size %s exceeds max-image-size %s",
+			formatSize(report.UncompressedSize), formatSize(budgets.maxImageSize)))
+	}
+	if budgets.maxLayers > 0 && report.LayerCount > budgets.maxLayers {
+		report.ExceededBudgets = append(report.ExceededBudgets, fmt.Sprintf("%d layers exceed max-layers %d",
+			report.LayerCount, budgets.maxLayers))
+	}
+	if budgets.maxLayerSize > 0 {
+		for _, layer := range report.BiggestLayers {
+			if layer.UncompressedSize <= budgets.maxLayerSize {
+				break
+			}
+			report.ExceededBudgets = append(report.ExceededBudgets, fmt.Sprintf("layer %d (%s) size %s exceeds max-layer-size %s",
+				layer.Index, describeLayer(layer), formatSize(layer.UncompressedSize), formatSize(budgets.maxLayerSize)))
+		}
+	}
+	return report, nil
+}
+
+func newImageSizeReport(layers []ociv1.Descriptor, history []ociv1.History) *ImageSizeReport {
+	// The history entries that created a layer are in the same order as the layers
+	var instructions []string
+	for _, entry := range history {
+		if !entry.EmptyLayer {
+			instructions = append(instructions, historyInstruction(entry.CreatedBy))
+		}
+	}
+	if len(instructions) != len(layers) {
+		// Incomplete history, can't map it to the layers
+		instructions = nil
+	}
+
+	report := &ImageSizeReport{LayerCount: len(layers), BiggestLayers: []LayerSize{}}
+	var layerSizes []LayerSize
+	for i, layer := range layers {
+		report.UncompressedSize += layer.Size
+		layerSize := LayerSize{Index: i, Digest: layer.Digest.String(), UncompressedSize: layer.Size}
+		if instructions != nil {
+			layerSize.Instruction = instructions[i]
+		}
+		layerSizes = append(layerSizes, layerSize)
+	}
+
+	sort.SliceStable(layerSizes, func(i, j int) bool {
+		return layerSizes[i].UncompressedSize > layerSizes[j].UncompressedSize
+	})
+	report.BiggestLayers = append(report.BiggestLayers, layerSizes[:min(biggestLayersCount, len(layerSizes))]...)
+	return report
+}
+
+// Matches the build args prefix of buildah's RUN history entries, e.g. '|2 A=1 B=2 /bin/sh -c ...'
+var historyBuildArgsPrefix = regexp.MustCompile(`^\|([0-9]+) `)
+
+// Turn the created_by of a history entry back into the Containerfile instruction.
+// Buildah records RUN instructions as '/bin/sh -c <command>' and other instructions
+// as '/bin/sh -c #(nop) <instruction>'.
+func historyInstruction(createdBy string) string {
+	instruction := strings.TrimSpace(createdBy)
+	if match := historyBuildArgsPrefix.FindStringSubmatch(instruction); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		fields := strings.SplitN(instruction[len(match[0]):], " ", count+1)
+		instruction = fields[len(fields)-1]
+	}
+	if rest, ok := strings.CutPrefix(instruction, "/bin/sh -c #(nop) "); ok {
+		return strings.TrimSpace(rest)
+	}
+	if rest, ok := strings.CutPrefix(instruction, "/bin/sh -c "); ok {
+		return "RUN " + strings.TrimSpace(rest)
+	}
+	return instruction
+}
+
+func describeLayer(layer LayerSize) string {
+	if layer.Instruction == "" {
+		return layer.Digest
+	}
+	const maxLength = 80
+	if len(layer.Instruction) > maxLength {
+		return layer.Instruction[:maxLength] + "..."
+	}
+	return layer.Instruction
+}
+
+func logImageSizeReport(report *ImageSizeReport) {
+	l.Logger.Infof("Image size: %s uncompressed, %d layers", formatSize(report.UncompressedSize), report.LayerCount)
+	for _, layer := range report.BiggestLayers {
+		l.Logger.Infof("  layer %d: %s - %s", layer.Index, formatSize(layer.UncompressedSize), describeLayer(layer))
+	}
+}
+
+// Add the compressed sizes of the pushed layers to the report.
+func (r *ImageSizeReport) addCompressedSizes(pushed []PushedImageLayers) {
+	if len(pushed) != 1 || len(pushed[0].Layers) != r.LayerCount {
+		return
+	}
+	r.CompressedSize = pushed[0].TotalSize
+	for i := range r.BiggestLayers {
+		r.BiggestLayers[i].CompressedSize = pushed[0].Layers[r.BiggestLayers[i].Index].Size
+	}
+}
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func Test_parseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected int64
+		errMsg   string
+	}{
+		{size: "1024", expected: 1024},
+		{size: "100B", expected: 100},
+		{size: "2GB", expected: 2_000_000_000},
+		{size: "500 MB", expected: 500_000_000},
+		{size: "1.5GiB", expected: 1536 << 20},
+		{size: "10kib", expected: 10240},
+		{size: "", errMsg: "invalid size ''"},
+		{size: "-1GB", errMsg: "invalid size '-1GB'"},
+		{size: "3XB", errMsg: "unknown unit 'XB'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.size, func(t *testing.T) {
+			g := NewWithT(t)
+			size, err := parseSize(tc.size)
+			if tc.errMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.errMsg)))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(size).To(Equal(tc.expected))
+			}
+		})
+	}
+}
+
+func Test_formatSize(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(formatSize(512)).To(Equal("512 B"))
+	g.Expect(formatSize(1536)).To(Equal("1.5 KiB"))
+	g.Expect(formatSize(4 << 30)).To(Equal("4.0 GiB"))
+}
+
+func Test_historyInstruction(t *testing.T) {
+	tests := []struct {
+		createdBy string
+		expected  string
+	}{
+		{createdBy: "/bin/sh -c dnf install -y python3", expected: "RUN dnf install -y python3"},
+		{createdBy: "|2 VERSION=1.0 TARGET=prod /bin/sh -c make install", expected: "RUN make install"},
+		{createdBy: "/bin/sh -c #(nop) COPY dir:abc123 in /app ", expected: "COPY dir:abc123 in /app"},
+		{createdBy: "COPY . /app # buildkit", expected: "COPY . /app # buildkit"},
+		{createdBy: "", expected: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.createdBy, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(historyInstruction(tc.createdBy)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_Build_checkSizeBudgets(t *testing.T) {
+	layer := func(n int, size int64) ociv1.Descriptor {
+		return ociv1.Descriptor{
+			MediaType: ociv1.MediaTypeImageLayer,
+			Digest:    digest.FromString(string(rune('a' + n))),
+			Size:      size,
+		}
+	}
+	manifest, _ := json.Marshal(ociv1.Manifest{Layers: []ociv1.Descriptor{
+		layer(0, 200_000_000),
+		layer(1, 1_500_000_000),
+		layer(2, 10_000),
+	}})
+	history := []ociv1.History{
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:base in / "},
+		{CreatedBy: "/bin/sh -c #(nop) ENV PATH=/usr/bin", EmptyLayer: true},
+		{CreatedBy: "/bin/sh -c dnf install -y texlive"},
+		{CreatedBy: "/bin/sh -c #(nop) COPY file:app in /app "},
+	}
+
+	mockBuildah := &mockBuildahCli{
+		InspectImageFunc: func(name string) (cliwrappers.BuildahImageInfo, error) {
+			info := cliwrappers.BuildahImageInfo{Manifest: string(manifest)}
+			info.OCIv1.History = history
+			return info, nil
+		},
+	}
+
+	t.Run("should report sizes within budgets", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &Build{Params: &BuildParams{OutputRef: "quay.io/org/image:tag"}, CliWrappers: BuildCliWrappers{BuildahCli: mockBuildah}}
+
+		report, err := c.checkSizeBudgets(&sizeBudgets{maxImageSize: 2_000_000_000, maxLayers: 3})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(report.UncompressedSize).To(Equal(int64(1_700_010_000)))
+		g.Expect(report.LayerCount).To(Equal(3))
+		g.Expect(report.ExceededBudgets).To(BeEmpty())
+		g.Expect(report.BiggestLayers).To(HaveLen(3))
+		g.Expect(report.BiggestLayers[0]).To(Equal(LayerSize{
+			Index: 1, Digest: layer(1, 0).Digest.String(), UncompressedSize: 1_500_000_000, Instruction: "RUN dnf install -y texlive",
+		}))
+		g.Expect(report.BiggestLayers[1].Instruction).To(Equal("ADD file:base in /"))
+		g.Expect(report.BiggestLayers[2].Instruction).To(Equal("COPY file:app in /app"))
+	})
+
+	t.Run("should report exceeded budgets", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &Build{Params: &BuildParams{OutputRef: "quay.io/org/image:tag"}, CliWrappers: BuildCliWrappers{BuildahCli: mockBuildah}}
+
+		report, err := c.checkSizeBudgets(&sizeBudgets{maxImageSize: 1_000_000_000, maxLayers: 2, maxLayerSize: 100_000_000})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(report.ExceededBudgets).To(Equal([]string{
+			"image size 1.6 GiB exceeds max-image-size 953.7 MiB",
+			"3 layers exceed max-layers 2",
+			"layer 1 (RUN dnf install -y texlive) size 1.4 GiB exceeds max-layer-size 95.4 MiB",
+			"layer 0 (ADD file:base in /) size 190.7 MiB exceeds max-layer-size 95.4 MiB",
+		}))
+	})
+
+	t.Run("should add compressed sizes after push", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &Build{Params: &BuildParams{OutputRef: "quay.io/org/image:tag"}, CliWrappers: BuildCliWrappers{BuildahCli: mockBuildah}}
+
+		report, err := c.checkSizeBudgets(&sizeBudgets{maxLayers: 10})
+		g.Expect(err).ToNot(HaveOccurred())
+		report.addCompressedSizes([]PushedImageLayers{{
+			Layers:    []PushedLayer{{Size: 80_000_000}, {Size: 600_000_000}, {Size: 4_000}},
+			TotalSize: 680_004_000,
+		}})
+		g.Expect(report.CompressedSize).To(Equal(int64(680_004_000)))
+		g.Expect(report.BiggestLayers[0].CompressedSize).To(Equal(int64(600_000_000)))
+		g.Expect(report.BiggestLayers[2].CompressedSize).To(Equal(int64(4_000)))
+	})
+}
+
+func Test_newImageSizeReport_incompleteHistory(t *testing.T) {
+	g := NewWithT(t)
+	layers := []ociv1.Descriptor{{Digest: digest.FromString("a"), Size: 10}, {Digest: digest.FromString("b"), Size: 20}}
+	report := newImageSizeReport(layers, []ociv1.History{{CreatedBy: "/bin/sh -c true"}})
+	g.Expect(report.BiggestLayers[0].Instruction).To(BeEmpty())
+	g.Expect(report.BiggestLayers[0].Index).To(Equal(1))
+}
+
+func Test_Build_Run_sizeBudgets(t *testing.T) {
+	manifest, _ := json.Marshal(ociv1.Manifest{Layers: []ociv1.Descriptor{{Digest: digest.FromString("a"), Size: 3_000_000_000}}})
+
+	for _, action := range []string{"fail", "warn"} {
+		t.Run("should "+action+" when a budget is exceeded", func(t *testing.T) {
+			g := NewWithT(t)
+
+			contextDir := t.TempDir()
+			g.Expect(os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte("FROM scratch"), 0644)).To(Succeed())
+
+			pushed := false
+			mockBuildah := &mockBuildahCli{
+				InspectImageFunc: func(name string) (cliwrappers.BuildahImageInfo, error) {
+					return cliwrappers.BuildahImageInfo{Manifest: string(manifest)}, nil
+				},
+				PushFunc: func(args *cliwrappers.BuildahPushArgs) (string, error) {
+					pushed = true
+					return "sha256:1234567890abcdef", nil
+				},
+			}
+
+			c := &Build{
+				Params: &BuildParams{
+					OutputRef:        "quay.io/org/image:tag",
+					Context:          contextDir,
+					Push:             true,
+					SkipInjections:   true,
+					MaxImageSize:     "2GB",
+					SizeBudgetAction: action,
+				},
+				CliWrappers:   BuildCliWrappers{BuildahCli: mockBuildah},
+				ResultsWriter: &mockResultsWriter{},
+			}
+
+			err := c.Run()
+			if action == "fail" {
+				g.Expect(err).To(MatchError("image exceeds size budgets: image size 2.8 GiB exceeds max-image-size 1.9 GiB"))
+				g.Expect(pushed).To(BeFalse())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(pushed).To(BeTrue())
+			}
+			g.Expect(c.Results.ImageSize.ExceededBudgets).To(HaveLen(1))
+		})
+	}
+}
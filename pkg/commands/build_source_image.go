@@ -0,0 +1,712 @@
+package commands
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+const (
+	sourceImageTagSuffix = ".src"
+
+	// Directories of the source container layout: each layer adds a single file to one of them.
+	sourceImageRpmDir   = "rpm_dir"
+	sourceImageExtraDir = "extra_src_dir"
+)
+
+var BuildSourceImageParamsConfig = map[string]common.Parameter{
+	"image-url": {
+		Name:       "image-url",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_IMAGE_IMAGE_URL",
+		TypeKind:   reflect.String,
+		Usage:      "Binary image URL. The source image is pushed to the image repository where this binary image is.",
+		Required:   true,
+	},
+	"image-digest": {
+		Name:       "image-digest",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_IMAGE_IMAGE_DIGEST",
+		TypeKind:   reflect.String,
+		Usage:      "Digest of the binary image. The source image is tagged <digest-tag>.src, e.g. sha256-1234567.src.",
+		Required:   true,
+	},
+	"source-dir": {
+		Name:       "source-dir",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_IMAGE_SOURCE_DIR",
+		TypeKind:   reflect.String,
+		Usage:      "Directory containing the source code. It is archived without the .git directory.\nMutually exclusive with source-archive.",
+	},
+	"source-archive": {
+		Name:       "source-archive",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_IMAGE_SOURCE_ARCHIVE",
+		TypeKind:   reflect.String,
+		Usage:      "Archive of the source code, e.g. the one created by git-clone. It is added to the source image as is.\nMutually exclusive with source-dir.",
+	},
+	"prefetch-dir": {
+		Name:       "prefetch-dir",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_IMAGE_PREFETCH_DIR",
+		TypeKind:   reflect.String,
+		Usage:      "Directory containing the outputs of the prefetch-dependencies subcommand.\nThe SRPMs and the sources of the other package managers are added to the source image.",
+	},
+	"resolved-base-images": {
+		Name:       "resolved-base-images",
+		ShortName:  "",
+		EnvVarName: "KBC_BUILD_SOURCE_IMAGE_RESOLVED_BASE_IMAGES",
+		TypeKind:   reflect.String,
+		Usage:      "File written by 'image build --resolved-base-images-output'.\nThe layers of the source images of these base images are included in the source image.",
+	},
+}
+
+type BuildSourceImageParams struct {
+	ImageUrl           string `paramName:"image-url"`
+	ImageDigest        string `paramName:"image-digest"`
+	SourceDir          string `paramName:"source-dir"`
+	SourceArchive      string `paramName:"source-archive"`
+	PrefetchDir        string `paramName:"prefetch-dir"`
+	ResolvedBaseImages string `paramName:"resolved-base-images"`
+}
+
+type BuildSourceImageResults struct {
+	ImageUrl    string `json:"image_url"`
+	ImageDigest string `json:"image_digest"`
+	// The source images of the base images whose layers are included
+	BaseSourceImages []string `json:"base_source_images,omitempty"`
+}
+
+type BuildSourceImageCliWrappers struct {
+	SkopeoCli cliWrappers.SkopeoCliInterface
+}
+
+// BuildSourceImage assembles the source container image of a binary image and pushes it
+// next to the binary image.
+type BuildSourceImage struct {
+	Params        *BuildSourceImageParams
+	CliWrappers   BuildSourceImageCliWrappers
+	Results       BuildSourceImageResults
+	ResultsWriter common.ResultsWriterInterface
+
+	imageName string
+}
+
+func NewBuildSourceImage(cmd *cobra.Command) (*BuildSourceImage, error) {
+	params := &BuildSourceImageParams{}
+	if err := common.ParseParameters(cmd, BuildSourceImageParamsConfig, params); err != nil {
+		return nil, err
+	}
+	buildSourceImage := &BuildSourceImage{
+		Params:        params,
+		ResultsWriter: common.NewResultsWriter(),
+	}
+	if err := buildSourceImage.initCliWrappers(); err != nil {
+		return nil, err
+	}
+	return buildSourceImage, nil
+}
+
+func (c *BuildSourceImage) initCliWrappers() error {
+	skopeoCli, err := cliWrappers.NewSkopeoCli(cliWrappers.NewCliExecutor())
+	if err != nil {
+		return err
+	}
+	c.CliWrappers.SkopeoCli = skopeoCli
+	return nil
+}
+
+// Run executes the command logic.
+func (c *BuildSourceImage) Run() error {
+	common.LogParameters(BuildSourceImageParamsConfig, c.Params)
+
+	c.imageName = common.GetImageName(c.Params.ImageUrl)
+	if err := c.validateParams(); err != nil {
+		return err
+	}
+
+	workDir, err := os.MkdirTemp("", "kbc-source-image-")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(workDir); err != nil {
+			l.Logger.Warnf("Failed to clean up temporary directory %s: %s", workDir, err)
+		}
+	}()
+
+	layout, err := newSourceImageLayout(filepath.Join(workDir, "layout"))
+	if err != nil {
+		return err
+	}
+
+	if c.Params.ResolvedBaseImages != "" {
+		if err := c.addBaseSourceImages(layout, workDir); err != nil {
+			return err
+		}
+	}
+	if c.Params.PrefetchDir != "" {
+		if err := c.addPrefetchedSources(layout, workDir); err != nil {
+			return err
+		}
+	}
+	if err := c.addSourceCode(layout, workDir); err != nil {
+		return err
+	}
+
+	if err := layout.writeImage(); err != nil {
+		return fmt.Errorf("writing source image: %w", err)
+	}
+
+	imageUrl := c.imageName + ":" + digestTag(c.Params.ImageDigest) + sourceImageTagSuffix
+	l.Logger.Infof("Pushing source image with %d layers to %s", len(layout.layers), imageUrl)
+	digestFile := filepath.Join(workDir, "digest")
+	err = c.CliWrappers.SkopeoCli.Copy(&cliWrappers.SkopeoCopyArgs{
+		SourceImage:      common.OCILayoutTransport + layout.dir,
+		DestinationImage: imageUrl,
+		RetryTimes:       3,
+		ExtraArgs:        []string{"--digestfile", digestFile},
+	})
+	if err != nil {
+		return fmt.Errorf("pushing source image: %w", err)
+	}
+	pushedDigest, err := os.ReadFile(digestFile)
+	if err != nil {
+		return fmt.Errorf("reading digest of pushed source image: %w", err)
+	}
+
+	c.Results.ImageUrl = imageUrl
+	c.Results.ImageDigest = strings.TrimSpace(string(pushedDigest))
+	l.Logger.Infof("Source image pushed: %s@%s", c.Results.ImageUrl, c.Results.ImageDigest)
+
+	if resultJson, err := c.ResultsWriter.CreateResultJson(c.Results); err == nil {
+		fmt.Print(resultJson)
+	} else {
+		l.Logger.Errorf("failed to create results json: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *BuildSourceImage) validateParams() error {
+	if !common.IsImageNameValid(c.imageName) {
+		return fmt.Errorf("image name '%s' is invalid", c.imageName)
+	}
+	if !common.IsImageDigestValid(c.Params.ImageDigest) {
+		return fmt.Errorf("image digest '%s' is invalid", c.Params.ImageDigest)
+	}
+	if (c.Params.SourceDir == "") == (c.Params.SourceArchive == "") {
+		return fmt.Errorf("exactly one of source-dir and source-archive must be set")
+	}
+	return nil
+}
+
+// The tag form of a digest, e.g. sha256-1234567 for sha256:1234567.
+func digestTag(d string) string {
+	return strings.Replace(d, ":", "-", 1)
+}
+
+// Read the canonical references from a file written by --resolved-base-images-output.
+// References without a digest are skipped, their source image can't be found.
+func readResolvedBaseImages(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var images []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line '%s', expected '<ref> <canonical-ref>'", scanner.Text())
+		}
+		canonicalRef := fields[1]
+		if common.GetImageDigest(canonicalRef) == "" {
+			l.Logger.Warnf("Base image %s has no digest, skipping its source image", canonicalRef)
+			continue
+		}
+		if !seen[canonicalRef] {
+			seen[canonicalRef] = true
+			images = append(images, canonicalRef)
+		}
+	}
+	return images, scanner.Err()
+}
+
+// The source image of an image, following the same tagging convention as our own source images.
+func baseSourceImageRef(canonicalRef string) string {
+	return common.GetImageName(canonicalRef) + ":" + digestTag(common.GetImageDigest(canonicalRef)) + sourceImageTagSuffix
+}
+
+// Add the layers of the base images' source images. Base images without a source image
+// are skipped, failing to check or copy an existing source image is an error.
+func (c *BuildSourceImage) addBaseSourceImages(layout *sourceImageLayout, workDir string) error {
+	baseImages, err := readResolvedBaseImages(c.Params.ResolvedBaseImages)
+	if err != nil {
+		return fmt.Errorf("reading resolved base images: %w", err)
+	}
+
+	for i, baseImage := range baseImages {
+		sourceImage := baseSourceImageRef(baseImage)
+		_, err := c.CliWrappers.SkopeoCli.Inspect(&cliWrappers.SkopeoInspectArgs{
+			ImageRef:   sourceImage,
+			Raw:        true,
+			RetryTimes: 3,
+		})
+		if err != nil {
+			if isImageNotFoundError(err) {
+				l.Logger.Infof("Base image %s has no source image, skipping it", baseImage)
+				continue
+			}
+			return fmt.Errorf("checking source image %s: %w", sourceImage, err)
+		}
+
+		baseLayoutDir := filepath.Join(workDir, fmt.Sprintf("base-%d", i))
+		err = c.CliWrappers.SkopeoCli.Copy(&cliWrappers.SkopeoCopyArgs{
+			SourceImage:      sourceImage,
+			DestinationImage: common.OCILayoutTransport + baseLayoutDir,
+			RetryTimes:       3,
+		})
+		if err != nil {
+			return fmt.Errorf("copying source image %s: %w", sourceImage, err)
+		}
+
+		l.Logger.Infof("Adding layers of base source image %s", sourceImage)
+		if err := layout.addImageLayers(baseLayoutDir); err != nil {
+			return fmt.Errorf("adding layers of %s: %w", sourceImage, err)
+		}
+		c.Results.BaseSourceImages = append(c.Results.BaseSourceImages, sourceImage)
+	}
+	return nil
+}
+
+// Whether a skopeo error means that the image (or the whole repository) doesn't exist.
+func isImageNotFoundError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "manifest unknown") || strings.Contains(msg, "name unknown")
+}
+
+// Add the SRPMs of the prefetched RPMs, then an archive for each of the other package managers.
+func (c *BuildSourceImage) addPrefetchedSources(layout *sourceImageLayout, workDir string) error {
+	depsDir := filepath.Join(c.Params.PrefetchDir, "output", "deps")
+	entries, err := os.ReadDir(depsDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			l.Logger.Infof("No prefetched dependencies found in %s", c.Params.PrefetchDir)
+			return nil
+		}
+		return fmt.Errorf("reading prefetched dependencies: %w", err)
+	}
+
+	srpms, err := findSrpms(filepath.Join(depsDir, "rpm"))
+	if err != nil {
+		return fmt.Errorf("searching SRPMs: %w", err)
+	}
+	for _, srpm := range srpms {
+		name := filepath.Join(sourceImageRpmDir, filepath.Base(srpm))
+		if layout.hasFile(name) {
+			l.Logger.Debugf("%s is already included by a base source image", name)
+			continue
+		}
+		if err := layout.addFileLayer(name, srpm); err != nil {
+			return fmt.Errorf("adding %s: %w", srpm, err)
+		}
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "rpm" {
+			continue
+		}
+		archiveName := "prefetch-" + entry.Name() + ".tar.gz"
+		archivePath := filepath.Join(workDir, archiveName)
+		if err := archiveDir(filepath.Join(depsDir, entry.Name()), archivePath, nil); err != nil {
+			return fmt.Errorf("archiving prefetched %s dependencies: %w", entry.Name(), err)
+		}
+		if err := layout.addFileLayer(filepath.Join(sourceImageExtraDir, archiveName), archivePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find the SRPMs in the prefetched RPMs, sorted by file name. The same SRPM may
+// have been prefetched for more than one architecture, it is only listed once.
+func findSrpms(rpmDir string) ([]string, error) {
+	srpms := make(map[string]string)
+	if _, err := os.Stat(rpmDir); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	err := filepath.WalkDir(rpmDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(d.Name(), ".src.rpm") {
+			if _, ok := srpms[d.Name()]; !ok {
+				srpms[d.Name()] = path
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(srpms))
+	for _, name := range sortedKeys(srpms) {
+		paths = append(paths, srpms[name])
+	}
+	return paths, nil
+}
+
+func (c *BuildSourceImage) addSourceCode(layout *sourceImageLayout, workDir string) error {
+	archivePath := c.Params.SourceArchive
+	if archivePath == "" {
+		archivePath = filepath.Join(workDir, "source.tar.gz")
+		err := archiveDir(c.Params.SourceDir, archivePath, func(path string) bool { return path == ".git" })
+		if err != nil {
+			return fmt.Errorf("archiving source directory: %w", err)
+		}
+	}
+	if err := layout.addFileLayer(filepath.Join(sourceImageExtraDir, filepath.Base(archivePath)), archivePath); err != nil {
+		return fmt.Errorf("adding source code: %w", err)
+	}
+	return nil
+}
+
+// Write a reproducible gzipped tarball of a directory: entries are sorted, owned by root
+// and have no timestamps. Paths (relative to dir) for which exclude returns true are skipped.
+func archiveDir(dir, outputPath string, exclude func(path string) bool) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		if exclude != nil && exclude(relPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var linkname string
+		if d.Type()&fs.ModeSymlink != 0 {
+			if linkname, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, linkname)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		normalizeTarHeader(header)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			return copyFileTo(tw, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func normalizeTarHeader(header *tar.Header) {
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+	header.ModTime = time.Unix(0, 0)
+	header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+	header.Format = tar.FormatPAX
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// An OCI layout in which the source image is assembled.
+type sourceImageLayout struct {
+	dir     string
+	layers  []ociv1.Descriptor
+	diffIDs []digest.Digest
+	history []ociv1.History
+	// Paths of the files added by the layers, e.g. /rpm_dir/bash-5.1-1.src.rpm
+	files map[string]bool
+}
+
+func newSourceImageLayout(dir string) (*sourceImageLayout, error) {
+	if err := os.MkdirAll(filepath.Join(dir, ociv1.ImageBlobsDir, digest.Canonical.String()), 0755); err != nil {
+		return nil, err
+	}
+	layoutFile, err := json.Marshal(ociv1.ImageLayout{Version: ociv1.ImageLayoutVersion})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ociv1.ImageLayoutFile), layoutFile, 0644); err != nil {
+		return nil, err
+	}
+	return &sourceImageLayout{dir: dir, files: make(map[string]bool)}, nil
+}
+
+func (s *sourceImageLayout) blobPath(d digest.Digest) string {
+	return filepath.Join(s.dir, ociv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+func (s *sourceImageLayout) hasFile(name string) bool {
+	return s.files[filepath.Clean("/"+name)]
+}
+
+// Add the layers of the image in another OCI layout, skipping layers that are already included.
+func (s *sourceImageLayout) addImageLayers(dir string) error {
+	image, err := readLayoutImage(dir)
+	if err != nil {
+		return err
+	}
+	var config ociv1.Image
+	if err := image.readJSONBlob(image.manifest.Config.Digest, &config); err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	if len(config.RootFS.DiffIDs) != len(image.manifest.Layers) {
+		return fmt.Errorf("config lists %d layers, manifest %d", len(config.RootFS.DiffIDs), len(image.manifest.Layers))
+	}
+	var history []ociv1.History
+	for _, entry := range config.History {
+		if !entry.EmptyLayer {
+			history = append(history, entry)
+		}
+	}
+	if len(history) != len(image.manifest.Layers) {
+		history = nil
+	}
+
+	for i, layer := range image.manifest.Layers {
+		if s.hasLayer(layer.Digest) {
+			continue
+		}
+		files, err := readLayerFiles(image.blobPath(layer.Digest), layer.MediaType)
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+		if err := linkOrCopyFile(image.blobPath(layer.Digest), s.blobPath(layer.Digest)); err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+		for path := range files {
+			s.files[path] = true
+		}
+		entry := ociv1.History{CreatedBy: "layer of base source image"}
+		if history != nil {
+			entry = history[i]
+		}
+		s.addLayer(layer, config.RootFS.DiffIDs[i], entry)
+	}
+	return nil
+}
+
+func (s *sourceImageLayout) hasLayer(d digest.Digest) bool {
+	for _, layer := range s.layers {
+		if layer.Digest == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sourceImageLayout) addLayer(layer ociv1.Descriptor, diffID digest.Digest, history ociv1.History) {
+	s.layers = append(s.layers, ociv1.Descriptor{MediaType: layer.MediaType, Digest: layer.Digest, Size: layer.Size})
+	s.diffIDs = append(s.diffIDs, diffID)
+	s.history = append(s.history, history)
+}
+
+// Add a layer containing a single file, at the given path in the layer.
+func (s *sourceImageLayout) addFileLayer(name, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	l.Logger.Debugf("Adding layer with %s", name)
+
+	blob, err := os.CreateTemp(filepath.Join(s.dir, ociv1.ImageBlobsDir), "layer-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(blob.Name())
+	defer blob.Close()
+
+	compressedDigester := digest.Canonical.Digester()
+	uncompressedDigester := digest.Canonical.Digester()
+	gw := gzip.NewWriter(io.MultiWriter(blob, compressedDigester.Hash()))
+	tw := tar.NewWriter(io.MultiWriter(gw, uncompressedDigester.Hash()))
+
+	header := &tar.Header{
+		Name:     filepath.ToSlash(name),
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     info.Size(),
+	}
+	normalizeTarHeader(header)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if err := copyFileTo(tw, path); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	size, err := blob.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err := blob.Close(); err != nil {
+		return err
+	}
+
+	layerDigest := compressedDigester.Digest()
+	if err := os.Rename(blob.Name(), s.blobPath(layerDigest)); err != nil {
+		return err
+	}
+	if !s.hasLayer(layerDigest) {
+		s.files[filepath.Clean("/"+name)] = true
+		s.addLayer(
+			ociv1.Descriptor{MediaType: ociv1.MediaTypeImageLayerGzip, Digest: layerDigest, Size: size},
+			uncompressedDigester.Digest(),
+			ociv1.History{CreatedBy: "adding " + header.Name},
+		)
+	}
+	return nil
+}
+
+// Write the config, manifest and index of the source image.
+func (s *sourceImageLayout) writeImage() error {
+	// Source images don't run anywhere, use the platform that registries and tools expect
+	config := ociv1.Image{
+		Platform: ociv1.Platform{OS: "linux", Architecture: "amd64"},
+		RootFS:   ociv1.RootFS{Type: "layers", DiffIDs: s.diffIDs},
+		History:  s.history,
+	}
+	configDescriptor, err := s.writeJSONBlob(ociv1.MediaTypeImageConfig, config)
+	if err != nil {
+		return err
+	}
+
+	manifest := ociv1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ociv1.MediaTypeImageManifest,
+		Config:    configDescriptor,
+		Layers:    s.layers,
+	}
+	if manifest.Layers == nil {
+		manifest.Layers = []ociv1.Descriptor{}
+	}
+	manifestDescriptor, err := s.writeJSONBlob(ociv1.MediaTypeImageManifest, manifest)
+	if err != nil {
+		return err
+	}
+
+	index := ociv1.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ociv1.MediaTypeImageIndex,
+		Manifests: []ociv1.Descriptor{manifestDescriptor},
+	}
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, ociv1.ImageIndexFile), content, 0644)
+}
+
+func (s *sourceImageLayout) writeJSONBlob(mediaType string, v any) (ociv1.Descriptor, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return ociv1.Descriptor{}, err
+	}
+	d := digest.FromBytes(content)
+	if err := os.WriteFile(s.blobPath(d), content, 0644); err != nil {
+		return ociv1.Descriptor{}, err
+	}
+	return ociv1.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}, nil
+}
+
+// Hard link a file, falling back to a copy (e.g. across filesystems).
+func linkOrCopyFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return nil
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func Test_BuildSourceImage_validateParams(t *testing.T) {
+	const validDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	tests := []struct {
+		name         string
+		params       BuildSourceImageParams
+		errSubstring string
+	}{
+		{
+			name:   "should accept source directory",
+			params: BuildSourceImageParams{ImageUrl: "quay.io/org/app:latest", ImageDigest: validDigest, SourceDir: "source"},
+		},
+		{
+			name:         "should fail on invalid digest",
+			params:       BuildSourceImageParams{ImageUrl: "quay.io/org/app", ImageDigest: "sha256:123", SourceDir: "source"},
+			errSubstring: "image digest 'sha256:123' is invalid",
+		},
+		{
+			name:         "should fail without source",
+			params:       BuildSourceImageParams{ImageUrl: "quay.io/org/app", ImageDigest: validDigest},
+			errSubstring: "exactly one of source-dir and source-archive must be set",
+		},
+		{
+			name:         "should fail with both source directory and archive",
+			params:       BuildSourceImageParams{ImageUrl: "quay.io/org/app", ImageDigest: validDigest, SourceDir: "source", SourceArchive: "source.tar.gz"},
+			errSubstring: "exactly one of source-dir and source-archive must be set",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := &BuildSourceImage{Params: &tc.params}
+			c.imageName = "quay.io/org/app"
+			err := c.validateParams()
+			if tc.errSubstring != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.errSubstring)))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func Test_readResolvedBaseImages(t *testing.T) {
+	g := NewWithT(t)
+	path := filepath.Join(t.TempDir(), "resolved-base-images.txt")
+	content := "registry.io/base:9 registry.io/base:9@sha256:1111111111111111111111111111111111111111111111111111111111111111\n" +
+		"builder registry.io/builder@sha256:2222222222222222222222222222222222222222222222222222222222222222\n" +
+		"base registry.io/base:9@sha256:1111111111111111111111111111111111111111111111111111111111111111\n" +
+		"oci-archive:base.tar oci-archive:base.tar\n"
+	g.Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+
+	images, err := readResolvedBaseImages(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(images).To(Equal([]string{
+		"registry.io/base:9@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"registry.io/builder@sha256:2222222222222222222222222222222222222222222222222222222222222222",
+	}))
+	g.Expect(baseSourceImageRef(images[0])).To(Equal("registry.io/base:sha256-1111111111111111111111111111111111111111111111111111111111111111.src"))
+}
+
+func Test_BuildSourceImage_Run(t *testing.T) {
+	g := NewWithT(t)
+	const (
+		imageDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		baseImage   = "registry.io/base@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		otherImage  = "registry.io/other@sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	epoch := time.Unix(0, 0).UTC()
+
+	tempDir := t.TempDir()
+	writeFile := func(path, content string) {
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		g.Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	sourceDir := filepath.Join(tempDir, "source")
+	writeFile(filepath.Join(sourceDir, "main.go"), "package main")
+	writeFile(filepath.Join(sourceDir, ".git", "HEAD"), "ref: refs/heads/main")
+
+	prefetchDir := filepath.Join(tempDir, "prefetch")
+	writeFile(filepath.Join(prefetchDir, "output", "deps", "rpm", "x86_64", "repo-source", "bash-5.1-1.src.rpm"), "bash")
+	writeFile(filepath.Join(prefetchDir, "output", "deps", "rpm", "x86_64", "repo-source", "jq-1.7-1.src.rpm"), "jq")
+	writeFile(filepath.Join(prefetchDir, "output", "deps", "rpm", "aarch64", "repo-source", "jq-1.7-1.src.rpm"), "jq")
+	writeFile(filepath.Join(prefetchDir, "output", "deps", "rpm", "x86_64", "repo", "jq-1.7-1.x86_64.rpm"), "binary")
+	writeFile(filepath.Join(prefetchDir, "output", "deps", "pip", "requests-2.32.tar.gz"), "requests")
+
+	resolvedBaseImages := filepath.Join(tempDir, "resolved-base-images.txt")
+	writeFile(resolvedBaseImages, "base "+baseImage+"\nother "+otherImage+"\n")
+
+	var pushedLayers [][]string
+	mockSkopeo := &mockSkopeoCli{
+		CopyFunc: func(args *cliwrappers.SkopeoCopyArgs) error {
+			switch args.SourceImage {
+			case "registry.io/base:sha256-1111111111111111111111111111111111111111111111111111111111111111.src":
+				writeTestOCILayoutWithConfig(t, strings.TrimPrefix(args.DestinationImage, "oci:"), func(diffIDs []digest.Digest) []byte {
+					content, err := json.Marshal(ociv1.Image{RootFS: ociv1.RootFS{Type: "layers", DiffIDs: diffIDs}})
+					g.Expect(err).ToNot(HaveOccurred())
+					return content
+				}, []testLayerFile{
+					{name: "rpm_dir/bash-5.1-1.src.rpm", content: "bash", mode: 0644, modTime: epoch},
+				})
+				return nil
+			case "registry.io/other:sha256-2222222222222222222222222222222222222222222222222222222222222222.src":
+				t.Fatal("the missing source image must not be copied")
+			}
+
+			g.Expect(args.DestinationImage).To(Equal("quay.io/org/app:sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.src"))
+			g.Expect(args.ExtraArgs).To(HaveLen(2))
+			image, err := readLayoutImage(strings.TrimPrefix(args.SourceImage, "oci:"))
+			g.Expect(err).ToNot(HaveOccurred())
+			for _, layer := range image.manifest.Layers {
+				pushedLayers = append(pushedLayers, readTestLayerNames(t, image.blobPath(layer.Digest)))
+			}
+			var config ociv1.Image
+			g.Expect(image.readJSONBlob(image.manifest.Config.Digest, &config)).To(Succeed())
+			g.Expect(config.RootFS.DiffIDs).To(HaveLen(len(image.manifest.Layers)))
+			g.Expect(config.History).To(HaveLen(len(image.manifest.Layers)))
+
+			return os.WriteFile(args.ExtraArgs[1], []byte("sha256:abcdef\n"), 0644)
+		},
+	}
+
+	mockSkopeo.InspectFunc = func(args *cliwrappers.SkopeoInspectArgs) (string, error) {
+		if args.ImageRef == "registry.io/other:sha256-2222222222222222222222222222222222222222222222222222222222222222.src" {
+			return "", errors.New("exit status 1: reading manifest: manifest unknown")
+		}
+		return "{}", nil
+	}
+
+	c := &BuildSourceImage{
+		Params: &BuildSourceImageParams{
+			ImageUrl:           "quay.io/org/app:latest",
+			ImageDigest:        imageDigest,
+			SourceDir:          sourceDir,
+			PrefetchDir:        prefetchDir,
+			ResolvedBaseImages: resolvedBaseImages,
+		},
+		CliWrappers:   BuildSourceImageCliWrappers{SkopeoCli: mockSkopeo},
+		ResultsWriter: &mockResultsWriter{},
+	}
+	g.Expect(c.Run()).To(Succeed())
+
+	g.Expect(pushedLayers).To(Equal([][]string{
+		{"rpm_dir/bash-5.1-1.src.rpm"},
+		{"rpm_dir/jq-1.7-1.src.rpm"},
+		{"extra_src_dir/prefetch-pip.tar.gz"},
+		{"extra_src_dir/source.tar.gz"},
+	}))
+	g.Expect(c.Results).To(Equal(BuildSourceImageResults{
+		ImageUrl:    "quay.io/org/app:sha256-e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.src",
+		ImageDigest: "sha256:abcdef",
+		BaseSourceImages: []string{
+			"registry.io/base:sha256-1111111111111111111111111111111111111111111111111111111111111111.src",
+		},
+	}))
+}
+
+func Test_BuildSourceImage_addBaseSourceImages(t *testing.T) {
+	const baseImage = "registry.io/base@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	newBuildSourceImage := func(t *testing.T, skopeoCli *mockSkopeoCli) *BuildSourceImage {
+		resolvedBaseImages := filepath.Join(t.TempDir(), "resolved-base-images.txt")
+		NewWithT(t).Expect(os.WriteFile(resolvedBaseImages, []byte("base "+baseImage+"\n"), 0644)).To(Succeed())
+		return &BuildSourceImage{
+			Params:      &BuildSourceImageParams{ResolvedBaseImages: resolvedBaseImages},
+			CliWrappers: BuildSourceImageCliWrappers{SkopeoCli: skopeoCli},
+		}
+	}
+
+	t.Run("should skip base images without a source image", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newBuildSourceImage(t, &mockSkopeoCli{
+			InspectFunc: func(args *cliwrappers.SkopeoInspectArgs) (string, error) {
+				return "", errors.New("exit status 1: reading manifest: NAME_UNKNOWN: name unknown")
+			},
+			CopyFunc: func(args *cliwrappers.SkopeoCopyArgs) error {
+				t.Fatal("the missing source image must not be copied")
+				return nil
+			},
+		})
+
+		g.Expect(c.addBaseSourceImages(nil, t.TempDir())).To(Succeed())
+		g.Expect(c.Results.BaseSourceImages).To(BeEmpty())
+	})
+
+	t.Run("should fail if the source image can't be checked", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newBuildSourceImage(t, &mockSkopeoCli{
+			InspectFunc: func(args *cliwrappers.SkopeoInspectArgs) (string, error) {
+				return "", errors.New("exit status 1: unauthorized: access to the requested resource is not authorized")
+			},
+		})
+
+		err := c.addBaseSourceImages(nil, t.TempDir())
+		g.Expect(err).To(MatchError(ContainSubstring("checking source image registry.io/base:sha256-1111111111111111111111111111111111111111111111111111111111111111.src: exit status 1: unauthorized")))
+	})
+
+	t.Run("should fail if an existing source image can't be copied", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newBuildSourceImage(t, &mockSkopeoCli{
+			CopyFunc: func(args *cliwrappers.SkopeoCopyArgs) error {
+				return errors.New("connection reset by peer")
+			},
+		})
+
+		err := c.addBaseSourceImages(nil, t.TempDir())
+		g.Expect(err).To(MatchError(ContainSubstring("copying source image registry.io/base:sha256-1111111111111111111111111111111111111111111111111111111111111111.src: connection reset by peer")))
+	})
+}
+
+func Test_archiveDir(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(dir, "src", ".git"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "src", "b.go"), []byte("b"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "src", "a.go"), []byte("a"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "src", ".git", "HEAD"), []byte("main"), 0644)).To(Succeed())
+	g.Expect(os.Symlink("a.go", filepath.Join(dir, "src", "link"))).To(Succeed())
+
+	isGit := func(path string) bool { return path == ".git" }
+	first, second := filepath.Join(dir, "first.tar.gz"), filepath.Join(dir, "second.tar.gz")
+	g.Expect(archiveDir(filepath.Join(dir, "src"), first, isGit)).To(Succeed())
+	g.Expect(os.Chtimes(filepath.Join(dir, "src", "a.go"), time.Now(), time.Now().Add(time.Hour))).To(Succeed())
+	g.Expect(archiveDir(filepath.Join(dir, "src"), second, isGit)).To(Succeed())
+
+	g.Expect(readTestLayerNames(t, first)).To(Equal([]string{"a.go", "b.go", "link"}))
+	firstContent, err := os.ReadFile(first)
+	g.Expect(err).ToNot(HaveOccurred())
+	secondContent, err := os.ReadFile(second)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(firstContent).To(Equal(secondContent))
+}
+
+// The names of the entries in a gzipped tarball, in order.
+func readTestLayerNames(t *testing.T, path string) []string {
+	t.Helper()
+	g := NewWithT(t)
+	f, err := os.Open(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		g.Expect(err).ToNot(HaveOccurred())
+		names = append(names, header.Name)
+	}
+}
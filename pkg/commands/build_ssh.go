@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/gitauth"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// An --ssh argument: the ID that 'RUN --mount=type=ssh,id=ID' refers to and the key file
+// or ssh-directory workspace to load the keys from. Without a path, the caller's agent
+// ($SSH_AUTH_SOCK) is forwarded instead.
+type sshSource struct {
+	id   string
+	path string
+}
+
+// An ephemeral ssh-agent started for the build, killed in cleanup.
+type sshAgent struct {
+	socket string
+	pid    int
+}
+
+func parseSSHArgs(sshArgs []string) ([]sshSource, error) {
+	var sources []sshSource
+	seen := make(map[string]bool)
+
+	for _, arg := range sshArgs {
+		id, path, _ := strings.Cut(arg, "=")
+		id = strings.TrimSpace(id)
+		path = strings.TrimSpace(path)
+		if id == "" {
+			return nil, fmt.Errorf("invalid ssh argument '%s': missing id", arg)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate ssh id '%s'", id)
+		}
+		seen[id] = true
+		sources = append(sources, sshSource{id: id, path: path})
+	}
+
+	return sources, nil
+}
+
+// The private keys to load for the source: the key file itself, or the id_* keys
+// in an ssh-directory workspace (the same discovery that git-clone uses).
+func findSSHKeyFiles(path string) ([]string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []string{path}, nil
+	}
+
+	names, err := gitauth.FindSSHKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no SSH keys (id_* files) found in %s", path)
+	}
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = filepath.Join(path, name)
+	}
+	return keys, nil
+}
+
+// Prepare the buildah --ssh arguments, starting an ssh-agent for each source that provides keys.
+//
+// Buildah gets explicit socket paths rather than relying on $SSH_AUTH_SOCK, so the agents are
+// reachable through the unshare/in-user-namespace wrappers regardless of the environment they
+// pass down. Inside the user namespace, the build runs as the (mapped) user that owns the
+// sockets, which is also the only peer ssh-agent accepts.
+func (c *Build) setSSHArgs() error {
+	sources, err := parseSSHArgs(c.Params.SSH)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		if source.path == "" {
+			socket := os.Getenv("SSH_AUTH_SOCK")
+			if socket == "" {
+				return fmt.Errorf("ssh %s: no key path given and SSH_AUTH_SOCK is not set", source.id)
+			}
+			l.Logger.Infof("Forwarding the ssh-agent at %s to the build as ssh id %s", socket, source.id)
+			c.buildahSSH = append(c.buildahSSH, cliWrappers.BuildahSSH{ID: source.id, Socket: socket})
+			continue
+		}
+
+		keys, err := findSSHKeyFiles(source.path)
+		if err != nil {
+			return fmt.Errorf("ssh %s: %w", source.id, err)
+		}
+
+		if c.Params.DryRun {
+			l.Logger.Warnf("Dry run, not starting an ssh-agent for ssh id %s", source.id)
+			c.buildahSSH = append(c.buildahSSH, cliWrappers.BuildahSSH{ID: source.id})
+			continue
+		}
+
+		socket, err := c.startSSHAgent(keys)
+		if err != nil {
+			return fmt.Errorf("ssh %s: %w", source.id, err)
+		}
+		l.Logger.Infof("Started ssh-agent with %d key(s) from %s, available with 'RUN --mount=type=ssh,id=%s'",
+			len(keys), source.path, source.id)
+		c.buildahSSH = append(c.buildahSSH, cliWrappers.BuildahSSH{ID: source.id, Socket: socket})
+	}
+
+	return nil
+}
+
+// Start an ssh-agent loaded with the keys, returns the path of its socket.
+func (c *Build) startSSHAgent(keys []string) (string, error) {
+	agentDir, err := c.createSocketDir("kbc-ssh-")
+	if err != nil {
+		return "", fmt.Errorf("creating ssh-agent directory: %w", err)
+	}
+
+	socket := filepath.Join(agentDir, "agent.sock")
+	pid, err := c.CliWrappers.SSHAgentCli.Start(socket)
+	if err != nil {
+		return "", fmt.Errorf("starting ssh-agent: %w", err)
+	}
+	c.sshAgents = append(c.sshAgents, sshAgent{socket: socket, pid: pid})
+
+	for _, key := range keys {
+		// ssh-add refuses keys readable by others, which is common for mounted secrets.
+		// Load a private copy, the agent keeps the key in memory.
+		content, err := os.ReadFile(key)
+		if err != nil {
+			return "", fmt.Errorf("reading SSH key: %w", err)
+		}
+		keyCopy := filepath.Join(agentDir, "key")
+		if err := os.WriteFile(keyCopy, content, 0600); err != nil {
+			return "", fmt.Errorf("copying SSH key %s: %w", key, err)
+		}
+		err = c.CliWrappers.SSHAgentCli.AddKey(socket, keyCopy)
+		if rmErr := os.Remove(keyCopy); rmErr != nil {
+			l.Logger.Warnf("Failed to remove SSH key copy %s: %s", keyCopy, rmErr)
+		}
+		if err != nil {
+			return "", fmt.Errorf("adding SSH key %s: %w", key, err)
+		}
+	}
+
+	return socket, nil
+}
+
+func (c *Build) killSSHAgents() {
+	for _, agent := range c.sshAgents {
+		c.CliWrappers.SSHAgentCli.Kill(agent.socket, agent.pid)
+	}
+	c.sshAgents = nil
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/testutil"
+)
+
+func Test_Build_setSSHArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should start an agent per key source and kill them in cleanup", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{
+			"ssh/id_rsa":       "rsa-key",
+			"ssh/id_rsa.pub":   "rsa-pub",
+			"ssh/id_ed25519":   "ed25519-key",
+			"ssh/known_hosts":  "github.com ssh-ed25519 AAAA",
+			"gitlab/deploykey": "deploy-key",
+		})
+		t.Setenv("SSH_AUTH_SOCK", "/run/user/1000/agent.sock")
+
+		var started, killed []string
+		loaded := make(map[string][]string)
+		sshAgentCli := &mockSSHAgentCli{
+			StartFunc: func(socketPath string) (int, error) {
+				started = append(started, socketPath)
+				return 100 + len(started), nil
+			},
+			AddKeyFunc: func(socketPath, keyPath string) error {
+				info, err := os.Stat(keyPath)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+				content, err := os.ReadFile(keyPath)
+				g.Expect(err).ToNot(HaveOccurred())
+				loaded[socketPath] = append(loaded[socketPath], string(content))
+				return nil
+			},
+			KillFunc: func(socketPath string, pid int) {
+				killed = append(killed, socketPath)
+			},
+		}
+
+		c := &Build{
+			Params: &BuildParams{
+				SSH: []string{
+					"default=" + filepath.Join(tempDir, "ssh"),
+					"gitlab=" + filepath.Join(tempDir, "gitlab", "deploykey"),
+					"host",
+				},
+			},
+			CliWrappers: BuildCliWrappers{SSHAgentCli: sshAgentCli},
+		}
+
+		g.Expect(c.setSSHArgs()).To(Succeed())
+
+		g.Expect(started).To(HaveLen(2))
+		g.Expect(c.buildahSSH).To(Equal([]cliwrappers.BuildahSSH{
+			{ID: "default", Socket: started[0]},
+			{ID: "gitlab", Socket: started[1]},
+			{ID: "host", Socket: "/run/user/1000/agent.sock"},
+		}))
+		g.Expect(loaded[started[0]]).To(Equal([]string{"ed25519-key", "rsa-key"}))
+		g.Expect(loaded[started[1]]).To(Equal([]string{"deploy-key"}))
+
+		c.cleanup()
+		g.Expect(killed).To(Equal(started))
+		for _, socket := range started {
+			_, err := os.Stat(filepath.Dir(socket))
+			g.Expect(os.IsNotExist(err)).To(BeTrue())
+		}
+	})
+
+	t.Run("should fail when a directory has no keys", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{
+			"ssh/known_hosts": "github.com ssh-ed25519 AAAA",
+		})
+		c := &Build{
+			Params:      &BuildParams{SSH: []string{"default=" + filepath.Join(tempDir, "ssh")}},
+			CliWrappers: BuildCliWrappers{SSHAgentCli: &mockSSHAgentCli{}},
+		}
+
+		g.Expect(c.setSSHArgs()).To(MatchError(ContainSubstring("no SSH keys (id_* files) found")))
+	})
+
+	t.Run("should fail to forward the agent without SSH_AUTH_SOCK", func(t *testing.T) {
+		t.Setenv("SSH_AUTH_SOCK", "")
+		c := &Build{Params: &BuildParams{SSH: []string{"default"}}}
+
+		g.Expect(c.setSSHArgs()).To(MatchError(ContainSubstring("SSH_AUTH_SOCK is not set")))
+	})
+
+	t.Run("should not start agents in dry run", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{"ssh/id_rsa": "rsa-key"})
+		c := &Build{
+			Params: &BuildParams{SSH: []string{"default=" + filepath.Join(tempDir, "ssh")}, DryRun: true},
+			CliWrappers: BuildCliWrappers{SSHAgentCli: &mockSSHAgentCli{
+				StartFunc: func(socketPath string) (int, error) {
+					t.Fatal("unexpected ssh-agent start")
+					return 0, nil
+				},
+			}},
+		}
+
+		g.Expect(c.setSSHArgs()).To(Succeed())
+		g.Expect(c.buildahSSH).To(Equal([]cliwrappers.BuildahSSH{{ID: "default"}}))
+	})
+}
@@ -1,12 +1,15 @@
 package commands
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -169,6 +172,58 @@ func Test_Build_validateParams(t *testing.T) {
 			errExpected:  true,
 			errSubstring: "yum-repos-d-target must be an absolute path",
 		},
+		{
+			name: "should fail on invalid yum-repos-mirrors entry",
+			params: BuildParams{
+				OutputRef:       "quay.io/org/image:tag",
+				Context:         tempDir,
+				YumReposMirrors: []string{"https://cdn.redhat.com/"},
+			},
+			errExpected:  true,
+			errSubstring: "invalid yum-repos-mirrors entry",
+		},
+		{
+			name: "should fail when ssh is used with hermetic",
+			params: BuildParams{
+				OutputRef: "quay.io/org/image:tag",
+				Context:   tempDir,
+				SSH:       []string{"default=/workspace/ssh"},
+				Hermetic:  true,
+			},
+			errExpected:  true,
+			errSubstring: "ssh and hermetic are mutually exclusive",
+		},
+		{
+			name: "should fail when network-allow is used without hermetic",
+			params: BuildParams{
+				OutputRef:    "quay.io/org/image:tag",
+				Context:      tempDir,
+				NetworkAllow: []string{"registry.npmjs.org:443"},
+			},
+			errExpected:  true,
+			errSubstring: "network-allow requires hermetic",
+		},
+		{
+			name: "should fail on invalid network-allow destination",
+			params: BuildParams{
+				OutputRef:    "quay.io/org/image:tag",
+				Context:      tempDir,
+				NetworkAllow: []string{"registry.npmjs.org"},
+				Hermetic:     true,
+			},
+			errExpected:  true,
+			errSubstring: "invalid network-allow destination 'registry.npmjs.org'",
+		},
+		{
+			name: "should fail on duplicate ssh ids",
+			params: BuildParams{
+				OutputRef: "quay.io/org/image:tag",
+				Context:   tempDir,
+				SSH:       []string{"default=/workspace/ssh", "default"},
+			},
+			errExpected:  true,
+			errSubstring: "duplicate ssh id 'default'",
+		},
 		{
 			name: "should fail when prefetch-dir-copy already exists",
 			params: BuildParams{
@@ -345,6 +400,34 @@ func Test_Build_validateParams(t *testing.T) {
 			errExpected:  true,
 			errSubstring: "requires rhsm-activation-mount or rhsm-activation-preregister",
 		},
+		{
+			name: "should fail when rhsm fallback activation keys and orgs are not paired",
+			params: BuildParams{
+				OutputRef:                  "quay.io/org/image:tag",
+				Context:                    tempDir,
+				RHSMActivationKey:          "/path/to/key",
+				RHSMOrg:                    "/path/to/org",
+				RHSMActivationPreregister:  true,
+				RHSMFallbackActivationKeys: []string{"/path/to/key2", "/path/to/key3"},
+				RHSMFallbackOrgs:           []string{"/path/to/org2"},
+			},
+			errExpected:  true,
+			errSubstring: "must have the same number of values",
+		},
+		{
+			name: "should fail when rhsm fallback activation keys are used without preregister",
+			params: BuildParams{
+				OutputRef:                  "quay.io/org/image:tag",
+				Context:                    tempDir,
+				RHSMActivationKey:          "/path/to/key",
+				RHSMOrg:                    "/path/to/org",
+				RHSMActivationMount:        "/activation-key",
+				RHSMFallbackActivationKeys: []string{"/path/to/key2"},
+				RHSMFallbackOrgs:           []string{"/path/to/org2"},
+			},
+			errExpected:  true,
+			errSubstring: "rhsm-fallback-activation-keys requires rhsm-activation-preregister",
+		},
 		{
 			name: "should allow source with relative context inside source",
 			params: BuildParams{
@@ -402,6 +485,240 @@ func Test_Build_validateParams(t *testing.T) {
 			errExpected:  true,
 			errSubstring: "no such file or directory",
 		},
+		{
+			name: "should allow build contexts inside source",
+			params: BuildParams{
+				OutputRef: "quay.io/org/image:tag",
+				Source:    sourceDir,
+				Context:   "ctx",
+				BuildContexts: []string{
+					"dir=ctx",
+					"layout=oci-layout://ctx:latest",
+					"image=docker-image://quay.io/org/base:1",
+				},
+			},
+			errExpected: false,
+		},
+		{
+			name: "should fail on build context outside source",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Source:        sourceDir,
+				Context:       "ctx",
+				BuildContexts: []string{"dir=../outside-ctx"},
+			},
+			errExpected:  true,
+			errSubstring: "build context 'dir' is outside source directory",
+		},
+		{
+			name: "should fail on missing build context directory",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{"layout=oci-layout://" + filepath.Join(tempDir, "missing")},
+			},
+			errExpected:  true,
+			errSubstring: "build context 'layout': directory '.*missing' does not exist",
+		},
+		{
+			name: "should fail on build context that is not a directory",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{"file=" + filepath.Join(tempDir, "notadir")},
+			},
+			errExpected:  true,
+			errSubstring: "build context 'file': path '.*notadir' is not a directory",
+		},
+		{
+			name: "should fail on invalid build context image",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{"image=docker-image://quay.io/Org/Image"},
+			},
+			errExpected:  true,
+			errSubstring: "build context 'image': invalid image",
+		},
+		{
+			name: "should fail on malformed build contexts",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{"no-location"},
+			},
+			errExpected:  true,
+			errSubstring: "invalid build context 'no-location', expected name=location",
+		},
+		{
+			name: "should fail on duplicate build contexts",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{"dir=" + tempDir, "dir=" + tempDir},
+			},
+			errExpected:  true,
+			errSubstring: "duplicate build context 'dir'",
+		},
+		{
+			name: "should fail on reserved build context name",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{".konflux-buildinfo=" + tempDir},
+			},
+			errExpected:  true,
+			errSubstring: "build context name '.konflux-buildinfo' is reserved",
+		},
+		{
+			name: "should allow output to an OCI layout",
+			params: BuildParams{
+				OutputRef: "quay.io/org/image:tag",
+				Context:   tempDir,
+				Output:    "oci-layout:" + filepath.Join(tempDir, "layout") + ":v1",
+			},
+			errExpected: false,
+		},
+		{
+			name: "should fail on invalid output",
+			params: BuildParams{
+				OutputRef: "quay.io/org/image:tag",
+				Context:   tempDir,
+				Output:    "registry:quay.io/org/image",
+			},
+			errExpected:  true,
+			errSubstring: "output 'registry:quay.io/org/image' is invalid",
+		},
+		{
+			name: "should fail on output with push",
+			params: BuildParams{
+				OutputRef: "quay.io/org/image:tag",
+				Context:   tempDir,
+				Push:      true,
+				Output:    "oci-archive:" + filepath.Join(tempDir, "image.tar"),
+			},
+			errExpected:  true,
+			errSubstring: "push and output are mutually exclusive",
+		},
+		{
+			name: "should fail on unsupported build context location",
+			params: BuildParams{
+				OutputRef:     "quay.io/org/image:tag",
+				Context:       tempDir,
+				BuildContexts: []string{"url=https://example.com/context.tar"},
+			},
+			errExpected:  true,
+			errSubstring: "build context 'url': unsupported location",
+		},
+		{
+			name: "should allow push destinations with push",
+			params: BuildParams{
+				OutputRef:        "quay.io/org/image:tag",
+				Context:          tempDir,
+				Push:             true,
+				PushDestinations: []string{"mirror.example.com/org/image:tag"},
+			},
+			errExpected: false,
+		},
+		{
+			name: "should allow size budgets",
+			params: BuildParams{
+				OutputRef:        "quay.io/org/image:tag",
+				Context:          tempDir,
+				MaxImageSize:     "1.5GiB",
+				MaxLayers:        20,
+				MaxLayerSize:     "500MB",
+				SizeBudgetAction: "warn",
+			},
+			errExpected: false,
+		},
+		{
+			name: "should fail on invalid max-image-size",
+			params: BuildParams{
+				OutputRef:    "quay.io/org/image:tag",
+				Context:      tempDir,
+				MaxImageSize: "2 gigabytes",
+			},
+			errExpected:  true,
+			errSubstring: "max-image-size: invalid size '2 gigabytes'",
+		},
+		{
+			name: "should fail on invalid size-budget-action",
+			params: BuildParams{
+				OutputRef:        "quay.io/org/image:tag",
+				Context:          tempDir,
+				SizeBudgetAction: "ignore",
+			},
+			errExpected:  true,
+			errSubstring: "size-budget-action must be 'fail' or 'warn'",
+		},
+		{
+			name: "should fail on push destinations without push",
+			params: BuildParams{
+				OutputRef:        "quay.io/org/image:tag",
+				Context:          tempDir,
+				PushDestinations: []string{"mirror.example.com/org/image:tag"},
+			},
+			errExpected:  true,
+			errSubstring: "push-destinations requires push",
+		},
+		{
+			name: "should fail on invalid push destination",
+			params: BuildParams{
+				OutputRef:        "quay.io/org/image:tag",
+				Context:          tempDir,
+				Push:             true,
+				PushDestinations: []string{"Mirror/Image:tag"},
+			},
+			errExpected:  true,
+			errSubstring: "push destination 'Mirror/Image:tag' is invalid",
+		},
+		{
+			name: "should fail on push destination same as output-ref",
+			params: BuildParams{
+				OutputRef:        "quay.io/org/image:tag",
+				Context:          tempDir,
+				Push:             true,
+				PushDestinations: []string{"quay.io/org/image:tag"},
+			},
+			errExpected:  true,
+			errSubstring: "duplicate push destination 'quay.io/org/image:tag'",
+		},
+		{
+			name: "should allow zstd:chunked compression with oci push format",
+			params: BuildParams{
+				OutputRef:         "quay.io/org/image:tag",
+				Context:           tempDir,
+				Push:              true,
+				PushFormat:        "oci",
+				CompressionFormat: "zstd:chunked",
+				CompressionLevel:  10,
+			},
+			errExpected: false,
+		},
+		{
+			name: "should fail on invalid push format",
+			params: BuildParams{
+				OutputRef:  "quay.io/org/image:tag",
+				Context:    tempDir,
+				Push:       true,
+				PushFormat: "v2s1",
+			},
+			errExpected:  true,
+			errSubstring: "push-format must be 'oci' or 'docker', got 'v2s1'",
+		},
+		{
+			name: "should fail on zstd compression with docker push format",
+			params: BuildParams{
+				OutputRef:         "quay.io/org/image:tag",
+				Context:           tempDir,
+				Push:              true,
+				PushFormat:        "docker",
+				CompressionFormat: "zstd",
+			},
+			errExpected:  true,
+			errSubstring: "compression-format zstd requires the oci format",
+		},
 	}
 
 	for _, tc := range tests {
@@ -546,6 +863,91 @@ func Test_Build_detectContainerfile(t *testing.T) {
 	}
 }
 
+func Test_Build_checkContext(t *testing.T) {
+	// source
+	// ├── .git/config
+	// └── context
+	//     ├── .git/config
+	//     └── main.go
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		for _, name := range []string{"source/.git/config", "source/context/.git/config", "source/context/main.go"} {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name           string
+		policy         string
+		writeReport    bool
+		expectedError  string
+		expectedReport []string
+	}{
+		{
+			name: "should skip checks without policy and report",
+		},
+		{
+			name:           "should check context with default rules when only report is requested",
+			writeReport:    true,
+			expectedReport: []string{`"errors": 0`, `"findings": []`},
+		},
+		{
+			name:           "should fail on forbidden path in context",
+			policy:         `{"forbiddenPaths": [".git"]}`,
+			writeReport:    true,
+			expectedError:  "found 1 source tree policy violation(s): forbidden-path",
+			expectedReport: []string{`"path": ".git"`},
+		},
+		{
+			name:   "should pass when findings are warnings",
+			policy: `{"forbiddenPaths": [".git"], "severities": {"forbidden-path": "warning"}}`,
+		},
+		{
+			name:          "should fail on invalid policy",
+			policy:        `{"forbiddenPaths": ".git"}`,
+			expectedError: "failed to parse source tree policy",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dir := setup(t)
+
+			c := &Build{Params: &BuildParams{Source: filepath.Join(dir, "source"), Context: "context"}}
+			if tc.policy != "" {
+				c.Params.SourcePolicyFile = filepath.Join(dir, "policy.json")
+				g.Expect(os.WriteFile(c.Params.SourcePolicyFile, []byte(tc.policy), 0644)).To(Succeed())
+			}
+			if tc.writeReport {
+				c.Params.SourceReportFile = filepath.Join(dir, "report.json")
+			}
+
+			err := c.checkContext()
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedError)))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+
+			if tc.writeReport {
+				report, err := os.ReadFile(c.Params.SourceReportFile)
+				g.Expect(err).ToNot(HaveOccurred())
+				for _, expected := range tc.expectedReport {
+					g.Expect(string(report)).To(ContainSubstring(expected))
+				}
+			}
+		})
+	}
+}
+
 func Test_Build_setSecretArgs(t *testing.T) {
 	g := NewWithT(t)
 
@@ -802,6 +1204,116 @@ func Test_Build_setSecretArgs(t *testing.T) {
 		g.Expect(err.Error()).To(ContainSubstring("invalid argument: optional=maybe"))
 	})
 
+	t.Run("should recurse into subdirectories when recursive=true", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{
+			"secret1/token":             "secret-token",
+			"secret1/subdir/nested":     "nested",
+			"secret1/subdir/deep/file":  "deep",
+			"secret1/..data/ignored":    "kubernetes volume internals",
+			"secret1/subdir/.hidden/ok": "dot directories are fine",
+		})
+
+		secretDir := filepath.Join(tempDir, "secret1")
+		c := &Build{
+			Params: &BuildParams{
+				SecretDirs: []string{"src=" + secretDir + ",recursive=true"},
+			},
+		}
+
+		err := c.setSecretArgs()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.buildahSecrets).To(Equal([]cliwrappers.BuildahSecret{
+			{Src: filepath.Join(secretDir, "subdir/.hidden/ok"), Id: "secret1/subdir/.hidden/ok"},
+			{Src: filepath.Join(secretDir, "subdir/deep/file"), Id: "secret1/subdir/deep/file"},
+			{Src: filepath.Join(secretDir, "subdir/nested"), Id: "secret1/subdir/nested"},
+			{Src: filepath.Join(secretDir, "token"), Id: "secret1/token"},
+		}))
+	})
+
+	t.Run("should add individual file and env secrets", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{
+			"secret1/token": "secret-token",
+			"netrc":         "machine example.com",
+		})
+		t.Setenv("KBC_TEST_SECRET_TOKEN", "s3cr3t")
+
+		secretDir := filepath.Join(tempDir, "secret1")
+		netrc := filepath.Join(tempDir, "netrc")
+		c := &Build{
+			Params: &BuildParams{
+				SecretDirs: []string{secretDir},
+				Secrets: []string{
+					"id=netrc,src=" + netrc,
+					"id=token,env=KBC_TEST_SECRET_TOKEN",
+					"id=missing-file,src=" + filepath.Join(tempDir, "nonexistent") + ",optional=true",
+					"id=missing-env,env=KBC_TEST_SECRET_UNSET,optional=true",
+				},
+			},
+		}
+
+		err := c.setSecretArgs()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.buildahSecrets).To(Equal([]cliwrappers.BuildahSecret{
+			{Src: filepath.Join(secretDir, "token"), Id: "secret1/token"},
+			{Src: netrc, Id: "netrc"},
+			{Src: "KBC_TEST_SECRET_TOKEN", Id: "token", Type: cliwrappers.BuildahSecretTypeEnv},
+		}))
+		g.Expect(c.Results.Secrets).To(Equal([]BuildSecret{
+			{ID: "secret1/token", Type: "file", Source: filepath.Join(secretDir, "token")},
+			{ID: "netrc", Type: "file", Source: netrc},
+			{ID: "token", Type: "env", Source: "KBC_TEST_SECRET_TOKEN"},
+		}))
+	})
+
+	t.Run("should error on duplicate IDs across secret dirs and individual secrets", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{
+			"secret1/token": "secret-token",
+		})
+		t.Setenv("KBC_TEST_SECRET_TOKEN", "s3cr3t")
+
+		c := &Build{
+			Params: &BuildParams{
+				SecretDirs: []string{filepath.Join(tempDir, "secret1")},
+				Secrets:    []string{"id=secret1/token,env=KBC_TEST_SECRET_TOKEN"},
+			},
+		}
+
+		err := c.setSecretArgs()
+
+		g.Expect(err).To(MatchError(ContainSubstring("duplicate secret ID 'secret1/token'")))
+	})
+
+	t.Run("should error on missing env var", func(t *testing.T) {
+		c := &Build{
+			Params: &BuildParams{
+				Secrets: []string{"id=token,env=KBC_TEST_SECRET_UNSET"},
+			},
+		}
+
+		err := c.setSecretArgs()
+
+		g.Expect(err).To(MatchError(ContainSubstring("environment variable KBC_TEST_SECRET_UNSET is not set")))
+	})
+
+	t.Run("should error on invalid Secrets syntax", func(t *testing.T) {
+		for arg, errSubstring := range map[string]string{
+			"src=/path":                     "missing id",
+			"id=foo":                        "must have exactly one of src and env",
+			"id=foo,src=/path,env=VAR":      "must have exactly one of src and env",
+			"id=foo,src=/path,name=bar":     "invalid attribute: name",
+			"id=foo,env=VAR,optional=maybe": "invalid argument: optional=maybe",
+			"/path":                         "expected key=value",
+		} {
+			c := &Build{Params: &BuildParams{Secrets: []string{arg}}}
+			g.Expect(c.setSecretArgs()).To(MatchError(ContainSubstring(errSubstring)), arg)
+		}
+	})
+
 	t.Run("should process symlink to file but skip symlink to directory", func(t *testing.T) {
 		tempDir := t.TempDir()
 		testutil.WriteFileTree(t, tempDir, map[string]string{
@@ -1281,6 +1793,36 @@ func Test_Build_Run(t *testing.T) {
 		g.Expect(isBuildCalled).To(BeTrue())
 	})
 
+	t.Run("should pass build contexts to buildah build and pre-pull context images", func(t *testing.T) {
+		beforeEach()
+		contextDir := c.Params.Context
+		os.WriteFile(filepath.Join(contextDir, "Containerfile"), []byte("FROM base\nCOPY --from=shared /a /a"), 0644)
+		sharedDir := filepath.Join(tempDir, "shared")
+		os.Mkdir(sharedDir, 0755)
+		c.Params.BuildContexts = []string{"shared=" + sharedDir, "base=docker-image://quay.io/org/base:1"}
+
+		var pulledImages []string
+		_mockBuildahCli.PullFunc = func(args *cliwrappers.BuildahPullArgs) error {
+			pulledImages = append(pulledImages, args.Image)
+			return nil
+		}
+
+		isBuildCalled := false
+		_mockBuildahCli.BuildFunc = func(args *cliwrappers.BuildahBuildArgs) error {
+			isBuildCalled = true
+			g.Expect(args.BuildContexts).To(Equal([]cliwrappers.BuildahBuildContext{
+				{Name: "shared", Location: sharedDir},
+				{Name: "base", Location: "docker-image://quay.io/org/base:1"},
+			}))
+			return nil
+		}
+
+		err := c.Run()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(isBuildCalled).To(BeTrue())
+		g.Expect(pulledImages).To(Equal([]string{"quay.io/org/base:1"}))
+	})
+
 	t.Run("should clean up temporary workdir on exit", func(t *testing.T) {
 		beforeEach()
 
@@ -1309,6 +1851,92 @@ func Test_Build_Run(t *testing.T) {
 		g.Expect(err.Error()).To(ContainSubstring("buildah build failed"))
 	})
 
+	t.Run("should push to all push destinations", func(t *testing.T) {
+		beforeEach()
+		c.Params.PushDestinations = []string{"mirror.example.com/org/image:tag"}
+
+		var mu sync.Mutex
+		var destinations []string
+		_mockBuildahCli.PushFunc = func(args *cliwrappers.BuildahPushArgs) (string, error) {
+			g.Expect(args.Image).To(Equal("quay.io/org/image:tag"))
+			mu.Lock()
+			defer mu.Unlock()
+			destinations = append(destinations, args.Destination)
+			return "sha256:1234567890abcdef", nil
+		}
+
+		_mockResultsWriter.CreateResultJsonFunc = func(result any) (string, error) {
+			buildResults := result.(BuildResults)
+			g.Expect(buildResults.Digest).To(Equal("sha256:1234567890abcdef"))
+			g.Expect(buildResults.PushDestinations).To(Equal([]PushedDestination{
+				{Image: "quay.io/org/image:tag", Digest: "sha256:1234567890abcdef"},
+				{Image: "mirror.example.com/org/image:tag", Digest: "sha256:1234567890abcdef"},
+			}))
+			return "", nil
+		}
+
+		err := c.Run()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(destinations).To(ConsistOf("", "docker://mirror.example.com/org/image:tag"))
+	})
+
+	t.Run("should push with the requested compression and report the layer sizes", func(t *testing.T) {
+		beforeEach()
+		c.Params.PushFormat = "oci"
+		c.Params.CompressionFormat = "zstd:chunked"
+		c.Params.CompressionLevel = 3
+		c.Params.ForceCompression = true
+
+		_mockBuildahCli.PushFunc = func(args *cliwrappers.BuildahPushArgs) (string, error) {
+			g.Expect(*args).To(Equal(cliwrappers.BuildahPushArgs{
+				Image:             "quay.io/org/image:tag",
+				Format:            "oci",
+				CompressionFormat: "zstd:chunked",
+				CompressionLevel:  3,
+				ForceCompression:  true,
+			}))
+			return "sha256:1234567890abcdef", nil
+		}
+		_mockBuildahCli.ManifestInspectFunc = func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+			g.Expect(args.ManifestName).To(Equal("docker://quay.io/org/image@sha256:1234567890abcdef"))
+			return `{"layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+zstd", "digest": "sha256:aaaa", "size": 42}]}`, nil
+		}
+
+		_mockResultsWriter.CreateResultJsonFunc = func(result any) (string, error) {
+			buildResults := result.(BuildResults)
+			g.Expect(buildResults.PushedLayers).To(Equal([]PushedImageLayers{{
+				Image:     "quay.io/org/image@sha256:1234567890abcdef",
+				Layers:    []PushedLayer{{Digest: "sha256:aaaa", MediaType: "application/vnd.oci.image.layer.v1.tar+zstd", Size: 42}},
+				TotalSize: 42,
+			}}))
+			return "", nil
+		}
+
+		err := c.Run()
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should not fail if the pushed layer sizes can't be read", func(t *testing.T) {
+		beforeEach()
+
+		_mockBuildahCli.PushFunc = func(args *cliwrappers.BuildahPushArgs) (string, error) {
+			return "sha256:1234567890abcdef", nil
+		}
+		_mockBuildahCli.ManifestInspectFunc = func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+			return "", errors.New("manifest unknown")
+		}
+
+		_mockResultsWriter.CreateResultJsonFunc = func(result any) (string, error) {
+			buildResults := result.(BuildResults)
+			g.Expect(buildResults.Digest).To(Equal("sha256:1234567890abcdef"))
+			g.Expect(buildResults.PushedLayers).To(BeNil())
+			return "", nil
+		}
+
+		err := c.Run()
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
 	t.Run("should error if push fails", func(t *testing.T) {
 		beforeEach()
 
@@ -1924,6 +2552,106 @@ func Test_Build_injectBuildinfo(t *testing.T) {
 	g.Expect(c.buildinfoBuildContext.Location).To(Equal(filepath.Join(c.tempWorkdir, "buildinfo")))
 }
 
+func Test_Build_injectBuildinfo_target(t *testing.T) {
+	content := strings.Join([]string{
+		"FROM scratch AS builder",
+		"LABEL builder=true version=1",
+		"",
+		"FROM builder AS debug",
+		"LABEL debug=true",
+		"RUN echo debug",
+		"",
+		"FROM scratch",
+		"LABEL version=2",
+	}, "\n")
+
+	tests := []struct {
+		name           string
+		target         string
+		inheritLabels  bool
+		expectedLabels map[string]string
+		expectedCopy   int // index of the line after which COPY gets inserted
+	}{
+		{
+			name:           "last stage by default",
+			inheritLabels:  true,
+			expectedLabels: map[string]string{"version": "2"},
+			expectedCopy:   8,
+		},
+		{
+			name:           "intermediate target inherits labels through FROM chain",
+			target:         "debug",
+			inheritLabels:  true,
+			expectedLabels: map[string]string{"builder": "true", "version": "1", "debug": "true"},
+			expectedCopy:   5,
+		},
+		{
+			name:           "intermediate target without label inheritance",
+			target:         "debug",
+			inheritLabels:  false,
+			expectedLabels: map[string]string{"debug": "true"},
+			expectedCopy:   5,
+		},
+		{
+			name:           "target by index",
+			target:         "0",
+			inheritLabels:  true,
+			expectedLabels: map[string]string{"builder": "true", "version": "1"},
+			expectedCopy:   1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			containerfile := filepath.Join(t.TempDir(), "Containerfile")
+			g.Expect(os.WriteFile(containerfile, []byte(content), 0644)).To(Succeed())
+			df := parseDockerfile(t, g, content)
+
+			c := &Build{
+				Params: &BuildParams{
+					SourceDateEpoch: "0",
+					Target:          tc.target,
+					InheritLabels:   tc.inheritLabels,
+				},
+				containerfilePath: containerfile,
+			}
+			defer c.cleanup()
+
+			g.Expect(c.injectBuildinfo(df, nil, nil)).To(Succeed())
+
+			labelsContent, err := os.ReadFile(filepath.Join(c.tempWorkdir, "buildinfo", "labels.json"))
+			g.Expect(err).NotTo(HaveOccurred())
+			var labels map[string]string
+			g.Expect(json.Unmarshal(labelsContent, &labels)).To(Succeed())
+			g.Expect(labels).To(Equal(tc.expectedLabels))
+
+			expectedLines := strings.Split(content, "\n")
+			expectedLines = slices.Insert(expectedLines, tc.expectedCopy+1, "COPY --from=.konflux-buildinfo . /usr/share/buildinfo/")
+			copyContent, err := os.ReadFile(c.containerfileCopyPath)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(string(copyContent)).To(Equal(strings.Join(expectedLines, "\n") + "\n"))
+		})
+	}
+
+	t.Run("unknown target", func(t *testing.T) {
+		g := NewWithT(t)
+
+		containerfile := filepath.Join(t.TempDir(), "Containerfile")
+		g.Expect(os.WriteFile(containerfile, []byte(content), 0644)).To(Succeed())
+
+		c := &Build{
+			Params:            &BuildParams{SourceDateEpoch: "0", Target: "nonexistent"},
+			containerfilePath: containerfile,
+		}
+		defer c.cleanup()
+
+		err := c.injectBuildinfo(parseDockerfile(t, g, content), nil, nil)
+		g.Expect(err).To(MatchError(`target stage "nonexistent" not found`))
+	})
+}
+
 func Test_findMatchingStages(t *testing.T) {
 	g := NewWithT(t)
 
@@ -2011,6 +2739,7 @@ func Test_Build_collectBaseImages(t *testing.T) {
 	tests := []struct {
 		name                 string
 		dockerfile           string
+		buildContexts        []string
 		targetStage          int
 		dontSkipUnusedStages bool
 		expected             []string
@@ -2252,13 +2981,29 @@ func Test_Build_collectBaseImages(t *testing.T) {
 				"rust:1.70",
 			},
 		},
+		{
+			name: "build contexts replace images, image contexts need their image",
+			dockerfile: strings.Join([]string{
+				"FROM base-context",
+				"COPY --from=dir-context /a /a",
+				"COPY --from=oci-context /b /b",
+				"RUN --mount=from=busybox:latest,target=/mnt echo hi",
+			}, "\n"),
+			buildContexts: []string{
+				"base-context=docker-image://registry.example.com/base:1",
+				"dir-context=./some/dir",
+				"oci-context=oci-layout://./layout:tag",
+			},
+			targetStage: 0,
+			expected:    []string{"busybox:latest", "registry.example.com/base:1"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			df := parseDockerfile(t, g, tt.dockerfile)
 
-			c := &Build{Params: &BuildParams{SkipUnusedStages: !tt.dontSkipUnusedStages}}
+			c := &Build{Params: &BuildParams{SkipUnusedStages: !tt.dontSkipUnusedStages, BuildContexts: tt.buildContexts}}
 			result := c.collectBaseImages(df, tt.targetStage)
 			if len(tt.expected) == 0 {
 				g.Expect(result).To(BeEmpty())
@@ -3135,8 +3880,92 @@ func Test_Build_integrateWithRHSM(t *testing.T) {
 		g.Expect(c.registeredWithRHSM).To(BeFalse(),
 			"should not be marked as registered when registration fails")
 	})
+
+	t.Run("should fall back to the next activation key", func(t *testing.T) {
+		g := NewWithT(t)
+
+		tempDir := t.TempDir()
+		testutil.WriteFileTree(t, tempDir, map[string]string{
+			"key.txt":          "expired-key",
+			"org.txt":          "my-org",
+			"fallback-key.txt": "fallback-key",
+			"fallback-org.txt": "fallback-org",
+		})
+
+		var registeredKeys []string
+		unregisterCalled := false
+		mockSM := &mockSubscriptionManagerCli{
+			RegisterFunc: func(params *cliwrappers.SubscriptionManagerRegisterParams) error {
+				registeredKeys = append(registeredKeys, params.ActivationKey)
+				if params.ActivationKey == "expired-key" {
+					return errors.New("None of the activation keys specified exist for this org.")
+				}
+				return nil
+			},
+			UnregisterFunc: func() {
+				unregisterCalled = true
+			},
+		}
+
+		c := &Build{
+			Params: &BuildParams{
+				RHSMActivationKey:          filepath.Join(tempDir, "key.txt"),
+				RHSMOrg:                    filepath.Join(tempDir, "org.txt"),
+				RHSMActivationPreregister:  true,
+				RHSMFallbackActivationKeys: []string{filepath.Join(tempDir, "fallback-key.txt")},
+				RHSMFallbackOrgs:           []string{filepath.Join(tempDir, "fallback-org.txt")},
+				RHSMMountCACerts:           "never",
+			},
+			CliWrappers:       BuildCliWrappers{SubscriptionManager: mockSM},
+			hostEntitlements:  t.TempDir(),
+			hostConsumerCerts: t.TempDir(),
+			rhsmStateFile:     filepath.Join(tempDir, "rhsm-session.json"),
+		}
+
+		g.Expect(c.integrateWithRHSM()).To(Succeed())
+		g.Expect(registeredKeys).To(Equal([]string{"expired-key", "fallback-key"}))
+		g.Expect(c.Results.RHSMActivationKey).To(Equal(filepath.Join(tempDir, "fallback-key.txt")))
+		g.Expect(c.rhsmStateFile).To(BeAnExistingFile())
+
+		c.cleanup()
+		g.Expect(unregisterCalled).To(BeTrue())
+		g.Expect(c.rhsmStateFile).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should error on expired entitlement certificates", func(t *testing.T) {
+		g := NewWithT(t)
+
+		entitlementsDir := t.TempDir()
+		testutil.WriteFileTree(t, entitlementsDir, map[string]string{
+			"12345.pem":     expiredTestCert,
+			"12345-key.pem": "entitlement-key",
+		})
+
+		c := &Build{
+			Params: &BuildParams{
+				RHSMEntitlements: entitlementsDir,
+				RHSMMountCACerts: "never",
+			},
+		}
+		defer c.cleanup()
+
+		err := c.integrateWithRHSM()
+		g.Expect(err).To(MatchError(ContainSubstring("invalid entitlement certificates: 12345.pem expired at 2020-01-01T00:00:00Z")))
+	})
 }
 
+// A self-signed certificate valid from 2019-01-01 to 2020-01-01
+const expiredTestCert = `-----BEGIN CERTIFICATE-----
+MIIBKDCBz6ADAgECAgEBMAoGCCqGSM49BAMCMB4xHDAaBgNVBAMTE2V4cGlyZWQg
+ZW50aXRsZW1lbnQwHhcNMTkwMTAxMDAwMDAwWhcNMjAwMTAxMDAwMDAwWjAeMRww
+GgYDVQQDExNleHBpcmVkIGVudGl0bGVtZW50MFkwEwYHKoZIzj0CAQYIKoZIzj0D
+AQcDQgAE13uxAJaxBWyxyf3daGeBQ+Ia2iAGrAE3Hha67KUn4BnWpWTMRnrDwW21
+4Z3pIPS+wjFm0vRAYRot7UctoxoswjAKBggqhkjOPQQDAgNIADBFAiB8OcfB+7dp
+LsiVli6Sa6d8hJqcmjft2upw8sCq2bhD7QIhANDigLOhxCDVxT3Bunn60dKLmuT0
+3YadOHzjMiUlNu4C
+-----END CERTIFICATE-----
+`
+
 func Test_Build_injectPrefetchEnvToContainerfile(t *testing.T) {
 	// Injection is thoroughly tested in RunInjector tests, test only the interesting cases here.
 	tests := []struct {
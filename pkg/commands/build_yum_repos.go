@@ -0,0 +1,307 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// A repo in the merged yum.repos.d.
+type YumRepo struct {
+	ID string `json:"id"`
+	// The .repo file that defines the repo
+	File string `json:"file"`
+	// The yum-repos-d-sources directory (or the prefetched yum.repos.d) that provided the file
+	Source     string   `json:"source"`
+	Enabled    bool     `json:"enabled"`
+	BaseURLs   []string `json:"baseurls,omitempty"`
+	Metalink   string   `json:"metalink,omitempty"`
+	Mirrorlist string   `json:"mirrorlist,omitempty"`
+	Proxy      string   `json:"proxy,omitempty"`
+	// The repo ID is already defined in an earlier file, dnf ignores this definition
+	Duplicate bool `json:"duplicate,omitempty"`
+	// What the merge changed in the repo definition
+	Changes []string `json:"changes,omitempty"`
+}
+
+type yumRepoMirror struct {
+	prefix      string
+	replacement string
+}
+
+// The keys that point dnf at the repo content (or at lists of mirrors that have it).
+var yumRepoURLKeys = []string{"baseurl", "metalink", "mirrorlist"}
+
+// baseurl may contain multiple URLs, separated by whitespace or commas.
+var yumRepoURLPattern = regexp.MustCompile(`[^\s,]+`)
+
+var yumRepoLoadOptions = ini.LoadOptions{
+	// baseurl values commonly continue on indented lines
+	AllowPythonMultilineValues: true,
+	// dnf doesn't support inline comments, '#' may be part of a URL
+	IgnoreInlineComment: true,
+}
+
+// Parse yum-repos-mirrors entries, sorted so that the longest prefix comes first.
+func parseYumRepoMirrors(mirrors []string) ([]yumRepoMirror, error) {
+	parsed := make([]yumRepoMirror, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		prefix, replacement, found := strings.Cut(mirror, "=")
+		if !found || prefix == "" || replacement == "" {
+			return nil, fmt.Errorf("invalid yum-repos-mirrors entry '%s', expected PREFIX=REPLACEMENT", mirror)
+		}
+		parsed = append(parsed, yumRepoMirror{prefix: prefix, replacement: replacement})
+	}
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return len(parsed[i].prefix) > len(parsed[j].prefix)
+	})
+	return parsed, nil
+}
+
+func mirrorYumRepoURL(url string, mirrors []yumRepoMirror) string {
+	for _, mirror := range mirrors {
+		if strings.HasPrefix(url, mirror.prefix) {
+			return mirror.replacement + strings.TrimPrefix(url, mirror.prefix)
+		}
+	}
+	return url
+}
+
+// Whether dnf would need network access to reach the URL. Repos served from the prefetch
+// output mount (or anywhere else on the filesystem) use file:// URLs or plain paths.
+func isNetworkYumRepoURL(url string) bool {
+	lower := strings.ToLower(url)
+	for _, scheme := range []string{"http://", "https://", "ftp://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse a boolean option the way dnf does, returning defaultValue for unset or unknown values.
+func parseYumRepoBool(value string, defaultValue bool) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "yes", "true", "on":
+		return true
+	case "0", "no", "false", "off":
+		return false
+	default:
+		return defaultValue
+	}
+}
+
+// Look up a key defined directly in the section. Unlike section.Key(), doesn't fall back
+// to "parent" sections, which ini.v1 derives from dots in the section name (e.g. rhel-9.4).
+func ownKey(section *ini.Section, name string) *ini.Key {
+	for _, key := range section.Keys() {
+		if key.Name() == name {
+			return key
+		}
+	}
+	return nil
+}
+
+func ownValue(section *ini.Section, name string) string {
+	if key := ownKey(section, name); key != nil {
+		return key.Value()
+	}
+	return ""
+}
+
+// Validate and rewrite the .repo files in the merged yum.repos.d:
+//
+//   - Warn about repo IDs defined in more than one file, dnf only uses the first definition.
+//   - Rewrite baseurl, metalink and mirrorlist URLs through the yum-repos-mirrors.
+//   - In hermetic builds, disable the repos that need network access, only local repos
+//     (typically the prefetched ones, served from the prefetch output mount) can work.
+//   - Set yum-repos-proxy as the proxy of the remaining network repos.
+//   - Enforce yum-repos-require-gpgcheck and yum-repos-require-sslverify.
+//
+// Files that fail to parse are mounted unchanged. Files that don't need any changes are
+// not rewritten. Records the resulting repos in the build results.
+func (c *Build) processYumRepos(mergedDir string, sources map[string]string) error {
+	mirrors, err := parseYumRepoMirrors(c.Params.YumReposMirrors)
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+	for filename := range sources {
+		// dnf ignores files without the .repo extension
+		if strings.HasSuffix(filename, ".repo") {
+			filenames = append(filenames, filename)
+		}
+	}
+	// dnf reads the files in alphabetical order
+	sort.Strings(filenames)
+
+	definedIn := make(map[string]string) // repo ID -> file that defines it first
+	var violations []error
+
+	for _, filename := range filenames {
+		path := filepath.Join(mergedDir, filename)
+		cfg, err := ini.LoadSources(yumRepoLoadOptions, path)
+		if err != nil {
+			l.Logger.Warnf("yum.repos.d: failed to parse %s, mounting it unchanged: %s", filename, err)
+			continue
+		}
+
+		modified := false
+		for _, section := range cfg.Sections() {
+			if section.Name() == ini.DefaultSection {
+				continue
+			}
+
+			repo := YumRepo{ID: section.Name(), File: filename, Source: sources[filename]}
+			if prev, ok := definedIn[repo.ID]; ok {
+				l.Logger.Warnf("yum.repos.d: repo %s in %s is already defined in %s, dnf will ignore it", repo.ID, filename, prev)
+				repo.Duplicate = true
+			} else {
+				definedIn[repo.ID] = filename
+			}
+
+			if c.rewriteYumRepo(section, &repo, mirrors) {
+				modified = true
+			}
+
+			repo.Enabled = parseYumRepoBool(ownValue(section, "enabled"), true)
+			repo.BaseURLs = yumRepoURLPattern.FindAllString(ownValue(section, "baseurl"), -1)
+			repo.Metalink = ownValue(section, "metalink")
+			repo.Mirrorlist = ownValue(section, "mirrorlist")
+			repo.Proxy = ownValue(section, "proxy")
+
+			if repo.Enabled && !repo.Duplicate {
+				if c.Params.YumReposRequireGPGCheck && !parseYumRepoBool(ownValue(section, "gpgcheck"), true) {
+					violations = append(violations, fmt.Errorf("repo %s in %s disables gpgcheck", repo.ID, filename))
+				}
+				if c.Params.YumReposRequireSSLVerify && !parseYumRepoBool(ownValue(section, "sslverify"), true) {
+					violations = append(violations, fmt.Errorf("repo %s in %s disables sslverify", repo.ID, filename))
+				}
+			}
+
+			c.Results.YumRepos = append(c.Results.YumRepos, repo)
+		}
+
+		if modified {
+			if err := writeYumRepoFile(path, cfg); err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
+			l.Logger.Infof("yum.repos.d: rewrote %s", filename)
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("yum.repos.d: %w", errors.Join(violations...))
+	}
+	return nil
+}
+
+// Apply the mirrors, hermetic restrictions and proxy to the repo section.
+// Records the changes in the repo and reports whether the section was modified.
+func (c *Build) rewriteYumRepo(section *ini.Section, repo *YumRepo, mirrors []yumRepoMirror) bool {
+	modified := false
+	needsNetwork := false
+	// Whether all the network URLs can be reached through the egress proxy
+	allowedByNetworkAllow := len(c.networkAllow) > 0
+
+	for _, name := range yumRepoURLKeys {
+		key := ownKey(section, name)
+		if key == nil {
+			continue
+		}
+		value := yumRepoURLPattern.ReplaceAllStringFunc(key.Value(), func(url string) string {
+			return mirrorYumRepoURL(url, mirrors)
+		})
+		if value != key.Value() {
+			key.SetValue(value)
+			repo.Changes = append(repo.Changes, fmt.Sprintf("%s rewritten through yum-repos-mirrors", name))
+			modified = true
+		}
+		for _, url := range yumRepoURLPattern.FindAllString(value, -1) {
+			if isNetworkYumRepoURL(url) {
+				needsNetwork = true
+				if !c.isNetworkAllowedYumRepoURL(url) {
+					allowedByNetworkAllow = false
+				}
+			}
+		}
+	}
+
+	if !needsNetwork || !parseYumRepoBool(ownValue(section, "enabled"), true) {
+		return modified
+	}
+
+	if c.Params.Hermetic && allowedByNetworkAllow {
+		// NewKey updates the value if the key already exists, other proxies are not reachable
+		if _, err := section.NewKey("proxy", "http://"+egressRelayAddress); err == nil {
+			repo.Changes = append(repo.Changes, "proxy set to the egress relay, the repo is allowed by network-allow")
+			modified = true
+		}
+	} else if c.Params.Hermetic {
+		l.Logger.Warnf("yum.repos.d: disabling repo %s in %s, hermetic builds have no network access", repo.ID, repo.File)
+		// NewKey updates the value if the key already exists
+		if _, err := section.NewKey("enabled", "0"); err == nil {
+			repo.Changes = append(repo.Changes, "disabled, hermetic builds have no network access")
+			modified = true
+		}
+	} else if c.Params.YumReposProxy != "" && ownKey(section, "proxy") == nil {
+		if _, err := section.NewKey("proxy", c.Params.YumReposProxy); err == nil {
+			repo.Changes = append(repo.Changes, "proxy set from yum-repos-proxy")
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// Whether the repo URL is an http(s) URL whose host:port is allowed by network-allow.
+func (c *Build) isNetworkAllowedYumRepoURL(url string) bool {
+	lower := strings.ToLower(url)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return false
+	}
+	destination, err := urlDestination(url)
+	return err == nil && slices.Contains(c.networkAllow, destination)
+}
+
+// Serialize the parsed .repo file. Doesn't use ini.File.WriteTo, which wraps multi-line
+// values in """ quotes that dnf doesn't understand.
+func writeYumRepoFile(path string, cfg *ini.File) error {
+	var sb strings.Builder
+	writeComment := func(comment string) {
+		if comment != "" {
+			sb.WriteString(comment)
+			sb.WriteString("\n")
+		}
+	}
+
+	for _, section := range cfg.Sections() {
+		isDefault := section.Name() == ini.DefaultSection
+		if isDefault && len(section.Keys()) == 0 && section.Comment == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		writeComment(section.Comment)
+		if !isDefault {
+			fmt.Fprintf(&sb, "[%s]\n", section.Name())
+		}
+		for _, key := range section.Keys() {
+			writeComment(key.Comment)
+			fmt.Fprintf(&sb, "%s=%s\n", key.Name(), key.Value())
+		}
+	}
+
+	return os.WriteFile(path, []byte(sb.String()), 0666)
+}
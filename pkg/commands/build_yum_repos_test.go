@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_parseYumRepoMirrors(t *testing.T) {
+	g := NewWithT(t)
+
+	mirrors, err := parseYumRepoMirrors([]string{
+		"https://cdn.redhat.com/=https://mirror.example.com/rh/",
+		"https://cdn.redhat.com/content/dist/=https://dist.example.com/",
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mirrorYumRepoURL("https://cdn.redhat.com/content/dist/rhel9/baseos", mirrors)).
+		To(Equal("https://dist.example.com/rhel9/baseos"))
+	g.Expect(mirrorYumRepoURL("https://cdn.redhat.com/content/beta/rhel9", mirrors)).
+		To(Equal("https://mirror.example.com/rh/content/beta/rhel9"))
+	g.Expect(mirrorYumRepoURL("https://mirrors.fedoraproject.org/metalink", mirrors)).
+		To(Equal("https://mirrors.fedoraproject.org/metalink"))
+
+	_, err = parseYumRepoMirrors([]string{"=https://mirror.example.com/"})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid yum-repos-mirrors entry")))
+}
+
+func Test_Build_processYumRepos(t *testing.T) {
+	const ubiRepo = `# UBI repos
+[ubi-9-baseos]
+name=UBI 9 BaseOS
+baseurl=https://cdn-ubi.redhat.com/content/public/ubi/dist/ubi9/9/$basearch/baseos/os
+        https://cdn-ubi.redhat.com/content/public/ubi/dist/ubi9/9/$basearch/baseos/os-backup
+enabled=1
+gpgcheck=1
+
+[ubi-9-appstream]
+name=UBI 9 AppStream
+metalink=https://cdn-ubi.redhat.com/metalink?repo=ubi-9-appstream
+enabled = 0
+`
+	const prefetchRepo = `[ubi-9-baseos]
+baseurl=file:///tmp/.prefetch-output/deps/rpm/x86_64/ubi-9-baseos
+gpgcheck=0
+`
+	const notIni = "aaaaaaaaaa"
+
+	setup := func(t *testing.T) (string, map[string]string) {
+		dir := t.TempDir()
+		files := map[string]string{
+			"ubi.repo":            ubiRepo,
+			"hermeto.repo":        prefetchRepo,
+			"broken.repo":         notIni,
+			"README":              "not a repo file",
+			"zz-other-copy.repo":  "[other]\nbaseurl=https://example.com/repo\nsslverify=false\n",
+			"zz-other-local.repo": "[local]\nbaseurl=/media/repo\n",
+		}
+		sources := make(map[string]string)
+		for name, content := range files {
+			NewWithT(t).Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+			sources[name] = "/yum-repos-d-source"
+		}
+		return dir, sources
+	}
+	readFile := func(g *WithT, path string) string {
+		content, err := os.ReadFile(path)
+		g.Expect(err).ToNot(HaveOccurred())
+		return string(content)
+	}
+
+	t.Run("should record the repos and leave unchanged files alone", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, sources := setup(t)
+		c := &Build{Params: &BuildParams{}}
+
+		g.Expect(c.processYumRepos(dir, sources)).To(Succeed())
+
+		g.Expect(readFile(g, filepath.Join(dir, "ubi.repo"))).To(Equal(ubiRepo))
+		g.Expect(readFile(g, filepath.Join(dir, "broken.repo"))).To(Equal(notIni))
+		g.Expect(c.Results.YumRepos).To(Equal([]YumRepo{
+			{
+				ID: "ubi-9-baseos", File: "hermeto.repo", Source: "/yum-repos-d-source", Enabled: true,
+				BaseURLs: []string{"file:///tmp/.prefetch-output/deps/rpm/x86_64/ubi-9-baseos"},
+			},
+			{
+				ID: "ubi-9-baseos", File: "ubi.repo", Source: "/yum-repos-d-source", Enabled: true,
+				BaseURLs: []string{
+					"https://cdn-ubi.redhat.com/content/public/ubi/dist/ubi9/9/$basearch/baseos/os",
+					"https://cdn-ubi.redhat.com/content/public/ubi/dist/ubi9/9/$basearch/baseos/os-backup",
+				},
+				Duplicate: true,
+			},
+			{
+				ID: "ubi-9-appstream", File: "ubi.repo", Source: "/yum-repos-d-source", Enabled: false,
+				Metalink: "https://cdn-ubi.redhat.com/metalink?repo=ubi-9-appstream",
+			},
+			{
+				ID: "other", File: "zz-other-copy.repo", Source: "/yum-repos-d-source", Enabled: true,
+				BaseURLs: []string{"https://example.com/repo"},
+			},
+			{
+				ID: "local", File: "zz-other-local.repo", Source: "/yum-repos-d-source", Enabled: true,
+				BaseURLs: []string{"/media/repo"},
+			},
+		}))
+	})
+
+	t.Run("should rewrite through mirrors and set the proxy", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, sources := setup(t)
+		c := &Build{Params: &BuildParams{
+			YumReposMirrors: []string{"https://cdn-ubi.redhat.com/=https://mirror.example.com/ubi/"},
+			YumReposProxy:   "http://cache-proxy:3128",
+		}}
+
+		g.Expect(c.processYumRepos(dir, sources)).To(Succeed())
+
+		g.Expect(readFile(g, filepath.Join(dir, "ubi.repo"))).To(Equal(`# UBI repos
+[ubi-9-baseos]
+name=UBI 9 BaseOS
+baseurl=https://mirror.example.com/ubi/content/public/ubi/dist/ubi9/9/$basearch/baseos/os
+        https://mirror.example.com/ubi/content/public/ubi/dist/ubi9/9/$basearch/baseos/os-backup
+enabled=1
+gpgcheck=1
+proxy=http://cache-proxy:3128
+
+[ubi-9-appstream]
+name=UBI 9 AppStream
+metalink=https://mirror.example.com/ubi/metalink?repo=ubi-9-appstream
+enabled=0
+`))
+		g.Expect(readFile(g, filepath.Join(dir, "zz-other-copy.repo"))).To(ContainSubstring("proxy=http://cache-proxy:3128\n"))
+		g.Expect(readFile(g, filepath.Join(dir, "zz-other-local.repo"))).ToNot(ContainSubstring("proxy"))
+		g.Expect(c.Results.YumRepos[1].Changes).To(Equal([]string{
+			"baseurl rewritten through yum-repos-mirrors",
+			"proxy set from yum-repos-proxy",
+		}))
+		g.Expect(c.Results.YumRepos[2].Changes).To(Equal([]string{"metalink rewritten through yum-repos-mirrors"}))
+	})
+
+	t.Run("should disable network repos in hermetic builds", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, sources := setup(t)
+		c := &Build{Params: &BuildParams{Hermetic: true, YumReposProxy: "http://cache-proxy:3128"}}
+
+		g.Expect(c.processYumRepos(dir, sources)).To(Succeed())
+
+		enabled := make(map[string]bool)
+		for _, repo := range c.Results.YumRepos {
+			enabled[repo.File+":"+repo.ID] = repo.Enabled
+			g.Expect(repo.Proxy).To(BeEmpty())
+		}
+		g.Expect(enabled).To(Equal(map[string]bool{
+			"hermeto.repo:ubi-9-baseos": true,
+			"ubi.repo:ubi-9-baseos":     false,
+			"ubi.repo:ubi-9-appstream":  false,
+			"zz-other-copy.repo:other":  false,
+			"zz-other-local.repo:local": true,
+		}))
+		g.Expect(readFile(g, filepath.Join(dir, "zz-other-copy.repo"))).To(ContainSubstring("enabled=0\n"))
+		g.Expect(readFile(g, filepath.Join(dir, "hermeto.repo"))).To(Equal(prefetchRepo))
+	})
+
+	t.Run("should send repos allowed by network-allow through the egress relay in hermetic builds", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, sources := setup(t)
+		c := &Build{
+			Params:       &BuildParams{Hermetic: true, YumReposProxy: "http://cache-proxy:3128"},
+			networkAllow: []string{"cdn-ubi.redhat.com:443"},
+		}
+
+		g.Expect(c.processYumRepos(dir, sources)).To(Succeed())
+
+		enabled := make(map[string]bool)
+		proxies := make(map[string]string)
+		for _, repo := range c.Results.YumRepos {
+			enabled[repo.File+":"+repo.ID] = repo.Enabled
+			proxies[repo.File+":"+repo.ID] = repo.Proxy
+		}
+		g.Expect(enabled).To(Equal(map[string]bool{
+			"hermeto.repo:ubi-9-baseos": true,
+			"ubi.repo:ubi-9-baseos":     true,
+			"ubi.repo:ubi-9-appstream":  false,
+			"zz-other-copy.repo:other":  false,
+			"zz-other-local.repo:local": true,
+		}))
+		g.Expect(proxies).To(Equal(map[string]string{
+			"hermeto.repo:ubi-9-baseos": "",
+			"ubi.repo:ubi-9-baseos":     "http://127.0.0.1:3128",
+			"ubi.repo:ubi-9-appstream":  "",
+			"zz-other-copy.repo:other":  "",
+			"zz-other-local.repo:local": "",
+		}))
+		g.Expect(c.Results.YumRepos[1].Changes).To(Equal([]string{"proxy set to the egress relay, the repo is allowed by network-allow"}))
+		g.Expect(readFile(g, filepath.Join(dir, "ubi.repo"))).To(ContainSubstring("proxy=http://127.0.0.1:3128\n"))
+		g.Expect(readFile(g, filepath.Join(dir, "zz-other-copy.repo"))).To(ContainSubstring("enabled=0\n"))
+	})
+
+	t.Run("should enforce gpgcheck and sslverify on enabled repos", func(t *testing.T) {
+		g := NewWithT(t)
+		dir, sources := setup(t)
+		c := &Build{Params: &BuildParams{YumReposRequireGPGCheck: true, YumReposRequireSSLVerify: true}}
+
+		err := c.processYumRepos(dir, sources)
+		g.Expect(err).To(MatchError(ContainSubstring("repo ubi-9-baseos in hermeto.repo disables gpgcheck")))
+		g.Expect(err).To(MatchError(ContainSubstring("repo other in zz-other-copy.repo disables sslverify")))
+	})
+}
@@ -40,6 +40,46 @@ type mockBuildahCli struct {
 	ManifestPushFunc    func(args *cliwrappers.BuildahManifestPushArgs) (string, error)
 	ImagesFunc          func(args *cliwrappers.BuildahImagesArgs) (string, error)
 	ImagesJsonFunc      func(args *cliwrappers.BuildahImagesArgs) ([]cliwrappers.BuildahImagesEntry, error)
+	FromFunc            func(image string) (string, error)
+	MountFunc           func(container string) (string, error)
+	UmountFunc          func(container string) error
+	RmFunc              func(container string) error
+	TagFunc             func(image string, names ...string) error
+}
+
+func (m *mockBuildahCli) From(image string) (string, error) {
+	if m.FromFunc != nil {
+		return m.FromFunc(image)
+	}
+	return "", nil
+}
+
+func (m *mockBuildahCli) Mount(container string) (string, error) {
+	if m.MountFunc != nil {
+		return m.MountFunc(container)
+	}
+	return "", nil
+}
+
+func (m *mockBuildahCli) Umount(container string) error {
+	if m.UmountFunc != nil {
+		return m.UmountFunc(container)
+	}
+	return nil
+}
+
+func (m *mockBuildahCli) Rm(container string) error {
+	if m.RmFunc != nil {
+		return m.RmFunc(container)
+	}
+	return nil
+}
+
+func (m *mockBuildahCli) Tag(image string, names ...string) error {
+	if m.TagFunc != nil {
+		return m.TagFunc(image, names...)
+	}
+	return nil
 }
 
 func (m *mockBuildahCli) Build(args *cliwrappers.BuildahBuildArgs) error {
@@ -146,6 +186,34 @@ func (m *mockSubscriptionManagerCli) Unregister() {
 	}
 }
 
+var _ cliwrappers.SSHAgentCliInterface = &mockSSHAgentCli{}
+
+type mockSSHAgentCli struct {
+	StartFunc  func(socketPath string) (int, error)
+	AddKeyFunc func(socketPath, keyPath string) error
+	KillFunc   func(socketPath string, pid int)
+}
+
+func (m *mockSSHAgentCli) Start(socketPath string) (int, error) {
+	if m.StartFunc != nil {
+		return m.StartFunc(socketPath)
+	}
+	return 0, nil
+}
+
+func (m *mockSSHAgentCli) AddKey(socketPath, keyPath string) error {
+	if m.AddKeyFunc != nil {
+		return m.AddKeyFunc(socketPath, keyPath)
+	}
+	return nil
+}
+
+func (m *mockSSHAgentCli) Kill(socketPath string, pid int) {
+	if m.KillFunc != nil {
+		m.KillFunc(socketPath, pid)
+	}
+}
+
 var _ cliwrappers.OrasCliInterface = &mockOrasCli{}
 
 type mockOrasCli struct {
@@ -159,3 +227,29 @@ func (m *mockOrasCli) Push(args *cliwrappers.OrasPushArgs) (string, string, erro
 	}
 	return "", "", nil
 }
+
+var _ cliwrappers.RpmCliInterface = &mockRpmCli{}
+
+type mockRpmCli struct {
+	QueryAllFunc func(dbPath string) ([]cliwrappers.RpmPackage, error)
+}
+
+func (m *mockRpmCli) QueryAll(dbPath string) ([]cliwrappers.RpmPackage, error) {
+	if m.QueryAllFunc != nil {
+		return m.QueryAllFunc(dbPath)
+	}
+	return nil, nil
+}
+
+var _ cliwrappers.PackageInventoryCliInterface = &mockPackageInventoryCli{}
+
+type mockPackageInventoryCli struct {
+	ScanFunc func(args *cliwrappers.PackageInventoryScanArgs) error
+}
+
+func (m *mockPackageInventoryCli) Scan(args *cliwrappers.PackageInventoryScanArgs) error {
+	if m.ScanFunc != nil {
+		return m.ScanFunc(args)
+	}
+	return nil
+}
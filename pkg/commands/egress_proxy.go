@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+const egressDialTimeout = 30 * time.Second
+
+// Where the egress relay listens inside the isolated network namespace. The namespace is new,
+// so the port is free, and being fixed lets files generated before the build (e.g. yum repos)
+// point at the relay.
+const egressRelayAddress = "127.0.0.1:3128"
+
+// A connection attempt that went through the egress proxy.
+type NetworkAccessAttempt struct {
+	Time        time.Time `json:"time"`
+	Destination string    `json:"destination"`
+	// CONNECT for tunnels (e.g. https), the request method for plain http requests
+	Method  string `json:"method"`
+	Allowed bool   `json:"allowed"`
+}
+
+// Summary of the connection attempts, reported in the build results.
+type NetworkAccessSummary struct {
+	Allowed            int      `json:"allowed"`
+	Denied             int      `json:"denied"`
+	DeniedDestinations []string `json:"denied_destinations,omitempty"`
+}
+
+// Parse host:port destinations into their normalized form (lowercase host).
+func parseNetworkDestinations(destinations []string) ([]string, error) {
+	var parsed []string
+	for _, destination := range destinations {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(destination))
+		if err != nil {
+			return nil, fmt.Errorf("invalid network-allow destination '%s': %w", destination, err)
+		}
+		if host == "" {
+			return nil, fmt.Errorf("invalid network-allow destination '%s': missing host", destination)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid network-allow destination '%s': invalid port", destination)
+		}
+		parsed = append(parsed, normalizeDestination(host, port))
+	}
+	return parsed, nil
+}
+
+func normalizeDestination(host, port string) string {
+	return net.JoinHostPort(strings.ToLower(host), port)
+}
+
+// The normalized host:port destination of an http(s) URL, the port defaults to the scheme's.
+func urlDestination(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("missing host in URL '%s'", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if strings.EqualFold(u.Scheme, "http") {
+			port = "80"
+		}
+	}
+	return normalizeDestination(u.Hostname(), port), nil
+}
+
+// Network egress for hermetic builds with network-allow.
+//
+// Hermetic builds run in an isolated network namespace (unshare --net) where only the loopback
+// interface is up. Unix sockets are not bound to a network namespace, which makes them the way out:
+//
+//   - The build command, outside the namespace, runs the egressProxy: an HTTP proxy listening
+//     on a unix socket that only connects to the allowed destinations and records every attempt.
+//   - 'internal in-user-namespace', inside the namespace, listens on the loopback interface and
+//     relays every connection to the unix socket (see serveEgressRelay). The build reaches the
+//     relay at egressRelayAddress through the standard proxy environment variables.
+type egressProxy struct {
+	allowed   map[string]bool
+	server    *http.Server
+	transport *http.Transport
+
+	mu       sync.Mutex
+	attempts []NetworkAccessAttempt
+}
+
+// Start serving the egress proxy on the unix socket. Only the allowed destinations
+// (in parseNetworkDestinations form) can be reached.
+func startEgressProxy(socketPath string, allowed []string) (*egressProxy, error) {
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", socketPath, err)
+	}
+
+	dialer := &net.Dialer{Timeout: egressDialTimeout}
+	p := &egressProxy{
+		allowed: make(map[string]bool),
+		// The destination is already checked, the transport only forwards plain http requests
+		transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+	for _, destination := range allowed {
+		p.allowed[destination] = true
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: egressDialTimeout}
+
+	go func() {
+		if err := p.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			l.Logger.Errorf("Egress proxy stopped: %s", err)
+		}
+	}()
+	return p, nil
+}
+
+func (p *egressProxy) Close() error {
+	p.transport.CloseIdleConnections()
+	return p.server.Close()
+}
+
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var destination string
+	if r.Method == http.MethodConnect {
+		host, port, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CONNECT destination: %s", r.Host), http.StatusBadRequest)
+			return
+		}
+		destination = normalizeDestination(host, port)
+	} else {
+		if r.URL.Scheme != "http" || r.URL.Host == "" {
+			http.Error(w, "only CONNECT and absolute http:// requests are supported", http.StatusBadRequest)
+			return
+		}
+		port := r.URL.Port()
+		if port == "" {
+			port = "80"
+		}
+		destination = normalizeDestination(r.URL.Hostname(), port)
+	}
+
+	allowed := p.allowed[destination]
+	p.record(NetworkAccessAttempt{Time: time.Now().UTC(), Destination: destination, Method: r.Method, Allowed: allowed})
+	if !allowed {
+		l.Logger.Warnf("network-allow: denied %s %s", r.Method, destination)
+		http.Error(w, fmt.Sprintf("%s is not in network-allow", destination), http.StatusForbidden)
+		return
+	}
+	l.Logger.Infof("network-allow: allowed %s %s", r.Method, destination)
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r, destination)
+	} else {
+		p.forward(w, r)
+	}
+}
+
+func (p *egressProxy) tunnel(w http.ResponseWriter, r *http.Request, destination string) {
+	ctx, cancel := context.WithTimeout(r.Context(), egressDialTimeout)
+	defer cancel()
+	upstream, err := (&net.Dialer{}).DialContext(ctx, "tcp", destination)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "connection hijacking is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		upstream.Close()
+		client.Close()
+		return
+	}
+	// The client may have sent data (e.g. the TLS hello) right after the CONNECT request
+	if err := flushBuffered(buffered.Reader, upstream); err != nil {
+		upstream.Close()
+		client.Close()
+		return
+	}
+	pipeConns(client, upstream)
+}
+
+func flushBuffered(r *bufio.Reader, w io.Writer) error {
+	if n := r.Buffered(); n > 0 {
+		data, err := r.Peek(n)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return nil
+}
+
+// Headers that apply to a single connection, not to be forwarded.
+var hopByHopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func (p *egressProxy) forward(w http.ResponseWriter, r *http.Request) {
+	outReq := r.Clone(r.Context())
+	outReq.RequestURI = ""
+	for _, header := range hopByHopHeaders {
+		outReq.Header.Del(header)
+	}
+
+	resp, err := p.transport.RoundTrip(outReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range hopByHopHeaders {
+		resp.Header.Del(header)
+	}
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *egressProxy) record(attempt NetworkAccessAttempt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts = append(p.attempts, attempt)
+}
+
+// The connection attempts so far, in order.
+func (p *egressProxy) Attempts() []NetworkAccessAttempt {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]NetworkAccessAttempt{}, p.attempts...)
+}
+
+func (p *egressProxy) Summary() *NetworkAccessSummary {
+	summary := &NetworkAccessSummary{}
+	denied := make(map[string]bool)
+	for _, attempt := range p.Attempts() {
+		if attempt.Allowed {
+			summary.Allowed++
+		} else {
+			summary.Denied++
+			denied[attempt.Destination] = true
+		}
+	}
+	for destination := range denied {
+		summary.DeniedDestinations = append(summary.DeniedDestinations, destination)
+	}
+	sort.Strings(summary.DeniedDestinations)
+	return summary
+}
+
+// Accept connections on the listener and relay each of them to the egress proxy socket.
+// Runs until the listener is closed.
+func serveEgressRelay(listener net.Listener, socketPath string) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			upstream, err := net.Dial("unix", socketPath)
+			if err != nil {
+				l.Logger.Warnf("Failed to connect to the egress proxy: %s", err)
+				conn.Close()
+				return
+			}
+			pipeConns(conn, upstream)
+		}()
+	}
+}
+
+// Copy data both ways until both sides are done, then close the connections.
+func pipeConns(a, b net.Conn) {
+	var wg sync.WaitGroup
+	copyAndCloseWrite := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		if conn, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = conn.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+	wg.Add(2)
+	go copyAndCloseWrite(a, b)
+	go copyAndCloseWrite(b, a)
+	wg.Wait()
+	_ = a.Close()
+	_ = b.Close()
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_parseNetworkDestinations(t *testing.T) {
+	g := NewWithT(t)
+
+	destinations, err := parseNetworkDestinations([]string{"Registry.NPMjs.org:443", " proxy.example.com:8080", "[::1]:3128"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(destinations).To(Equal([]string{"registry.npmjs.org:443", "proxy.example.com:8080", "[::1]:3128"}))
+
+	for _, invalid := range []string{"registry.npmjs.org", ":443", "registry.npmjs.org:0", "registry.npmjs.org:https"} {
+		_, err := parseNetworkDestinations([]string{invalid})
+		g.Expect(err).To(MatchError(ContainSubstring("invalid network-allow destination '%s'", invalid)))
+	}
+}
+
+func Test_egressProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello from %s", r.URL.Path)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	allowed := backendURL.Host
+
+	// The socket path must be short, t.TempDir() may be too deep
+	dir, err := os.MkdirTemp("", "kbc-egress-test-")
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "egress.sock")
+
+	proxy, err := startEgressProxy(socket, []string{allowed})
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	defer proxy.Close()
+
+	// Reach the proxy the way the build does, through the relay on the loopback interface
+	relayListener, err := net.Listen("tcp", "127.0.0.1:0")
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	defer relayListener.Close()
+	go func() {
+		_ = serveEgressRelay(relayListener, socket)
+	}()
+	relayURL, _ := url.Parse("http://" + relayListener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(relayURL)}}
+
+	t.Run("should forward plain http requests to allowed destinations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		resp, err := client.Get(backend.URL + "/allowed")
+		g.Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		g.Expect(string(body)).To(Equal("hello from /allowed"))
+	})
+
+	t.Run("should deny other destinations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		resp, err := client.Get("http://denied.example.com/")
+		g.Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		g.Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+	})
+
+	t.Run("should tunnel CONNECT requests to allowed destinations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		conn, err := (&net.Dialer{}).DialContext(context.Background(), "tcp", relayListener.Addr().String())
+		g.Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		// Send the tunneled request right away, before reading the CONNECT response
+		fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", allowed, allowed)
+		fmt.Fprintf(conn, "GET /tunneled HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", allowed)
+
+		reader := bufio.NewReader(conn)
+		connectResp, err := http.ReadResponse(reader, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(connectResp.StatusCode).To(Equal(http.StatusOK))
+
+		resp, err := http.ReadResponse(reader, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		g.Expect(string(body)).To(Equal("hello from /tunneled"))
+	})
+
+	t.Run("should record every attempt", func(t *testing.T) {
+		g := NewWithT(t)
+
+		resp, err := client.Get("https://denied.example.com/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		g.Expect(err).To(HaveOccurred())
+
+		var recorded []string
+		for _, attempt := range proxy.Attempts() {
+			recorded = append(recorded, fmt.Sprintf("%s %s %t", attempt.Method, attempt.Destination, attempt.Allowed))
+		}
+		g.Expect(recorded).To(Equal([]string{
+			"GET " + allowed + " true",
+			"GET denied.example.com:80 false",
+			"CONNECT " + allowed + " true",
+			"CONNECT denied.example.com:443 false",
+		}))
+		g.Expect(proxy.Summary()).To(Equal(&NetworkAccessSummary{
+			Allowed:            2,
+			Denied:             2,
+			DeniedDestinations: []string{"denied.example.com:443", "denied.example.com:80"},
+		}))
+	})
+}
@@ -0,0 +1,121 @@
+package gitclone
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// bundleFileGlob matches bundle files when a directory of bundles is given.
+const bundleFileGlob = "*.bundle"
+
+// resolveBundles returns absolute paths of the bundle files to clone from,
+// in the order they have to be applied. If bundlePath is a directory, all
+// *.bundle files in it are used in lexical order, so incremental bundles
+// must be named to sort after the bundles they depend on.
+func resolveBundles(bundlePath string) ([]string, error) {
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access bundle: %w", err)
+	}
+
+	var bundles []string
+	if info.IsDir() {
+		// Glob returns matches in lexical order
+		bundles, err = filepath.Glob(filepath.Join(bundlePath, bundleFileGlob))
+		if err != nil {
+			return nil, err
+		}
+		if len(bundles) == 0 {
+			return nil, fmt.Errorf("no %s files found in bundle directory %s", bundleFileGlob, bundlePath)
+		}
+	} else {
+		bundles = []string{bundlePath}
+	}
+
+	// git runs in the checkout directory, relative paths would not resolve
+	for i, bundle := range bundles {
+		absBundle, err := filepath.Abs(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve bundle path: %w", err)
+		}
+		bundles[i] = absBundle
+	}
+	return bundles, nil
+}
+
+// importBundles verifies the bundles and stores the objects of all but the newest one
+// in the repository. This satisfies the prerequisites of the newest bundle, which is
+// then used as the fetch source in place of the remote (see fetchOptions).
+func (c *GitClone) importBundles() error {
+	for i, bundle := range c.bundles {
+		l.Logger.Debugf("Verifying bundle %s", bundle)
+		if err := c.CliWrappers.GitCli.BundleVerify(bundle); err != nil {
+			return fmt.Errorf("bundle verification failed for %s: %w", bundle, err)
+		}
+		if i == len(c.bundles)-1 {
+			break
+		}
+		if err := c.CliWrappers.GitCli.BundleUnbundle(bundle); err != nil {
+			return fmt.Errorf("failed to unbundle %s: %w", bundle, err)
+		}
+	}
+	return nil
+}
+
+// fetchOptions returns fetch options for the given remote. When cloning from bundles,
+// the newest bundle replaces the remote and network related options are dropped.
+func (c *GitClone) fetchOptions(remote, refspec string, depth int, submodules bool) cliwrappers.GitFetchOptions {
+	if len(c.bundles) > 0 {
+		return cliwrappers.GitFetchOptions{
+			Remote:      c.bundles[len(c.bundles)-1],
+			Refspec:     refspec,
+			MaxAttempts: 1,
+		}
+	}
+
+	maxAttempts := c.Params.RetryMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return cliwrappers.GitFetchOptions{
+		Remote:      remote,
+		Refspec:     refspec,
+		Depth:       depth,
+		Submodules:  submodules,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// fetchBundleBranches maps the branches in the newest bundle to the remote-tracking refs of origin,
+// which fetching from origin would update, so that the branch of the revision can be resolved.
+func (c *GitClone) fetchBundleBranches() error {
+	bundle := c.bundles[len(c.bundles)-1]
+	err := c.CliWrappers.GitCli.FetchWithRefspec(cliwrappers.GitFetchOptions{
+		Remote:      bundle,
+		Refspec:     "+refs/heads/*:refs/remotes/origin/*",
+		MaxAttempts: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch branches from bundle %s: %w", bundle, err)
+	}
+	return nil
+}
+
+// fetchBundleTags fetches all tags recorded in the bundles, the offline equivalent of git fetch --tags.
+func (c *GitClone) fetchBundleTags() error {
+	for _, bundle := range c.bundles {
+		err := c.CliWrappers.GitCli.FetchWithRefspec(cliwrappers.GitFetchOptions{
+			Remote:      bundle,
+			Refspec:     "+refs/tags/*:refs/tags/*",
+			MaxAttempts: 1,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch tags from bundle %s: %w", bundle, err)
+		}
+	}
+	return nil
+}
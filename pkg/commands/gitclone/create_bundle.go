@@ -0,0 +1,152 @@
+package gitclone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var CreateBundleParamsConfig = map[string]common.Parameter{
+	"source-dir": {
+		Name:         "source-dir",
+		EnvVarName:   "KBC_GIT_CREATE_BUNDLE_SOURCE_DIR",
+		TypeKind:     reflect.String,
+		DefaultValue: ".",
+		Usage:        "Path to an existing git checkout to create the bundle from.",
+	},
+	"output": {
+		Name:       "output",
+		ShortName:  "o",
+		EnvVarName: "KBC_GIT_CREATE_BUNDLE_OUTPUT",
+		TypeKind:   reflect.String,
+		Usage:      "Path of the bundle file to create.",
+		Required:   true,
+	},
+	"revisions": {
+		Name:         "revisions",
+		EnvVarName:   "KBC_GIT_CREATE_BUNDLE_REVISIONS",
+		TypeKind:     reflect.Slice,
+		DefaultValue: "--all",
+		Usage:        "Revisions to include in the bundle, as accepted by 'git bundle create', e.g. --all, a branch name or a range like v1.0..main for an incremental bundle.",
+	},
+}
+
+type CreateBundleParams struct {
+	SourceDir string   `paramName:"source-dir"`
+	Output    string   `paramName:"output"`
+	Revisions []string `paramName:"revisions"`
+}
+
+type CreateBundleResults struct {
+	Bundle string `json:"bundle"`
+	Digest string `json:"digest"`
+	// Ref name => commit SHA
+	Refs map[string]string `json:"refs"`
+}
+
+// CreateBundle creates git bundles usable by git-clone --bundle.
+type CreateBundle struct {
+	Params        *CreateBundleParams
+	CliWrappers   CliWrappers
+	Results       CreateBundleResults
+	ResultsWriter common.ResultsWriterInterface
+}
+
+func NewCreateBundle(cmd *cobra.Command) (*CreateBundle, error) {
+	createBundle := &CreateBundle{}
+
+	params := &CreateBundleParams{}
+	if err := common.ParseParameters(cmd, CreateBundleParamsConfig, params); err != nil {
+		return nil, err
+	}
+	createBundle.Params = params
+
+	createBundle.ResultsWriter = common.NewResultsWriter()
+
+	return createBundle, nil
+}
+
+func (c *CreateBundle) Run() error {
+	common.LogParameters(CreateBundleParamsConfig, c.Params)
+
+	if c.Params.Output == "" {
+		return fmt.Errorf("output parameter is required")
+	}
+	if len(c.Params.Revisions) == 0 {
+		return fmt.Errorf("at least one revision is required")
+	}
+
+	// git runs in the source directory, relative paths would not resolve
+	output, err := filepath.Abs(c.Params.Output)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output path: %w", err)
+	}
+
+	if c.CliWrappers.GitCli == nil {
+		gitCli, err := cliwrappers.NewGitCli(cliwrappers.NewCliExecutor(), c.Params.SourceDir)
+		if err != nil {
+			return err
+		}
+		c.CliWrappers.GitCli = gitCli
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	l.Logger.Debugf("Creating bundle %s", output)
+	if err := c.CliWrappers.GitCli.BundleCreate(output, c.Params.Revisions); err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	refs, err := c.CliWrappers.GitCli.BundleListHeads(output)
+	if err != nil {
+		return fmt.Errorf("failed to list bundle refs: %w", err)
+	}
+
+	digest, err := fileDigest(output)
+	if err != nil {
+		return fmt.Errorf("failed to compute bundle digest: %w", err)
+	}
+
+	c.Results = CreateBundleResults{
+		Bundle: output,
+		Digest: digest,
+		Refs:   refs,
+	}
+
+	resultJson, err := c.ResultsWriter.CreateResultJson(c.Results)
+	if err != nil {
+		return fmt.Errorf("failed to create results json: %w", err)
+	}
+	fmt.Println(resultJson)
+	return nil
+}
+
+// fileDigest returns the sha256 digest of a file in the sha256:<hex> form.
+func fileDigest(path string) (digest string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
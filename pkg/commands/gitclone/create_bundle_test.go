@@ -0,0 +1,71 @@
+package gitclone
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_CreateBundle_Run(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should create bundle and report digest and refs", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "bundles", "repo.bundle")
+
+		_mockGitCli := &mockGitCli{
+			BundleCreateFunc: func(bundlePath string, revs []string) error {
+				g.Expect(bundlePath).To(Equal(output))
+				g.Expect(revs).To(Equal([]string{"--all"}))
+				return os.WriteFile(bundlePath, []byte("bundle content"), 0644)
+			},
+			BundleListHeadsFunc: func(bundlePath string) (map[string]string, error) {
+				return map[string]string{"refs/heads/main": "abc123"}, nil
+			},
+		}
+		resultsWriter := &mockResultsWriter{}
+		c := &CreateBundle{
+			Params:        &CreateBundleParams{SourceDir: ".", Output: output, Revisions: []string{"--all"}},
+			CliWrappers:   CliWrappers{GitCli: _mockGitCli},
+			ResultsWriter: resultsWriter,
+		}
+
+		err := c.Run()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.Results.Bundle).To(Equal(output))
+		// sha256 of "bundle content"
+		g.Expect(c.Results.Digest).To(Equal("sha256:f559e7ab98071a6e97c14ea20a76d030d725f47d17bbb2960aeabcb96f2131e7"))
+		g.Expect(c.Results.Refs).To(Equal(map[string]string{"refs/heads/main": "abc123"}))
+	})
+
+	t.Run("should fail if bundle creation fails", func(t *testing.T) {
+		c := &CreateBundle{
+			Params: &CreateBundleParams{Output: filepath.Join(t.TempDir(), "repo.bundle"), Revisions: []string{"--all"}},
+			CliWrappers: CliWrappers{GitCli: &mockGitCli{
+				BundleCreateFunc: func(bundlePath string, revs []string) error {
+					return errors.New("empty bundle")
+				},
+			}},
+			ResultsWriter: &mockResultsWriter{},
+		}
+
+		err := c.Run()
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed to create bundle"))
+	})
+
+	t.Run("should require revisions", func(t *testing.T) {
+		c := &CreateBundle{
+			Params: &CreateBundleParams{Output: "repo.bundle"},
+		}
+
+		err := c.Run()
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("at least one revision is required"))
+	})
+}
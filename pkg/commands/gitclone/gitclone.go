@@ -2,6 +2,7 @@ package gitclone
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -24,6 +25,10 @@ type GitClone struct {
 	Results       Results
 	ResultsWriter common.ResultsWriterInterface
 	internalDir   string
+	// bundles to clone from instead of the remote, in the order they're applied
+	bundles []string
+	// urlRewrites configured in the internal global git config
+	urlRewrites []urlRewrite
 }
 
 func New(cmd *cobra.Command) (*GitClone, error) {
@@ -59,6 +64,14 @@ func (c *GitClone) Run() error {
 		return err
 	}
 
+	if c.Params.Bundle != "" {
+		bundles, err := resolveBundles(c.Params.Bundle)
+		if err != nil {
+			return err
+		}
+		c.bundles = bundles
+	}
+
 	if c.CliWrappers.GitCli == nil {
 		if err := c.initCliWrappers(); err != nil {
 			return err
@@ -88,6 +101,11 @@ func (c *GitClone) Run() error {
 		return err
 	}
 
+	// Must run after setupBasicAuth, which may create the global git config
+	if err := c.setupURLRewrites(); err != nil {
+		return err
+	}
+
 	// Verify the checkout directory path doesn't escape OutputDir via symlinks
 	// before any destructive operations (clean/clone).
 	if err := c.verifyCheckoutDirContainment(); err != nil {
@@ -111,10 +129,12 @@ func (c *GitClone) Run() error {
 		}
 	}
 
-	if c.Params.EnableSymlinkCheck {
-		if err := common.CheckSymlinks(c.getCheckoutDir()); err != nil {
+	if err := c.checkSourceTree(); err != nil {
+		if c.Params.SourcePolicyFile == "" {
+			// Without a policy, only the symlink rule runs
 			return fmt.Errorf("symlink check: %w", err)
 		}
+		return fmt.Errorf("source tree check: %w", err)
 	}
 
 	if err := c.gatherCommitInfo(); err != nil {
@@ -122,11 +142,23 @@ func (c *GitClone) Run() error {
 	}
 
 	if c.Params.FetchTags {
-		if _, err := c.CliWrappers.GitCli.FetchTags(); err != nil {
+		if len(c.bundles) > 0 {
+			if err := c.fetchBundleTags(); err != nil {
+				return err
+			}
+		} else if _, err := c.CliWrappers.GitCli.FetchTags(); err != nil {
 			return err
 		}
 	}
 
+	if err := c.gatherCommitMetadata(); err != nil {
+		return err
+	}
+
+	if err := c.writeCommitMetadataFile(); err != nil {
+		return err
+	}
+
 	return c.outputResults()
 }
 
@@ -136,7 +168,11 @@ func (c *GitClone) logParams() {
 	if c.Params.MergeSourceRepoURL != "" {
 		l.Logger.Infof("[param] merge-source-repo-url: %s", sanitizeURL(c.Params.MergeSourceRepoURL))
 	}
-	common.LogParameters(ParamsConfig, c.Params, "url", "merge-source-repo-url")
+	for _, rule := range c.Params.URLRewrite {
+		from, to, _ := strings.Cut(rule, "=")
+		l.Logger.Infof("[param] url-rewrite: %s=%s", sanitizeURL(from), sanitizeURL(to))
+	}
+	common.LogParameters(ParamsConfig, c.Params, "url", "merge-source-repo-url", "url-rewrite")
 }
 
 // sanitizeURL removes credentials from a URL for safe logging.
@@ -297,10 +333,23 @@ func (c *GitClone) performClone() error {
 	}
 
 	l.Logger.Debugf("Adding remote origin: %s", sanitizeURL(c.Params.URL))
+	if len(c.urlRewrites) > 0 {
+		l.Logger.Debugf("Effective origin URL: %s", sanitizeURL(rewriteURL(c.Params.URL, c.urlRewrites)))
+	}
 	if _, err := c.CliWrappers.GitCli.RemoteAdd("origin", c.Params.URL); err != nil {
 		return fmt.Errorf("git remote add failed: %w", err)
 	}
 
+	if len(c.bundles) > 0 {
+		if err := c.importBundles(); err != nil {
+			return err
+		}
+		// Before fetching the revision, which sets the FETCH_HEAD to check out
+		if err := c.fetchBundleBranches(); err != nil {
+			return err
+		}
+	}
+
 	if err := c.fetchRevision(); err != nil {
 		return err
 	}
@@ -317,7 +366,12 @@ func (c *GitClone) performClone() error {
 		return fmt.Errorf("git checkout failed: %w", err)
 	}
 
-	if c.Params.Submodules {
+	if c.Params.Submodules && len(c.bundles) > 0 {
+		// Bundles don't contain the submodule repositories, the checkout would be incomplete
+		if _, err := os.Lstat(filepath.Join(checkoutDir, ".gitmodules")); err == nil {
+			return errors.New("the repository has submodules, which can't be fetched from a bundle, use --submodules=false to clone without them")
+		}
+	} else if c.Params.Submodules {
 		l.Logger.Debug("Updating submodules")
 		paths, err := parseCSV(c.Params.SubmodulePaths)
 		if err != nil {
@@ -339,20 +393,10 @@ func (c *GitClone) fetchRevision() error {
 		refspec = c.Params.Revision
 	}
 
-	l.Logger.Debugf("Fetching from origin (depth=%d, refspec=%s)", c.Params.Depth, refspec)
-
-	maxAttempts := c.Params.RetryMaxAttempts
-	if maxAttempts < 1 {
-		maxAttempts = 1
-	}
+	opts := c.fetchOptions("origin", refspec, c.Params.Depth, c.Params.Submodules)
+	l.Logger.Debugf("Fetching from %s (depth=%d, refspec=%s)", sanitizeURL(opts.Remote), opts.Depth, refspec)
 
-	err := c.CliWrappers.GitCli.FetchWithRefspec(cliwrappers.GitFetchOptions{
-		Remote:      "origin",
-		Refspec:     refspec,
-		Depth:       c.Params.Depth,
-		Submodules:  c.Params.Submodules,
-		MaxAttempts: maxAttempts,
-	})
+	err := c.CliWrappers.GitCli.FetchWithRefspec(opts)
 	if err != nil {
 		return fmt.Errorf("git fetch failed: %w", err)
 	}
@@ -370,11 +414,12 @@ func parseCSV(input string) ([]string, error) {
 }
 
 func (c *GitClone) mergeTargetBranch() error {
-	if c.Params.Depth == 1 {
+	// Depth doesn't apply to bundles, they contain whatever history they were created with
+	if c.Params.Depth == 1 && len(c.bundles) == 0 {
 		l.Logger.Warning("Shallow clone with depth=1 may cause merge conflicts due to insufficient commit history.")
 	}
 
-	if c.Params.MergeSourceDepth == 1 {
+	if c.Params.MergeSourceDepth == 1 && len(c.bundles) == 0 {
 		l.Logger.Warning("Shallow fetch with merge-source-depth=1 may cause merge conflicts due to insufficient commit history.")
 	}
 
@@ -394,18 +439,13 @@ func (c *GitClone) mergeTargetBranch() error {
 		}
 	}
 
-	maxAttempts := c.Params.RetryMaxAttempts
-	if maxAttempts < 1 {
-		maxAttempts = 1
+	refspec := c.Params.TargetBranch
+	if len(c.bundles) > 0 {
+		// Fetching from a bundle doesn't update remote-tracking refs, map the branch explicitly
+		refspec = fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", c.Params.TargetBranch, mergeRemote, c.Params.TargetBranch)
 	}
 
-	err := c.CliWrappers.GitCli.FetchWithRefspec(cliwrappers.GitFetchOptions{
-		Remote:      mergeRemote,
-		Refspec:     c.Params.TargetBranch,
-		Depth:       c.Params.MergeSourceDepth,
-		Submodules:  false,
-		MaxAttempts: maxAttempts,
-	})
+	err := c.CliWrappers.GitCli.FetchWithRefspec(c.fetchOptions(mergeRemote, refspec, c.Params.MergeSourceDepth, false))
 	if err != nil {
 		return fmt.Errorf("failed to fetch target branch: %w", err)
 	}
@@ -208,6 +208,21 @@ func Test_GitClone_gatherCommitInfo(t *testing.T) {
 		g.Expect(c.Results.ChainsGitCommit).To(Equal(fullSha))
 	})
 
+	t.Run("should report effective URLs when rewrite rules are configured", func(t *testing.T) {
+		beforeEach()
+		c.Params.MergeTargetBranch = true
+		c.Params.MergeSourceRepoURL = "https://git.test/upstream/repo.git"
+		c.urlRewrites = []urlRewrite{{From: "https://git.test/", To: "https://mirror.test/"}}
+
+		err := c.gatherCommitInfo()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.Results.URL).To(Equal("https://git.test/user/repo.git"))
+		g.Expect(c.Results.ChainsGitURL).To(Equal("https://git.test/user/repo.git"))
+		g.Expect(c.Results.EffectiveURL).To(Equal("https://mirror.test/user/repo.git"))
+		g.Expect(c.Results.EffectiveMergeSourceURL).To(Equal("https://mirror.test/upstream/repo.git"))
+	})
+
 	t.Run("should fail if getting full SHA fails", func(t *testing.T) {
 		beforeEach()
 
@@ -283,6 +298,149 @@ func Test_GitClone_gatherCommitInfo(t *testing.T) {
 	})
 }
 
+func Test_GitClone_gatherCommitMetadata(t *testing.T) {
+	g := NewWithT(t)
+
+	var _mockGitCli *mockGitCli
+	var c *GitClone
+
+	beforeEach := func() {
+		_mockGitCli = &mockGitCli{
+			LogFunc: func(format string, count int) (string, error) {
+				g.Expect(format).To(Equal(commitMetadataLogFormat))
+				g.Expect(count).To(Equal(1))
+				return "Jane Doe\x00jane@git.test\x00CI Bot\x00ci@git.test\x00aaa111 bbb222\x00Merge feature: add things", nil
+			},
+		}
+		c = &GitClone{
+			CliWrappers: CliWrappers{GitCli: _mockGitCli},
+			Params: &Params{
+				URL:      "https://git.test/user/repo.git",
+				Revision: "main",
+			},
+		}
+	}
+
+	t.Run("should gather commit metadata", func(t *testing.T) {
+		beforeEach()
+		c.Params.Submodules = true
+
+		_mockGitCli.DescribeTagsFunc = func(ref string) (string, error) {
+			g.Expect(ref).To(Equal("HEAD"))
+			return "v1.0.0-3-gabc123d", nil
+		}
+		_mockGitCli.TagsPointingAtFunc = func(ref string) ([]string, error) {
+			g.Expect(ref).To(Equal("HEAD"))
+			return []string{"v1.0.3"}, nil
+		}
+		_mockGitCli.RevParseFunc = func(ref string, short bool, length int) (string, error) {
+			g.Expect(ref).To(Equal("refs/remotes/origin/main"))
+			return "abc123", nil
+		}
+		submodules := []cliwrappers.GitSubmodule{{Path: "lib", Commit: "ccc333", URL: "https://git.test/user/lib.git"}}
+		_mockGitCli.SubmoduleListFunc = func() ([]cliwrappers.GitSubmodule, error) {
+			return submodules, nil
+		}
+
+		err := c.gatherCommitMetadata()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.Results.CommitMetadata).To(Equal(CommitMetadata{
+			AuthorName:     "Jane Doe",
+			AuthorEmail:    "jane@git.test",
+			CommitterName:  "CI Bot",
+			CommitterEmail: "ci@git.test",
+			Subject:        "Merge feature: add things",
+			Describe:       "v1.0.0-3-gabc123d",
+			Tags:           []string{"v1.0.3"},
+			Parents:        []string{"aaa111", "bbb222"},
+			Branch:         "main",
+			Submodules:     submodules,
+		}))
+	})
+
+	t.Run("should tolerate missing tags for describe", func(t *testing.T) {
+		beforeEach()
+
+		_mockGitCli.DescribeTagsFunc = func(ref string) (string, error) {
+			return "", errors.New("No names found, cannot describe anything.")
+		}
+
+		err := c.gatherCommitMetadata()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.Results.Describe).To(BeEmpty())
+	})
+
+	t.Run("should not report branch for a commit SHA", func(t *testing.T) {
+		beforeEach()
+		c.Params.Revision = "abc123def456"
+
+		_mockGitCli.RevParseFunc = func(ref string, short bool, length int) (string, error) {
+			return "", errors.New("unknown revision")
+		}
+
+		err := c.gatherCommitMetadata()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.Results.Branch).To(BeEmpty())
+	})
+
+	t.Run("should strip refs/heads prefix from branch", func(t *testing.T) {
+		beforeEach()
+		c.Params.Revision = "refs/heads/release-1.0"
+
+		err := c.gatherCommitMetadata()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(c.Results.Branch).To(Equal("release-1.0"))
+	})
+
+	t.Run("should fail on unexpected log output", func(t *testing.T) {
+		beforeEach()
+
+		_mockGitCli.LogFunc = func(format string, count int) (string, error) {
+			return "garbage", nil
+		}
+
+		err := c.gatherCommitMetadata()
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unexpected commit metadata format"))
+	})
+}
+
+func Test_GitClone_writeCommitMetadataFile(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should skip when no file requested", func(t *testing.T) {
+		resultsWriter := &mockResultsWriter{}
+		c := &GitClone{Params: &Params{}, ResultsWriter: resultsWriter}
+
+		g.Expect(c.writeCommitMetadataFile()).To(Succeed())
+		g.Expect(resultsWriter.WrittenResults).To(BeEmpty())
+	})
+
+	t.Run("should write all results as JSON", func(t *testing.T) {
+		resultsWriter := &mockResultsWriter{}
+		c := &GitClone{
+			Params:        &Params{CommitMetadataFile: "/results/commit.json"},
+			ResultsWriter: resultsWriter,
+			Results: Results{
+				Commit:         "abc123",
+				CommitMetadata: CommitMetadata{AuthorName: "Jane Doe", Parents: []string{"aaa111"}},
+			},
+		}
+
+		g.Expect(c.writeCommitMetadataFile()).To(Succeed())
+		g.Expect(resultsWriter.WrittenResults["/results/commit.json"]).To(And(
+			ContainSubstring(`"commit":"abc123"`),
+			ContainSubstring(`"authorName":"Jane Doe"`),
+			ContainSubstring(`"parents":["aaa111"]`),
+		))
+	})
+}
+
 func Test_GitClone_performClone(t *testing.T) {
 	g := NewWithT(t)
 
@@ -440,6 +598,131 @@ func Test_GitClone_performClone(t *testing.T) {
 		g.Expect(err).ToNot(HaveOccurred())
 		g.Expect(isSubmoduleUpdateCalled).To(BeTrue())
 	})
+
+	t.Run("should fetch from bundles instead of origin", func(t *testing.T) {
+		beforeEach()
+		c.Params.Revision = "main"
+		c.Params.Submodules = true
+		c.bundles = []string{"/bundles/00-full.bundle", "/bundles/01-incr.bundle"}
+
+		var verified, unbundled []string
+		_mockGitCli.BundleVerifyFunc = func(bundlePath string) error {
+			verified = append(verified, bundlePath)
+			return nil
+		}
+		_mockGitCli.BundleUnbundleFunc = func(bundlePath string) error {
+			unbundled = append(unbundled, bundlePath)
+			return nil
+		}
+		_mockGitCli.RemoteAddFunc = func(name, url string) (string, error) {
+			g.Expect(name).To(Equal("origin"))
+			g.Expect(url).To(Equal("https://git.test/user/repo.git"))
+			return "", nil
+		}
+		var fetches []cliwrappers.GitFetchOptions
+		_mockGitCli.FetchWithRefspecFunc = func(opts cliwrappers.GitFetchOptions) error {
+			fetches = append(fetches, opts)
+			return nil
+		}
+		_mockGitCli.SubmoduleUpdateFunc = func(init bool, depth int, paths []string) error {
+			t.Error("submodules must not be updated when cloning from bundles")
+			return nil
+		}
+
+		err := c.performClone()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		// The branches are mapped before the revision is fetched, the checkout uses its FETCH_HEAD
+		g.Expect(fetches).To(Equal([]cliwrappers.GitFetchOptions{
+			{Remote: "/bundles/01-incr.bundle", Refspec: "+refs/heads/*:refs/remotes/origin/*", MaxAttempts: 1},
+			{Remote: "/bundles/01-incr.bundle", Refspec: "main", MaxAttempts: 1},
+		}))
+		g.Expect(verified).To(Equal(c.bundles))
+		g.Expect(unbundled).To(Equal([]string{"/bundles/00-full.bundle"}))
+	})
+
+	t.Run("should fail to clone a repository with submodules from bundles", func(t *testing.T) {
+		beforeEach()
+		c.Params.Submodules = true
+		c.bundles = []string{"/bundles/repo.bundle"}
+
+		_mockGitCli.CheckoutFunc = func(ref string) error {
+			return os.WriteFile(filepath.Join(c.getCheckoutDir(), ".gitmodules"), []byte("[submodule \"lib\"]\n"), 0644)
+		}
+
+		err := c.performClone()
+
+		g.Expect(err).To(MatchError(ContainSubstring("use --submodules=false to clone without them")))
+
+		c.Params.Submodules = false
+		g.Expect(c.performClone()).To(Succeed())
+	})
+
+	t.Run("should fail if bundle verification fails", func(t *testing.T) {
+		beforeEach()
+		c.bundles = []string{"/bundles/repo.bundle"}
+
+		_mockGitCli.BundleVerifyFunc = func(bundlePath string) error {
+			return errors.New("missing prerequisites")
+		}
+		_mockGitCli.FetchWithRefspecFunc = func(opts cliwrappers.GitFetchOptions) error {
+			t.Error("fetch must not be called")
+			return nil
+		}
+
+		err := c.performClone()
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("bundle verification failed for /bundles/repo.bundle"))
+	})
+}
+
+func Test_resolveBundles(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should resolve a single bundle file to an absolute path", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		bundle := filepath.Join(tmpDir, "repo.bundle")
+		g.Expect(os.WriteFile(bundle, []byte("bundle"), 0644)).To(Succeed())
+		t.Chdir(tmpDir)
+
+		bundles, err := resolveBundles("repo.bundle")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		resolvedDir, err := os.Getwd()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(bundles).To(Equal([]string{filepath.Join(resolvedDir, "repo.bundle")}))
+	})
+
+	t.Run("should return bundles from a directory in lexical order", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		for _, name := range []string{"02-b.bundle", "00-full.bundle", "01-a.bundle", "README"} {
+			g.Expect(os.WriteFile(filepath.Join(tmpDir, name), []byte("bundle"), 0644)).To(Succeed())
+		}
+
+		bundles, err := resolveBundles(tmpDir)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(bundles).To(Equal([]string{
+			filepath.Join(tmpDir, "00-full.bundle"),
+			filepath.Join(tmpDir, "01-a.bundle"),
+			filepath.Join(tmpDir, "02-b.bundle"),
+		}))
+	})
+
+	t.Run("should fail for a directory without bundles", func(t *testing.T) {
+		_, err := resolveBundles(t.TempDir())
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("no *.bundle files found"))
+	})
+
+	t.Run("should fail for missing bundle", func(t *testing.T) {
+		_, err := resolveBundles("/nonexistent/repo.bundle")
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed to access bundle"))
+	})
 }
 
 func Test_GitClone_outputResults(t *testing.T) {
@@ -573,6 +856,9 @@ func Test_GitClone_Run(t *testing.T) {
 		}
 
 		_mockGitCli.LogFunc = func(format string, count int) (string, error) {
+			if format == commitMetadataLogFormat {
+				return "Author\x00author@git.test\x00Committer\x00committer@git.test\x00parent1\x00Subject", nil
+			}
 			return timestamp, nil
 		}
 
@@ -641,6 +927,9 @@ func Test_GitClone_Run(t *testing.T) {
 		}
 
 		_mockGitCli.LogFunc = func(format string, count int) (string, error) {
+			if format == commitMetadataLogFormat {
+				return "Author\x00author@git.test\x00Committer\x00committer@git.test\x00parent1\x00Subject", nil
+			}
 			return timestamp, nil
 		}
 
@@ -672,6 +961,9 @@ func Test_GitClone_Run(t *testing.T) {
 		}
 
 		_mockGitCli.LogFunc = func(format string, count int) (string, error) {
+			if format == commitMetadataLogFormat {
+				return "Author\x00author@git.test\x00Committer\x00committer@git.test\x00parent1\x00Subject", nil
+			}
 			return timestamp, nil
 		}
 
@@ -1035,6 +1327,37 @@ func Test_GitClone_setupBasicAuth(t *testing.T) {
 		g.Expect(envVars[envGitConfigGlobal]).To(Equal(filepath.Join(internalDir, ".gitconfig")))
 	})
 
+	t.Run("should configure extraHeader from token file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		authDir := filepath.Join(tmpDir, "auth")
+		internalDir := t.TempDir()
+		g.Expect(os.MkdirAll(authDir, 0755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(authDir, "token"), []byte("mytoken\n"), 0644)).To(Succeed())
+
+		envVars := map[string]string{}
+		_mockGitCli := &mockGitCli{
+			SetEnvFunc: func(key, value string) { envVars[key] = value },
+		}
+		c := &GitClone{
+			CliWrappers: CliWrappers{GitCli: _mockGitCli},
+			Params: &Params{
+				URL:                "https://git.test/user/repo",
+				BasicAuthDirectory: authDir,
+			},
+			internalDir: internalDir,
+		}
+
+		err := c.setupBasicAuth()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		config, err := os.ReadFile(filepath.Join(internalDir, ".gitconfig"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(config)).To(ContainSubstring(`[http "https://git.test/"]`))
+		g.Expect(string(config)).To(ContainSubstring(`extraHeader = "Authorization: Bearer mytoken"`))
+		g.Expect(filepath.Join(internalDir, ".git-credentials")).ToNot(BeAnExistingFile())
+		g.Expect(envVars[envGitConfigGlobal]).To(Equal(filepath.Join(internalDir, ".gitconfig")))
+	})
+
 	t.Run("should fail with unknown auth format", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		authDir := filepath.Join(tmpDir, "auth")
@@ -1196,49 +1519,6 @@ func Test_GitClone_validateParams_subdirectory(t *testing.T) {
 	})
 }
 
-func Test_readFileWithLimit(t *testing.T) {
-	g := NewWithT(t)
-
-	t.Run("should read file within limit", func(t *testing.T) {
-		tmpFile := filepath.Join(t.TempDir(), "small.txt")
-		g.Expect(os.WriteFile(tmpFile, []byte("hello"), 0644)).To(Succeed())
-
-		data, err := readFileWithLimit(tmpFile, 1024)
-
-		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(string(data)).To(Equal("hello"))
-	})
-
-	t.Run("should reject file exceeding limit", func(t *testing.T) {
-		tmpFile := filepath.Join(t.TempDir(), "large.txt")
-		// Create a file larger than the limit
-		largeData := make([]byte, 2048)
-		g.Expect(os.WriteFile(tmpFile, largeData, 0644)).To(Succeed())
-
-		_, err := readFileWithLimit(tmpFile, 1024)
-
-		g.Expect(err).To(HaveOccurred())
-		g.Expect(err.Error()).To(ContainSubstring("exceeds maximum allowed size"))
-	})
-
-	t.Run("should return error for nonexistent file", func(t *testing.T) {
-		_, err := readFileWithLimit("/nonexistent/file", 1024)
-
-		g.Expect(err).To(HaveOccurred())
-	})
-
-	t.Run("should read file exactly at limit", func(t *testing.T) {
-		tmpFile := filepath.Join(t.TempDir(), "exact.txt")
-		exactData := make([]byte, 1024)
-		g.Expect(os.WriteFile(tmpFile, exactData, 0644)).To(Succeed())
-
-		data, err := readFileWithLimit(tmpFile, 1024)
-
-		g.Expect(err).ToNot(HaveOccurred())
-		g.Expect(data).To(HaveLen(1024))
-	})
-}
-
 func Test_GitClone_verifyCheckoutDirContainment(t *testing.T) {
 	g := NewWithT(t)
 
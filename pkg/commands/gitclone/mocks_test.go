@@ -23,6 +23,13 @@ type mockGitCli struct {
 	CommitFunc            func(message string) (string, error)
 	MergeFunc             func(ref, message string) (string, error)
 	FetchTagsFunc         func() ([]string, error)
+	BundleVerifyFunc      func(bundlePath string) error
+	BundleUnbundleFunc    func(bundlePath string) error
+	BundleCreateFunc      func(bundlePath string, revs []string) error
+	BundleListHeadsFunc   func(bundlePath string) (map[string]string, error)
+	DescribeTagsFunc      func(ref string) (string, error)
+	TagsPointingAtFunc    func(ref string) ([]string, error)
+	SubmoduleListFunc     func() ([]cliwrappers.GitSubmodule, error)
 }
 
 func (m *mockGitCli) SetEnv(key, value string) {
@@ -115,6 +122,55 @@ func (m *mockGitCli) Log(format string, count int) (string, error) {
 	return "", nil
 }
 
+func (m *mockGitCli) BundleVerify(bundlePath string) error {
+	if m.BundleVerifyFunc != nil {
+		return m.BundleVerifyFunc(bundlePath)
+	}
+	return nil
+}
+
+func (m *mockGitCli) BundleUnbundle(bundlePath string) error {
+	if m.BundleUnbundleFunc != nil {
+		return m.BundleUnbundleFunc(bundlePath)
+	}
+	return nil
+}
+
+func (m *mockGitCli) BundleCreate(bundlePath string, revs []string) error {
+	if m.BundleCreateFunc != nil {
+		return m.BundleCreateFunc(bundlePath, revs)
+	}
+	return nil
+}
+
+func (m *mockGitCli) BundleListHeads(bundlePath string) (map[string]string, error) {
+	if m.BundleListHeadsFunc != nil {
+		return m.BundleListHeadsFunc(bundlePath)
+	}
+	return nil, nil
+}
+
+func (m *mockGitCli) DescribeTags(ref string) (string, error) {
+	if m.DescribeTagsFunc != nil {
+		return m.DescribeTagsFunc(ref)
+	}
+	return "", nil
+}
+
+func (m *mockGitCli) TagsPointingAt(ref string) ([]string, error) {
+	if m.TagsPointingAtFunc != nil {
+		return m.TagsPointingAtFunc(ref)
+	}
+	return nil, nil
+}
+
+func (m *mockGitCli) SubmoduleList() ([]cliwrappers.GitSubmodule, error) {
+	if m.SubmoduleListFunc != nil {
+		return m.SubmoduleListFunc()
+	}
+	return nil, nil
+}
+
 var _ common.ResultsWriterInterface = &mockResultsWriter{}
 
 type mockResultsWriter struct {
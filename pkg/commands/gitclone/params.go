@@ -15,6 +15,13 @@ var ParamsConfig = map[string]common.Parameter{
 		Usage:      "Repository URL to clone from.",
 		Required:   true,
 	},
+	"bundle": {
+		Name:         "bundle",
+		EnvVarName:   "KBC_GIT_CLONE_BUNDLE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to a git bundle file, or a directory of *.bundle files applied in lexical order, to clone from instead of the network. The url parameter is still recorded as the origin. Bundles don't contain submodules, repositories with submodules need submodules=false.",
+	},
 	"revision": {
 		Name:         "revision",
 		EnvVarName:   "KBC_GIT_CLONE_REVISION",
@@ -90,7 +97,21 @@ var ParamsConfig = map[string]common.Parameter{
 		EnvVarName:   "KBC_GIT_CLONE_ENABLE_SYMLINK_CHECK",
 		TypeKind:     reflect.Bool,
 		DefaultValue: "true",
-		Usage:        "Check symlinks in the repo. If they're pointing outside of the repo, the build will fail.",
+		Usage:        "Check symlinks in the repo. If they're pointing outside of the repo, the build will fail. Setting this to false turns off the symlink-escape rule of the source tree checks.",
+	},
+	"source-policy-file": {
+		Name:         "source-policy-file",
+		EnvVarName:   "KBC_GIT_CLONE_SOURCE_POLICY_FILE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to a JSON source tree policy to check the checked-out files against, e.g. {\"maxFileSize\": 104857600, \"forbiddenPaths\": [\"*.pem\"], \"defaultSecretPatterns\": true, \"severities\": {\"case-collision\": \"error\"}}. Findings with the error severity fail the clone.",
+	},
+	"source-report-file": {
+		Name:         "source-report-file",
+		EnvVarName:   "KBC_GIT_CLONE_SOURCE_REPORT_FILE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to a file to write the findings of the source tree checks to, as JSON.",
 	},
 	"fetch-tags": {
 		Name:         "fetch-tags",
@@ -141,6 +162,13 @@ var ParamsConfig = map[string]common.Parameter{
 		DefaultValue: "git-clone@konflux-ci.dev",
 		Usage:        "Author email used for merge commits when --merge-target-branch is set.",
 	},
+	"commit-metadata-file": {
+		Name:         "commit-metadata-file",
+		EnvVarName:   "KBC_GIT_CLONE_COMMIT_METADATA_FILE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to a file to write all results, including author, committer, subject, tags, parents, branch and submodules of the commit, as JSON.",
+	},
 	"output-dir": {
 		Name:         "output-dir",
 		ShortName:    "o",
@@ -161,7 +189,21 @@ var ParamsConfig = map[string]common.Parameter{
 		EnvVarName:   "KBC_GIT_CLONE_BASIC_AUTH_DIRECTORY",
 		TypeKind:     reflect.String,
 		DefaultValue: "",
-		Usage:        "Path to directory containing basic auth credentials (.git-credentials and .gitconfig, or username and password files).",
+		Usage:        "Path to directory containing basic auth credentials (.git-credentials and .gitconfig, username and password files, a token file, or github-app-id and github-app-private-key files).",
+	},
+	"url-rewrite": {
+		Name:         "url-rewrite",
+		EnvVarName:   "KBC_GIT_CLONE_URL_REWRITE",
+		TypeKind:     reflect.Slice,
+		DefaultValue: "",
+		Usage:        "URL rewrite rule in the from=to format, e.g. https://github.com/=https://mirror.example.com/github/. Applies to the repository, the merge source repository and submodules via git's url.<base>.insteadOf. Can be specified multiple times; the longest matching prefix wins.",
+	},
+	"url-rewrite-file": {
+		Name:         "url-rewrite-file",
+		EnvVarName:   "KBC_GIT_CLONE_URL_REWRITE_FILE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to a file with URL rewrite rules, one from=to rule per line. Empty lines and lines starting with # are ignored. See url-rewrite.",
 	},
 	"ssh-directory": {
 		Name:         "ssh-directory",
@@ -173,27 +215,33 @@ var ParamsConfig = map[string]common.Parameter{
 }
 
 type Params struct {
-	URL                       string `paramName:"url"`
-	Revision                  string `paramName:"revision"`
-	Refspec                   string `paramName:"refspec"`
-	Submodules                bool   `paramName:"submodules"`
-	SubmodulePaths            string `paramName:"submodule-paths"`
-	Depth                     int    `paramName:"depth"`
-	ShortCommitLength         int    `paramName:"short-commit-length"`
-	SSLVerify                 bool   `paramName:"ssl-verify"`
-	Subdirectory              string `paramName:"subdirectory"`
-	SparseCheckoutDirectories string `paramName:"sparse-checkout-directories"`
-	DeleteExisting            bool   `paramName:"delete-existing"`
-	EnableSymlinkCheck        bool   `paramName:"enable-symlink-check"`
-	FetchTags                 bool   `paramName:"fetch-tags"`
-	MergeTargetBranch         bool   `paramName:"merge-target-branch"`
-	TargetBranch              string `paramName:"target-branch"`
-	MergeSourceRepoURL        string `paramName:"merge-source-repo-url"`
-	MergeSourceDepth          int    `paramName:"merge-source-depth"`
-	MergeCommitAuthorName     string `paramName:"merge-commit-author-name"`
-	MergeCommitAuthorEmail    string `paramName:"merge-commit-author-email"`
-	OutputDir                 string `paramName:"output-dir"`
-	RetryMaxAttempts          int    `paramName:"retry-max-attempts"`
-	BasicAuthDirectory        string `paramName:"basic-auth-directory"`
-	SSHDirectory              string `paramName:"ssh-directory"`
+	URL                       string   `paramName:"url"`
+	Bundle                    string   `paramName:"bundle"`
+	Revision                  string   `paramName:"revision"`
+	Refspec                   string   `paramName:"refspec"`
+	Submodules                bool     `paramName:"submodules"`
+	SubmodulePaths            string   `paramName:"submodule-paths"`
+	Depth                     int      `paramName:"depth"`
+	ShortCommitLength         int      `paramName:"short-commit-length"`
+	SSLVerify                 bool     `paramName:"ssl-verify"`
+	Subdirectory              string   `paramName:"subdirectory"`
+	SparseCheckoutDirectories string   `paramName:"sparse-checkout-directories"`
+	DeleteExisting            bool     `paramName:"delete-existing"`
+	EnableSymlinkCheck        bool     `paramName:"enable-symlink-check"`
+	SourcePolicyFile          string   `paramName:"source-policy-file"`
+	SourceReportFile          string   `paramName:"source-report-file"`
+	FetchTags                 bool     `paramName:"fetch-tags"`
+	MergeTargetBranch         bool     `paramName:"merge-target-branch"`
+	TargetBranch              string   `paramName:"target-branch"`
+	MergeSourceRepoURL        string   `paramName:"merge-source-repo-url"`
+	MergeSourceDepth          int      `paramName:"merge-source-depth"`
+	MergeCommitAuthorName     string   `paramName:"merge-commit-author-name"`
+	MergeCommitAuthorEmail    string   `paramName:"merge-commit-author-email"`
+	CommitMetadataFile        string   `paramName:"commit-metadata-file"`
+	OutputDir                 string   `paramName:"output-dir"`
+	RetryMaxAttempts          int      `paramName:"retry-max-attempts"`
+	BasicAuthDirectory        string   `paramName:"basic-auth-directory"`
+	SSHDirectory              string   `paramName:"ssh-directory"`
+	URLRewrite                []string `paramName:"url-rewrite"`
+	URLRewriteFile            string   `paramName:"url-rewrite-file"`
 }
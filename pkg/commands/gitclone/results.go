@@ -2,7 +2,9 @@ package gitclone
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
 	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
 )
 
@@ -15,8 +17,34 @@ type Results struct {
 	MergedSha       string `json:"mergedSha,omitempty"`
 	ChainsGitURL    string `json:"CHAINS-GIT_URL"`
 	ChainsGitCommit string `json:"CHAINS-GIT_COMMIT"`
+	// URLs after applying url rewrite rules, set only when rewrite rules are configured
+	EffectiveURL            string `json:"effectiveUrl,omitempty"`
+	EffectiveMergeSourceURL string `json:"effectiveMergeSourceUrl,omitempty"`
+
+	CommitMetadata
+}
+
+// CommitMetadata holds additional information about the checked out commit,
+// so that downstream tasks (labels, SBOM, provenance) don't need to run git.
+type CommitMetadata struct {
+	AuthorName     string `json:"authorName,omitempty"`
+	AuthorEmail    string `json:"authorEmail,omitempty"`
+	CommitterName  string `json:"committerName,omitempty"`
+	CommitterEmail string `json:"committerEmail,omitempty"`
+	Subject        string `json:"subject,omitempty"`
+	// Output of git describe --tags, empty if no tag is reachable
+	Describe string   `json:"describe,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Parents  []string `json:"parents,omitempty"`
+	// Branch the revision was resolved from, empty if the revision is not a branch
+	Branch     string                     `json:"branch,omitempty"`
+	Submodules []cliwrappers.GitSubmodule `json:"submodules,omitempty"`
 }
 
+// commitMetadataLogFormat separates the fields with NUL bytes,
+// which can't appear in names, emails or the subject.
+const commitMetadataLogFormat = "%an%x00%ae%x00%cn%x00%ce%x00%P%x00%s"
+
 func (c *GitClone) gatherCommitInfo() error {
 	// Get full SHA
 	sha, err := c.CliWrappers.GitCli.RevParse("HEAD", false, 0)
@@ -41,6 +69,13 @@ func (c *GitClone) gatherCommitInfo() error {
 
 	c.Results.URL = c.Params.URL
 
+	if len(c.urlRewrites) > 0 {
+		c.Results.EffectiveURL = rewriteURL(c.Params.URL, c.urlRewrites)
+		if c.Params.MergeTargetBranch && c.Params.MergeSourceRepoURL != "" {
+			c.Results.EffectiveMergeSourceURL = rewriteURL(c.Params.MergeSourceRepoURL, c.urlRewrites)
+		}
+	}
+
 	// CHAINS results are duplicates for Tekton Chains provenance
 	c.Results.ChainsGitURL = c.Params.URL
 	c.Results.ChainsGitCommit = sha
@@ -52,6 +87,81 @@ func (c *GitClone) gatherCommitInfo() error {
 	return nil
 }
 
+// gatherCommitMetadata collects CommitMetadata for HEAD.
+// Tags are only known locally if they were fetched, see the fetch-tags parameter.
+func (c *GitClone) gatherCommitMetadata() error {
+	metadata := CommitMetadata{}
+
+	logOutput, err := c.CliWrappers.GitCli.Log(commitMetadataLogFormat, 1)
+	if err != nil {
+		return fmt.Errorf("failed to get commit metadata: %w", err)
+	}
+	fields := strings.Split(logOutput, "\x00")
+	if len(fields) != 6 {
+		return fmt.Errorf("unexpected commit metadata format: %q", logOutput)
+	}
+	metadata.AuthorName = fields[0]
+	metadata.AuthorEmail = fields[1]
+	metadata.CommitterName = fields[2]
+	metadata.CommitterEmail = fields[3]
+	metadata.Parents = strings.Fields(fields[4])
+	metadata.Subject = fields[5]
+
+	// Fails when no tag is reachable, e.g. in shallow clones
+	describe, err := c.CliWrappers.GitCli.DescribeTags("HEAD")
+	if err != nil {
+		l.Logger.Debugf("No tag description available: %s", err)
+	} else {
+		metadata.Describe = describe
+	}
+
+	metadata.Tags, err = c.CliWrappers.GitCli.TagsPointingAt("HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to list tags pointing at HEAD: %w", err)
+	}
+
+	metadata.Branch = c.resolveBranch()
+
+	if c.Params.Submodules && len(c.bundles) == 0 {
+		metadata.Submodules, err = c.CliWrappers.GitCli.SubmoduleList()
+		if err != nil {
+			return fmt.Errorf("failed to list submodules: %w", err)
+		}
+		if len(c.urlRewrites) > 0 {
+			for i := range metadata.Submodules {
+				metadata.Submodules[i].EffectiveURL = rewriteURL(metadata.Submodules[i].URL, c.urlRewrites)
+			}
+		}
+	}
+
+	c.Results.CommitMetadata = metadata
+
+	l.Logger.Debugf("Author: %s <%s>", metadata.AuthorName, metadata.AuthorEmail)
+	l.Logger.Debugf("Subject: %s", metadata.Subject)
+	l.Logger.Debugf("Branch: %s", metadata.Branch)
+
+	return nil
+}
+
+// resolveBranch returns the branch name if the revision refers to a branch of origin.
+func (c *GitClone) resolveBranch() string {
+	revision := c.Params.Revision
+	if revision == "" {
+		return ""
+	}
+	if branch, found := strings.CutPrefix(revision, "refs/heads/"); found {
+		return branch
+	}
+	if strings.HasPrefix(revision, "refs/") {
+		return ""
+	}
+	// The remote-tracking ref is updated when a branch is fetched from origin
+	if _, err := c.CliWrappers.GitCli.RevParse("refs/remotes/origin/"+revision, false, 0); err != nil {
+		return ""
+	}
+	return revision
+}
+
 func (c *GitClone) outputResults() error {
 	resultJson, err := c.ResultsWriter.CreateResultJson(c.Results)
 	if err != nil {
@@ -60,3 +170,15 @@ func (c *GitClone) outputResults() error {
 	fmt.Println(resultJson)
 	return nil
 }
+
+// writeCommitMetadataFile writes all results as JSON into the commit-metadata-file, if requested.
+func (c *GitClone) writeCommitMetadataFile() error {
+	if c.Params.CommitMetadataFile == "" {
+		return nil
+	}
+	resultJson, err := c.ResultsWriter.CreateResultJson(c.Results)
+	if err != nil {
+		return fmt.Errorf("failed to create commit metadata json: %w", err)
+	}
+	return c.ResultsWriter.WriteResultString(resultJson, c.Params.CommitMetadataFile)
+}
@@ -2,12 +2,12 @@ package gitclone
 
 import (
 	"fmt"
-	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/gitauth"
 	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
 )
 
@@ -66,9 +66,10 @@ func (c *GitClone) setupGitConfig() {
 }
 
 // setupBasicAuth sets up git credentials from a basic-auth workspace.
-// Supports two formats:
+// Supports three formats:
 // 1. .git-credentials and .gitconfig files (copied directly)
 // 2. username and password files (kubernetes.io/basic-auth secret format)
+// 3. token file, or GitHub App id and private key files (sent via http.extraHeader, see [gitauth.LoadTokenAuth])
 func (c *GitClone) setupBasicAuth() error {
 	if c.Params.BasicAuthDirectory == "" {
 		return nil
@@ -93,14 +94,14 @@ func (c *GitClone) setupBasicAuth() error {
 	destConfig := filepath.Join(c.internalDir, ".gitconfig")
 
 	// Format 1: .git-credentials and .gitconfig files
-	if fileExists(gitCredentialsPath) && fileExists(gitConfigPath) {
+	if gitauth.FileExists(gitCredentialsPath) && gitauth.FileExists(gitConfigPath) {
 		l.Logger.Debug("Setting up basic auth from .git-credentials and .gitconfig")
 
 		if err := copyFile(gitCredentialsPath, destCredentials, 0400); err != nil {
 			return fmt.Errorf("failed to copy .git-credentials: %w", err)
 		}
 
-		configContent, err := readFileWithLimit(gitConfigPath, maxAuthFileSize)
+		configContent, err := gitauth.ReadFileWithLimit(gitConfigPath, gitauth.MaxAuthFileSize)
 		if err != nil {
 			return fmt.Errorf("failed to read .gitconfig: %w", err)
 		}
@@ -116,15 +117,15 @@ func (c *GitClone) setupBasicAuth() error {
 	}
 
 	// Format 2: kubernetes.io/basic-auth secret (username and password files)
-	if fileExists(usernamePath) && fileExists(passwordPath) {
+	if gitauth.FileExists(usernamePath) && gitauth.FileExists(passwordPath) {
 		l.Logger.Debug("Setting up basic auth from username/password files")
 
-		username, err := readFileWithLimit(usernamePath, maxAuthFileSize)
+		username, err := gitauth.ReadFileWithLimit(usernamePath, gitauth.MaxAuthFileSize)
 		if err != nil {
 			return fmt.Errorf("failed to read username file: %w", err)
 		}
 
-		password, err := readFileWithLimit(passwordPath, maxAuthFileSize)
+		password, err := gitauth.ReadFileWithLimit(passwordPath, gitauth.MaxAuthFileSize)
 		if err != nil {
 			return fmt.Errorf("failed to read password file: %w", err)
 		}
@@ -159,7 +160,25 @@ func (c *GitClone) setupBasicAuth() error {
 		return nil
 	}
 
-	return fmt.Errorf("unknown basic-auth workspace format: expected .git-credentials/.gitconfig or username/password files")
+	// Format 3: bearer token or GitHub App credentials
+	if gitauth.HasTokenAuth(authDir) {
+		l.Logger.Debug("Setting up token auth")
+
+		tokenAuth, err := gitauth.LoadTokenAuth(authDir, c.Params.URL)
+		if err != nil {
+			return fmt.Errorf("failed to set up token auth: %w", err)
+		}
+		if err := tokenAuth.WriteGitConfig(destConfig, 0400); err != nil {
+			return fmt.Errorf("failed to write .gitconfig: %w", err)
+		}
+
+		c.CliWrappers.GitCli.SetEnv(envGitConfigGlobal, destConfig)
+
+		l.Logger.Debugf("Token auth (%s) configured for %s", tokenAuth.Source, tokenAuth.BaseURL)
+		return nil
+	}
+
+	return fmt.Errorf("unknown basic-auth workspace format: expected .git-credentials/.gitconfig, username/password, token or github-app-id/github-app-private-key files")
 }
 
 // rewriteGitConfigCredentialHelper rewrites "helper = store" lines in a git config
@@ -223,21 +242,22 @@ func (c *GitClone) setupSSH() error {
 	sshCmd := "ssh"
 
 	configPath := filepath.Join(destSSHDir, "config")
-	if fileExists(configPath) {
+	if gitauth.FileExists(configPath) {
 		sshCmd += fmt.Sprintf(` -F "%s"`, configPath)
 	} else {
 		sshCmd += " -F /dev/null"
 	}
 
-	for _, entry := range entries {
-		name := entry.Name()
-		if strings.HasPrefix(name, "id_") && !strings.HasSuffix(name, ".pub") && !entry.IsDir() {
-			sshCmd += fmt.Sprintf(` -i "%s"`, filepath.Join(destSSHDir, name))
-		}
+	keys, err := gitauth.FindSSHKeys(sshDir)
+	if err != nil {
+		return fmt.Errorf("failed to read SSH directory: %w", err)
+	}
+	for _, name := range keys {
+		sshCmd += fmt.Sprintf(` -i "%s"`, filepath.Join(destSSHDir, name))
 	}
 
 	knownHostsPath := filepath.Join(destSSHDir, "known_hosts")
-	if fileExists(knownHostsPath) {
+	if gitauth.FileExists(knownHostsPath) {
 		sshCmd += fmt.Sprintf(` -o UserKnownHostsFile="%s"`, knownHostsPath)
 	}
 
@@ -247,59 +267,13 @@ func (c *GitClone) setupSSH() error {
 	return nil
 }
 
-// fileExists checks if a file exists and is not a directory.
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return err == nil && !info.IsDir()
-}
-
-// maxAuthFileSize is the maximum allowed size for auth-related files
-// (.gitconfig, .git-credentials, SSH keys, username, password).
-const maxAuthFileSize = 1 << 20 // 1MB
-
-// readFileWithLimit reads a file, rejecting files larger than maxSize.
-// Uses file-descriptor-based stat and limited read to avoid TOCTOU races.
-func readFileWithLimit(path string, maxSize int64) (data []byte, err error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if cerr := f.Close(); cerr != nil && err == nil {
-			err = cerr
-		}
-	}()
-
-	info, err := f.Stat()
-	if err != nil {
-		return nil, err
-	}
-	if info.Size() > maxSize {
-		return nil, fmt.Errorf("authentication file exceeds maximum allowed size (%d bytes)", maxSize)
-	}
-
-	// Use LimitReader to enforce the size cap during read, even if the file
-	// was extended between Stat and Read (belt-and-suspenders).
-	data, err = io.ReadAll(io.LimitReader(f, maxSize+1))
-	if err != nil {
-		return nil, err
-	}
-	if int64(len(data)) > maxSize {
-		return nil, fmt.Errorf("authentication file exceeds maximum allowed size (%d bytes)", maxSize)
-	}
-	return data, nil
-}
-
 // copyFile copies a file from src to dest with the specified permissions.
 func copyFile(src, dest string, perm os.FileMode) error {
 	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
 		return err
 	}
 
-	data, err := readFileWithLimit(src, maxAuthFileSize)
+	data, err := gitauth.ReadFileWithLimit(src, gitauth.MaxAuthFileSize)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,52 @@
+package gitclone
+
+import (
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+)
+
+// checkSourceTree runs the source tree checks on the checkout. Without a policy, only the
+// symlink-escape rule runs, and only if the symlink check is enabled, as the check used to be
+// limited to symlinks. The other built-in rules need a policy. The .git directory is not scanned.
+func (c *GitClone) checkSourceTree() error {
+	if !c.Params.EnableSymlinkCheck && c.Params.SourcePolicyFile == "" && c.Params.SourceReportFile == "" {
+		return nil
+	}
+
+	policy := &common.SourceTreePolicy{
+		Severities: map[string]common.Severity{
+			common.RuleDeviceFile:    common.SeverityOff,
+			common.RuleSetuid:        common.SeverityOff,
+			common.RuleCaseCollision: common.SeverityOff,
+		},
+	}
+	if c.Params.SourcePolicyFile != "" {
+		var err error
+		if policy, err = common.LoadSourceTreePolicy(c.Params.SourcePolicyFile); err != nil {
+			return err
+		}
+	}
+	if !c.Params.EnableSymlinkCheck {
+		if policy.Severities == nil {
+			policy.Severities = make(map[string]common.Severity)
+		}
+		policy.Severities[common.RuleSymlinkEscape] = common.SeverityOff
+	}
+
+	scanner, err := common.NewSourceTreeScanner(policy)
+	if err != nil {
+		return err
+	}
+	scanner.SkipDirs = []string{".git"}
+
+	report, err := scanner.Scan(c.getCheckoutDir())
+	if err != nil {
+		return err
+	}
+
+	if c.Params.SourceReportFile != "" {
+		if err := report.WriteFile(c.Params.SourceReportFile); err != nil {
+			return err
+		}
+	}
+	return report.Err()
+}
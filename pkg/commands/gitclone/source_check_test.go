@@ -0,0 +1,128 @@
+package gitclone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_GitClone_checkSourceTree(t *testing.T) {
+	// checkout
+	// ├── .git/big      (skipped)
+	// ├── big
+	// └── outside -> /etc/passwd
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		checkoutDir := filepath.Join(dir, "checkout")
+		if err := os.MkdirAll(filepath.Join(checkoutDir, ".git"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{".git/big", "big"} {
+			if err := os.WriteFile(filepath.Join(checkoutDir, name), []byte("1234567890"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink("/etc/passwd", filepath.Join(checkoutDir, "outside")); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	writePolicy := func(t *testing.T, dir, policy string) string {
+		policyPath := filepath.Join(dir, "policy.json")
+		if err := os.WriteFile(policyPath, []byte(policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return policyPath
+	}
+
+	t.Run("should fail on symlinks pointing outside by default", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+		c := &GitClone{Params: &Params{OutputDir: dir, Subdirectory: "checkout", EnableSymlinkCheck: true}}
+
+		g.Expect(c.checkSourceTree()).To(MatchError("found 1 source tree policy violation(s): symlink-escape"))
+	})
+
+	t.Run("should only check symlinks without a policy", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+		checkoutDir := filepath.Join(dir, "checkout")
+		g.Expect(os.Remove(filepath.Join(checkoutDir, "outside"))).To(Succeed())
+		// Would be a case-collision and a setuid warning with the other rules enabled
+		g.Expect(os.WriteFile(filepath.Join(checkoutDir, "BIG"), []byte("x"), 0644)).To(Succeed())
+		g.Expect(os.Chmod(filepath.Join(checkoutDir, "BIG"), 0755|os.ModeSetuid)).To(Succeed())
+		reportPath := filepath.Join(dir, "report.json")
+		c := &GitClone{Params: &Params{
+			OutputDir: dir, Subdirectory: "checkout", EnableSymlinkCheck: true, SourceReportFile: reportPath,
+		}}
+
+		g.Expect(c.checkSourceTree()).To(Succeed())
+
+		report, err := os.ReadFile(reportPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(report)).To(ContainSubstring(`"findings": []`))
+	})
+
+	t.Run("should skip checks when symlink check is disabled and no policy is set", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+		c := &GitClone{Params: &Params{OutputDir: dir, Subdirectory: "checkout"}}
+
+		g.Expect(c.checkSourceTree()).To(Succeed())
+	})
+
+	t.Run("should apply policy without the symlink rule when symlink check is disabled", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+		reportPath := filepath.Join(dir, "report.json")
+		c := &GitClone{Params: &Params{
+			OutputDir:        dir,
+			Subdirectory:     "checkout",
+			SourcePolicyFile: writePolicy(t, dir, `{"maxFileSize": 5}`),
+			SourceReportFile: reportPath,
+		}}
+
+		g.Expect(c.checkSourceTree()).To(MatchError("found 1 source tree policy violation(s): file-size"))
+
+		report, err := os.ReadFile(reportPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(report)).To(ContainSubstring(`"path": "big"`))
+		g.Expect(string(report)).ToNot(ContainSubstring(".git"))
+		g.Expect(string(report)).ToNot(ContainSubstring("outside"))
+	})
+
+	t.Run("should write report and succeed when findings are warnings", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+		reportPath := filepath.Join(dir, "report.json")
+		c := &GitClone{Params: &Params{
+			OutputDir:          dir,
+			Subdirectory:       "checkout",
+			EnableSymlinkCheck: true,
+			SourcePolicyFile:   writePolicy(t, dir, `{"severities": {"symlink-escape": "warning"}}`),
+			SourceReportFile:   reportPath,
+		}}
+
+		g.Expect(c.checkSourceTree()).To(Succeed())
+
+		report, err := os.ReadFile(reportPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(report)).To(ContainSubstring(`"severity": "warning"`))
+		g.Expect(string(report)).To(ContainSubstring(`"warnings": 1`))
+	})
+
+	t.Run("should fail on invalid policy", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := setup(t)
+		c := &GitClone{Params: &Params{
+			OutputDir:        dir,
+			Subdirectory:     "checkout",
+			SourcePolicyFile: writePolicy(t, dir, `{"severities": {"foo": "error"}}`),
+		}}
+
+		g.Expect(c.checkSourceTree()).To(MatchError(ContainSubstring(`unknown source tree rule "foo"`)))
+	})
+}
@@ -0,0 +1,140 @@
+package gitclone
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/gitauth"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// urlRewrite replaces the From prefix of git URLs with To, see git config url.<base>.insteadOf.
+type urlRewrite struct {
+	From string
+	To   string
+}
+
+// parseURLRewrite parses a rule in the from=to format.
+func parseURLRewrite(rule string) (urlRewrite, error) {
+	from, to, found := strings.Cut(strings.TrimSpace(rule), "=")
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+	if !found || from == "" || to == "" {
+		return urlRewrite{}, fmt.Errorf("invalid url rewrite rule for %q, expected from=to", sanitizeURL(from))
+	}
+	return urlRewrite{From: from, To: to}, nil
+}
+
+// parseURLRewriteFile reads url rewrite rules from a file with one from=to rule per line.
+// Empty lines and lines starting with # are ignored.
+func parseURLRewriteFile(path string) ([]urlRewrite, error) {
+	content, err := gitauth.ReadFileWithLimit(path, gitauth.MaxAuthFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read url rewrite file: %w", err)
+	}
+
+	var rewrites []urlRewrite
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rewrite, err := parseURLRewrite(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineno, err)
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read url rewrite file: %w", err)
+	}
+	return rewrites, nil
+}
+
+// rewriteURL applies the rewrite rules to a URL the same way git does:
+// the rule with the longest matching From prefix wins.
+func rewriteURL(rawURL string, rewrites []urlRewrite) string {
+	var best *urlRewrite
+	for i := range rewrites {
+		rewrite := &rewrites[i]
+		if strings.HasPrefix(rawURL, rewrite.From) && (best == nil || len(rewrite.From) > len(best.From)) {
+			best = rewrite
+		}
+	}
+	if best == nil {
+		return rawURL
+	}
+	return best.To + strings.TrimPrefix(rawURL, best.From)
+}
+
+// setupURLRewrites adds url.<to>.insteadOf rules to the internal global git config.
+// Because the rules are global, they apply to origin, the merge source remote and submodules alike.
+func (c *GitClone) setupURLRewrites() error {
+	var rewrites []urlRewrite
+	if c.Params.URLRewriteFile != "" {
+		fileRewrites, err := parseURLRewriteFile(c.Params.URLRewriteFile)
+		if err != nil {
+			return err
+		}
+		rewrites = append(rewrites, fileRewrites...)
+	}
+	for _, rule := range c.Params.URLRewrite {
+		rewrite, err := parseURLRewrite(rule)
+		if err != nil {
+			return err
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	if len(rewrites) == 0 {
+		return nil
+	}
+
+	var config strings.Builder
+	for _, rewrite := range rewrites {
+		l.Logger.Debugf("Rewriting URLs starting with %s to %s", sanitizeURL(rewrite.From), sanitizeURL(rewrite.To))
+		fmt.Fprintf(&config, "[url %s]\n  insteadOf = %s\n", gitauth.QuoteGitConfigValue(rewrite.To), gitauth.QuoteGitConfigValue(rewrite.From))
+	}
+	if err := c.appendGlobalGitConfig(config.String()); err != nil {
+		return fmt.Errorf("failed to configure url rewrites: %w", err)
+	}
+
+	c.urlRewrites = rewrites
+	return nil
+}
+
+// appendGlobalGitConfig appends content to the internal .gitconfig, creating it and
+// pointing GIT_CONFIG_GLOBAL to it if it doesn't exist yet (see setupBasicAuth).
+func (c *GitClone) appendGlobalGitConfig(content string) error {
+	destConfig := filepath.Join(c.internalDir, ".gitconfig")
+
+	existing, err := os.ReadFile(destConfig)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		existing = append(existing, '\n')
+	}
+
+	// The file is read-only, replace it instead of opening it for writing
+	if exists {
+		if err := os.Remove(destConfig); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(destConfig, append(existing, content...), 0400); err != nil {
+		return err
+	}
+
+	if !exists {
+		c.CliWrappers.GitCli.SetEnv(envGitConfigGlobal, destConfig)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package gitclone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_parseURLRewrite(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name        string
+		rule        string
+		expected    urlRewrite
+		errContains string
+	}{
+		{
+			name:     "should parse rule",
+			rule:     "https://github.com/=https://mirror.test/github/",
+			expected: urlRewrite{From: "https://github.com/", To: "https://mirror.test/github/"},
+		},
+		{
+			name:     "should trim whitespace",
+			rule:     "  https://github.com/ = https://mirror.test/  ",
+			expected: urlRewrite{From: "https://github.com/", To: "https://mirror.test/"},
+		},
+		{
+			name:     "should split on first equal sign",
+			rule:     "https://github.com/=https://mirror.test/?a=b",
+			expected: urlRewrite{From: "https://github.com/", To: "https://mirror.test/?a=b"},
+		},
+		{
+			name:        "should fail without separator",
+			rule:        "https://github.com/",
+			errContains: "expected from=to",
+		},
+		{
+			name:        "should fail with empty target",
+			rule:        "https://github.com/=",
+			errContains: "expected from=to",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rewrite, err := parseURLRewrite(tc.rule)
+
+			if tc.errContains != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.errContains))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(rewrite).To(Equal(tc.expected))
+			}
+		})
+	}
+}
+
+func Test_parseURLRewriteFile(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should parse rules and skip comments", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rewrites")
+		content := "# mirrors\n\nhttps://github.com/=https://mirror.test/github/\nhttps://gitlab.com/=https://mirror.test/gitlab/\n"
+		g.Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+
+		rewrites, err := parseURLRewriteFile(path)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(rewrites).To(Equal([]urlRewrite{
+			{From: "https://github.com/", To: "https://mirror.test/github/"},
+			{From: "https://gitlab.com/", To: "https://mirror.test/gitlab/"},
+		}))
+	})
+
+	t.Run("should report line of invalid rule", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rewrites")
+		g.Expect(os.WriteFile(path, []byte("# comment\ninvalid\n"), 0644)).To(Succeed())
+
+		_, err := parseURLRewriteFile(path)
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring(path + ":2:"))
+	})
+}
+
+func Test_rewriteURL(t *testing.T) {
+	g := NewWithT(t)
+
+	rewrites := []urlRewrite{
+		{From: "https://github.com/", To: "https://mirror.test/github/"},
+		{From: "https://github.com/org/special", To: "https://special.test/special"},
+	}
+
+	g.Expect(rewriteURL("https://github.com/user/repo.git", rewrites)).To(Equal("https://mirror.test/github/user/repo.git"))
+	g.Expect(rewriteURL("https://github.com/org/special.git", rewrites)).To(Equal("https://special.test/special.git"))
+	g.Expect(rewriteURL("https://gitlab.com/user/repo.git", rewrites)).To(Equal("https://gitlab.com/user/repo.git"))
+	g.Expect(rewriteURL("https://gitlab.com/user/repo.git", nil)).To(Equal("https://gitlab.com/user/repo.git"))
+}
+
+func Test_GitClone_setupURLRewrites(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should skip when no rules configured", func(t *testing.T) {
+		internalDir := t.TempDir()
+		c := &GitClone{
+			CliWrappers: CliWrappers{GitCli: &mockGitCli{}},
+			Params:      &Params{},
+			internalDir: internalDir,
+		}
+
+		g.Expect(c.setupURLRewrites()).To(Succeed())
+		g.Expect(filepath.Join(internalDir, ".gitconfig")).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should create global git config with insteadOf rules", func(t *testing.T) {
+		internalDir := t.TempDir()
+		rulesFile := filepath.Join(t.TempDir(), "rewrites")
+		g.Expect(os.WriteFile(rulesFile, []byte("https://gitlab.com/=https://mirror.test/gitlab/\n"), 0644)).To(Succeed())
+
+		envVars := map[string]string{}
+		c := &GitClone{
+			CliWrappers: CliWrappers{GitCli: &mockGitCli{
+				SetEnvFunc: func(key, value string) { envVars[key] = value },
+			}},
+			Params: &Params{
+				URLRewrite:     []string{"https://github.com/=https://mirror.test/github/"},
+				URLRewriteFile: rulesFile,
+			},
+			internalDir: internalDir,
+		}
+
+		err := c.setupURLRewrites()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		config, err := os.ReadFile(filepath.Join(internalDir, ".gitconfig"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(config)).To(Equal(
+			"[url \"https://mirror.test/gitlab/\"]\n  insteadOf = \"https://gitlab.com/\"\n" +
+				"[url \"https://mirror.test/github/\"]\n  insteadOf = \"https://github.com/\"\n"))
+		g.Expect(envVars[envGitConfigGlobal]).To(Equal(filepath.Join(internalDir, ".gitconfig")))
+		g.Expect(c.urlRewrites).To(HaveLen(2))
+	})
+
+	t.Run("should append to git config created by basic auth", func(t *testing.T) {
+		internalDir := t.TempDir()
+		configPath := filepath.Join(internalDir, ".gitconfig")
+		g.Expect(os.WriteFile(configPath, []byte("[credential]\n  helper = store"), 0400)).To(Succeed())
+
+		isSetEnvCalled := false
+		c := &GitClone{
+			CliWrappers: CliWrappers{GitCli: &mockGitCli{
+				SetEnvFunc: func(key, value string) { isSetEnvCalled = true },
+			}},
+			Params: &Params{
+				URLRewrite: []string{"https://github.com/=https://mirror.test/github/"},
+			},
+			internalDir: internalDir,
+		}
+
+		err := c.setupURLRewrites()
+
+		g.Expect(err).ToNot(HaveOccurred())
+		config, err := os.ReadFile(configPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(config)).To(Equal("[credential]\n  helper = store\n[url \"https://mirror.test/github/\"]\n  insteadOf = \"https://github.com/\"\n"))
+		g.Expect(isSetEnvCalled).To(BeFalse())
+	})
+
+	t.Run("should fail on invalid rule", func(t *testing.T) {
+		c := &GitClone{
+			Params:      &Params{URLRewrite: []string{"no-separator"}},
+			internalDir: t.TempDir(),
+		}
+
+		err := c.setupURLRewrites()
+
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,539 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/containerd/platforms"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var ImageDiffParamsConfig = map[string]common.Parameter{
+	"platform": {
+		Name:       "platform",
+		ShortName:  "",
+		EnvVarName: "KBC_IMAGE_DIFF_PLATFORM",
+		TypeKind:   reflect.String,
+		Usage:      "Platform (os/arch[/variant]) to compare for multi-platform images. Defaults to the platform of the system.",
+	},
+}
+
+type ImageDiffParams struct {
+	// The compared images, given as positional arguments
+	ImageA string
+	ImageB string
+
+	Platform string `paramName:"platform"`
+}
+
+type ImageDiffCliWrappers struct {
+	SkopeoCli cliWrappers.SkopeoCliInterface
+	// Nil if the rpm CLI is not available, package changes are not reported then
+	RpmCli cliWrappers.RpmCliInterface
+}
+
+type ImageDiffResults struct {
+	Images        [2]string      `json:"images"`
+	ConfigChanges []ConfigChange `json:"config_changes"`
+	Layers        LayerChanges   `json:"layers"`
+	FileChanges   []FileChange   `json:"file_changes"`
+	// Only reported if at least one of the images has an rpmdb
+	PackageChanges []PackageChange `json:"package_changes,omitempty"`
+}
+
+// A change in the image config. Before or after is omitted if the value is not set in that image.
+type ConfigChange struct {
+	// e.g. "user", "entrypoint", "env.PATH", "labels.version"
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// Layers are identified by their uncompressed digest (the diff ID), so recompressing
+// a layer doesn't make it a different layer.
+type LayerChanges struct {
+	Added     []ImageLayer `json:"added"`
+	Removed   []ImageLayer `json:"removed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+type ImageLayer struct {
+	DiffID string `json:"diff_id"`
+	Size   int64  `json:"size"`
+}
+
+// A change in the image filesystem (all layers applied). Modification times are ignored,
+// they change with every rebuild.
+type FileChange struct {
+	Path string `json:"path"`
+	// added, removed or modified
+	Change string `json:"change"`
+	// For modified files: the attributes that differ (type, mode, owner, size, content, linkname)
+	Differences []string   `json:"differences,omitempty"`
+	Before      *LayerFile `json:"before,omitempty"`
+	After       *LayerFile `json:"after,omitempty"`
+}
+
+type PackageChange struct {
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+	// added, removed or changed
+	Change string `json:"change"`
+	// epoch:version-release
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Directories of the rpmdb, in order of preference (/var/lib/rpm is a symlink in newer distros).
+var rpmdbDirs = []string{"/usr/lib/sysimage/rpm", "/var/lib/rpm"}
+
+// ImageDiff compares two images and reports the config, layer, file and package changes.
+type ImageDiff struct {
+	Params        *ImageDiffParams
+	CliWrappers   ImageDiffCliWrappers
+	Results       ImageDiffResults
+	ResultsWriter common.ResultsWriterInterface
+}
+
+func NewImageDiff(cmd *cobra.Command, args []string) (*ImageDiff, error) {
+	params := &ImageDiffParams{}
+	if err := common.ParseParameters(cmd, ImageDiffParamsConfig, params); err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected 2 images to compare, got %d", len(args))
+	}
+	params.ImageA, params.ImageB = args[0], args[1]
+
+	imageDiff := &ImageDiff{
+		Params:        params,
+		ResultsWriter: common.NewResultsWriter(),
+	}
+	if err := imageDiff.initCliWrappers(); err != nil {
+		return nil, err
+	}
+	return imageDiff, nil
+}
+
+func (c *ImageDiff) initCliWrappers() error {
+	executor := cliWrappers.NewCliExecutor()
+
+	skopeoCli, err := cliWrappers.NewSkopeoCli(executor)
+	if err != nil {
+		return err
+	}
+	c.CliWrappers.SkopeoCli = skopeoCli
+
+	rpmCli, err := cliWrappers.NewRpmCli(executor)
+	if err != nil {
+		l.Logger.Warnf("Package changes will not be reported: %s", err)
+	} else {
+		c.CliWrappers.RpmCli = rpmCli
+	}
+	return nil
+}
+
+// Run executes the command logic.
+func (c *ImageDiff) Run() error {
+	common.LogParameters(ImageDiffParamsConfig, c.Params)
+	l.Logger.Infof("Comparing %s and %s", c.Params.ImageA, c.Params.ImageB)
+
+	if err := c.validateParams(); err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "kbc-image-diff-")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			l.Logger.Warnf("Failed to clean up temporary directory %s: %s", tempDir, err)
+		}
+	}()
+
+	var images [2]*diffedImage
+	for i, imageRef := range []string{c.Params.ImageA, c.Params.ImageB} {
+		image, err := c.fetchImage(imageRef, filepath.Join(tempDir, fmt.Sprintf("image-%d", i)))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", imageRef, err)
+		}
+		images[i] = image
+	}
+
+	c.Results.Images = [2]string{c.Params.ImageA, c.Params.ImageB}
+	c.Results.ConfigChanges = diffImageConfigs(&images[0].imageConfig.Config, &images[1].imageConfig.Config)
+	c.Results.Layers = diffImageLayers(images[0], images[1])
+	c.Results.FileChanges = diffFilesystems(images[0].files, images[1].files)
+	c.Results.PackageChanges, err = c.diffPackages(images)
+	if err != nil {
+		return err
+	}
+
+	l.Logger.Infof("Found %d config changes, %d added and %d removed layers, %d file changes, %d package changes",
+		len(c.Results.ConfigChanges), len(c.Results.Layers.Added), len(c.Results.Layers.Removed),
+		len(c.Results.FileChanges), len(c.Results.PackageChanges))
+
+	if resultJson, err := c.ResultsWriter.CreateResultJson(c.Results); err == nil {
+		fmt.Print(resultJson)
+	} else {
+		l.Logger.Errorf("failed to create results json: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *ImageDiff) validateParams() error {
+	for _, imageRef := range []string{c.Params.ImageA, c.Params.ImageB} {
+		if imageRef == "" {
+			return fmt.Errorf("image reference is empty")
+		}
+		if common.IsOCILayoutRef(imageRef) {
+			if _, err := common.ParseOCILayoutRef(imageRef); err != nil {
+				return fmt.Errorf("invalid image reference: %w", err)
+			}
+		}
+	}
+	if c.Params.Platform != "" {
+		if _, err := platforms.Parse(c.Params.Platform); err != nil {
+			return fmt.Errorf("invalid platform '%s': %w", c.Params.Platform, err)
+		}
+	}
+	return nil
+}
+
+// An image prepared for comparison: the filesystem with all layers applied.
+type diffedImage struct {
+	*layoutImage
+	imageConfig ociv1.Image
+	files       map[string]*LayerFile
+	// Path of the extracted rpmdb, empty if the image doesn't have one
+	rpmdbPath string
+}
+
+// Copy the image to an OCI layout in workDir and read its config and filesystem.
+func (c *ImageDiff) fetchImage(imageRef, workDir string) (*diffedImage, error) {
+	sourceRef := imageRef
+	if common.IsOCILayoutRef(imageRef) {
+		layoutRef, err := common.ParseOCILayoutRef(imageRef)
+		if err != nil {
+			return nil, err
+		}
+		if sourceRef, err = layoutRef.SourceRef(); err != nil {
+			return nil, err
+		}
+	}
+
+	var extraArgs []string
+	if c.Params.Platform != "" {
+		platform, err := platforms.Parse(c.Params.Platform)
+		if err != nil {
+			return nil, err
+		}
+		extraArgs = append(extraArgs, "--override-os", platform.OS, "--override-arch", platform.Architecture)
+		if platform.Variant != "" {
+			extraArgs = append(extraArgs, "--override-variant", platform.Variant)
+		}
+	}
+
+	layoutDir := filepath.Join(workDir, "layout")
+	err := c.CliWrappers.SkopeoCli.Copy(&cliWrappers.SkopeoCopyArgs{
+		SourceImage:      sourceRef,
+		DestinationImage: common.OCILayoutTransport + layoutDir,
+		RetryTimes:       3,
+		ExtraArgs:        extraArgs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := readLayoutImage(layoutDir)
+	if err != nil {
+		return nil, err
+	}
+	image := &diffedImage{layoutImage: layout}
+	if err := layout.readJSONBlob(layout.manifest.Config.Digest, &image.imageConfig); err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	files, rpmdbFiles, err := image.flatten()
+	if err != nil {
+		return nil, err
+	}
+	image.files = files
+	if image.rpmdbPath, err = extractRpmdb(rpmdbFiles, filepath.Join(workDir, "rootfs")); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// Apply the layers of the image on top of each other, handling whiteouts. Returns the files
+// of the resulting filesystem and the content of the rpmdb files.
+func (i *diffedImage) flatten() (map[string]*LayerFile, map[string][]byte, error) {
+	files := make(map[string]*LayerFile)
+	rpmdbFiles := make(map[string][]byte)
+
+	remove := func(target string) {
+		for p := range files {
+			if p == target || strings.HasPrefix(p, target+"/") {
+				delete(files, p)
+				delete(rpmdbFiles, p)
+			}
+		}
+	}
+
+	for index, layer := range i.manifest.Layers {
+		entries, err := readLayer(i.blobPath(layer.Digest), layer.MediaType, isRpmdbFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("layer %d: %w", index, err)
+		}
+
+		// Whiteouts only apply to the lower layers, process them first
+		for _, entry := range entries {
+			dir, base := path.Split(entry.path)
+			if base == ".wh..wh..opq" {
+				for p := range files {
+					if strings.HasPrefix(p, dir) {
+						delete(files, p)
+						delete(rpmdbFiles, p)
+					}
+				}
+			} else if whiteout, ok := strings.CutPrefix(base, ".wh."); ok {
+				remove(path.Join(dir, whiteout))
+			}
+		}
+
+		for _, entry := range entries {
+			if strings.HasPrefix(path.Base(entry.path), ".wh.") {
+				continue
+			}
+			files[entry.path] = entry.file
+			delete(rpmdbFiles, entry.path)
+			if entry.content != nil {
+				rpmdbFiles[entry.path] = entry.content
+			}
+		}
+	}
+	return files, rpmdbFiles, nil
+}
+
+func isRpmdbFile(p string) bool {
+	for _, dir := range rpmdbDirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the rpmdb files under rootDir. Returns the path of the rpmdb directory,
+// or an empty string if there are no rpmdb files.
+func extractRpmdb(rpmdbFiles map[string][]byte, rootDir string) (string, error) {
+	for _, dir := range rpmdbDirs {
+		found := false
+		for p, content := range rpmdbFiles {
+			if !strings.HasPrefix(p, dir+"/") {
+				continue
+			}
+			found = true
+			target := filepath.Join(rootDir, p)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return "", fmt.Errorf("extracting rpmdb: %w", err)
+			}
+			if err := os.WriteFile(target, content, 0644); err != nil {
+				return "", fmt.Errorf("extracting rpmdb: %w", err)
+			}
+		}
+		if found {
+			return filepath.Join(rootDir, dir), nil
+		}
+	}
+	return "", nil
+}
+
+func diffImageConfigs(a, b *ociv1.ImageConfig) []ConfigChange {
+	var changes []ConfigChange
+	add := func(field string, before, after any) {
+		before, after = emptyToNil(before), emptyToNil(after)
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, ConfigChange{Field: field, Before: before, After: after})
+		}
+	}
+
+	add("user", a.User, b.User)
+	add("working_dir", a.WorkingDir, b.WorkingDir)
+	add("entrypoint", a.Entrypoint, b.Entrypoint)
+	add("cmd", a.Cmd, b.Cmd)
+	add("stop_signal", a.StopSignal, b.StopSignal)
+	add("exposed_ports", sortedKeys(a.ExposedPorts), sortedKeys(b.ExposedPorts))
+	add("volumes", sortedKeys(a.Volumes), sortedKeys(b.Volumes))
+
+	envA, envB := envToMap(a.Env), envToMap(b.Env)
+	for _, name := range sortedKeys(mergeKeys(envA, envB)) {
+		add("env."+name, envA[name], envB[name])
+	}
+	for _, name := range sortedKeys(mergeKeys(a.Labels, b.Labels)) {
+		add("labels."+name, a.Labels[name], b.Labels[name])
+	}
+	return changes
+}
+
+func emptyToNil(value any) any {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0) {
+		return nil
+	}
+	return value
+}
+
+func envToMap(env []string) map[string]string {
+	envMap := make(map[string]string, len(env))
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		envMap[name] = value
+	}
+	return envMap
+}
+
+func mergeKeys(a, b map[string]string) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func diffImageLayers(a, b *diffedImage) LayerChanges {
+	layersA, layersB := a.imageLayers(), b.imageLayers()
+	changes := LayerChanges{Added: []ImageLayer{}, Removed: []ImageLayer{}}
+
+	inB := make(map[string]bool, len(layersB))
+	for _, layer := range layersB {
+		inB[layer.DiffID] = true
+	}
+	inA := make(map[string]bool, len(layersA))
+	for _, layer := range layersA {
+		inA[layer.DiffID] = true
+		if inB[layer.DiffID] {
+			changes.Unchanged++
+		} else {
+			changes.Removed = append(changes.Removed, layer)
+		}
+	}
+	for _, layer := range layersB {
+		if !inA[layer.DiffID] {
+			changes.Added = append(changes.Added, layer)
+		}
+	}
+	return changes
+}
+
+func (i *diffedImage) imageLayers() []ImageLayer {
+	var layers []ImageLayer
+	for index, diffID := range i.imageConfig.RootFS.DiffIDs {
+		layer := ImageLayer{DiffID: diffID.String()}
+		if index < len(i.manifest.Layers) {
+			layer.Size = i.manifest.Layers[index].Size
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+func diffFilesystems(a, b map[string]*LayerFile) []FileChange {
+	paths := make(map[string]struct{}, len(a))
+	for p := range a {
+		paths[p] = struct{}{}
+	}
+	for p := range b {
+		paths[p] = struct{}{}
+	}
+
+	changes := []FileChange{}
+	for _, p := range sortedKeys(paths) {
+		before, after := a[p], b[p]
+		switch {
+		case before == nil:
+			changes = append(changes, FileChange{Path: p, Change: "added", After: after})
+		case after == nil:
+			changes = append(changes, FileChange{Path: p, Change: "removed", Before: before})
+		default:
+			differences := slices.DeleteFunc(diffLayerFiles(before, after), func(d string) bool { return d == "mtime" })
+			if len(differences) > 0 {
+				changes = append(changes, FileChange{Path: p, Change: "modified", Differences: differences, Before: before, After: after})
+			}
+		}
+	}
+	return changes
+}
+
+func (c *ImageDiff) diffPackages(images [2]*diffedImage) ([]PackageChange, error) {
+	if images[0].rpmdbPath == "" && images[1].rpmdbPath == "" {
+		return nil, nil
+	}
+	if c.CliWrappers.RpmCli == nil {
+		l.Logger.Warn("The images have an rpmdb, but the rpm CLI is not available, skipping package changes")
+		return nil, nil
+	}
+
+	var packages [2]map[string]cliWrappers.RpmPackage
+	for i, image := range images {
+		packages[i] = make(map[string]cliWrappers.RpmPackage)
+		if image.rpmdbPath == "" {
+			continue
+		}
+		list, err := c.CliWrappers.RpmCli.QueryAll(image.rpmdbPath)
+		if err != nil {
+			return nil, fmt.Errorf("listing packages in %s: %w", []string{c.Params.ImageA, c.Params.ImageB}[i], err)
+		}
+		for _, pkg := range list {
+			packages[i][pkg.Name+"."+pkg.Arch] = pkg
+		}
+	}
+
+	keys := make(map[string]struct{})
+	for key := range packages[0] {
+		keys[key] = struct{}{}
+	}
+	for key := range packages[1] {
+		keys[key] = struct{}{}
+	}
+
+	changes := []PackageChange{}
+	for _, key := range sortedKeys(keys) {
+		before, inA := packages[0][key]
+		after, inB := packages[1][key]
+		switch {
+		case !inA:
+			changes = append(changes, PackageChange{Name: after.Name, Arch: after.Arch, Change: "added", After: after.EVR()})
+		case !inB:
+			changes = append(changes, PackageChange{Name: before.Name, Arch: before.Arch, Change: "removed", Before: before.EVR()})
+		case before.EVR() != after.EVR():
+			changes = append(changes, PackageChange{Name: after.Name, Arch: after.Arch, Change: "changed", Before: before.EVR(), After: after.EVR()})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func Test_ImageDiff_validateParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       ImageDiffParams
+		errExpected  bool
+		errSubstring string
+	}{
+		{
+			name:   "should allow registry and oci images",
+			params: ImageDiffParams{ImageA: "quay.io/org/image:1", ImageB: "oci:/tmp/layout:image", Platform: "linux/arm64"},
+		},
+		{
+			name:         "should fail on empty image",
+			params:       ImageDiffParams{ImageA: "quay.io/org/image:1", ImageB: ""},
+			errExpected:  true,
+			errSubstring: "image reference is empty",
+		},
+		{
+			name:         "should fail on invalid oci reference",
+			params:       ImageDiffParams{ImageA: "oci:", ImageB: "quay.io/org/image:1"},
+			errExpected:  true,
+			errSubstring: "invalid image reference",
+		},
+		{
+			name:         "should fail on invalid platform",
+			params:       ImageDiffParams{ImageA: "quay.io/org/image:1", ImageB: "quay.io/org/image:2", Platform: "linux/arm64/v8/extra"},
+			errExpected:  true,
+			errSubstring: "invalid platform",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := &ImageDiff{Params: &tc.params}
+			err := c.validateParams()
+			if tc.errExpected {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.errSubstring)))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func Test_diffImageConfigs(t *testing.T) {
+	g := NewWithT(t)
+
+	a := &ociv1.ImageConfig{
+		User:         "1001",
+		Env:          []string{"PATH=/usr/bin", "OLD=1"},
+		Entrypoint:   []string{"/app"},
+		Labels:       map[string]string{"version": "1.0", "vendor": "org"},
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+	}
+	b := &ociv1.ImageConfig{
+		User:         "1001",
+		Env:          []string{"PATH=/usr/local/bin:/usr/bin", "NEW=2"},
+		Entrypoint:   []string{"/app", "--serve"},
+		Labels:       map[string]string{"version": "1.1", "vendor": "org"},
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+		WorkingDir:   "/srv",
+	}
+
+	g.Expect(diffImageConfigs(a, b)).To(Equal([]ConfigChange{
+		{Field: "working_dir", After: "/srv"},
+		{Field: "entrypoint", Before: []string{"/app"}, After: []string{"/app", "--serve"}},
+		{Field: "env.NEW", After: "2"},
+		{Field: "env.OLD", Before: "1"},
+		{Field: "env.PATH", Before: "/usr/bin", After: "/usr/local/bin:/usr/bin"},
+		{Field: "labels.version", Before: "1.0", After: "1.1"},
+	}))
+	g.Expect(diffImageConfigs(a, a)).To(BeEmpty())
+}
+
+func Test_ImageDiff_Run(t *testing.T) {
+	g := NewWithT(t)
+	epoch := time.Unix(1700000000, 0).UTC()
+
+	baseLayer := []testLayerFile{
+		{name: "etc/os-release", content: "ID=test", mode: 0644, modTime: epoch},
+		{name: "usr/bin/tool", content: "tool-v1", mode: 0755, modTime: epoch},
+		{name: "var/lib/rpm/rpmdb.sqlite", content: "base", mode: 0644, modTime: epoch},
+		{name: "opt/data/a", content: "a", mode: 0644, modTime: epoch},
+	}
+	appLayer := []testLayerFile{{name: "app/main", content: "app", mode: 0755, modTime: epoch}}
+	updateLayer := []testLayerFile{
+		{name: "usr/bin/tool", content: "tool-v2", mode: 0700, modTime: epoch.Add(time.Hour)},
+		{name: "etc/.wh.os-release", mode: 0644, modTime: epoch},
+		{name: "opt/data/.wh..wh..opq", mode: 0644, modTime: epoch},
+		{name: "opt/data/b", content: "b", mode: 0644, modTime: epoch},
+		{name: "var/lib/rpm/rpmdb.sqlite", content: "updated", mode: 0644, modTime: epoch},
+	}
+
+	imageConfig := func(env string) func([]digest.Digest) []byte {
+		return func(diffIDs []digest.Digest) []byte {
+			config := ociv1.Image{
+				Config: ociv1.ImageConfig{Env: []string{env}},
+				RootFS: ociv1.RootFS{Type: "layers", DiffIDs: diffIDs},
+			}
+			content, err := json.Marshal(config)
+			g.Expect(err).ToNot(HaveOccurred())
+			return content
+		}
+	}
+
+	var copies []*cliwrappers.SkopeoCopyArgs
+	mockSkopeo := &mockSkopeoCli{
+		CopyFunc: func(args *cliwrappers.SkopeoCopyArgs) error {
+			copies = append(copies, args)
+			dir := strings.TrimPrefix(args.DestinationImage, "oci:")
+			if args.SourceImage == "quay.io/org/image:1" {
+				writeTestOCILayoutWithConfig(t, dir, imageConfig("VERSION=1"), baseLayer, appLayer)
+			} else {
+				writeTestOCILayoutWithConfig(t, dir, imageConfig("VERSION=2"), baseLayer, updateLayer, appLayer)
+			}
+			return nil
+		},
+	}
+	mockRpm := &mockRpmCli{
+		QueryAllFunc: func(dbPath string) ([]cliwrappers.RpmPackage, error) {
+			content, err := os.ReadFile(filepath.Join(dbPath, "rpmdb.sqlite"))
+			g.Expect(err).ToNot(HaveOccurred())
+			packages := []cliwrappers.RpmPackage{{Name: "bash", Epoch: "0", Version: "5.1", Release: "1", Arch: "x86_64"}}
+			if string(content) == "updated" {
+				packages = []cliwrappers.RpmPackage{
+					{Name: "bash", Epoch: "0", Version: "5.2", Release: "1", Arch: "x86_64"},
+					{Name: "jq", Epoch: "1", Version: "1.7", Release: "2", Arch: "x86_64"},
+				}
+			}
+			return packages, nil
+		},
+	}
+	mockWriter := &mockResultsWriter{}
+
+	c := &ImageDiff{
+		Params:        &ImageDiffParams{ImageA: "quay.io/org/image:1", ImageB: "quay.io/org/image:2", Platform: "linux/arm64/v8"},
+		CliWrappers:   ImageDiffCliWrappers{SkopeoCli: mockSkopeo, RpmCli: mockRpm},
+		ResultsWriter: mockWriter,
+	}
+
+	g.Expect(c.Run()).To(Succeed())
+
+	g.Expect(copies).To(HaveLen(2))
+	g.Expect(copies[0].ExtraArgs).To(Equal([]string{"--override-os", "linux", "--override-arch", "arm64", "--override-variant", "v8"}))
+
+	results := c.Results
+	g.Expect(results.Images).To(Equal([2]string{"quay.io/org/image:1", "quay.io/org/image:2"}))
+	g.Expect(results.ConfigChanges).To(Equal([]ConfigChange{{Field: "env.VERSION", Before: "1", After: "2"}}))
+
+	g.Expect(results.Layers.Unchanged).To(Equal(2))
+	g.Expect(results.Layers.Removed).To(BeEmpty())
+	g.Expect(results.Layers.Added).To(HaveLen(1))
+
+	var fileChanges []string
+	for _, change := range results.FileChanges {
+		fileChanges = append(fileChanges, change.Change+" "+change.Path+" "+strings.Join(change.Differences, ","))
+	}
+	g.Expect(fileChanges).To(Equal([]string{
+		"removed /etc/os-release ",
+		"removed /opt/data/a ",
+		"added /opt/data/b ",
+		"modified /usr/bin/tool mode,content",
+		"modified /var/lib/rpm/rpmdb.sqlite size,content",
+	}))
+
+	g.Expect(results.PackageChanges).To(Equal([]PackageChange{
+		{Name: "bash", Arch: "x86_64", Change: "changed", Before: "0:5.1-1", After: "0:5.2-1"},
+		{Name: "jq", Arch: "x86_64", Change: "added", After: "1:1.7-2"},
+	}))
+}
+
+func Test_ImageDiff_diffPackages(t *testing.T) {
+	t.Run("should skip package changes without rpmdb", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &ImageDiff{CliWrappers: ImageDiffCliWrappers{RpmCli: &mockRpmCli{
+			QueryAllFunc: func(string) ([]cliwrappers.RpmPackage, error) {
+				t.Fatal("rpm must not be called")
+				return nil, nil
+			},
+		}}}
+		changes, err := c.diffPackages([2]*diffedImage{{}, {}})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(changes).To(BeNil())
+	})
+
+	t.Run("should skip package changes without the rpm CLI", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &ImageDiff{}
+		changes, err := c.diffPackages([2]*diffedImage{{rpmdbPath: "/tmp/rpm"}, {}})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(changes).To(BeNil())
+	})
+
+	t.Run("should report all packages as removed if the second image has no rpmdb", func(t *testing.T) {
+		g := NewWithT(t)
+		c := &ImageDiff{CliWrappers: ImageDiffCliWrappers{RpmCli: &mockRpmCli{
+			QueryAllFunc: func(string) ([]cliwrappers.RpmPackage, error) {
+				return []cliwrappers.RpmPackage{{Name: "bash", Epoch: "0", Version: "5.1", Release: "1", Arch: "x86_64"}}, nil
+			},
+		}}}
+		changes, err := c.diffPackages([2]*diffedImage{{rpmdbPath: "/tmp/rpm"}, {}})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(changes).To(Equal([]PackageChange{{Name: "bash", Arch: "x86_64", Change: "removed", Before: "0:5.1-1"}}))
+	})
+}
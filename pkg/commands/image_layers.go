@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// A file in a layer tarball.
+type LayerFile struct {
+	Type     string    `json:"type"`
+	Mode     string    `json:"mode"`
+	Uid      int       `json:"uid"`
+	Gid      int       `json:"gid"`
+	ModTime  time.Time `json:"mtime"`
+	Size     int64     `json:"size"`
+	Sha256   string    `json:"sha256,omitempty"`
+	Linkname string    `json:"linkname,omitempty"`
+}
+
+// An entry in a layer tarball. The path is absolute and clean.
+type layerEntry struct {
+	path string
+	file *LayerFile
+	// Only set for regular files selected by the keepContent function of readLayer
+	content []byte
+}
+
+// Read the files in a layer blob, keyed by path.
+func readLayerFiles(blobPath, mediaType string) (map[string]*LayerFile, error) {
+	entries, err := readLayer(blobPath, mediaType, nil)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*LayerFile, len(entries))
+	for _, entry := range entries {
+		files[entry.path] = entry.file
+	}
+	return files, nil
+}
+
+// Read the entries of a layer blob, in tarball order. Layers must be uncompressed, gzipped or zstd-compressed.
+// If keepContent is set, the content of the regular files it selects is kept in memory.
+func readLayer(blobPath, mediaType string, keepContent func(path string) bool) ([]layerEntry, error) {
+	f, err := os.Open(blobPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	switch {
+	case strings.HasSuffix(mediaType, "gzip"):
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing layer: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case strings.HasSuffix(mediaType, "zstd"):
+		zstdReader, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing layer: %w", err)
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	}
+
+	var entries []layerEntry
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading layer: %w", err)
+		}
+
+		entry := layerEntry{
+			path: filepath.Clean("/" + header.Name),
+			file: &LayerFile{
+				Type:     tarEntryType(header.Typeflag),
+				Mode:     fmt.Sprintf("%04o", header.Mode&0o7777),
+				Uid:      header.Uid,
+				Gid:      header.Gid,
+				ModTime:  header.ModTime.UTC(),
+				Size:     header.Size,
+				Linkname: header.Linkname,
+			},
+		}
+		if header.Typeflag == tar.TypeReg {
+			hash := sha256.New()
+			var content io.Writer = hash
+			var buf bytes.Buffer
+			if keepContent != nil && keepContent(entry.path) {
+				content = io.MultiWriter(hash, &buf)
+			}
+			if _, err := io.Copy(content, tr); err != nil {
+				return nil, fmt.Errorf("reading layer: %w", err)
+			}
+			entry.file.Sha256 = hex.EncodeToString(hash.Sum(nil))
+			if buf.Len() > 0 {
+				entry.content = buf.Bytes()
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func tarEntryType(typeflag byte) string {
+	switch typeflag {
+	case tar.TypeReg:
+		return "file"
+	case tar.TypeDir:
+		return "dir"
+	case tar.TypeSymlink:
+		return "symlink"
+	case tar.TypeLink:
+		return "hardlink"
+	default:
+		return "other"
+	}
+}
+
+// Compare two versions of a file. Returns the attributes that differ.
+func diffLayerFiles(a, b *LayerFile) []string {
+	if a == nil || b == nil {
+		return []string{"missing"}
+	}
+
+	var differences []string
+	if a.Type != b.Type {
+		differences = append(differences, "type")
+	}
+	if a.Mode != b.Mode {
+		differences = append(differences, "mode")
+	}
+	if a.Uid != b.Uid || a.Gid != b.Gid {
+		differences = append(differences, "owner")
+	}
+	if !a.ModTime.Equal(b.ModTime) {
+		differences = append(differences, "mtime")
+	}
+	if a.Size != b.Size {
+		differences = append(differences, "size")
+	}
+	if a.Sha256 != b.Sha256 {
+		differences = append(differences, "content")
+	}
+	if a.Linkname != b.Linkname {
+		differences = append(differences, "linkname")
+	}
+	return differences
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/gomega"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func Test_readLayer(t *testing.T) {
+	writeTar := func(t *testing.T, w io.Writer) {
+		g := NewWithT(t)
+		tw := tar.NewWriter(w)
+		g.Expect(tw.WriteHeader(&tar.Header{Name: "etc/", Mode: 0755, Typeflag: tar.TypeDir})).To(Succeed())
+		g.Expect(tw.WriteHeader(&tar.Header{Name: "etc/os-release", Mode: 0644, Size: 7, Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte("ID=test"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(tw.Close()).To(Succeed())
+	}
+
+	tests := []struct {
+		name      string
+		mediaType string
+		compress  func(t *testing.T, w io.Writer)
+	}{
+		{
+			name:      "uncompressed",
+			mediaType: ociv1.MediaTypeImageLayer,
+			compress:  writeTar,
+		},
+		{
+			name:      "gzip",
+			mediaType: ociv1.MediaTypeImageLayerGzip,
+			compress: func(t *testing.T, w io.Writer) {
+				gw := gzip.NewWriter(w)
+				writeTar(t, gw)
+				NewWithT(t).Expect(gw.Close()).To(Succeed())
+			},
+		},
+		{
+			name:      "zstd",
+			mediaType: ociv1.MediaTypeImageLayerZstd,
+			compress: func(t *testing.T, w io.Writer) {
+				g := NewWithT(t)
+				zw, err := zstd.NewWriter(w)
+				g.Expect(err).ToNot(HaveOccurred())
+				writeTar(t, zw)
+				g.Expect(zw.Close()).To(Succeed())
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var buf bytes.Buffer
+			tc.compress(t, &buf)
+			blobPath := filepath.Join(t.TempDir(), "layer")
+			g.Expect(os.WriteFile(blobPath, buf.Bytes(), 0644)).To(Succeed())
+
+			entries, err := readLayer(blobPath, tc.mediaType, func(path string) bool { return path == "/etc/os-release" })
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(entries).To(HaveLen(2))
+			g.Expect(entries[0].path).To(Equal("/etc"))
+			g.Expect(entries[0].file.Type).To(Equal("dir"))
+			g.Expect(entries[1].path).To(Equal("/etc/os-release"))
+			g.Expect(entries[1].file.Mode).To(Equal("0644"))
+			g.Expect(string(entries[1].content)).To(Equal("ID=test"))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/spf13/cobra"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var ImagePackagesParamsConfig = map[string]common.Parameter{
+	"output": {
+		Name:       "output",
+		ShortName:  "",
+		EnvVarName: "KBC_IMAGE_PACKAGES_OUTPUT",
+		TypeKind:   reflect.String,
+		Usage:      "Also write the inventory JSON to the file at the specified path.",
+	},
+}
+
+type ImagePackagesParams struct {
+	// The scanned image, given as a positional argument
+	Image string
+
+	Output string `paramName:"output"`
+}
+
+type ImagePackagesCliWrappers struct {
+	PackageInventoryCli cliWrappers.PackageInventoryCliInterface
+	BuildahUnshare      cliWrappers.WrapperCmd
+	Unshare             cliWrappers.WrapperCmd
+}
+
+// ImagePackages lists the packages installed in an image.
+type ImagePackages struct {
+	Params        *ImagePackagesParams
+	CliWrappers   ImagePackagesCliWrappers
+	Results       *PackageInventory
+	ResultsWriter common.ResultsWriterInterface
+}
+
+func NewImagePackages(cmd *cobra.Command, args []string) (*ImagePackages, error) {
+	params := &ImagePackagesParams{}
+	if err := common.ParseParameters(cmd, ImagePackagesParamsConfig, params); err != nil {
+		return nil, err
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 image, got %d", len(args))
+	}
+	params.Image = args[0]
+
+	imagePackages := &ImagePackages{
+		Params:        params,
+		ResultsWriter: common.NewResultsWriter(),
+	}
+
+	packageInventoryCli, err := cliWrappers.NewPackageInventoryCli(cliWrappers.NewCliExecutor())
+	if err != nil {
+		return nil, err
+	}
+	imagePackages.CliWrappers = ImagePackagesCliWrappers{
+		PackageInventoryCli: packageInventoryCli,
+		BuildahUnshare:      cliWrappers.NewWrapperCmd("buildah", "unshare"),
+		Unshare:             cliWrappers.NewWrapperCmd("unshare"),
+	}
+	return imagePackages, nil
+}
+
+// Run executes the command logic.
+func (c *ImagePackages) Run() error {
+	common.LogParameters(ImagePackagesParamsConfig, c.Params)
+
+	if c.Params.Image == "" {
+		return fmt.Errorf("image reference is empty")
+	}
+
+	wrapper := userNamespaceWrapper(c.CliWrappers.Unshare, c.CliWrappers.BuildahUnshare)
+	inventory, err := scanImagePackages(c.CliWrappers.PackageInventoryCli, wrapper, c.Params.Image)
+	if err != nil {
+		return fmt.Errorf("scanning installed packages: %w", err)
+	}
+	c.Results = inventory
+	l.Logger.Infof("Found %d packages", len(inventory.Packages))
+
+	if c.Params.Output != "" {
+		content, err := json.MarshalIndent(inventory, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(c.Params.Output, content, 0644); err != nil {
+			return fmt.Errorf("writing package inventory: %w", err)
+		}
+	}
+
+	if resultJson, err := c.ResultsWriter.CreateResultJson(c.Results); err == nil {
+		fmt.Print(resultJson)
+	} else {
+		l.Logger.Errorf("failed to create results json: %s", err.Error())
+		return err
+	}
+	return nil
+}
@@ -3,9 +3,12 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
@@ -18,8 +21,11 @@ const rhsmSecretsDir = "/usr/share/rhel/secrets"
 // the loopback interface is brought up before executing the command.
 // If disableRHSMHostIntegration is true and /usr/share/rhel/secrets exists,
 // a tmpfs is mounted over it to disable RHSM host integration.
-// This function does not return on success — it replaces the current process.
-func RunInUserNamespace(loopbackUp bool, disableRHSMHostIntegration bool, args []string) error {
+// If egressProxySocket is set, the command runs as a child process while a relay on the
+// loopback interface forwards its proxied connections to the egress proxy socket.
+// This function does not return on success — it replaces the current process
+// (or exits with the exit code of the child process).
+func RunInUserNamespace(loopbackUp bool, disableRHSMHostIntegration bool, egressProxySocket string, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("no command specified")
 	}
@@ -49,5 +55,62 @@ func RunInUserNamespace(loopbackUp bool, disableRHSMHostIntegration bool, args [
 		return err
 	}
 
+	if egressProxySocket != "" {
+		return runWithEgressRelay(binary, args, egressProxySocket)
+	}
+
 	return unix.Exec(binary, args, os.Environ())
 }
+
+// Run the command with the proxy environment variables pointing at an egress relay.
+// The relay runs in this process, so the command can't replace it.
+func runWithEgressRelay(binary string, args []string, egressProxySocket string) error {
+	listener, err := net.Listen("tcp", egressRelayAddress)
+	if err != nil {
+		return fmt.Errorf("listening for the egress relay: %w", err)
+	}
+	go func() {
+		_ = serveEgressRelay(listener, egressProxySocket)
+	}()
+
+	proxyURL := "http://" + listener.Addr().String()
+	noProxy := "localhost,127.0.0.1,::1"
+	// Buildah passes the proxy variables into RUN instructions (and uses them for ADD <url>)
+	env := append(os.Environ(),
+		"HTTP_PROXY="+proxyURL, "HTTPS_PROXY="+proxyURL, "http_proxy="+proxyURL, "https_proxy="+proxyURL,
+		"NO_PROXY="+noProxy, "no_proxy="+noProxy,
+	)
+
+	cmd := exec.Command(binary, args[1:]...)
+	cmd.Args[0] = args[0]
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, unix.SIGINT, unix.SIGTERM)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode := exitErr.ExitCode()
+		if exitCode < 0 {
+			// Killed by a signal
+			exitCode = 1
+		}
+		os.Exit(exitCode)
+	} else if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
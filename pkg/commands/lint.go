@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+var LintParamsConfig = map[string]common.Parameter{
+	"containerfile": {
+		Name:         "containerfile",
+		ShortName:    "f",
+		EnvVarName:   "KBC_LINT_CONTAINERFILE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to Containerfile. Tries with prepended --context first before falling back to the direct path.\nIf not specified, uses Containerfile/Dockerfile from the context directory.",
+	},
+	"context": {
+		Name:         "context",
+		ShortName:    "c",
+		EnvVarName:   "KBC_LINT_CONTEXT",
+		TypeKind:     reflect.String,
+		DefaultValue: ".",
+		Usage:        "Build context directory.",
+	},
+	"source": {
+		Name:         "source",
+		ShortName:    "s",
+		EnvVarName:   "KBC_LINT_SOURCE",
+		TypeKind:     reflect.String,
+		DefaultValue: "",
+		Usage:        "Path to a directory containing the source code.\nIf specified, the --containerfile and --context are treated as (and verified to be) relative to the source.",
+	},
+	"build-args": {
+		Name:       "build-args",
+		ShortName:  "",
+		EnvVarName: "KBC_LINT_BUILD_ARGS",
+		TypeKind:   reflect.Slice,
+		Usage:      "Build arguments that would be passed to the build, see 'image build --build-args'.",
+	},
+	"build-args-file": {
+		Name:       "build-args-file",
+		ShortName:  "",
+		EnvVarName: "KBC_LINT_BUILD_ARGS_FILE",
+		TypeKind:   reflect.String,
+		Usage:      "Path to a file with build arguments, see https://www.mankier.com/1/buildah-build#--build-arg-file",
+	},
+	"target": {
+		Name:       "target",
+		ShortName:  "",
+		EnvVarName: "KBC_LINT_TARGET",
+		TypeKind:   reflect.String,
+		Usage:      "Target stage in the Containerfile. By default, the target stage is the last stage.",
+	},
+	"skip-unused-stages": {
+		Name:         "skip-unused-stages",
+		ShortName:    "",
+		EnvVarName:   "KBC_LINT_SKIP_UNUSED_STAGES",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "true",
+		Usage:        "Only check stages which affect the target stage, same as 'image build --skip-unused-stages'.",
+	},
+	"strict-build-args": {
+		Name:         "strict-build-args",
+		ShortName:    "",
+		EnvVarName:   "KBC_LINT_STRICT_BUILD_ARGS",
+		TypeKind:     reflect.Bool,
+		DefaultValue: "false",
+		Usage:        "Exit with an error if there are any build arg issues.",
+	},
+}
+
+type LintParams struct {
+	Containerfile    string   `paramName:"containerfile"`
+	Context          string   `paramName:"context"`
+	Source           string   `paramName:"source"`
+	BuildArgs        []string `paramName:"build-args"`
+	BuildArgsFile    string   `paramName:"build-args-file"`
+	Target           string   `paramName:"target"`
+	SkipUnusedStages bool     `paramName:"skip-unused-stages"`
+	StrictBuildArgs  bool     `paramName:"strict-build-args"`
+}
+
+type LintResults struct {
+	BuildArgIssues []BuildArgIssue `json:"buildArgIssues"`
+}
+
+// Lint checks a Containerfile for problems that 'image build' would otherwise run into,
+// without building anything.
+type Lint struct {
+	Params        *LintParams
+	ResultsWriter common.ResultsWriterInterface
+}
+
+func NewLint(cmd *cobra.Command) (*Lint, error) {
+	params := &LintParams{}
+	if err := common.ParseParameters(cmd, LintParamsConfig, params); err != nil {
+		return nil, err
+	}
+	return &Lint{
+		Params:        params,
+		ResultsWriter: common.NewResultsWriter(),
+	}, nil
+}
+
+// Run executes the command logic.
+func (c *Lint) Run() error {
+	common.LogParameters(LintParamsConfig, c.Params)
+
+	// Reuse the containerfile lookup and parsing from 'image build', so that the results match
+	build := &Build{Params: &BuildParams{
+		Containerfile:    c.Params.Containerfile,
+		Context:          c.Params.Context,
+		Source:           c.Params.Source,
+		BuildArgs:        c.Params.BuildArgs,
+		BuildArgsFile:    c.Params.BuildArgsFile,
+		Target:           c.Params.Target,
+		SkipUnusedStages: c.Params.SkipUnusedStages,
+	}}
+
+	if err := build.detectContainerfile(); err != nil {
+		return err
+	}
+	issues, err := build.findBuildArgIssues()
+	if err != nil {
+		return fmt.Errorf("checking build args: %w", err)
+	}
+	reportErr := reportBuildArgIssues(issues, c.Params.StrictBuildArgs)
+	if len(issues) == 0 {
+		l.Logger.Info("No build arg issues found")
+	}
+
+	results := LintResults{BuildArgIssues: issues}
+	if results.BuildArgIssues == nil {
+		results.BuildArgIssues = []BuildArgIssue{}
+	}
+	resultsJson, err := c.ResultsWriter.CreateResultJson(results)
+	if err != nil {
+		l.Logger.Errorf("failed to create results json: %s", err.Error())
+		return err
+	}
+	fmt.Print(resultsJson)
+
+	return reportErr
+}
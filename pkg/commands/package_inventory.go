@@ -0,0 +1,498 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/package-url/packageurl-go"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// The packages installed in an image.
+type PackageInventory struct {
+	// ID-VERSION_ID from /etc/os-release (e.g. rhel-9.4), empty if the image doesn't have one
+	Distro   string             `json:"distro,omitempty"`
+	Packages []InventoryPackage `json:"packages"`
+}
+
+type InventoryPackage struct {
+	Purl string `json:"purl"`
+	// rpm, deb, apk, pypi or golang
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Where in the image the package was found: the package database, the metadata file
+	// or the Go binary
+	Location string `json:"location"`
+}
+
+// Any of the database files in one of the rpmdbDirs means there is an rpmdb: sqlite, bdb or ndb.
+var rpmdbFiles = []string{"rpmdb.sqlite", "Packages", "Packages.db"}
+
+// The purl namespaces for rpm distros whose os-release ID differs from the vendor.
+var rpmPurlNamespaces = map[string]string{"rhel": "redhat"}
+
+var pythonMetadataPatterns = []string{
+	"usr/lib*/python*/*-packages/*.dist-info/METADATA",
+	"usr/local/lib*/python*/*-packages/*.dist-info/METADATA",
+	"usr/lib*/python*/*-packages/*.egg-info",
+	"usr/local/lib*/python*/*-packages/*.egg-info",
+}
+
+// Scan the packages of an image in a separate process (see PackageInventoryCli), wrapped
+// in a user namespace in which buildah can mount the image.
+func scanImagePackages(cli cliWrappers.PackageInventoryCliInterface, wrapper cliWrappers.WrapperCmd, image string) (*PackageInventory, error) {
+	tempDir, err := os.MkdirTemp("", "kbc-package-inventory-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			l.Logger.Warnf("Failed to clean up temporary directory %s: %s", tempDir, err)
+		}
+	}()
+
+	outputFile := filepath.Join(tempDir, "inventory.json")
+	err = cli.Scan(&cliWrappers.PackageInventoryScanArgs{Image: image, OutputFile: outputFile, Wrapper: &wrapper})
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading package inventory: %w", err)
+	}
+	var inventory PackageInventory
+	if err := json.Unmarshal(content, &inventory); err != nil {
+		return nil, fmt.Errorf("parsing package inventory: %w", err)
+	}
+	return &inventory, nil
+}
+
+// Runs inside the user namespace (see PackageInventoryCli): mount the image, scan it
+// and write the inventory JSON to outputFile.
+func RunPackageInventory(image, outputFile string) (err error) {
+	executor := cliWrappers.NewCliExecutor()
+	buildahCli, err := cliWrappers.NewBuildahCli(executor)
+	if err != nil {
+		return err
+	}
+	var rpmCli cliWrappers.RpmCliInterface
+	if cli, err := cliWrappers.NewRpmCli(executor); err != nil {
+		l.Logger.Warnf("RPM packages will not be scanned: %s", err)
+	} else {
+		rpmCli = cli
+	}
+
+	inventory, err := mountAndScanPackages(buildahCli, rpmCli, image)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, content, 0644)
+}
+
+func mountAndScanPackages(buildahCli cliWrappers.BuildahCliInterface, rpmCli cliWrappers.RpmCliInterface, image string) (inventory *PackageInventory, err error) {
+	container, err := buildahCli.From(image)
+	if err != nil {
+		return nil, fmt.Errorf("creating container from %s: %w", image, err)
+	}
+	defer func() {
+		if e := buildahCli.Rm(container); e != nil && err == nil {
+			err = fmt.Errorf("removing container %s: %w", container, e)
+		}
+	}()
+
+	rootfs, err := buildahCli.Mount(container)
+	if err != nil {
+		return nil, fmt.Errorf("mounting container %s: %w", container, err)
+	}
+	defer func() {
+		if e := buildahCli.Umount(container); e != nil && err == nil {
+			err = fmt.Errorf("unmounting container %s: %w", container, e)
+		}
+	}()
+
+	l.Logger.Infof("Scanning packages of %s", image)
+	return scanPackageInventory(rootfs, rpmCli)
+}
+
+// Find the installed packages in the root filesystem of an image. Reads the rpmdb (via the rpm
+// CLI, packages are skipped if rpmCli is nil), the dpkg status, the apk database, the Python
+// package metadata and the build info of Go binaries.
+func scanPackageInventory(rootfs string, rpmCli cliWrappers.RpmCliInterface) (*PackageInventory, error) {
+	// All the reads go through root, so that symlinks in the image can't point outside of it
+	root, err := os.OpenRoot(rootfs)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	osID, osVersion := readOSRelease(root)
+	scan := &packageScan{root: root, rootfs: rootfs, osID: osID}
+	inventory := &PackageInventory{Packages: []InventoryPackage{}}
+	if osID != "" {
+		scan.distro = osID
+		if osVersion != "" {
+			scan.distro += "-" + osVersion
+		}
+		inventory.Distro = scan.distro
+	}
+
+	scanners := []struct {
+		name string
+		scan func() ([]InventoryPackage, error)
+	}{
+		{"rpm", func() ([]InventoryPackage, error) { return scan.rpmPackages(rpmCli) }},
+		{"dpkg", scan.dpkgPackages},
+		{"apk", scan.apkPackages},
+		{"python", scan.pythonPackages},
+		{"go", scan.goBinaries},
+	}
+	for _, scanner := range scanners {
+		packages, err := scanner.scan()
+		if err != nil {
+			return nil, fmt.Errorf("scanning %s packages: %w", scanner.name, err)
+		}
+		l.Logger.Debugf("Found %d %s packages", len(packages), scanner.name)
+		inventory.Packages = append(inventory.Packages, packages...)
+	}
+
+	sort.SliceStable(inventory.Packages, func(i, j int) bool {
+		a, b := inventory.Packages[i], inventory.Packages[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		return a.Purl < b.Purl
+	})
+	return inventory, nil
+}
+
+func readOSRelease(root *os.Root) (id, versionID string) {
+	for _, p := range []string{"etc/os-release", "usr/lib/os-release"} {
+		content, err := root.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(value, `"'`)
+			switch key {
+			case "ID":
+				id = value
+			case "VERSION_ID":
+				versionID = value
+			}
+		}
+		return id, versionID
+	}
+	return "", ""
+}
+
+// The root filesystem of an image being scanned.
+type packageScan struct {
+	root   *os.Root
+	rootfs string
+	// ID from /etc/os-release, used as the purl namespace of OS packages
+	osID string
+	// ID-VERSION_ID from /etc/os-release
+	distro string
+}
+
+func newInventoryPackage(purlType, namespace, name, version string, qualifiers map[string]string, location string) InventoryPackage {
+	purl := packageurl.NewPackageURL(purlType, namespace, name, version, packageurl.QualifiersFromMap(qualifiers), "")
+	return InventoryPackage{Purl: purl.ToString(), Type: purlType, Name: name, Version: version, Location: location}
+}
+
+// Qualifiers with empty values are left out.
+func purlQualifiers(keysAndValues ...string) map[string]string {
+	qualifiers := make(map[string]string)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i+1] != "" {
+			qualifiers[keysAndValues[i]] = keysAndValues[i+1]
+		}
+	}
+	return qualifiers
+}
+
+func (s *packageScan) isRegularFile(p string) bool {
+	info, err := s.root.Lstat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func (s *packageScan) rpmPackages(rpmCli cliWrappers.RpmCliInterface) ([]InventoryPackage, error) {
+	dbPath := ""
+	for _, dir := range rpmdbDirs {
+		location := strings.TrimPrefix(dir, "/")
+		// rpm gets the real path, make sure it's a directory in the image and not a symlink
+		if info, err := s.root.Lstat(location); err != nil || !info.IsDir() {
+			continue
+		}
+		for _, file := range rpmdbFiles {
+			if s.isRegularFile(path.Join(location, file)) {
+				dbPath = location
+				break
+			}
+		}
+		if dbPath != "" {
+			break
+		}
+	}
+	if dbPath == "" {
+		return nil, nil
+	}
+	if rpmCli == nil {
+		l.Logger.Warnf("Found an rpmdb in /%s, but the rpm CLI is not available, skipping RPM packages", dbPath)
+		return nil, nil
+	}
+
+	rpms, err := rpmCli.QueryAll(path.Join(s.rootfs, dbPath))
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := s.osID
+	if ns, ok := rpmPurlNamespaces[s.osID]; ok {
+		namespace = ns
+	}
+	var packages []InventoryPackage
+	for _, rpm := range rpms {
+		if rpm.Name == "gpg-pubkey" {
+			// Imported GPG keys, not actual packages
+			continue
+		}
+		epoch := rpm.Epoch
+		if epoch == "0" {
+			epoch = ""
+		}
+		qualifiers := purlQualifiers("arch", rpm.Arch, "epoch", epoch, "distro", s.distro)
+		packages = append(packages, newInventoryPackage(
+			packageurl.TypeRPM, namespace, rpm.Name, rpm.Version+"-"+rpm.Release, qualifiers, "/"+dbPath))
+	}
+	return packages, nil
+}
+
+// Parse the stanzas (separated by empty lines) of a dpkg status or apk installed file.
+// The separator is between the field name and value: ": " for dpkg, ":" for apk.
+// Continuation lines (starting with a space) are ignored.
+func parseStanzas(content []byte, separator string) []map[string]string {
+	var stanzas []map[string]string
+	stanza := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(stanza) > 0 {
+				stanzas = append(stanzas, stanza)
+				stanza = make(map[string]string)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, separator); ok {
+			stanza[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(stanza) > 0 {
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas
+}
+
+// Reads /var/lib/dpkg/status and the per-package status files in /var/lib/dpkg/status.d
+// (used by distroless images).
+func (s *packageScan) dpkgPackages() ([]InventoryPackage, error) {
+	statusFiles := []string{"var/lib/dpkg/status"}
+	if entries, err := fs.ReadDir(s.root.FS(), "var/lib/dpkg/status.d"); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".md5sums") {
+				statusFiles = append(statusFiles, "var/lib/dpkg/status.d/"+entry.Name())
+			}
+		}
+	}
+
+	var packages []InventoryPackage
+	for _, statusFile := range statusFiles {
+		if !s.isRegularFile(statusFile) {
+			continue
+		}
+		content, err := s.root.ReadFile(statusFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, stanza := range parseStanzas(content, ": ") {
+			status, hasStatus := stanza["Status"]
+			if stanza["Package"] == "" || (hasStatus && !strings.HasSuffix(status, " installed")) {
+				continue
+			}
+			qualifiers := purlQualifiers("arch", stanza["Architecture"], "distro", s.distro)
+			packages = append(packages, newInventoryPackage(
+				packageurl.TypeDebian, s.osID, stanza["Package"], stanza["Version"], qualifiers, "/"+statusFile))
+		}
+	}
+	return packages, nil
+}
+
+func (s *packageScan) apkPackages() ([]InventoryPackage, error) {
+	const installedFile = "lib/apk/db/installed"
+	if !s.isRegularFile(installedFile) {
+		return nil, nil
+	}
+	content, err := s.root.ReadFile(installedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []InventoryPackage
+	for _, stanza := range parseStanzas(content, ":") {
+		if stanza["P"] == "" {
+			continue
+		}
+		qualifiers := purlQualifiers("arch", stanza["A"], "distro", s.distro)
+		packages = append(packages, newInventoryPackage(
+			packageurl.TypeApk, s.osID, stanza["P"], stanza["V"], qualifiers, "/"+installedFile))
+	}
+	return packages, nil
+}
+
+var pythonNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// Reads the metadata of installed Python distributions: METADATA in .dist-info directories,
+// PKG-INFO in .egg-info directories or .egg-info files.
+func (s *packageScan) pythonPackages() ([]InventoryPackage, error) {
+	var packages []InventoryPackage
+	for _, pattern := range pythonMetadataPatterns {
+		matches, err := fs.Glob(s.root.FS(), pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			info, err := s.root.Lstat(match)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				match = path.Join(match, "PKG-INFO")
+			}
+			if !s.isRegularFile(match) {
+				continue
+			}
+			content, err := s.root.ReadFile(match)
+			if err != nil {
+				return nil, err
+			}
+
+			var name, version string
+			for _, line := range strings.Split(string(content), "\n") {
+				line = strings.TrimRight(line, "\r")
+				if line == "" {
+					// End of the headers
+					break
+				}
+				if value, ok := strings.CutPrefix(line, "Name: "); ok {
+					name = strings.TrimSpace(value)
+				} else if value, ok := strings.CutPrefix(line, "Version: "); ok {
+					version = strings.TrimSpace(value)
+				}
+			}
+			if name == "" {
+				continue
+			}
+			// https://packaging.python.org/en/latest/specifications/name-normalization/
+			normalizedName := pythonNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+			packages = append(packages, newInventoryPackage(packageurl.TypePyPi, "", normalizedName, version, nil, "/"+match))
+		}
+	}
+	return packages, nil
+}
+
+// Reads the build info embedded in Go binaries: the main module, the dependencies and the
+// Go standard library version.
+func (s *packageScan) goBinaries() ([]InventoryPackage, error) {
+	var packages []InventoryPackage
+	err := fs.WalkDir(s.root.FS(), ".", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if p == "proc" || p == "sys" || p == "dev" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			return nil
+		}
+
+		f, err := s.root.Open(p)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		buildInfo, err := buildinfo.Read(f)
+		if err != nil {
+			// Not a Go binary
+			return nil
+		}
+
+		location := "/" + p
+		if version, ok := strings.CutPrefix(buildInfo.GoVersion, "go"); ok {
+			packages = append(packages, goModulePackage("stdlib", version, location))
+		}
+		if buildInfo.Main.Path != "" {
+			version := buildInfo.Main.Version
+			if version == "(devel)" {
+				version = ""
+			}
+			packages = append(packages, goModulePackage(buildInfo.Main.Path, version, location))
+		}
+		for _, dep := range buildInfo.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			packages = append(packages, goModulePackage(dep.Path, dep.Version, location))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+func goModulePackage(modulePath, version, location string) InventoryPackage {
+	namespace, name := path.Split(modulePath)
+	pkg := newInventoryPackage(packageurl.TypeGolang, strings.TrimSuffix(namespace, "/"), name, version, nil, location)
+	pkg.Name = modulePath
+	return pkg
+}
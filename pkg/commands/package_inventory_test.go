@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func writeRootfsFile(t *testing.T, rootfs, path, content string, mode os.FileMode) {
+	t.Helper()
+	fullPath := filepath.Join(rootfs, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_scanPackageInventory(t *testing.T) {
+	t.Run("should find OS and language packages", func(t *testing.T) {
+		g := NewWithT(t)
+		rootfs := t.TempDir()
+
+		writeRootfsFile(t, rootfs, "etc/os-release", "NAME=\"Red Hat Enterprise Linux\"\nID=\"rhel\"\nVERSION_ID=\"9.4\"\n", 0644)
+		writeRootfsFile(t, rootfs, "usr/lib/sysimage/rpm/rpmdb.sqlite", "", 0644)
+		writeRootfsFile(t, rootfs, "var/lib/dpkg/status", `Package: libc6
+Status: install ok installed
+Architecture: amd64
+Version: 2.36-9
+Description: GNU C Library
+ continuation line: ignored
+
+Package: removed-pkg
+Status: deinstall ok config-files
+Version: 1.0
+`, 0644)
+		writeRootfsFile(t, rootfs, "lib/apk/db/installed", "C:Q1abc=\nP:musl\nV:1.2.4-r2\nA:x86_64\n\nP:busybox\nV:1.36.1-r5\nA:x86_64\n", 0644)
+		writeRootfsFile(t, rootfs, "usr/lib/python3.9/site-packages/Requests_OAuthlib-1.3.1.dist-info/METADATA",
+			"Metadata-Version: 2.1\nName: Requests_OAuthlib\nVersion: 1.3.1\n\nName: not-a-header\n", 0644)
+		writeRootfsFile(t, rootfs, "usr/lib64/python3.9/site-packages/six-1.16.0-py3.9.egg-info",
+			"Metadata-Version: 1.2\nName: six\nVersion: 1.16.0\n", 0644)
+
+		var rpmDbPath string
+		rpmCli := &mockRpmCli{
+			QueryAllFunc: func(dbPath string) ([]cliwrappers.RpmPackage, error) {
+				rpmDbPath = dbPath
+				return []cliwrappers.RpmPackage{
+					{Name: "bash", Epoch: "0", Version: "5.1.8", Release: "9.el9", Arch: "x86_64"},
+					{Name: "shadow-utils", Epoch: "2", Version: "4.9", Release: "8.el9", Arch: "x86_64"},
+					{Name: "gpg-pubkey", Epoch: "0", Version: "fd431d51", Release: "4ae0493b", Arch: "(none)"},
+				}, nil
+			},
+		}
+
+		inventory, err := scanPackageInventory(rootfs, rpmCli)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(rpmDbPath).To(Equal(filepath.Join(rootfs, "usr/lib/sysimage/rpm")))
+
+		g.Expect(inventory.Distro).To(Equal("rhel-9.4"))
+		g.Expect(inventory.Packages).To(Equal([]InventoryPackage{
+			{Purl: "pkg:apk/rhel/busybox@1.36.1-r5?arch=x86_64&distro=rhel-9.4", Type: "apk", Name: "busybox", Version: "1.36.1-r5", Location: "/lib/apk/db/installed"},
+			{Purl: "pkg:apk/rhel/musl@1.2.4-r2?arch=x86_64&distro=rhel-9.4", Type: "apk", Name: "musl", Version: "1.2.4-r2", Location: "/lib/apk/db/installed"},
+			{Purl: "pkg:deb/rhel/libc6@2.36-9?arch=amd64&distro=rhel-9.4", Type: "deb", Name: "libc6", Version: "2.36-9", Location: "/var/lib/dpkg/status"},
+			{Purl: "pkg:pypi/requests-oauthlib@1.3.1", Type: "pypi", Name: "requests-oauthlib", Version: "1.3.1", Location: "/usr/lib/python3.9/site-packages/Requests_OAuthlib-1.3.1.dist-info/METADATA"},
+			{Purl: "pkg:pypi/six@1.16.0", Type: "pypi", Name: "six", Version: "1.16.0", Location: "/usr/lib64/python3.9/site-packages/six-1.16.0-py3.9.egg-info"},
+			{Purl: "pkg:rpm/redhat/bash@5.1.8-9.el9?arch=x86_64&distro=rhel-9.4", Type: "rpm", Name: "bash", Version: "5.1.8-9.el9", Location: "/usr/lib/sysimage/rpm"},
+			{Purl: "pkg:rpm/redhat/shadow-utils@4.9-8.el9?arch=x86_64&distro=rhel-9.4&epoch=2", Type: "rpm", Name: "shadow-utils", Version: "4.9-8.el9", Location: "/usr/lib/sysimage/rpm"},
+		}))
+	})
+
+	t.Run("should read the build info of Go binaries", func(t *testing.T) {
+		g := NewWithT(t)
+		rootfs := t.TempDir()
+
+		// The test binary is a Go binary
+		self, err := os.Executable()
+		g.Expect(err).ToNot(HaveOccurred())
+		in, err := os.Open(self)
+		g.Expect(err).ToNot(HaveOccurred())
+		defer in.Close()
+		g.Expect(os.MkdirAll(filepath.Join(rootfs, "usr/bin"), 0755)).To(Succeed())
+		out, err := os.OpenFile(filepath.Join(rootfs, "usr/bin/app"), os.O_CREATE|os.O_WRONLY, 0755)
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = io.Copy(out, in)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(out.Close()).To(Succeed())
+		// Not executable, not scanned
+		writeRootfsFile(t, rootfs, "usr/share/data.bin", "data", 0644)
+
+		inventory, err := scanPackageInventory(rootfs, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(inventory.Distro).To(BeEmpty())
+		g.Expect(inventory.Packages).ToNot(BeEmpty())
+		var purls []string
+		for _, pkg := range inventory.Packages {
+			g.Expect(pkg.Type).To(Equal("golang"))
+			g.Expect(pkg.Location).To(Equal("/usr/bin/app"))
+			purls = append(purls, pkg.Purl)
+		}
+		g.Expect(purls).To(ContainElement(HavePrefix("pkg:golang/stdlib@")))
+		g.Expect(purls).To(ContainElement(HavePrefix("pkg:golang/github.com/onsi/gomega@v")))
+	})
+
+	t.Run("should skip RPM packages without the rpm CLI", func(t *testing.T) {
+		g := NewWithT(t)
+		rootfs := t.TempDir()
+		writeRootfsFile(t, rootfs, "var/lib/rpm/Packages", "", 0644)
+
+		inventory, err := scanPackageInventory(rootfs, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(inventory.Packages).To(BeEmpty())
+	})
+
+	t.Run("should not follow a symlinked rpmdb directory", func(t *testing.T) {
+		g := NewWithT(t)
+		rootfs := t.TempDir()
+		writeRootfsFile(t, rootfs, "usr/lib/sysimage/rpm/rpmdb.sqlite", "", 0644)
+		g.Expect(os.MkdirAll(filepath.Join(rootfs, "var/lib"), 0755)).To(Succeed())
+		g.Expect(os.Symlink("/usr/lib/sysimage/rpm", filepath.Join(rootfs, "var/lib/rpm"))).To(Succeed())
+
+		var dbPaths []string
+		rpmCli := &mockRpmCli{QueryAllFunc: func(dbPath string) ([]cliwrappers.RpmPackage, error) {
+			dbPaths = append(dbPaths, dbPath)
+			return nil, nil
+		}}
+		_, err := scanPackageInventory(rootfs, rpmCli)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(dbPaths).To(Equal([]string{filepath.Join(rootfs, "usr/lib/sysimage/rpm")}))
+	})
+}
+
+func Test_mountAndScanPackages(t *testing.T) {
+	t.Run("should mount the image and clean up", func(t *testing.T) {
+		g := NewWithT(t)
+		rootfs := t.TempDir()
+		writeRootfsFile(t, rootfs, "lib/apk/db/installed", "P:musl\nV:1.2.4-r2\nA:x86_64\n", 0644)
+
+		var calls []string
+		buildahCli := &mockBuildahCli{
+			FromFunc: func(image string) (string, error) {
+				calls = append(calls, "from "+image)
+				return "image-working-container", nil
+			},
+			MountFunc: func(container string) (string, error) {
+				calls = append(calls, "mount "+container)
+				return rootfs, nil
+			},
+			UmountFunc: func(container string) error {
+				calls = append(calls, "umount "+container)
+				return nil
+			},
+			RmFunc: func(container string) error {
+				calls = append(calls, "rm "+container)
+				return nil
+			},
+		}
+
+		inventory, err := mountAndScanPackages(buildahCli, nil, "quay.io/org/image:tag")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(inventory.Packages).To(HaveLen(1))
+		g.Expect(calls).To(Equal([]string{
+			"from quay.io/org/image:tag",
+			"mount image-working-container",
+			"umount image-working-container",
+			"rm image-working-container",
+		}))
+	})
+
+	t.Run("should remove the container if mounting fails", func(t *testing.T) {
+		g := NewWithT(t)
+		removed := false
+		buildahCli := &mockBuildahCli{
+			FromFunc: func(image string) (string, error) { return "image-working-container", nil },
+			MountFunc: func(container string) (string, error) {
+				return "", os.ErrPermission
+			},
+			RmFunc: func(container string) error {
+				removed = true
+				return nil
+			},
+		}
+
+		_, err := mountAndScanPackages(buildahCli, nil, "quay.io/org/image:tag")
+		g.Expect(err).To(MatchError(ContainSubstring("mounting container image-working-container")))
+		g.Expect(removed).To(BeTrue())
+	})
+}
+
+func Test_ImagePackages_Run(t *testing.T) {
+	g := NewWithT(t)
+	outputFile := filepath.Join(t.TempDir(), "inventory.json")
+
+	var scanArgs *cliwrappers.PackageInventoryScanArgs
+	packageInventoryCli := &mockPackageInventoryCli{
+		ScanFunc: func(args *cliwrappers.PackageInventoryScanArgs) error {
+			scanArgs = args
+			return os.WriteFile(args.OutputFile,
+				[]byte(`{"distro": "alpine-3.19", "packages": [{"purl": "pkg:apk/alpine/musl@1.2.4-r2", "type": "apk", "name": "musl"}]}`), 0644)
+		},
+	}
+	c := &ImagePackages{
+		Params: &ImagePackagesParams{Image: "quay.io/org/image:tag", Output: outputFile},
+		CliWrappers: ImagePackagesCliWrappers{
+			PackageInventoryCli: packageInventoryCli,
+			BuildahUnshare:      cliwrappers.NewWrapperCmd("buildah", "unshare"),
+			Unshare:             cliwrappers.NewWrapperCmd("unshare"),
+		},
+		ResultsWriter: &mockResultsWriter{},
+	}
+
+	g.Expect(c.Run()).To(Succeed())
+
+	g.Expect(scanArgs.Image).To(Equal("quay.io/org/image:tag"))
+	g.Expect(scanArgs.Wrapper).ToNot(BeNil())
+	g.Expect(c.Results.Distro).To(Equal("alpine-3.19"))
+	g.Expect(c.Results.Packages).To(HaveLen(1))
+
+	content, err := os.ReadFile(outputFile)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(strings.Contains(string(content), "pkg:apk/alpine/musl@1.2.4-r2")).To(BeTrue())
+}
+
+func Test_Build_writePackageInventory(t *testing.T) {
+	g := NewWithT(t)
+	outputFile := filepath.Join(t.TempDir(), "inventory.json")
+
+	packageInventoryCli := &mockPackageInventoryCli{
+		ScanFunc: func(args *cliwrappers.PackageInventoryScanArgs) error {
+			g.Expect(args.Image).To(Equal("quay.io/org/image:tag"))
+			return os.WriteFile(args.OutputFile, []byte(`{"packages": []}`), 0644)
+		},
+	}
+	c := &Build{
+		Params:      &BuildParams{OutputRef: "quay.io/org/image:tag"},
+		CliWrappers: BuildCliWrappers{PackageInventoryCli: packageInventoryCli},
+	}
+
+	g.Expect(c.writePackageInventory(outputFile)).To(Succeed())
+	content, err := os.ReadFile(outputFile)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(content)).To(MatchJSON(`{"packages": []}`))
+
+	packageInventoryCli.ScanFunc = func(args *cliwrappers.PackageInventoryScanArgs) error {
+		return os.ErrNotExist
+	}
+	g.Expect(c.writePackageInventory(outputFile)).To(MatchError(ContainSubstring("scanning installed packages")))
+}
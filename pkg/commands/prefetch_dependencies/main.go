@@ -3,11 +3,10 @@ package prefetch_dependencies
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
 	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/rhsm"
 	cfg "github.com/konflux-ci/konflux-build-cli/pkg/config"
 	"github.com/konflux-ci/konflux-build-cli/pkg/logger"
 
@@ -20,6 +19,10 @@ type PrefetchDependencies struct {
 	Config                 *Params
 	HermetoCli             cliwrappers.HermetoCliInterface
 	SubscriptionManagerCli cliwrappers.SubscriptionManagerCliInterface
+
+	rhsmSession *rhsm.Session
+	// a constant, but it needs to be mockable for tests
+	rhsmStateFile string
 }
 
 func getPackageProxyConfiguration() ([]string, error) {
@@ -76,7 +79,11 @@ func New(cmd *cobra.Command) (*PrefetchDependencies, error) {
 		return nil, err
 	}
 
-	prefetchDependencies := PrefetchDependencies{Config: &local_config, HermetoCli: hermetoCli}
+	prefetchDependencies := PrefetchDependencies{
+		Config:        &local_config,
+		HermetoCli:    hermetoCli,
+		rhsmStateFile: rhsm.DefaultStateFile,
+	}
 	return &prefetchDependencies, nil
 }
 
@@ -162,34 +169,26 @@ func (pd *PrefetchDependencies) Run() error {
 	return nil
 }
 
+// Register with the activation key, falling back to the fallback keys in order.
 func (pd *PrefetchDependencies) registerRHSM() error {
 	if err := pd.initSubscriptionManager(); err != nil {
 		return err
 	}
 
-	org, err := os.ReadFile(pd.Config.RHSMOrg)
-	if err != nil {
-		return err
-	}
-	key, err := os.ReadFile(pd.Config.RHSMActivationKey)
+	credentials, err := rhsm.CredentialsWithFallbacks(pd.Config.RHSMActivationKey, pd.Config.RHSMOrg,
+		pd.Config.RHSMFallbackActivationKeys, pd.Config.RHSMFallbackOrgs)
 	if err != nil {
 		return err
 	}
 
-	params := &cliwrappers.SubscriptionManagerRegisterParams{
-		Org:           strings.TrimSpace(string(org)),
-		ActivationKey: strings.TrimSpace(string(key)),
-		Force:         true,
-	}
-	return pd.SubscriptionManagerCli.Register(params)
+	pd.rhsmSession = &rhsm.Session{SubscriptionManager: pd.SubscriptionManagerCli, StateFile: pd.rhsmStateFile}
+	return pd.rhsmSession.Register(credentials)
 }
 
 func (pd *PrefetchDependencies) unregisterRHSM() {
-	if err := pd.initSubscriptionManager(); err != nil {
-		log.Warnf("Couldn't unregister with subscription-manager: %s", err)
-		return
+	if pd.rhsmSession != nil {
+		pd.rhsmSession.Unregister()
 	}
-	pd.SubscriptionManagerCli.Unregister()
 }
 
 func (pd *PrefetchDependencies) initSubscriptionManager() error {
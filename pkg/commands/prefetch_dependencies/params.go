@@ -87,12 +87,28 @@ var ParamsConfig = map[string]common.Parameter{
 		Usage:        "path to file containing Red Hat Subscription Manager activation key",
 		Required:     false,
 	},
+	"rhsm-fallback-orgs": {
+		Name:         "rhsm-fallback-orgs",
+		TypeKind:     reflect.Slice,
+		EnvVarName:   "KBC_PD_RHSM_FALLBACK_ORGS",
+		DefaultValue: "",
+		Usage:        "paths to files containing the organization IDs of the fallback activation keys",
+		Required:     false,
+	},
+	"rhsm-fallback-activation-keys": {
+		Name:         "rhsm-fallback-activation-keys",
+		TypeKind:     reflect.Slice,
+		EnvVarName:   "KBC_PD_RHSM_FALLBACK_ACTIVATION_KEYS",
+		DefaultValue: "",
+		Usage:        "paths to files containing activation keys to register with, in order, if registering with rhsm-activation-key fails; paired with rhsm-fallback-orgs by position",
+		Required:     false,
+	},
 	"git-auth-directory": {
 		Name:         "git-auth-directory",
 		TypeKind:     reflect.String,
 		EnvVarName:   "KBC_PD_GIT_AUTH_DIRECTORY",
 		DefaultValue: "",
-		Usage:        "directory with git auth credentials (.git-credentials, .gitconfig or username/password)",
+		Usage:        "directory with git auth credentials (.git-credentials, .gitconfig, username/password, token or github-app-id/github-app-private-key)",
 		Required:     false,
 	},
 	"enable-package-registry-proxy": { // Pipeline-level registry proxy switch.
@@ -116,6 +132,8 @@ type Params struct {
 	EnvFiles                   []string `paramName:"env-files"`
 	RHSMOrg                    string   `paramName:"rhsm-org"`
 	RHSMActivationKey          string   `paramName:"rhsm-activation-key"`
+	RHSMFallbackOrgs           []string `paramName:"rhsm-fallback-orgs"`
+	RHSMFallbackActivationKeys []string `paramName:"rhsm-fallback-activation-keys"`
 	GitAuthDirectory           string   `paramName:"git-auth-directory"`
 	EnablePackageRegistryProxy bool     `paramName:"enable-package-registry-proxy"`
 }
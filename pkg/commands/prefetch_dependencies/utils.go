@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/gitauth"
 )
 
 const readOnlyFileMode = os.FileMode(0444)
@@ -195,15 +196,6 @@ func cpFile(sourcePath, destinationPath string) error {
 	return os.WriteFile(destinationPath, data, readOnlyFileMode)
 }
 
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return err == nil && info.Mode().IsRegular()
-}
-
-// TODO: This will be shared with the git-clone command.
 // Copy git credentials and config files from the workspace to the home directory.
 func setupGitBasicAuth(authDir, sourceDir string) error {
 	if authDir == "" {
@@ -215,7 +207,7 @@ func setupGitBasicAuth(authDir, sourceDir string) error {
 	gitCredentialsPath := filepath.Join(authDir, ".git-credentials")
 	gitConfigPath := filepath.Join(authDir, ".gitconfig")
 
-	if fileExists(gitCredentialsPath) && fileExists(gitConfigPath) {
+	if gitauth.FileExists(gitCredentialsPath) && gitauth.FileExists(gitConfigPath) {
 		if err := cpFile(gitCredentialsPath, filepath.Join(home, ".git-credentials")); err != nil {
 			return err
 		}
@@ -228,7 +220,7 @@ func setupGitBasicAuth(authDir, sourceDir string) error {
 	usernamePath := filepath.Join(authDir, "username")
 	passwordPath := filepath.Join(authDir, "password")
 
-	if fileExists(usernamePath) && fileExists(passwordPath) {
+	if gitauth.FileExists(usernamePath) && gitauth.FileExists(passwordPath) {
 		rawUsername, err := os.ReadFile(usernamePath)
 		if err != nil {
 			return err
@@ -257,18 +249,41 @@ func setupGitBasicAuth(authDir, sourceDir string) error {
 		return nil
 	}
 
+	// Token and GitHub App credentials are handled the same way as in git-clone.
+	if gitauth.HasTokenAuth(authDir) {
+		originURL, err := getRemoteOriginURL(sourceDir)
+		if err != nil {
+			return err
+		}
+		tokenAuth, err := gitauth.LoadTokenAuth(authDir, originURL)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Using token auth (%s) for %s", tokenAuth.Source, tokenAuth.BaseURL)
+		return tokenAuth.WriteGitConfig(filepath.Join(home, ".gitconfig"), readOnlyFileMode)
+	}
+
 	return errors.New("unknown git basic auth workspace format")
 }
 
-// Parse the hostname from the git remote origin URL.
-func getHostnameFromRemoteOriginURL(sourceDir string) (string, error) {
+// Get the git remote origin URL.
+func getRemoteOriginURL(sourceDir string) (string, error) {
 	executor := cliwrappers.NewCliExecutor()
 	stdout, _, _, err := executor.Execute(cliwrappers.Cmd{Name: "git", Args: []string{"remote", "get-url", "origin"}, Dir: sourceDir})
 	if err != nil {
 		return "", err
 	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// Parse the hostname from the git remote origin URL.
+func getHostnameFromRemoteOriginURL(sourceDir string) (string, error) {
+	originURL, err := getRemoteOriginURL(sourceDir)
+	if err != nil {
+		return "", err
+	}
 
-	parsedURL, err := url.Parse(strings.TrimSpace(stdout))
+	parsedURL, err := url.Parse(originURL)
 	if err != nil {
 		return "", err
 	}
@@ -189,6 +189,37 @@ func TestGetHostnameFromRemoteOriginURL(t *testing.T) {
 	})
 }
 
+func TestSetupGitBasicAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should configure token auth for origin host", func(t *testing.T) {
+		sourceDir := t.TempDir()
+		executor := cliwrappers.NewCliExecutor()
+
+		gitInit := cliwrappers.Command("git", "init")
+		gitInit.Dir = sourceDir
+		gitRemoteAdd := cliwrappers.Command("git", "remote", "add", "origin", "https://github.com/user/repo.git")
+		gitRemoteAdd.Dir = sourceDir
+
+		_, _, _, err := executor.Execute(gitInit)
+		g.Expect(err).ToNot(HaveOccurred())
+		_, _, _, err = executor.Execute(gitRemoteAdd)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		authDir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(authDir, "token"), []byte("mytoken"), 0644)).To(Succeed())
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+
+		g.Expect(setupGitBasicAuth(authDir, sourceDir)).To(Succeed())
+
+		gitConfig, err := os.ReadFile(filepath.Join(home, ".gitconfig"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(gitConfig)).To(Equal("[http \"https://github.com/\"]\n  extraHeader = \"Authorization: Bearer mytoken\"\n"))
+		g.Expect(filepath.Join(home, ".git-credentials")).ToNot(BeAnExistingFile())
+	})
+}
+
 func TestDropGoProxyFromConfigFile(t *testing.T) {
 	g := NewWithT(t)
 
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/containerd/platforms"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+// Layer compression formats supported by buildah push and buildah manifest push.
+const (
+	compressionGzip        = "gzip"
+	compressionZstd        = "zstd"
+	compressionZstdChunked = "zstd:chunked"
+)
+
+// The compressed size of a pushed layer.
+type PushedLayer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+}
+
+// The compressed layers of a pushed image, as stored in the registry.
+type PushedImageLayers struct {
+	// Image reference with digest
+	Image string `json:"image"`
+	// Platform of the image, only for images in an image index
+	Platform string        `json:"platform,omitempty"`
+	Layers   []PushedLayer `json:"layers"`
+	// Sum of the compressed layer sizes
+	TotalSize int64 `json:"total_size"`
+}
+
+// Check the compression options. The format is the manifest type the image is pushed as
+// (oci or docker), empty if it's not known up front.
+func validateCompression(format, compressionFormat string, compressionLevel int) error {
+	maxLevel := 0
+	switch compressionFormat {
+	case "":
+		if compressionLevel != 0 {
+			return fmt.Errorf("compression-level requires compression-format")
+		}
+		return nil
+	case compressionGzip:
+		maxLevel = 9
+	case compressionZstd, compressionZstdChunked:
+		if format == "docker" {
+			return fmt.Errorf("compression-format %s requires the oci format, docker manifests only support gzip", compressionFormat)
+		}
+		maxLevel = 22
+	default:
+		return fmt.Errorf("compression-format must be one of %s, %s, %s, got '%s'",
+			compressionGzip, compressionZstd, compressionZstdChunked, compressionFormat)
+	}
+
+	if compressionLevel < 0 || compressionLevel > maxLevel {
+		return fmt.Errorf("compression-level for %s must be between 1 and %d, got %d", compressionFormat, maxLevel, compressionLevel)
+	}
+	return nil
+}
+
+// Read a pushed manifest (or image index) from the registry.
+func inspectPushedManifest(buildahCli cliWrappers.BuildahCliInterface, imageName, digest string) (string, error) {
+	return buildahCli.ManifestInspect(&cliWrappers.BuildahManifestInspectArgs{
+		ManifestName: "docker://" + imageName + "@" + digest,
+	})
+}
+
+// Collect the compressed layer sizes of a pushed image. If the manifest is an image index,
+// the manifests of all the images in the index are read from the registry.
+func pushedLayers(buildahCli cliWrappers.BuildahCliInterface, imageName, digest, rawManifest string) ([]PushedImageLayers, error) {
+	var manifest struct {
+		Manifests []ociv1.Descriptor `json:"manifests"`
+		Layers    []ociv1.Descriptor `json:"layers"`
+	}
+	if err := json.Unmarshal([]byte(rawManifest), &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest of %s@%s: %w", imageName, digest, err)
+	}
+
+	if len(manifest.Manifests) == 0 {
+		return []PushedImageLayers{newPushedImageLayers(imageName+"@"+digest, manifest.Layers)}, nil
+	}
+
+	var images []PushedImageLayers
+	for _, descriptor := range manifest.Manifests {
+		rawImageManifest, err := inspectPushedManifest(buildahCli, imageName, descriptor.Digest.String())
+		if err != nil {
+			return nil, fmt.Errorf("inspecting %s@%s: %w", imageName, descriptor.Digest, err)
+		}
+		imageLayers, err := pushedLayers(buildahCli, imageName, descriptor.Digest.String(), rawImageManifest)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range imageLayers {
+			if descriptor.Platform != nil {
+				image.Platform = platforms.Format(*descriptor.Platform)
+			}
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+func newPushedImageLayers(imageRef string, layers []ociv1.Descriptor) PushedImageLayers {
+	image := PushedImageLayers{Image: imageRef, Layers: []PushedLayer{}}
+	for _, layer := range layers {
+		image.Layers = append(image.Layers, PushedLayer{
+			Digest:    layer.Digest.String(),
+			MediaType: layer.MediaType,
+			Size:      layer.Size,
+		})
+		image.TotalSize += layer.Size
+	}
+	return image
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+func Test_validateCompression(t *testing.T) {
+	tests := []struct {
+		name              string
+		format            string
+		compressionFormat string
+		compressionLevel  int
+		expectedErr       string
+	}{
+		{name: "no compression options"},
+		{name: "gzip with level", compressionFormat: "gzip", compressionLevel: 9},
+		{name: "zstd:chunked with oci format", format: "oci", compressionFormat: "zstd:chunked", compressionLevel: 20},
+		{name: "zstd with unknown format", compressionFormat: "zstd"},
+		{
+			name:              "zstd with docker format",
+			format:            "docker",
+			compressionFormat: "zstd",
+			expectedErr:       "compression-format zstd requires the oci format, docker manifests only support gzip",
+		},
+		{
+			name:              "unknown compression format",
+			compressionFormat: "estargz",
+			expectedErr:       "compression-format must be one of gzip, zstd, zstd:chunked, got 'estargz'",
+		},
+		{
+			name:              "gzip level out of range",
+			compressionFormat: "gzip",
+			compressionLevel:  10,
+			expectedErr:       "compression-level for gzip must be between 1 and 9, got 10",
+		},
+		{
+			name:              "negative level",
+			compressionFormat: "zstd",
+			compressionLevel:  -1,
+			expectedErr:       "compression-level for zstd must be between 1 and 22, got -1",
+		},
+		{
+			name:             "level without compression format",
+			compressionLevel: 3,
+			expectedErr:      "compression-level requires compression-format",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := validateCompression(tc.format, tc.compressionFormat, tc.compressionLevel)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(tc.expectedErr))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func Test_pushedLayers(t *testing.T) {
+	const (
+		digestIndex = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		digestAmd64 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		digestArm64 = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		digestLayer = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	)
+
+	imageManifest := func(sizes ...string) string {
+		layers := ""
+		for i, size := range sizes {
+			if i > 0 {
+				layers += ","
+			}
+			layers += `{"mediaType": "application/vnd.oci.image.layer.v1.tar+zstd", "digest": "` + digestLayer + `", "size": ` + size + `}`
+		}
+		return `{"schemaVersion": 2, "config": {"size": 1000}, "layers": [` + layers + `]}`
+	}
+
+	t.Run("should collect the layers of a single image", func(t *testing.T) {
+		g := NewWithT(t)
+
+		layers, err := pushedLayers(&mockBuildahCli{}, "quay.io/org/image", digestAmd64, imageManifest("100", "20"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(layers).To(Equal([]PushedImageLayers{{
+			Image: "quay.io/org/image@" + digestAmd64,
+			Layers: []PushedLayer{
+				{Digest: digestLayer, MediaType: "application/vnd.oci.image.layer.v1.tar+zstd", Size: 100},
+				{Digest: digestLayer, MediaType: "application/vnd.oci.image.layer.v1.tar+zstd", Size: 20},
+			},
+			TotalSize: 120,
+		}}))
+	})
+
+	indexManifest := `{"schemaVersion": 2, "manifests": [
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestAmd64 + `", "size": 1,
+		 "platform": {"os": "linux", "architecture": "amd64"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + digestArm64 + `", "size": 1,
+		 "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}}
+	]}`
+
+	t.Run("should collect the layers of all images in an index", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var inspected []string
+		buildahCli := &mockBuildahCli{
+			ManifestInspectFunc: func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+				inspected = append(inspected, args.ManifestName)
+				if args.ManifestName == "docker://quay.io/org/image@"+digestAmd64 {
+					return imageManifest("300"), nil
+				}
+				return imageManifest("200"), nil
+			},
+		}
+
+		layers, err := pushedLayers(buildahCli, "quay.io/org/image", digestIndex, indexManifest)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(inspected).To(Equal([]string{
+			"docker://quay.io/org/image@" + digestAmd64,
+			"docker://quay.io/org/image@" + digestArm64,
+		}))
+		g.Expect(layers).To(HaveLen(2))
+		g.Expect(layers[0].Image).To(Equal("quay.io/org/image@" + digestAmd64))
+		g.Expect(layers[0].Platform).To(Equal("linux/amd64"))
+		g.Expect(layers[0].TotalSize).To(Equal(int64(300)))
+		g.Expect(layers[1].Platform).To(Equal("linux/arm64/v8"))
+		g.Expect(layers[1].TotalSize).To(Equal(int64(200)))
+	})
+
+	t.Run("should fail if an image in the index can't be inspected", func(t *testing.T) {
+		g := NewWithT(t)
+
+		buildahCli := &mockBuildahCli{
+			ManifestInspectFunc: func(args *cliwrappers.BuildahManifestInspectArgs) (string, error) {
+				return "", errors.New("manifest unknown")
+			},
+		}
+
+		_, err := pushedLayers(buildahCli, "quay.io/org/image", digestIndex, indexManifest)
+		g.Expect(err).To(MatchError("inspecting quay.io/org/image@" + digestAmd64 + ": manifest unknown"))
+	})
+
+	t.Run("should fail on invalid manifest", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := pushedLayers(&mockBuildahCli{}, "quay.io/org/image", digestIndex, "")
+		g.Expect(err).To(MatchError(ContainSubstring("parsing manifest of quay.io/org/image@" + digestIndex)))
+	})
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/common"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// The digest of an image pushed to one of the push destinations.
+type PushedDestination struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+}
+
+// Check that the --push-destinations are valid image references.
+func validatePushDestinations(destinations []string) error {
+	seen := make(map[string]bool)
+	for _, destination := range destinations {
+		if !common.IsImageNameValid(common.GetImageName(destination)) {
+			return fmt.Errorf("push destination '%s' is invalid", destination)
+		}
+		if seen[destination] {
+			return fmt.Errorf("duplicate push destination '%s'", destination)
+		}
+		seen[destination] = true
+	}
+	return nil
+}
+
+// Push to all the destinations in parallel, using the push function (which gets the destination
+// and returns the pushed digest). The digests must be the same for all destinations, pushing
+// the same image to different registries should never change the manifest.
+//
+// Returns the digests in the order of the destinations.
+func pushToDestinations(destinations []string, push func(destination string) (string, error)) ([]PushedDestination, error) {
+	pushed := make([]PushedDestination, len(destinations))
+	errs := make([]error, len(destinations))
+
+	var wg sync.WaitGroup
+	for i, destination := range destinations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Logger.Infof("Pushing image to %s", destination)
+			digest, err := push(destination)
+			if err != nil {
+				errs[i] = fmt.Errorf("pushing to %s: %w", destination, err)
+				return
+			}
+			l.Logger.Infof("Pushed image to %s, digest: %s", destination, digest)
+			pushed[i] = PushedDestination{Image: destination, Digest: digest}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range pushed[1:] {
+		if p.Digest != pushed[0].Digest {
+			var digests []string
+			for _, p := range pushed {
+				digests = append(digests, p.Image+": "+p.Digest)
+			}
+			return nil, fmt.Errorf("digests differ across push destinations: %s", strings.Join(digests, ", "))
+		}
+	}
+
+	return pushed, nil
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_pushToDestinations(t *testing.T) {
+	const (
+		digestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		digestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+
+	destinations := []string{"quay.io/org/image:tag", "mirror.example.com/org/image:tag", "registry.local/image:tag"}
+
+	t.Run("should push to all destinations in parallel", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// Every push waits until all of them started, which only works if they run in parallel
+		started := make(chan struct{}, len(destinations))
+		release := make(chan struct{})
+		go func() {
+			for range destinations {
+				<-started
+			}
+			close(release)
+		}()
+
+		pushed, err := pushToDestinations(destinations, func(destination string) (string, error) {
+			started <- struct{}{}
+			<-release
+			return digestA, nil
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(pushed).To(Equal([]PushedDestination{
+			{Image: "quay.io/org/image:tag", Digest: digestA},
+			{Image: "mirror.example.com/org/image:tag", Digest: digestA},
+			{Image: "registry.local/image:tag", Digest: digestA},
+		}))
+	})
+
+	t.Run("should fail if digests differ", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := pushToDestinations(destinations, func(destination string) (string, error) {
+			if destination == "mirror.example.com/org/image:tag" {
+				return digestB, nil
+			}
+			return digestA, nil
+		})
+		g.Expect(err).To(MatchError("digests differ across push destinations: " +
+			"quay.io/org/image:tag: " + digestA + ", " +
+			"mirror.example.com/org/image:tag: " + digestB + ", " +
+			"registry.local/image:tag: " + digestA))
+	})
+
+	t.Run("should return the first error in destination order", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := pushToDestinations(destinations, func(destination string) (string, error) {
+			if destination == "quay.io/org/image:tag" {
+				return digestA, nil
+			}
+			return "", errors.New("unauthorized")
+		})
+		g.Expect(err).To(MatchError("pushing to mirror.example.com/org/image:tag: unauthorized"))
+	})
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	cliWrappers "github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	"github.com/konflux-ci/konflux-build-cli/pkg/common/rhsm"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// RunRHSMCleanup unregisters the system if the state file records an RHSM registration
+// that was never ended, e.g. because the process that registered it crashed.
+func RunRHSMCleanup(stateFile string) error {
+	if _, err := os.Stat(stateFile); errors.Is(err, fs.ErrNotExist) {
+		l.Logger.Infof("No leaked RHSM registration recorded in %s", stateFile)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("checking RHSM session state: %w", err)
+	}
+
+	subman, err := cliWrappers.NewSubscriptionManagerCli(cliWrappers.NewCliExecutor())
+	if err != nil {
+		return err
+	}
+	session := &rhsm.Session{SubscriptionManager: subman, StateFile: stateFile}
+	err = session.CleanupLeaked()
+	if errors.Is(err, rhsm.ErrSessionActive) {
+		l.Logger.Infof("Not cleaning up: %s", err)
+		return nil
+	}
+	return err
+}
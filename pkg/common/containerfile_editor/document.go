@@ -0,0 +1,190 @@
+package containerfileeditor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	dfparser "github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// A parsed containerfile along with its physical lines, which get edited in place.
+//
+// Edits never change the number of physical lines, so that the line numbers in the parsed
+// AST remain valid. Removing lines and adding new ones is tracked separately and only
+// applied when rendering the result.
+type document struct {
+	physicalLines []string
+	parsed        *dfparser.Result
+	escapeToken   byte
+	// Indices of physical lines dropped when rewriting instructions.
+	removedLines map[int]bool
+	// Lines to add after the physical line at the given index.
+	insertedLines map[int][]string
+}
+
+func newDocument(content string) (*document, error) {
+	doc := &document{
+		physicalLines: splitLines(content),
+		removedLines:  make(map[int]bool),
+		insertedLines: make(map[int][]string),
+	}
+
+	result, err := dfparser.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't try to support non-ASCII escape tokens (only \ and ` should be valid anyway)
+	if rune(byte(result.EscapeToken)) != result.EscapeToken {
+		return nil, fmt.Errorf("unsupported escape token: %c", result.EscapeToken)
+	}
+
+	doc.parsed = result
+	doc.escapeToken = byte(result.EscapeToken)
+
+	return doc, nil
+}
+
+// Produce the edited content. Always ends with a newline.
+func (doc *document) render() string {
+	var lines []string
+	for i, line := range doc.physicalLines {
+		if !doc.removedLines[i] {
+			lines = append(lines, line)
+		}
+		lines = append(lines, doc.insertedLines[i]...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Get the physical line indices that form the instruction represented by the node,
+// i.e. the starting line and any lines joined with continuations.
+// This does not include heredocs that may be attached to this instruction,
+// their bodies start on the line following the last returned line.
+// Skips comment-only and empty lines within continuations.
+//
+// Example:
+// 0| FROM alpine:latest
+// 1| RUN echo hi && \
+// 2|     # this is a comment
+// 3|     echo hello && \
+// 4|     sh <<EOF
+// 5| echo bye
+// 6| EOF
+// getPhysicalLines(RUN node) => [1, 3, 4]
+func (doc *document) getPhysicalLines(node *dfparser.Node) []int {
+	var lines []int
+
+	// startLine is 1-indexed, our physical lines are 0-indexed
+	for i := node.StartLine - 1; i < len(doc.physicalLines); i++ {
+		line := doc.physicalLines[i]
+		trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		// Buildkit skips empty continuation lines (with a warning)
+		if trimmed == "" && len(lines) > 0 {
+			continue
+		}
+
+		lines = append(lines, i)
+
+		if !hasContinuation(line, doc.escapeToken) {
+			break
+		}
+	}
+
+	return lines
+}
+
+// Join the physical lines at the given indices into a single logical line, stripping continuations.
+func (doc *document) joinToLogicalLine(lineIndices []int) string {
+	var b strings.Builder
+	for _, idx := range lineIndices {
+		b.WriteString(trimContinuation(doc.physicalLines[idx], doc.escapeToken))
+	}
+	return b.String()
+}
+
+// Translate an offset into the joined logical line to the position of the physical line
+// in logicalLine and the offset within that physical line. The offset may also point
+// right after the end of the logical line.
+func (doc *document) locateInPhysicalLines(logicalLine []int, index int) (int, int) {
+	for pos, i := range logicalLine {
+		contributionToLogicalLine := len(trimContinuation(doc.physicalLines[i], doc.escapeToken))
+
+		if contributionToLogicalLine > index || (pos == len(logicalLine)-1 && contributionToLogicalLine == index) {
+			return pos, index
+		}
+
+		// Subtract this physical line's contribution, so that
+		// index becomes relative to the next physical line.
+		index -= contributionToLogicalLine
+	}
+	panic("injection index out of range")
+}
+
+// Insert text at the given offset into the joined logical line.
+func (doc *document) insertAt(logicalLine []int, index int, text string) {
+	doc.replaceRange(logicalLine, index, index, text)
+}
+
+// Replace the logical line content between offsets start and end with the replacement text.
+// If the range spans multiple physical lines, the replacement goes into the first one
+// together with whatever follows the end of the range; the other lines are removed.
+func (doc *document) replaceRange(logicalLine []int, start, end int, replacement string) {
+	startPos, startOffset := doc.locateInPhysicalLines(logicalLine, start)
+
+	// The end may point right after the last character of a physical line (or of the whole
+	// logical line), prefer the earliest physical line that can contain it.
+	endPos, endOffset := len(logicalLine)-1, end
+	for pos, i := range logicalLine {
+		contributionToLogicalLine := len(trimContinuation(doc.physicalLines[i], doc.escapeToken))
+		if pos >= startPos && contributionToLogicalLine >= endOffset {
+			endPos = pos
+			break
+		}
+		endOffset -= contributionToLogicalLine
+	}
+
+	firstLine := doc.physicalLines[logicalLine[startPos]]
+	lastLine := doc.physicalLines[logicalLine[endPos]]
+	doc.physicalLines[logicalLine[startPos]] = firstLine[:startOffset] + replacement + lastLine[endOffset:]
+	for _, i := range logicalLine[startPos+1 : endPos+1] {
+		doc.removedLines[i] = true
+	}
+}
+
+// Check whether a line ends with an unescaped escape character (possibly followed by whitespace).
+func hasContinuation(line string, escapeToken byte) bool {
+	return len(trimContinuation(line, escapeToken)) != len(line)
+}
+
+// Remove the trailing escape character (and any trailing whitespace after it) from a line,
+// if it ends with an unescaped continuation. Otherwise returns the line unchanged.
+//
+// Matches buildkit behavior:
+//   - the escape character may be followed by tabs or spaces
+//   - the escape character must not be preceded by an escape character
+//     (ignores the fact that the preceding escape may itself be escaped)
+//   - see https://github.com/moby/buildkit/blob/fa19659fc7b7af25fcac96e4c6314b2146994e8c/frontend/dockerfile/parser/parser.go#L168
+func trimContinuation(line string, escapeToken byte) string {
+	trimmed := strings.TrimRight(line, " \t")
+	length := len(trimmed)
+	if length > 0 && trimmed[length-1] == escapeToken {
+		if length == 1 || trimmed[length-2] != escapeToken {
+			return trimmed[:length-1]
+		}
+	}
+	return line
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for line := range strings.Lines(s) {
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	return lines
+}
@@ -0,0 +1,400 @@
+package containerfileeditor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dfparser "github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+var ErrStageNotFound = errors.New("stage not found")
+
+// Same as buildkit, https://github.com/moby/buildkit/blob/v0.19.0/frontend/dockerfile/instructions/parse.go#L427
+var validStageName = regexp.MustCompile("^[a-z][a-z0-9-_.]*$")
+
+// Editor makes targeted changes to a containerfile while preserving everything else as is,
+// including comments, the escape token and line continuations.
+//
+// All edits refer to the containerfile as it was originally parsed. Stages keep their indices
+// and instructions added by the editor are not considered by subsequent edits (e.g. SetLabel
+// does not update a LABEL added via InsertAtStageEnd). Renaming a stage does update the name
+// used by subsequent edits.
+type Editor struct {
+	*document
+	stages []*editorStage
+	// Number of lines inserted at the start of a stage, by physical line index (see insertAfterLine)
+	insertedAtStart map[int]int
+}
+
+type editorStage struct {
+	// Lowercase, like in buildkit. Empty for unnamed stages.
+	name         string
+	from         *dfparser.Node
+	instructions []*dfparser.Node
+}
+
+// Stage describes a build stage of the edited containerfile.
+type Stage struct {
+	Index int
+	// Empty for unnamed stages
+	Name      string
+	BaseImage string
+	// The line number of the FROM instruction (1-indexed)
+	Line int
+}
+
+func NewEditor(content string) (*Editor, error) {
+	doc, err := newDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Editor{document: doc, insertedAtStart: make(map[int]int)}
+	for _, node := range doc.parsed.AST.Children {
+		if strings.EqualFold(node.Value, "FROM") {
+			stage := &editorStage{from: node}
+			if args := nodeArgs(node); len(args) == 3 && strings.EqualFold(args[1], "as") {
+				stage.name = strings.ToLower(args[2])
+			}
+			e.stages = append(e.stages, stage)
+		} else if len(e.stages) > 0 {
+			// Global instructions (ARGs before the first FROM) don't belong to any stage
+			last := e.stages[len(e.stages)-1]
+			last.instructions = append(last.instructions, node)
+		}
+	}
+
+	return e, nil
+}
+
+// Get the edited containerfile content.
+func (e *Editor) String() string {
+	return e.render()
+}
+
+// Stages returns the build stages in order of appearance.
+func (e *Editor) Stages() []Stage {
+	stages := make([]Stage, len(e.stages))
+	for i, stage := range e.stages {
+		stages[i] = Stage{Index: i, Name: stage.name, Line: stage.from.StartLine}
+		if args := nodeArgs(stage.from); len(args) > 0 {
+			stages[i].BaseImage = args[0]
+		}
+	}
+	return stages
+}
+
+// FindStage returns the index of the stage referred to by ref, which is either the stage name
+// (case-insensitive) or the stage index, as in 'COPY --from=<ref>'.
+func (e *Editor) FindStage(ref string) (int, error) {
+	for i, stage := range e.stages {
+		if stage.name != "" && stage.name == strings.ToLower(ref) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(ref); err == nil && 0 <= i && i < len(e.stages) {
+		return i, nil
+	}
+	return -1, fmt.Errorf("%w: %s", ErrStageNotFound, ref)
+}
+
+func (e *Editor) getStage(index int) (*editorStage, error) {
+	if index < 0 || index >= len(e.stages) {
+		return nil, fmt.Errorf("%w: index %d out of range (%d stages)", ErrStageNotFound, index, len(e.stages))
+	}
+	return e.stages[index], nil
+}
+
+// SetBaseImage replaces the image reference in the FROM instruction of the stage.
+func (e *Editor) SetBaseImage(stageIndex int, ref string) error {
+	stage, err := e.getStage(stageIndex)
+	if err != nil {
+		return err
+	}
+
+	lineIndices := e.getPhysicalLines(stage.from)
+	args := argTokens(tokenize(e.joinToLogicalLine(lineIndices), e.escapeToken))
+	if len(args) == 0 {
+		return fmt.Errorf("FROM on line %d has no base image", stage.from.StartLine)
+	}
+
+	e.replaceRange(lineIndices, args[0].start, args[0].start+len(args[0].raw), ref)
+	return nil
+}
+
+// InsertAtStageStart adds instructions right after the FROM instruction of the stage,
+// after any instructions previously inserted there.
+//
+// Each instruction may span multiple lines using backslash line continuations, which get
+// converted to the escape token of the containerfile. Heredocs are inserted as is.
+func (e *Editor) InsertAtStageStart(stageIndex int, instructions ...string) error {
+	stage, err := e.getStage(stageIndex)
+	if err != nil {
+		return err
+	}
+	e.insertAfterLine(stage.from.EndLine-1, instructions, false)
+	return nil
+}
+
+// InsertAtStageEnd adds instructions after the last instruction of the stage,
+// after any instructions previously inserted there. See [Editor.InsertAtStageStart].
+func (e *Editor) InsertAtStageEnd(stageIndex int, instructions ...string) error {
+	stage, err := e.getStage(stageIndex)
+	if err != nil {
+		return err
+	}
+	last := stage.from
+	if len(stage.instructions) > 0 {
+		last = stage.instructions[len(stage.instructions)-1]
+	}
+	e.insertAfterLine(last.EndLine-1, instructions, true)
+	return nil
+}
+
+// Insert lines after the physical line at the given index. Insertions at the start of a stage
+// go before insertions at the end, which matters for stages that consist of a single FROM.
+func (e *Editor) insertAfterLine(index int, instructions []string, atEnd bool) {
+	var lines []string
+	for _, instruction := range instructions {
+		for _, line := range splitLines(instruction) {
+			if hasContinuation(line, '\\') {
+				line = trimContinuation(line, '\\') + string(e.escapeToken)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	existing := e.insertedLines[index]
+	if atEnd {
+		e.insertedLines[index] = append(existing, lines...)
+		return
+	}
+
+	n := e.insertedAtStart[index]
+	var updated []string
+	updated = append(updated, existing[:n]...)
+	updated = append(updated, lines...)
+	updated = append(updated, existing[n:]...)
+	e.insertedLines[index] = updated
+	e.insertedAtStart[index] = n + len(lines)
+}
+
+// SetLabel sets a label for the stage. If the stage already sets the label, replaces the value
+// in the last LABEL instruction that sets it. Otherwise, adds a new LABEL instruction at the end
+// of the stage. The value is used literally, it is quoted so that variables don't get expanded.
+func (e *Editor) SetLabel(stageIndex int, key string, value string) error {
+	stage, err := e.getStage(stageIndex)
+	if err != nil {
+		return err
+	}
+	quotedValue := quote(value, e.escapeToken)
+
+	for i := len(stage.instructions) - 1; i >= 0; i-- {
+		node := stage.instructions[i]
+		if !strings.EqualFold(node.Value, "LABEL") {
+			continue
+		}
+		lineIndices := e.getPhysicalLines(node)
+		logicalLine := e.joinToLogicalLine(lineIndices)
+		args := tokenize(logicalLine, e.escapeToken)[1:]
+		if len(args) == 0 {
+			continue
+		}
+
+		// Legacy form: LABEL key the rest of the line is the value
+		if !strings.Contains(args[0].raw, "=") {
+			if unquote(args[0].raw) == key && len(args) > 1 {
+				e.replaceRange(lineIndices, args[1].start, len(logicalLine), quotedValue)
+				return nil
+			}
+			continue
+		}
+
+		for j := len(args) - 1; j >= 0; j-- {
+			tokenKey, _, found := strings.Cut(args[j].raw, "=")
+			if found && unquote(tokenKey) == key {
+				valueStart := args[j].start + len(tokenKey) + 1
+				e.replaceRange(lineIndices, valueStart, args[j].start+len(args[j].raw), quotedValue)
+				return nil
+			}
+		}
+	}
+
+	quotedKey := key
+	if strings.ContainsFunc(key, func(r rune) bool { return !isPlainLabelKeyChar(r) }) {
+		quotedKey = quote(key, e.escapeToken)
+	}
+	return e.InsertAtStageEnd(stageIndex, fmt.Sprintf("LABEL %s=%s", quotedKey, quotedValue))
+}
+
+// AddRunMount adds a --mount=<mount> option to all RUN instructions in the stage. The mount
+// is inserted as is, e.g. type=cache,target=/root/.cache/go-build.
+func (e *Editor) AddRunMount(stageIndex int, mount string) error {
+	stage, err := e.getStage(stageIndex)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range stage.instructions {
+		if !strings.EqualFold(node.Value, "RUN") {
+			continue
+		}
+		lineIndices := e.getPhysicalLines(node)
+		tokens := tokenize(e.joinToLogicalLine(lineIndices), e.escapeToken)
+		if len(tokens) < 2 {
+			continue
+		}
+		e.insertAt(lineIndices, tokens[1].start, "--mount="+mount+" ")
+	}
+
+	return nil
+}
+
+// RenameStage sets the name of the stage ('FROM image AS name') and updates references to the
+// stage in the rest of the containerfile: FROM <name>, COPY/ADD --from=<name> and
+// RUN --mount=from=<name>.
+func (e *Editor) RenameStage(stageIndex int, name string) error {
+	stage, err := e.getStage(stageIndex)
+	if err != nil {
+		return err
+	}
+	name = strings.ToLower(name)
+	if !validStageName.MatchString(name) {
+		return fmt.Errorf("invalid stage name %q", name)
+	}
+	for i, other := range e.stages {
+		if i != stageIndex && other.name == name {
+			return fmt.Errorf("stage name %q is already used by stage %d", name, i)
+		}
+	}
+
+	lineIndices := e.getPhysicalLines(stage.from)
+	logicalLine := e.joinToLogicalLine(lineIndices)
+	args := argTokens(tokenize(logicalLine, e.escapeToken))
+	switch {
+	case len(args) >= 3 && strings.EqualFold(args[1].raw, "as"):
+		e.replaceRange(lineIndices, args[2].start, args[2].start+len(args[2].raw), name)
+	case len(args) == 1:
+		e.insertAt(lineIndices, args[0].start+len(args[0].raw), " AS "+name)
+	default:
+		return fmt.Errorf("FROM on line %d has unexpected arguments", stage.from.StartLine)
+	}
+
+	oldName := stage.name
+	stage.name = name
+	if oldName == "" {
+		// Unnamed stages can only be referenced by index, which doesn't change
+		return nil
+	}
+
+	for _, laterStage := range e.stages[stageIndex+1:] {
+		e.renameFromRef(laterStage.from, oldName, name)
+	}
+	for _, s := range e.stages {
+		for _, node := range s.instructions {
+			e.renameFlagRefs(node, oldName, name)
+		}
+	}
+	return nil
+}
+
+// Update 'FROM oldName' to 'FROM newName'.
+func (e *Editor) renameFromRef(from *dfparser.Node, oldName, newName string) {
+	lineIndices := e.getPhysicalLines(from)
+	args := argTokens(tokenize(e.joinToLogicalLine(lineIndices), e.escapeToken))
+	if len(args) > 0 && strings.EqualFold(unquote(args[0].raw), oldName) {
+		e.replaceRange(lineIndices, args[0].start, args[0].start+len(args[0].raw), newName)
+	}
+}
+
+// Update --from=oldName (COPY, ADD) and --mount=...,from=oldName (RUN) options.
+func (e *Editor) renameFlagRefs(node *dfparser.Node, oldName, newName string) {
+	instruction := strings.ToUpper(node.Value)
+	if instruction != "COPY" && instruction != "ADD" && instruction != "RUN" {
+		return
+	}
+
+	lineIndices := e.getPhysicalLines(node)
+	tokens := tokenize(e.joinToLogicalLine(lineIndices), e.escapeToken)
+
+	// Iterate backwards, so that replacements don't shift the offsets of the remaining tokens
+	for i := len(tokens) - 1; i >= 1; i-- {
+		tok := tokens[i]
+		if !strings.HasPrefix(tok.raw, "--") {
+			continue
+		}
+
+		if instruction != "RUN" {
+			if value, ok := strings.CutPrefix(tok.raw, "--from="); ok && strings.EqualFold(unquote(value), oldName) {
+				e.replaceRange(lineIndices, tok.start, tok.start+len(tok.raw), "--from="+newName)
+			}
+			continue
+		}
+
+		mount, ok := strings.CutPrefix(tok.raw, "--mount=")
+		if !ok {
+			continue
+		}
+		offset := tok.start + len("--mount=")
+		fields := strings.Split(mount, ",")
+		for j := len(fields) - 1; j >= 0; j-- {
+			fieldOffset := offset
+			for _, field := range fields[:j] {
+				fieldOffset += len(field) + 1
+			}
+			if value, ok := strings.CutPrefix(fields[j], "from="); ok && strings.EqualFold(unquote(value), oldName) {
+				e.replaceRange(lineIndices, fieldOffset, fieldOffset+len(fields[j]), "from="+newName)
+			}
+		}
+	}
+}
+
+// Get the argument values of an instruction as parsed by buildkit.
+func nodeArgs(node *dfparser.Node) []string {
+	var args []string
+	for n := node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+	return args
+}
+
+// Skip the instruction keyword and any --options.
+func argTokens(tokens []token) []token {
+	if len(tokens) > 0 {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 && strings.HasPrefix(tokens[0].raw, "--") {
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+// Wrap s in double quotes, escaping the characters that have a special meaning inside them.
+func quote(s string, escapeToken byte) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '$' || s[i] == escapeToken {
+			b.WriteByte(escapeToken)
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Remove surrounding quotes, if any. Doesn't handle escapes, good enough for comparing names.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func isPlainLabelKeyChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '.' || r == '-' || r == '_' || r == '/'
+}
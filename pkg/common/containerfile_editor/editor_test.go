@@ -0,0 +1,391 @@
+package containerfileeditor
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestEditor(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		edit   func(e *Editor) error
+		output string
+	}{
+		// SetBaseImage
+		{
+			name: "set-base-image",
+			input: dedent(`
+				# syntax=docker/dockerfile:1
+				FROM --platform=$BUILDPLATFORM golang:1.22 AS builder
+				RUN go build
+
+				FROM registry.access.redhat.com/ubi9/ubi-minimal:latest
+			`),
+			edit: func(e *Editor) error {
+				if err := e.SetBaseImage(0, "golang:1.22@sha256:abcd"); err != nil {
+					return err
+				}
+				return e.SetBaseImage(1, "registry.access.redhat.com/ubi9/ubi-minimal@sha256:1234")
+			},
+			output: dedent(`
+				# syntax=docker/dockerfile:1
+				FROM --platform=$BUILDPLATFORM golang:1.22@sha256:abcd AS builder
+				RUN go build
+
+				FROM registry.access.redhat.com/ubi9/ubi-minimal@sha256:1234
+			`),
+		},
+		{
+			name: "set-base-image-with-continuation",
+			input: dedent(`
+				FROM \
+				    alpine:latest \
+				    AS base
+			`),
+			edit: func(e *Editor) error { return e.SetBaseImage(0, "alpine:3.20") },
+			output: dedent(`
+				FROM \
+				    alpine:3.20 \
+				    AS base
+			`),
+		},
+
+		// Insertions
+		{
+			name: "insert-at-stage-start-and-end",
+			input: dedent(`
+				ARG BASE=alpine:latest
+				FROM $BASE AS builder
+				RUN echo build
+				# trailing comment stays where it is
+
+				FROM scratch
+				COPY --from=builder /out /out
+			`),
+			edit: func(e *Editor) error {
+				if err := e.InsertAtStageStart(0, "ENV FOO=bar"); err != nil {
+					return err
+				}
+				if err := e.InsertAtStageEnd(0, "RUN echo end of builder"); err != nil {
+					return err
+				}
+				return e.InsertAtStageEnd(1, "COPY a /a", "COPY b /b")
+			},
+			output: dedent(`
+				ARG BASE=alpine:latest
+				FROM $BASE AS builder
+				ENV FOO=bar
+				RUN echo build
+				RUN echo end of builder
+				# trailing comment stays where it is
+
+				FROM scratch
+				COPY --from=builder /out /out
+				COPY a /a
+				COPY b /b
+			`),
+		},
+		{
+			name:  "insert-into-from-only-stage",
+			input: "FROM scratch",
+			edit: func(e *Editor) error {
+				if err := e.InsertAtStageEnd(0, "LABEL end=1"); err != nil {
+					return err
+				}
+				if err := e.InsertAtStageStart(0, "LABEL start=1"); err != nil {
+					return err
+				}
+				return e.InsertAtStageStart(0, "LABEL start=2")
+			},
+			output: dedent(`
+				FROM scratch
+				LABEL start=1
+				LABEL start=2
+				LABEL end=1
+			`),
+		},
+		{
+			name: "insert-after-heredoc",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				echo hello
+				EOF
+			`),
+			edit: func(e *Editor) error { return e.InsertAtStageEnd(0, "USER 1001") },
+			output: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				echo hello
+				EOF
+				USER 1001
+			`),
+		},
+		{
+			name: "insert-with-backtick-escape",
+			input: dedent(`
+				# escape=` + "`" + `
+				FROM mcr.microsoft.com/windows/servercore
+			`),
+			edit: func(e *Editor) error { return e.InsertAtStageEnd(0, "RUN echo a && \\\n    echo b") },
+			output: dedent(`
+				# escape=` + "`" + `
+				FROM mcr.microsoft.com/windows/servercore
+				RUN echo a && ` + "`" + `
+				    echo b
+			`),
+		},
+
+		// SetLabel
+		{
+			name: "set-label-override",
+			input: dedent(`
+				FROM alpine:latest
+				LABEL name="app" \
+				      version=1.0
+				RUN echo hello
+				LABEL version=1.1
+			`),
+			edit: func(e *Editor) error { return e.SetLabel(0, "version", "2.0") },
+			output: dedent(`
+				FROM alpine:latest
+				LABEL name="app" \
+				      version=1.0
+				RUN echo hello
+				LABEL version="2.0"
+			`),
+		},
+		{
+			name: "set-label-multiline",
+			input: dedent(`
+				FROM alpine:latest
+				LABEL name="app" \
+				      version=1.0 \
+				      release=1
+			`),
+			edit: func(e *Editor) error { return e.SetLabel(0, "version", "2.0") },
+			output: dedent(`
+				FROM alpine:latest
+				LABEL name="app" \
+				      version="2.0" \
+				      release=1
+			`),
+		},
+		{
+			name: "set-label-quoted-key-and-legacy-form",
+			input: dedent(`
+				FROM alpine:latest
+				LABEL "com.example.vendor"="ACME Inc"
+				LABEL description This is my app
+			`),
+			edit: func(e *Editor) error {
+				if err := e.SetLabel(0, "com.example.vendor", "Example"); err != nil {
+					return err
+				}
+				return e.SetLabel(0, "description", `Costs $5 "or more"`)
+			},
+			output: dedent(`
+				FROM alpine:latest
+				LABEL "com.example.vendor"="Example"
+				LABEL description "Costs \$5 \"or more\""
+			`),
+		},
+		{
+			name: "set-label-add",
+			input: dedent(`
+				FROM alpine:latest AS builder
+				LABEL version=1.0
+
+				FROM scratch
+				COPY --from=builder /out /out
+			`),
+			edit: func(e *Editor) error {
+				if err := e.SetLabel(1, "version", "1.0"); err != nil {
+					return err
+				}
+				return e.SetLabel(1, "with space", "x")
+			},
+			output: dedent(`
+				FROM alpine:latest AS builder
+				LABEL version=1.0
+
+				FROM scratch
+				COPY --from=builder /out /out
+				LABEL version="1.0"
+				LABEL "with space"="x"
+			`),
+		},
+
+		// AddRunMount
+		{
+			name: "add-run-mount",
+			input: dedent(`
+				FROM golang:1.22 AS builder
+				RUN go build
+				RUN --network=none \
+				    go test ./...
+				RUN ["go", "vet"]
+				RUN <<EOF
+				echo hello
+				EOF
+
+				FROM scratch
+				RUN echo other stage
+			`),
+			edit: func(e *Editor) error { return e.AddRunMount(0, "type=cache,target=/root/.cache/go-build") },
+			output: dedent(`
+				FROM golang:1.22 AS builder
+				RUN --mount=type=cache,target=/root/.cache/go-build go build
+				RUN --mount=type=cache,target=/root/.cache/go-build --network=none \
+				    go test ./...
+				RUN --mount=type=cache,target=/root/.cache/go-build ["go", "vet"]
+				RUN --mount=type=cache,target=/root/.cache/go-build <<EOF
+				echo hello
+				EOF
+
+				FROM scratch
+				RUN echo other stage
+			`),
+		},
+
+		// RenameStage
+		{
+			name: "rename-stage",
+			input: dedent(`
+				FROM golang:1.22 as Builder
+				RUN go build
+
+				FROM builder AS tester
+				RUN --mount=type=bind,from=builder,source=/out,target=/out go test
+
+				FROM scratch
+				COPY --from=builder /out /out
+				ADD --chown=1001 --from=BUILDER /data /data
+				COPY --from=builder-other /x /x
+			`),
+			edit: func(e *Editor) error { return e.RenameStage(0, "build") },
+			output: dedent(`
+				FROM golang:1.22 as build
+				RUN go build
+
+				FROM build AS tester
+				RUN --mount=type=bind,from=build,source=/out,target=/out go test
+
+				FROM scratch
+				COPY --from=build /out /out
+				ADD --chown=1001 --from=build /data /data
+				COPY --from=builder-other /x /x
+			`),
+		},
+		{
+			name: "name-unnamed-stage",
+			input: dedent(`
+				FROM golang:1.22
+				RUN go build
+
+				FROM scratch
+				COPY --from=0 /out /out
+			`),
+			edit: func(e *Editor) error {
+				if err := e.RenameStage(0, "builder"); err != nil {
+					return err
+				}
+				// Renamed stages are found by the new name
+				i, err := e.FindStage("builder")
+				if err != nil {
+					return err
+				}
+				return e.SetBaseImage(i, "golang:1.23")
+			},
+			output: dedent(`
+				FROM golang:1.23 AS builder
+				RUN go build
+
+				FROM scratch
+				COPY --from=0 /out /out
+			`),
+		},
+		{
+			name: "multiple-edits",
+			input: dedent(`
+				FROM alpine:latest AS base
+				LABEL version=1.0
+				RUN apk add curl
+			`),
+			edit: func(e *Editor) error {
+				return errors.Join(
+					e.SetBaseImage(0, "alpine:3.20"),
+					e.RenameStage(0, "final"),
+					e.SetLabel(0, "version", "1.1"),
+					e.AddRunMount(0, "type=cache,target=/var/cache/apk"),
+					e.InsertAtStageEnd(0, "USER 1001"),
+				)
+			},
+			output: dedent(`
+				FROM alpine:3.20 AS final
+				LABEL version="1.1"
+				RUN --mount=type=cache,target=/var/cache/apk apk add curl
+				USER 1001
+			`),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			editor, err := NewEditor(tc.input)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tc.edit(editor)).To(Succeed())
+			g.Expect(editor.String()).To(Equal(tc.output))
+		})
+	}
+}
+
+func TestEditor_Stages(t *testing.T) {
+	g := NewWithT(t)
+
+	editor, err := NewEditor(dedent(`
+		ARG GO_VERSION=1.22
+		FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS Builder
+		RUN go build
+
+		FROM scratch
+	`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(editor.Stages()).To(Equal([]Stage{
+		{Index: 0, Name: "builder", BaseImage: "golang:${GO_VERSION}", Line: 2},
+		{Index: 1, Name: "", BaseImage: "scratch", Line: 5},
+	}))
+
+	for ref, expected := range map[string]int{"builder": 0, "BUILDER": 0, "0": 0, "1": 1} {
+		i, err := editor.FindStage(ref)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(i).To(Equal(expected), ref)
+	}
+
+	for _, ref := range []string{"other", "2", "-1"} {
+		_, err := editor.FindStage(ref)
+		g.Expect(errors.Is(err, ErrStageNotFound)).To(BeTrue(), ref)
+	}
+}
+
+func TestEditor_Errors(t *testing.T) {
+	g := NewWithT(t)
+
+	editor, err := NewEditor(dedent(`
+		FROM alpine:latest AS builder
+		FROM scratch
+	`))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(errors.Is(editor.SetBaseImage(2, "alpine"), ErrStageNotFound)).To(BeTrue())
+	g.Expect(errors.Is(editor.InsertAtStageEnd(-1, "USER 1001"), ErrStageNotFound)).To(BeTrue())
+
+	g.Expect(editor.RenameStage(1, "builder")).To(MatchError(`stage name "builder" is already used by stage 0`))
+	g.Expect(editor.RenameStage(1, "1st")).To(MatchError(`invalid stage name "1st"`))
+}
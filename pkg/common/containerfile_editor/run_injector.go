@@ -1,8 +1,9 @@
 package containerfileeditor
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
+	"path"
 	"strings"
 	"unicode"
 
@@ -11,10 +12,17 @@ import (
 
 var (
 	ErrRunHeredoc = errors.New("heredoc")
-	ErrRunExec    = errors.New("exec-form")
 	ErrRunNoOp    = errors.New("no-op")
 )
 
+// Shell used to wrap exec-form RUN instructions.
+const execFormShell = "/bin/sh"
+
+// Interpreters that can run the injected text when used in the shebang of a heredoc.
+var knownShells = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "ash": true, "ksh": true, "zsh": true,
+}
+
 type RunInjector struct {
 	// Called when encountering an unsupported RUN instruction, see [RunInjector.Inject]
 	OnUnsupported func(lineno int, err error)
@@ -22,15 +30,23 @@ type RunInjector struct {
 
 // Prepend toInject at the beginning of supported RUN instructions, after any options like --mount.
 //
+// RUN instructions that consist of a single heredoc (e.g. RUN <<EOF) get toInject as a separate
+// command at the start of the heredoc body instead (after the shebang, if any), if the body is run
+// by a shell, i.e. it has no shebang or the shebang refers to a known shell. A trailing && in
+// toInject becomes || exit, so that the script still stops if the injected command fails.
+//
+// Exec-form RUN instructions (e.g. RUN ["echo", "hello"]) are rewritten to run through
+// /bin/sh -c, which executes toInject and then execs the original command with its arguments
+// unchanged: RUN ["/bin/sh", "-c", "<toInject> exec \"$0\" \"$@\"", "echo", "hello"].
+// The rewritten instruction requires /bin/sh in the image.
+//
 // Does not inject into RUN instructions that:
-//   - are effectively no-ops (e.g. RUN # just a comment)
-//   - are in exec-form (e.g. RUN ["echo", "hello"])
-//   - start with a heredoc (e.g. RUN <<EOF)
-//     -- heredocs appearing later in the instruction are OK ('RUN sh <<EOF' works)
+//   - are effectively no-ops (e.g. RUN # just a comment, or a heredoc with an empty body)
+//   - are heredocs with a shebang referring to an interpreter other than a known shell
 //
 // When encountering an unsupported RUN instruction, calls the OnUnsupported function
 // with the RUN instruction's line number and an error value that gives the unsupported reason;
-// one of [ErrRunHeredoc], [ErrRunExec], [ErrRunNoOp].
+// one of [ErrRunHeredoc], [ErrRunNoOp].
 //
 // The toInject text can contain multiple lines. This function automatically adds the appropriate
 // line continuation character if needed. If toInject already has line continuations, they must use
@@ -50,118 +66,81 @@ func (r *RunInjector) Inject(containerfileContent string, toInject string) (stri
 // Tracks the internal state necessary to inject text into RUN instructions in a containerfile.
 // Single-use, won't produce sensible results after the first inject() call.
 type internalInjector struct {
-	physicalLines []string
-	parsed        *dfparser.Result
-	escapeToken   byte
+	*document
 }
 
 func newInternalInjector(content string) (*internalInjector, error) {
-	inj := &internalInjector{physicalLines: splitLines(content)}
-
-	result, err := dfparser.Parse(strings.NewReader(content))
+	doc, err := newDocument(content)
 	if err != nil {
 		return nil, err
 	}
-
-	// Don't try to support non-ASCII escape tokens (only \ and ` should be valid anyway)
-	if rune(byte(result.EscapeToken)) != result.EscapeToken {
-		return nil, fmt.Errorf("unsupported escape token: %c", result.EscapeToken)
-	}
-
-	inj.parsed = result
-	inj.escapeToken = byte(result.EscapeToken)
-
-	return inj, nil
+	return &internalInjector{document: doc}, nil
 }
 
 // Perform the injection as described in [RunInjector.Inject].
 // Should not be called more than once on the same internalInjector instance.
 func (inj *internalInjector) inject(toInject string, onUnsupported func(lineno int, err error)) string {
-	toInject = inj.fixInjection(toInject)
+	lineInjection := fixInjection(toInject, inj.escapeToken)
+	heredocInjection := standaloneInjection(toInject)
 
 	for _, node := range inj.parsed.AST.Children {
 		if strings.ToUpper(node.Value) != "RUN" {
 			continue
 		}
-		if node.Attributes["json"] {
-			onUnsupported(node.StartLine, ErrRunExec)
-			continue
-		}
 
 		lineIndices := inj.getPhysicalLines(node)
 		logicalLine := inj.joinToLogicalLine(lineIndices)
 		injectionIndex, unsupportedErr := inj.findInjectionIndex(logicalLine)
 
-		if unsupportedErr != nil {
-			onUnsupported(node.StartLine, unsupportedErr)
+		switch {
+		case unsupportedErr == nil && node.Attributes["json"]:
+			unsupportedErr = inj.rewriteExecForm(node, lineIndices, injectionIndex, toInject)
+		case unsupportedErr == nil:
+			inj.insertAt(lineIndices, injectionIndex, lineInjection)
+		case errors.Is(unsupportedErr, ErrRunHeredoc) && len(node.Heredocs) > 0:
+			bodyStart := lineIndices[len(lineIndices)-1] + 1
+			unsupportedErr = inj.injectIntoHeredocBody(bodyStart, node.Heredocs[0], heredocInjection)
 		}
 
-		if injectionIndex >= 0 {
-			inj.injectIntoPhysicalLine(lineIndices, injectionIndex, toInject)
+		if unsupportedErr != nil {
+			onUnsupported(node.StartLine, unsupportedErr)
 		}
 	}
 
-	return strings.Join(inj.physicalLines, "\n") + "\n"
+	return inj.render()
 }
 
 // Adjust the injection so that newlines are escaped as appropriate.
 // The input injection may contain unescaped newlines or backslash-escaped newlines,
-// which will be converted to line continuations using the proper escape character.
-func (inj *internalInjector) fixInjection(toInject string) string {
+// which will be converted to line continuations using the given escape character.
+func fixInjection(toInject string, escapeToken byte) string {
 	lines := splitLines(toInject)
 	for i, line := range lines {
 		lines[i] = trimContinuation(line, '\\')
 	}
-	return strings.Join(lines, string(inj.escapeToken)+"\n")
+	return strings.Join(lines, string(escapeToken)+"\n")
 }
 
-// Get the physical line indices that form the instruction represented by the node,
-// i.e. the starting line and any lines joined with continuations.
-// This does not include heredocs that may be attached to this instruction,
-// but that's OK, we don't want to inject into heredocs.
-// Skips comment-only lines within continuations.
-//
-// Example:
-// 0| FROM alpine:latest
-// 1| RUN echo hi && \
-// 2|     # this is a comment
-// 3|     echo hello && \
-// 4|     sh <<EOF
-// 5| echo bye
-// 6| EOF
-// getPhysicalLines(RUN node) => [1, 3, 4]
-func (inj *internalInjector) getPhysicalLines(node *dfparser.Node) []int {
-	var lines []int
-
-	// startLine is 1-indexed, our physical lines are 0-indexed
-	for i := node.StartLine - 1; i < len(inj.physicalLines); i++ {
-		line := inj.physicalLines[i]
-		trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
-
-		if strings.HasPrefix(trimmed, "#") {
-			continue
-		}
-
-		lines = append(lines, i)
-
-		if !hasContinuation(line, inj.escapeToken) {
-			break
+// Turn the injection into a command on its own line, for heredoc bodies. Prepending it to the
+// first line of the body would break bodies that start with e.g. a function definition.
+func standaloneInjection(toInject string) string {
+	var parts []string
+	for _, line := range splitLines(toInject) {
+		if part := strings.TrimSpace(trimContinuation(line, '\\')); part != "" {
+			parts = append(parts, part)
 		}
 	}
-
-	return lines
-}
-
-// Join the physical lines at the given indices into a single logical line, stripping continuations.
-func (inj *internalInjector) joinToLogicalLine(lineIndices []int) string {
-	var b strings.Builder
-	for _, idx := range lineIndices {
-		b.WriteString(trimContinuation(inj.physicalLines[idx], inj.escapeToken))
+	command := strings.Join(parts, " ")
+	if trimmed, found := strings.CutSuffix(command, "&&"); found {
+		command = strings.TrimSpace(trimmed) + " || exit"
 	}
-	return b.String()
+	return command
 }
 
 // Given a logical RUN line, find the index at which we should insert the injection.
+// For exec-form instructions, this is the start of the JSON array.
+// If the instruction consists of a single heredoc, return (-1, ErrRunHeredoc),
+// the injection then goes into the heredoc body.
 // If this RUN line isn't supported for injection, return (-1, error).
 func (inj *internalInjector) findInjectionIndex(line string) (int, error) {
 	tokens := tokenize(line, inj.escapeToken)
@@ -170,71 +149,122 @@ func (inj *internalInjector) findInjectionIndex(line string) (int, error) {
 		return -1, ErrRunNoOp
 	}
 
-	for _, tok := range tokens[1:] {
+	for i, tok := range tokens[1:] {
 		if strings.HasPrefix(tok.raw, "--") {
 			continue
 		}
 		if strings.HasPrefix(tok.raw, "#") {
 			return -1, ErrRunNoOp
 		}
-		if dfparser.MustParseHeredoc(tok.raw) != nil {
+		// Buildkit runs the heredoc body as the script only if the heredoc is the whole command,
+		// otherwise the command is passed to the shell as is ('RUN <<EOF python3' works)
+		if i == len(tokens)-2 && dfparser.MustParseHeredoc(tok.raw) != nil {
 			return -1, ErrRunHeredoc
 		}
-		// ErrExecForm already handled in the main loop
 		return tok.start, nil
 	}
 
 	return -1, ErrRunNoOp
 }
 
-// Find the physical line that corresponds to injectionIndex
-// (which is an offset into the joined logical line with continuations stripped)
-// and insert toInject into this line at the corresponding offset.
-func (inj *internalInjector) injectIntoPhysicalLine(logicalLine []int, injectionIndex int, toInject string) {
-	for _, i := range logicalLine {
-		physicalLine := inj.physicalLines[i]
-		contributionToLogicalLine := len(trimContinuation(physicalLine, inj.escapeToken))
-
-		if contributionToLogicalLine > injectionIndex {
-			inj.physicalLines[i] = physicalLine[:injectionIndex] + toInject + physicalLine[injectionIndex:]
-			return
+// Insert toInject as the first line of the body of a heredoc starting at the physical line
+// bodyStart, after the shebang if any. Returns ErrRunHeredoc if the body is not a shell script
+// and ErrRunNoOp if it has no commands.
+func (inj *internalInjector) injectIntoHeredocBody(bodyStart int, heredoc dfparser.Heredoc, toInject string) error {
+	isTerminator := func(line string) bool {
+		if heredoc.Chomp {
+			line = strings.TrimLeft(line, "\t")
+		}
+		return line == heredoc.Name
+	}
+
+	// Insert after the line that ends the RUN instruction, or after the shebang
+	insertAfter := bodyStart - 1
+	for i := bodyStart; i < len(inj.physicalLines) && !isTerminator(inj.physicalLines[i]); i++ {
+		line := inj.physicalLines[i]
+		if i == bodyStart && strings.HasPrefix(line, "#!") {
+			if !isShellShebang(line) {
+				return ErrRunHeredoc
+			}
+			insertAfter = i
+			continue
+		}
+
+		trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
 		}
 
-		// Subtract this physical line's contribution, so that
-		// injectionIndex becomes relative to the next physical line.
-		injectionIndex -= contributionToLogicalLine
+		inj.insertedLines[insertAfter] = append(inj.insertedLines[insertAfter], toInject)
+		return nil
 	}
-	panic("injection index out of range")
+
+	return ErrRunNoOp
 }
 
-// Check whether a line ends with an unescaped escape character (possibly followed by whitespace).
-func hasContinuation(line string, escapeToken byte) bool {
-	return len(trimContinuation(line, escapeToken)) != len(line)
+// Check whether a shebang line refers to one of the known shells, directly or via env.
+func isShellShebang(line string) bool {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return false
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// e.g. #!/usr/bin/env -S bash -e
+			if !strings.HasPrefix(field, "-") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+	return knownShells[interpreter]
 }
 
-// Remove the trailing escape character (and any trailing whitespace after it) from a line,
-// if it ends with an unescaped continuation. Otherwise returns the line unchanged.
-//
-// Matches buildkit behavior:
-//   - the escape character may be followed by tabs or spaces
-//   - the escape character must not be preceded by an escape character
-//     (ignores the fact that the preceding escape may itself be escaped)
-//   - see https://github.com/moby/buildkit/blob/fa19659fc7b7af25fcac96e4c6314b2146994e8c/frontend/dockerfile/parser/parser.go#L168
-func trimContinuation(line string, escapeToken byte) string {
-	trimmed := strings.TrimRight(line, " \t")
-	length := len(trimmed)
-	if length > 0 && trimmed[length-1] == escapeToken {
-		if length == 1 || trimmed[length-2] != escapeToken {
-			return trimmed[:length-1]
+// Rewrite an exec-form RUN instruction to run the original command through a shell
+// that executes toInject first, see [RunInjector.Inject]. The JSON array starts at
+// jsonIndex in the logical line and is replaced along with the rest of the instruction.
+func (inj *internalInjector) rewriteExecForm(node *dfparser.Node, lineIndices []int, jsonIndex int, toInject string) error {
+	var args []string
+	for n := node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+	if len(args) == 0 {
+		return ErrRunNoOp
+	}
+
+	// The script is a single JSON string, join the injection into one line
+	var parts []string
+	for _, line := range splitLines(toInject) {
+		if part := strings.TrimSpace(trimContinuation(line, '\\')); part != "" {
+			parts = append(parts, part)
 		}
 	}
-	return line
+	parts = append(parts, `exec "$0" "$@"`)
+	script := strings.Join(parts, " ")
+
+	encoded, err := encodeExecForm(append([]string{execFormShell, "-c", script}, args...))
+	if err != nil {
+		return err
+	}
+
+	inj.replaceRange(lineIndices, jsonIndex, len(inj.joinToLogicalLine(lineIndices)), encoded)
+	return nil
 }
 
-func splitLines(s string) []string {
-	var lines []string
-	for line := range strings.Lines(s) {
-		lines = append(lines, strings.TrimSuffix(line, "\n"))
+// Encode arguments as a JSON array in the style usually found in containerfiles: ["a", "b"]
+func encodeExecForm(args []string) (string, error) {
+	encoded := make([]string, len(args))
+	for i, arg := range args {
+		var b strings.Builder
+		encoder := json.NewEncoder(&b)
+		// Keep characters like & readable
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(arg); err != nil {
+			return "", err
+		}
+		encoded[i] = strings.TrimSuffix(b.String(), "\n")
 	}
-	return lines
+	return "[" + strings.Join(encoded, ", ") + "]", nil
 }
@@ -219,10 +219,179 @@ func TestInject(t *testing.T) {
 			output: dedent(`
 				FROM alpine:latest
 				RUN <<EOF
+				echo INJECTED || exit
 				echo hello
 				EOF
 			`),
 		},
+		{
+			name: "heredoc-interpreter-after-marker",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF python3
+				print("hello")
+				EOF
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN echo INJECTED && <<EOF python3
+				print("hello")
+				EOF
+			`),
+		},
+		{
+			name: "heredoc-shell-shebang",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				#!/bin/bash
+				set -e
+				echo hello
+				EOF
+
+				RUN <<EOF
+				#!/usr/bin/env -S bash -e
+				echo hello
+				EOF
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				#!/bin/bash
+				echo INJECTED || exit
+				set -e
+				echo hello
+				EOF
+
+				RUN <<EOF
+				#!/usr/bin/env -S bash -e
+				echo INJECTED || exit
+				echo hello
+				EOF
+			`),
+		},
+		{
+			name: "heredoc-non-shell-shebang",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				#!/usr/bin/env python3
+				print("hello")
+				EOF
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				#!/usr/bin/env python3
+				print("hello")
+				EOF
+			`),
+		},
+		{
+			name: "heredoc-body-comments-and-indent",
+			input: dedent(`
+				FROM alpine:latest
+				RUN --mount=type=cache,target=/cache <<EOF
+
+				    # install things
+				    echo hello
+				EOF
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN --mount=type=cache,target=/cache <<EOF
+				echo INJECTED || exit
+
+				    # install things
+				    echo hello
+				EOF
+			`),
+		},
+		{
+			name: "heredoc-body-starting-with-function-case-or-comment",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				greet() {
+				    echo hello
+				}
+				greet
+				EOF
+
+				RUN <<EOF
+				case "$(uname -m)" in
+				    x86_64) echo amd64 ;;
+				esac
+				EOF
+
+				RUN <<EOF
+				# comment first
+				echo hello
+				EOF
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				echo INJECTED || exit
+				greet() {
+				    echo hello
+				}
+				greet
+				EOF
+
+				RUN <<EOF
+				echo INJECTED || exit
+				case "$(uname -m)" in
+				    x86_64) echo amd64 ;;
+				esac
+				EOF
+
+				RUN <<EOF
+				echo INJECTED || exit
+				# comment first
+				echo hello
+				EOF
+			`),
+		},
+		{
+			name:     "heredoc-multiline-injection",
+			toInject: ". /tmp/prefetch.env && \\\n    ",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				echo hello
+				EOF
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				. /tmp/prefetch.env || exit
+				echo hello
+				EOF
+			`),
+		},
+		{
+			name:   "heredoc-chomp",
+			input:  "FROM alpine:latest\nRUN <<-EOF\n\techo hello\n\tEOF\nRUN echo after\n",
+			output: "FROM alpine:latest\nRUN <<-EOF\necho INJECTED || exit\n\techo hello\n\tEOF\nRUN echo INJECTED && echo after\n",
+		},
+		{
+			name: "heredoc-empty-body",
+			input: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				# nothing to do
+				EOF
+				RUN echo after
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN <<EOF
+				# nothing to do
+				EOF
+				RUN echo INJECTED && echo after
+			`),
+		},
 		{
 			name: "heredoc-quoted-marker",
 			input: dedent(`
@@ -240,6 +409,7 @@ func TestInject(t *testing.T) {
 				FROM alpine:latest
 
 				RUN <<'EOF'
+				echo INJECTED || exit
 				echo bare single-quoted
 				EOF
 
@@ -360,7 +530,45 @@ func TestInject(t *testing.T) {
 			`),
 			output: dedent(`
 				FROM alpine:latest
-				RUN ["echo", "hello"]
+				RUN ["/bin/sh", "-c", "echo INJECTED && exec \"$0\" \"$@\"", "echo", "hello"]
+			`),
+		},
+		{
+			name: "exec-form-with-options",
+			input: dedent(`
+				FROM alpine:latest
+				RUN --mount=type=cache,target=/cache ["sh", "-c", "echo \"a & b\""]
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN --mount=type=cache,target=/cache ["/bin/sh", "-c", "echo INJECTED && exec \"$0\" \"$@\"", "sh", "-c", "echo \"a & b\""]
+			`),
+		},
+		{
+			name: "exec-form-multiline",
+			input: dedent(`
+				FROM alpine:latest
+				RUN --network=host \
+				    ["echo", \
+				     "hello"]
+				RUN echo after
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN --network=host \
+				    ["/bin/sh", "-c", "echo INJECTED && exec \"$0\" \"$@\"", "echo", "hello"]
+				RUN echo INJECTED && echo after
+			`),
+		},
+		{
+			name: "exec-form-empty",
+			input: dedent(`
+				FROM alpine:latest
+				RUN []
+			`),
+			output: dedent(`
+				FROM alpine:latest
+				RUN []
 			`),
 		},
 
@@ -485,10 +693,11 @@ func TestInject(t *testing.T) {
 				RUN echo INJECTED && echo first
 
 				RUN <<EOF
+				echo INJECTED || exit
 				echo heredoc
 				EOF
 
-				RUN ["echo", "exec"]
+				RUN ["/bin/sh", "-c", "echo INJECTED && exec \"$0\" \"$@\"", "echo", "exec"]
 
 				RUN echo INJECTED && echo second
 				RUN # no-op
@@ -727,10 +936,11 @@ func TestInject_OnUnsupported(t *testing.T) {
 		RUN echo supported
 
 		RUN <<EOF
-		echo heredoc
+		#!/usr/bin/python3
+		print("heredoc")
 		EOF
 
-		RUN ["echo", "exec"]
+		RUN []
 
 		# no-op
 		RUN
@@ -754,10 +964,11 @@ func TestInject_OnUnsupported(t *testing.T) {
 		RUN INJECTED echo supported
 
 		RUN <<EOF
-		echo heredoc
+		#!/usr/bin/python3
+		print("heredoc")
 		EOF
 
-		RUN ["echo", "exec"]
+		RUN []
 
 		# no-op
 		RUN
@@ -771,12 +982,12 @@ func TestInject_OnUnsupported(t *testing.T) {
 	g.Expect(calls[0].Line).To(Equal(5))
 	g.Expect(errors.Is(calls[0].Error, ErrRunHeredoc)).To(BeTrue())
 
-	g.Expect(calls[1].Line).To(Equal(9))
-	g.Expect(errors.Is(calls[1].Error, ErrRunExec)).To(BeTrue())
+	g.Expect(calls[1].Line).To(Equal(10))
+	g.Expect(errors.Is(calls[1].Error, ErrRunNoOp)).To(BeTrue())
 
-	g.Expect(calls[2].Line).To(Equal(12))
+	g.Expect(calls[2].Line).To(Equal(13))
 	g.Expect(errors.Is(calls[2].Error, ErrRunNoOp)).To(BeTrue())
 
-	g.Expect(calls[3].Line).To(Equal(13))
+	g.Expect(calls[3].Line).To(Equal(14))
 	g.Expect(errors.Is(calls[3].Error, ErrRunNoOp)).To(BeTrue())
 }
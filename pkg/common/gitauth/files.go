@@ -0,0 +1,53 @@
+package gitauth
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// MaxAuthFileSize is the maximum allowed size for auth-related files
+// (.gitconfig, .git-credentials, SSH keys, username, password, tokens).
+const MaxAuthFileSize = 1 << 20 // 1MB
+
+// FileExists checks if a file exists and is a regular file (following symlinks).
+func FileExists(path string) bool {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ReadFileWithLimit reads a file, rejecting files larger than maxSize.
+// Uses file-descriptor-based stat and limited read to avoid TOCTOU races.
+func ReadFileWithLimit(path string, maxSize int64) (data []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxSize {
+		return nil, fmt.Errorf("authentication file exceeds maximum allowed size (%d bytes)", maxSize)
+	}
+
+	// Use LimitReader to enforce the size cap during read, even if the file
+	// was extended between Stat and Read (belt-and-suspenders).
+	data, err = io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("authentication file exceeds maximum allowed size (%d bytes)", maxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,71 @@
+package gitauth
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_ReadFileWithLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should read file within limit", func(t *testing.T) {
+		tmpFile := filepath.Join(t.TempDir(), "small.txt")
+		g.Expect(os.WriteFile(tmpFile, []byte("hello"), 0644)).To(Succeed())
+
+		data, err := ReadFileWithLimit(tmpFile, 1024)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal("hello"))
+	})
+
+	t.Run("should reject file exceeding limit", func(t *testing.T) {
+		tmpFile := filepath.Join(t.TempDir(), "large.txt")
+		// Create a file larger than the limit
+		largeData := make([]byte, 2048)
+		g.Expect(os.WriteFile(tmpFile, largeData, 0644)).To(Succeed())
+
+		_, err := ReadFileWithLimit(tmpFile, 1024)
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("exceeds maximum allowed size"))
+	})
+
+	t.Run("should return error for nonexistent file", func(t *testing.T) {
+		_, err := ReadFileWithLimit("/nonexistent/file", 1024)
+
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should read file exactly at limit", func(t *testing.T) {
+		tmpFile := filepath.Join(t.TempDir(), "exact.txt")
+		exactData := make([]byte, 1024)
+		g.Expect(os.WriteFile(tmpFile, exactData, 0644)).To(Succeed())
+
+		data, err := ReadFileWithLimit(tmpFile, 1024)
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(HaveLen(1024))
+	})
+}
+
+func Test_FileExists(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+
+	regular := filepath.Join(dir, "regular")
+	g.Expect(os.WriteFile(regular, []byte("hello"), 0644)).To(Succeed())
+	symlink := filepath.Join(dir, "symlink")
+	g.Expect(os.Symlink(regular, symlink)).To(Succeed())
+	fifo := filepath.Join(dir, "fifo")
+	g.Expect(syscall.Mkfifo(fifo, 0644)).To(Succeed())
+
+	g.Expect(FileExists(regular)).To(BeTrue())
+	g.Expect(FileExists(symlink)).To(BeTrue())
+	g.Expect(FileExists(dir)).To(BeFalse())
+	g.Expect(FileExists(fifo)).To(BeFalse())
+	g.Expect(FileExists(filepath.Join(dir, "missing"))).To(BeFalse())
+}
@@ -0,0 +1,189 @@
+package gitauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpClient is used for GitHub API requests. Replaced in tests.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type gitHubApp struct {
+	appID          string
+	installationID string
+	privateKey     *rsa.PrivateKey
+}
+
+func loadGitHubApp(authDir string) (*gitHubApp, error) {
+	appID, err := readTrimmedFile(filepath.Join(authDir, GitHubAppIDFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App id: %w", err)
+	}
+	if appID == "" {
+		return nil, errors.New("GitHub App id file is empty")
+	}
+
+	keyData, err := ReadFileWithLimit(filepath.Join(authDir, GitHubAppPrivateKeyFile), MaxAuthFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	privateKey, err := parseRSAPrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+
+	installationID := ""
+	installationIDPath := filepath.Join(authDir, GitHubAppInstallationIDFile)
+	if FileExists(installationIDPath) {
+		installationID, err = readTrimmedFile(installationIDPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App installation id: %w", err)
+		}
+	}
+
+	return &gitHubApp{appID: appID, installationID: installationID, privateKey: privateKey}, nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt creates a short-lived JWT to authenticate as the GitHub App.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func (a *gitHubApp) jwt() (string, error) {
+	now := time.Now()
+	header := `{"alg":"RS256","typ":"JWT"}`
+	claims, err := json.Marshal(map[string]any{
+		// Allow for clock drift between us and GitHub
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	signingInput := encoding.EncodeToString([]byte(header)) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+// mintInstallationToken creates an installation access token usable for the given repository.
+// If the installation id is not configured, it is looked up from the repository.
+func (a *gitHubApp) mintInstallationToken(repoURL *url.URL) (string, error) {
+	jwt, err := a.jwt()
+	if err != nil {
+		return "", err
+	}
+	apiURL := gitHubAPIURL(repoURL)
+
+	installationID := a.installationID
+	if installationID == "" {
+		owner, repo, err := ownerAndRepo(repoURL)
+		if err != nil {
+			return "", err
+		}
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		endpoint := fmt.Sprintf("%s/repos/%s/%s/installation", apiURL, url.PathEscape(owner), url.PathEscape(repo))
+		if err := gitHubAPIRequest(http.MethodGet, endpoint, jwt, http.StatusOK, &installation); err != nil {
+			return "", fmt.Errorf("failed to find installation for %s/%s: %w", owner, repo, err)
+		}
+		installationID = strconv.FormatInt(installation.ID, 10)
+	}
+
+	var accessToken struct {
+		Token string `json:"token"`
+	}
+	endpoint := fmt.Sprintf("%s/app/installations/%s/access_tokens", apiURL, url.PathEscape(installationID))
+	if err := gitHubAPIRequest(http.MethodPost, endpoint, jwt, http.StatusCreated, &accessToken); err != nil {
+		return "", err
+	}
+	if accessToken.Token == "" {
+		return "", errors.New("GitHub API returned an empty installation token")
+	}
+	return accessToken.Token, nil
+}
+
+// gitHubAPIURL returns the REST API root for the GitHub instance hosting the repository.
+func gitHubAPIURL(repoURL *url.URL) string {
+	if repoURL.Hostname() == "github.com" {
+		return "https://api.github.com"
+	}
+	// GitHub Enterprise Server
+	return fmt.Sprintf("%s://%s/api/v3", repoURL.Scheme, repoURL.Host)
+}
+
+func ownerAndRepo(repoURL *url.URL) (string, string, error) {
+	path := strings.Trim(repoURL.Path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("cannot determine GitHub owner and repository from URL path %q", repoURL.Path)
+	}
+	return parts[0], parts[1], nil
+}
+
+func gitHubAPIRequest(method, endpoint, jwt string, expectedStatus int, result any) error {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-Github-Api-Version", "2022-11-28")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxAuthFileSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("%s %s returned status %d", method, endpoint, resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to parse GitHub API response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package gitauth
+
+import (
+	"os"
+	"strings"
+)
+
+// FindSSHKeys returns the names of the private keys in an ssh-directory workspace:
+// the files named id_* that aren't public keys (*.pub).
+func FindSSHKeys(sshDir string) ([]string, error) {
+	entries, err := os.ReadDir(sshDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "id_") && !strings.HasSuffix(name, ".pub") && !entry.IsDir() {
+			keys = append(keys, name)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,25 @@
+package gitauth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_FindSSHKeys(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	for _, name := range []string{"id_rsa", "id_rsa.pub", "id_ed25519", "known_hosts", "config"} {
+		writeFile(t, filepath.Join(dir, name), name)
+	}
+	g.Expect(os.Mkdir(filepath.Join(dir, "id_dir"), 0755)).To(Succeed())
+
+	keys, err := FindSSHKeys(dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(keys).To(Equal([]string{"id_ed25519", "id_rsa"}))
+
+	_, err = FindSSHKeys(filepath.Join(dir, "nonexistent"))
+	g.Expect(err).To(HaveOccurred())
+}
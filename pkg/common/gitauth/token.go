@@ -0,0 +1,138 @@
+package gitauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// File names recognized in a git auth workspace for token based authentication.
+const (
+	TokenFile                   = "token"
+	GitHubAppIDFile             = "github-app-id"
+	GitHubAppPrivateKeyFile     = "github-app-private-key"
+	GitHubAppInstallationIDFile = "github-app-installation-id"
+)
+
+const (
+	TokenSourceFile      = "token"
+	TokenSourceGitHubApp = "github-app"
+)
+
+// TokenAuth holds an HTTP authorization header scoped to a single git host.
+// The header is never written into the remote URL; it's passed to git via
+// http.<url>.extraHeader in a git config file.
+type TokenAuth struct {
+	// BaseURL is the scheme and host the header applies to, e.g. https://github.com/
+	BaseURL string
+	// Source is where the token came from, one of TokenSourceFile or TokenSourceGitHubApp.
+	Source string
+
+	header string
+}
+
+// HasTokenAuth reports whether authDir contains a token file or a GitHub App id/private key pair.
+func HasTokenAuth(authDir string) bool {
+	if authDir == "" {
+		return false
+	}
+	if FileExists(filepath.Join(authDir, TokenFile)) {
+		return true
+	}
+	return FileExists(filepath.Join(authDir, GitHubAppIDFile)) &&
+		FileExists(filepath.Join(authDir, GitHubAppPrivateKeyFile))
+}
+
+// LoadTokenAuth creates TokenAuth for the given repository URL from the authDir workspace.
+//
+// Supported formats:
+//  1. token file: the token is sent as "Authorization: Bearer <token>"
+//  2. github-app-id and github-app-private-key files (and optionally github-app-installation-id):
+//     an installation token is minted via the GitHub API and sent as basic auth for x-access-token,
+//     which is the form GitHub expects for git over HTTPS.
+//
+// The repository URL must be an HTTPS URL, the token is never sent in cleartext.
+func LoadTokenAuth(authDir, repoURL string) (*TokenAuth, error) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository URL: %w", err)
+	}
+	if parsedURL.Scheme != "https" || parsedURL.Host == "" {
+		return nil, fmt.Errorf("token auth requires an HTTPS URL with scheme and host")
+	}
+	baseURL := fmt.Sprintf("%s://%s/", parsedURL.Scheme, parsedURL.Host)
+
+	tokenPath := filepath.Join(authDir, TokenFile)
+	if FileExists(tokenPath) {
+		token, err := readTrimmedFile(tokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		if token == "" {
+			return nil, errors.New("token file is empty")
+		}
+		l.Logger.Debugf("Using token auth for %s", baseURL)
+		return &TokenAuth{
+			BaseURL: baseURL,
+			Source:  TokenSourceFile,
+			header:  "Authorization: Bearer " + token,
+		}, nil
+	}
+
+	appIDPath := filepath.Join(authDir, GitHubAppIDFile)
+	privateKeyPath := filepath.Join(authDir, GitHubAppPrivateKeyFile)
+	if FileExists(appIDPath) && FileExists(privateKeyPath) {
+		app, err := loadGitHubApp(authDir)
+		if err != nil {
+			return nil, err
+		}
+		token, err := app.mintInstallationToken(parsedURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mint GitHub App installation token: %w", err)
+		}
+		l.Logger.Debugf("Using GitHub App %s installation token for %s", app.appID, baseURL)
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+		return &TokenAuth{
+			BaseURL: baseURL,
+			Source:  TokenSourceGitHubApp,
+			header:  "Authorization: Basic " + basic,
+		}, nil
+	}
+
+	return nil, errors.New("no token or GitHub App credentials found")
+}
+
+// GitConfig renders a git config which sends the authorization header
+// only to URLs under BaseURL.
+func (t *TokenAuth) GitConfig() string {
+	return fmt.Sprintf("[http \"%s\"]\n  extraHeader = %s\n", t.BaseURL, QuoteGitConfigValue(t.header))
+}
+
+// WriteGitConfig writes the git config returned by GitConfig to path with the given permissions.
+func (t *TokenAuth) WriteGitConfig(path string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(t.GitConfig()), perm)
+}
+
+// QuoteGitConfigValue wraps value in double quotes, escaping characters
+// that have special meaning in git config files.
+func QuoteGitConfigValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func readTrimmedFile(path string) (string, error) {
+	data, err := ReadFileWithLimit(path, MaxAuthFileSize)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,209 @@
+package gitauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_HasTokenAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should be false for empty dir", func(t *testing.T) {
+		g.Expect(HasTokenAuth("")).To(BeFalse())
+		g.Expect(HasTokenAuth(t.TempDir())).To(BeFalse())
+	})
+
+	t.Run("should detect token file", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, TokenFile), "abc")
+		g.Expect(HasTokenAuth(authDir)).To(BeTrue())
+	})
+
+	t.Run("should require both GitHub App files", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, GitHubAppIDFile), "123")
+		g.Expect(HasTokenAuth(authDir)).To(BeFalse())
+		writeFile(t, filepath.Join(authDir, GitHubAppPrivateKeyFile), "key")
+		g.Expect(HasTokenAuth(authDir)).To(BeTrue())
+	})
+}
+
+func Test_LoadTokenAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should configure bearer token scoped to the host", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, TokenFile), "my-token\n")
+
+		auth, err := LoadTokenAuth(authDir, "https://git.test/user/repo.git")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(auth.Source).To(Equal(TokenSourceFile))
+		g.Expect(auth.BaseURL).To(Equal("https://git.test/"))
+		g.Expect(auth.GitConfig()).To(Equal("[http \"https://git.test/\"]\n  extraHeader = \"Authorization: Bearer my-token\"\n"))
+	})
+
+	t.Run("should fail with empty token file", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, TokenFile), "  \n")
+
+		_, err := LoadTokenAuth(authDir, "https://git.test/user/repo.git")
+
+		g.Expect(err).To(MatchError(ContainSubstring("token file is empty")))
+	})
+
+	t.Run("should reject non-HTTP URLs", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, TokenFile), "my-token")
+
+		_, err := LoadTokenAuth(authDir, "git@git.test:user/repo.git")
+
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should reject plain HTTP URLs", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, TokenFile), "my-token")
+
+		_, err := LoadTokenAuth(authDir, "http://git.test/user/repo.git")
+
+		g.Expect(err).To(MatchError(ContainSubstring("requires an HTTPS URL")))
+	})
+
+	t.Run("should fail without credentials", func(t *testing.T) {
+		_, err := LoadTokenAuth(t.TempDir(), "https://git.test/user/repo.git")
+
+		g.Expect(err).To(MatchError(ContainSubstring("no token or GitHub App credentials found")))
+	})
+
+	t.Run("should write git config file", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, TokenFile), `to"ken`)
+		auth, err := LoadTokenAuth(authDir, "https://git.test/user/repo.git")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		configPath := filepath.Join(t.TempDir(), "sub", ".gitconfig")
+		g.Expect(auth.WriteGitConfig(configPath, 0400)).To(Succeed())
+
+		content, err := os.ReadFile(configPath)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(content)).To(ContainSubstring(`extraHeader = "Authorization: Bearer to\"ken"`))
+	})
+}
+
+func Test_LoadTokenAuth_GitHubApp(t *testing.T) {
+	g := NewWithT(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	var requests []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/repos/org/repo/installation":
+			_, _ = w.Write([]byte(`{"id": 42}`))
+		case "/api/v3/app/installations/42/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token": "ghs_installation"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	origClient := httpClient
+	httpClient = server.Client()
+	defer func() { httpClient = origClient }()
+
+	t.Run("should look up installation and mint a token", func(t *testing.T) {
+		requests = nil
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, GitHubAppIDFile), "1234")
+		writeFile(t, filepath.Join(authDir, GitHubAppPrivateKeyFile), string(keyPEM))
+
+		auth, err := LoadTokenAuth(authDir, server.URL+"/org/repo.git")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(auth.Source).To(Equal(TokenSourceGitHubApp))
+		g.Expect(requests).To(Equal([]string{
+			"GET /api/v3/repos/org/repo/installation",
+			"POST /api/v3/app/installations/42/access_tokens",
+		}))
+		expectedBasic := base64.StdEncoding.EncodeToString([]byte("x-access-token:ghs_installation"))
+		g.Expect(auth.GitConfig()).To(ContainSubstring("Authorization: Basic " + expectedBasic))
+	})
+
+	t.Run("should use configured installation id", func(t *testing.T) {
+		requests = nil
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, GitHubAppIDFile), "1234")
+		writeFile(t, filepath.Join(authDir, GitHubAppPrivateKeyFile), string(keyPEM))
+		writeFile(t, filepath.Join(authDir, GitHubAppInstallationIDFile), "42")
+
+		_, err := LoadTokenAuth(authDir, server.URL+"/org/repo")
+
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(requests).To(Equal([]string{"POST /api/v3/app/installations/42/access_tokens"}))
+	})
+
+	t.Run("should fail on unknown installation", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, GitHubAppIDFile), "1234")
+		writeFile(t, filepath.Join(authDir, GitHubAppPrivateKeyFile), string(keyPEM))
+
+		_, err := LoadTokenAuth(authDir, server.URL+"/other/repo")
+
+		g.Expect(err).To(MatchError(ContainSubstring("returned status 404")))
+	})
+
+	t.Run("should fail with invalid private key", func(t *testing.T) {
+		authDir := t.TempDir()
+		writeFile(t, filepath.Join(authDir, GitHubAppIDFile), "1234")
+		writeFile(t, filepath.Join(authDir, GitHubAppPrivateKeyFile), "not a key")
+
+		_, err := LoadTokenAuth(authDir, server.URL+"/org/repo")
+
+		g.Expect(err).To(MatchError(ContainSubstring("failed to parse GitHub App private key")))
+	})
+}
+
+func Test_gitHubApp_jwt(t *testing.T) {
+	g := NewWithT(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	app := &gitHubApp{appID: "1234", privateKey: privateKey}
+
+	jwt, err := app.jwt()
+
+	g.Expect(err).ToNot(HaveOccurred())
+	parts := strings.Split(jwt, ".")
+	g.Expect(parts).To(HaveLen(3))
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(claims)).To(ContainSubstring(`"iss":"1234"`))
+}
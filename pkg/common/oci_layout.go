@@ -0,0 +1,181 @@
+package common
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// The transport prefix for OCI image layouts, see https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+const OCILayoutTransport = "oci:"
+
+// A reference to an image in an OCI layout: oci:<dir>[:<image>][@<digest>]
+//
+// The image is the value of the org.opencontainers.image.ref.name annotation in index.json.
+// The digest is not part of the oci: transport syntax, but lets us treat layouts like registries,
+// which are referenced by digest in build results.
+type OCILayoutRef struct {
+	Dir    string
+	Image  string
+	Digest string
+}
+
+func IsOCILayoutRef(ref string) bool {
+	return strings.HasPrefix(ref, OCILayoutTransport)
+}
+
+func ParseOCILayoutRef(ref string) (OCILayoutRef, error) {
+	rest, ok := strings.CutPrefix(ref, OCILayoutTransport)
+	if !ok {
+		return OCILayoutRef{}, fmt.Errorf("'%s' is not an %s reference", ref, OCILayoutTransport)
+	}
+
+	var layoutRef OCILayoutRef
+	// @<algorithm>:<hex> is a digest, the transport's own :@<index> syntax has no colon after the @
+	if i := strings.LastIndex(rest, "@"); i >= 0 && strings.Contains(rest[i:], ":") {
+		layoutRef.Digest = rest[i+1:]
+		rest = rest[:i]
+		if !IsImageDigestValid(layoutRef.Digest) {
+			return OCILayoutRef{}, fmt.Errorf("invalid digest in %s", ref)
+		}
+	}
+
+	layoutRef.Dir, layoutRef.Image, _ = strings.Cut(rest, ":")
+	if layoutRef.Dir == "" {
+		return OCILayoutRef{}, fmt.Errorf("missing directory in %s", ref)
+	}
+	return layoutRef, nil
+}
+
+// The reference in the oci: transport syntax, without the digest.
+func (r OCILayoutRef) String() string {
+	if r.Image == "" {
+		return OCILayoutTransport + r.Dir
+	}
+	return OCILayoutTransport + r.Dir + ":" + r.Image
+}
+
+// A reference that tools using the oci: transport (buildah, skopeo) can use to read the image.
+// If the digest is set, the image is selected by its position in index.json (oci:<dir>:@<index>).
+func (r OCILayoutRef) SourceRef() (string, error) {
+	if r.Digest == "" {
+		return r.String(), nil
+	}
+	index, err := ReadOCILayoutIndex(r.Dir)
+	if err != nil {
+		return "", err
+	}
+	position, _, err := FindOCIIndexDescriptor(index, r.Image, r.Digest)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", r.Dir, err)
+	}
+	return OCILayoutTransport + r.Dir + ":@" + strconv.Itoa(position), nil
+}
+
+// Determine the digest of the referenced image (the manifest or index listed in index.json).
+func (r OCILayoutRef) ResolveDigest() (string, error) {
+	index, err := ReadOCILayoutIndex(r.Dir)
+	if err != nil {
+		return "", err
+	}
+	_, descriptor, err := FindOCIIndexDescriptor(index, r.Image, r.Digest)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", r.Dir, err)
+	}
+	return descriptor.Digest.String(), nil
+}
+
+// Read the index.json of an OCI layout directory.
+func ReadOCILayoutIndex(dir string) (*ociv1.Index, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ociv1.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI layout index: %w", err)
+	}
+	return parseOCIIndex(content)
+}
+
+// Read the index.json of an OCI archive (a tarball of an OCI layout).
+func ReadOCIArchiveIndex(archivePath string) (*ociv1.Index, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening OCI archive: %w", err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s not found in OCI archive %s", ociv1.ImageIndexFile, archivePath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading OCI archive: %w", err)
+		}
+		if filepath.Clean(header.Name) != ociv1.ImageIndexFile {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading OCI archive: %w", err)
+		}
+		return parseOCIIndex(content)
+	}
+}
+
+func parseOCIIndex(content []byte) (*ociv1.Index, error) {
+	var index ociv1.Index
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("parsing OCI layout index: %w", err)
+	}
+	return &index, nil
+}
+
+// Find an image in the OCI layout index. Returns its position in the manifests list and its descriptor.
+//
+// If the digest is set, the image must have that digest. If the image name is set, the image must
+// have a matching org.opencontainers.image.ref.name annotation. If neither is set, the index must
+// contain exactly one image.
+func FindOCIIndexDescriptor(index *ociv1.Index, image, digest string) (int, ociv1.Descriptor, error) {
+	found := -1
+	for i, descriptor := range index.Manifests {
+		if digest != "" && descriptor.Digest.String() != digest {
+			continue
+		}
+		if image != "" && descriptor.Annotations[ociv1.AnnotationRefName] != image {
+			continue
+		}
+		if found >= 0 {
+			if digest != "" {
+				// The same image may be listed more than once (e.g. with different names), any will do
+				break
+			}
+			return -1, ociv1.Descriptor{}, fmt.Errorf("more than one image matches %s, specify the image name or digest", describeOCIImage(image, digest))
+		}
+		found = i
+	}
+	if found < 0 {
+		return -1, ociv1.Descriptor{}, fmt.Errorf("no image matches %s", describeOCIImage(image, digest))
+	}
+	return found, index.Manifests[found], nil
+}
+
+func describeOCIImage(image, digest string) string {
+	switch {
+	case image != "" && digest != "":
+		return fmt.Sprintf("name '%s' and digest %s", image, digest)
+	case image != "":
+		return fmt.Sprintf("name '%s'", image)
+	case digest != "":
+		return fmt.Sprintf("digest %s", digest)
+	default:
+		return "the reference"
+	}
+}
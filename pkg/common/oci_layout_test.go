@@ -0,0 +1,136 @@
+package common
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	ociTestDigestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	ociTestDigestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+const ociTestIndex = `{
+	"schemaVersion": 2,
+	"manifests": [
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + ociTestDigestA + `", "size": 100,
+		 "annotations": {"org.opencontainers.image.ref.name": "v1"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + ociTestDigestB + `", "size": 100},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + ociTestDigestA + `", "size": 100,
+		 "annotations": {"org.opencontainers.image.ref.name": "latest"}}
+	]
+}`
+
+func Test_ParseOCILayoutRef(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		expected    OCILayoutRef
+		expectedErr string
+	}{
+		{name: "dir only", ref: "oci:/path/to/layout", expected: OCILayoutRef{Dir: "/path/to/layout"}},
+		{name: "dir and image", ref: "oci:layout:v1", expected: OCILayoutRef{Dir: "layout", Image: "v1"}},
+		{name: "dir and digest", ref: "oci:layout@" + ociTestDigestA, expected: OCILayoutRef{Dir: "layout", Digest: ociTestDigestA}},
+		{
+			name:     "dir, image and digest",
+			ref:      "oci:layout:v1@" + ociTestDigestA,
+			expected: OCILayoutRef{Dir: "layout", Image: "v1", Digest: ociTestDigestA},
+		},
+		{name: "index syntax is not a digest", ref: "oci:layout:@1", expected: OCILayoutRef{Dir: "layout", Image: "@1"}},
+		{name: "not an oci ref", ref: "quay.io/org/image:tag", expectedErr: "'quay.io/org/image:tag' is not an oci: reference"},
+		{name: "invalid digest", ref: "oci:layout@sha256:abc", expectedErr: "invalid digest in oci:layout@sha256:abc"},
+		{name: "missing dir", ref: "oci::v1", expectedErr: "missing directory in oci::v1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ref, err := ParseOCILayoutRef(tc.ref)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(tc.expectedErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(ref).To(Equal(tc.expected))
+		})
+	}
+}
+
+func Test_OCILayoutRef(t *testing.T) {
+	g := NewWithT(t)
+
+	layoutDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(layoutDir, "index.json"), []byte(ociTestIndex), 0644)).To(Succeed())
+
+	t.Run("String", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(OCILayoutRef{Dir: "layout"}.String()).To(Equal("oci:layout"))
+		g.Expect(OCILayoutRef{Dir: "layout", Image: "v1", Digest: ociTestDigestA}.String()).To(Equal("oci:layout:v1"))
+	})
+
+	t.Run("SourceRef", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ref, err := OCILayoutRef{Dir: layoutDir, Image: "v1"}.SourceRef()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ref).To(Equal("oci:" + layoutDir + ":v1"))
+
+		ref, err = OCILayoutRef{Dir: layoutDir, Digest: ociTestDigestB}.SourceRef()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ref).To(Equal("oci:" + layoutDir + ":@1"))
+
+		ref, err = OCILayoutRef{Dir: layoutDir, Image: "latest", Digest: ociTestDigestA}.SourceRef()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ref).To(Equal("oci:" + layoutDir + ":@2"))
+
+		_, err = OCILayoutRef{Dir: layoutDir, Image: "v1", Digest: ociTestDigestB}.SourceRef()
+		g.Expect(err).To(MatchError(ContainSubstring("no image matches name 'v1' and digest " + ociTestDigestB)))
+	})
+
+	t.Run("ResolveDigest", func(t *testing.T) {
+		g := NewWithT(t)
+
+		digest, err := OCILayoutRef{Dir: layoutDir, Image: "latest"}.ResolveDigest()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(digest).To(Equal(ociTestDigestA))
+
+		_, err = OCILayoutRef{Dir: layoutDir}.ResolveDigest()
+		g.Expect(err).To(MatchError(ContainSubstring("more than one image matches the reference")))
+
+		_, err = OCILayoutRef{Dir: t.TempDir()}.ResolveDigest()
+		g.Expect(err).To(MatchError(ContainSubstring("reading OCI layout index")))
+	})
+}
+
+func Test_ReadOCIArchiveIndex(t *testing.T) {
+	g := NewWithT(t)
+
+	writeArchive := func(files map[string]string) string {
+		archivePath := filepath.Join(t.TempDir(), "archive.tar")
+		f, err := os.Create(archivePath)
+		g.Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+
+		tw := tar.NewWriter(f)
+		for name, content := range files {
+			g.Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+			_, err := tw.Write([]byte(content))
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+		g.Expect(tw.Close()).To(Succeed())
+		return archivePath
+	}
+
+	archivePath := writeArchive(map[string]string{"oci-layout": `{"imageLayoutVersion": "1.0.0"}`, "./index.json": ociTestIndex})
+	index, err := ReadOCIArchiveIndex(archivePath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(index.Manifests).To(HaveLen(3))
+
+	archivePath = writeArchive(map[string]string{"oci-layout": `{"imageLayoutVersion": "1.0.0"}`})
+	_, err = ReadOCIArchiveIndex(archivePath)
+	g.Expect(err).To(MatchError("index.json not found in OCI archive " + archivePath))
+}
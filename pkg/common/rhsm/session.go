@@ -0,0 +1,237 @@
+// Package rhsm manages the registration of the host system with Red Hat Subscription Management
+// for the duration of a command.
+package rhsm
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// Where sessions are recorded by default. The registration is host-wide, so is the state file.
+const DefaultStateFile = "/var/lib/konflux-build-cli/rhsm-session.json"
+
+var log = l.Logger.WithField("logger", "RHSM")
+
+// The state file records a registration whose process is still running.
+var ErrSessionActive = errors.New("RHSM registration is still in use")
+
+// Files containing an activation key and the organization ID it belongs to.
+type Credentials struct {
+	ActivationKeyFile string
+	OrgFile           string
+}
+
+// Pair activation key files with org files by position.
+func PairCredentials(activationKeyFiles, orgFiles []string) ([]Credentials, error) {
+	if len(activationKeyFiles) != len(orgFiles) {
+		return nil, fmt.Errorf("got %d activation keys but %d orgs, they must be paired",
+			len(activationKeyFiles), len(orgFiles))
+	}
+	credentials := make([]Credentials, len(activationKeyFiles))
+	for i := range activationKeyFiles {
+		credentials[i] = Credentials{ActivationKeyFile: activationKeyFiles[i], OrgFile: orgFiles[i]}
+	}
+	return credentials, nil
+}
+
+// The primary credentials followed by the fallbacks, paired by position.
+func CredentialsWithFallbacks(activationKeyFile, orgFile string, fallbackKeyFiles, fallbackOrgFiles []string) ([]Credentials, error) {
+	fallbacks, err := PairCredentials(fallbackKeyFiles, fallbackOrgFiles)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Credentials{{ActivationKeyFile: activationKeyFile, OrgFile: orgFile}}, fallbacks...), nil
+}
+
+func (c Credentials) registerParams() (*cliwrappers.SubscriptionManagerRegisterParams, error) {
+	key, err := os.ReadFile(c.ActivationKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	org, err := os.ReadFile(c.OrgFile)
+	if err != nil {
+		return nil, err
+	}
+	return &cliwrappers.SubscriptionManagerRegisterParams{
+		Org:           strings.TrimSpace(string(org)),
+		ActivationKey: strings.TrimSpace(string(key)),
+		Force:         true,
+	}, nil
+}
+
+// The content of the state file. Never contains the secrets themselves.
+type sessionState struct {
+	ActivationKeyFile string    `json:"activation_key_file"`
+	RegisteredAt      time.Time `json:"registered_at"`
+	Pid               int       `json:"pid"`
+}
+
+// Session is a registration with RHSM. The state file records the registration until it
+// is ended with Unregister, so that a registration leaked by a crashed process can be
+// cleaned up by the next session or by CleanupLeaked.
+type Session struct {
+	SubscriptionManager cliwrappers.SubscriptionManagerCliInterface
+	// Path of the state file, empty to not record the session
+	StateFile string
+
+	// The credentials that registered the system, nil if not registered
+	Registered *Credentials
+}
+
+// Register the system with the first of the credentials that works, trying them in order.
+// Each registration attempt is retried by the subscription-manager wrapper.
+func (s *Session) Register(credentials []Credentials) error {
+	if len(credentials) == 0 {
+		return errors.New("no RHSM credentials to register with")
+	}
+	if err := s.CleanupLeaked(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, creds := range credentials {
+		params, err := creds.registerParams()
+		if err != nil {
+			return err
+		}
+		// Record the session before registering, the process may crash halfway through
+		if err := s.writeState(creds); err != nil {
+			return err
+		}
+		if err := s.SubscriptionManager.Register(params); err != nil {
+			log.Warnf("Registration with activation key %s failed: %s", creds.ActivationKeyFile, err)
+			errs = append(errs, fmt.Errorf("%w (activation key %s)", err, creds.ActivationKeyFile))
+			continue
+		}
+		log.Infof("Registered with RHSM using activation key %s", creds.ActivationKeyFile)
+		s.Registered = &creds
+		return nil
+	}
+
+	s.removeState()
+	return errors.Join(errs...)
+}
+
+// Unregister the system if the session registered it (best-effort).
+func (s *Session) Unregister() {
+	if s.Registered == nil {
+		return
+	}
+	s.SubscriptionManager.Unregister()
+	s.removeState()
+	s.Registered = nil
+}
+
+// Unregister the system if the state file records a session that was never ended.
+// Returns ErrSessionActive without unregistering if the process that registered is still running.
+func (s *Session) CleanupLeaked() error {
+	if s.StateFile == "" {
+		return nil
+	}
+	content, err := os.ReadFile(s.StateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading RHSM session state: %w", err)
+	}
+
+	var state sessionState
+	if err := json.Unmarshal(content, &state); err != nil {
+		log.Warnf("Ignoring invalid RHSM session state %s: %s", s.StateFile, err)
+	} else if state.Pid != os.Getpid() && processRunning(state.Pid) {
+		return fmt.Errorf("%w: registered at %s by pid %d (activation key %s), which is still running",
+			ErrSessionActive, state.RegisteredAt.Format(time.RFC3339), state.Pid, state.ActivationKeyFile)
+	} else {
+		log.Warnf("Found a leaked RHSM registration (activation key %s, registered at %s by pid %d), unregistering",
+			state.ActivationKeyFile, state.RegisteredAt.Format(time.RFC3339), state.Pid)
+	}
+	s.SubscriptionManager.Unregister()
+	s.removeState()
+	return nil
+}
+
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// Signal 0 only checks that the process exists, EPERM means it exists but belongs to another user
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func (s *Session) writeState(creds Credentials) error {
+	if s.StateFile == "" {
+		return nil
+	}
+	content, err := json.Marshal(sessionState{
+		ActivationKeyFile: creds.ActivationKeyFile,
+		RegisteredAt:      time.Now().UTC(),
+		Pid:               os.Getpid(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.StateFile), 0755); err != nil {
+		return fmt.Errorf("writing RHSM session state: %w", err)
+	}
+	if err := os.WriteFile(s.StateFile, content, 0644); err != nil {
+		return fmt.Errorf("writing RHSM session state: %w", err)
+	}
+	return nil
+}
+
+func (s *Session) removeState() {
+	if s.StateFile == "" {
+		return
+	}
+	if err := os.Remove(s.StateFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warnf("Failed to remove RHSM session state %s: %s", s.StateFile, err)
+	}
+}
+
+// Check that the entitlement certificates in the directory are valid at the given time.
+// Files without certificates (the entitlement keys) are skipped.
+func CheckEntitlementCerts(dir string, now time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", entry.Name(), err)
+			}
+			if now.After(cert.NotAfter) {
+				errs = append(errs, fmt.Errorf("%s expired at %s", entry.Name(), cert.NotAfter.Format(time.RFC3339)))
+			} else if now.Before(cert.NotBefore) {
+				errs = append(errs, fmt.Errorf("%s is not valid before %s", entry.Name(), cert.NotBefore.Format(time.RFC3339)))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,211 @@
+package rhsm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/konflux-build-cli/pkg/cliwrappers"
+)
+
+type mockSubscriptionManager struct {
+	registered   []*cliwrappers.SubscriptionManagerRegisterParams
+	unregistered int
+	registerErrs map[string]error
+}
+
+func (m *mockSubscriptionManager) Register(params *cliwrappers.SubscriptionManagerRegisterParams) error {
+	m.registered = append(m.registered, params)
+	return m.registerErrs[params.ActivationKey]
+}
+
+func (m *mockSubscriptionManager) Unregister() {
+	m.unregistered++
+}
+
+func writeCredentials(t *testing.T, names ...string) []Credentials {
+	t.Helper()
+	dir := t.TempDir()
+	var credentials []Credentials
+	for _, name := range names {
+		creds := Credentials{ActivationKeyFile: filepath.Join(dir, name+"-key"), OrgFile: filepath.Join(dir, name+"-org")}
+		if err := os.WriteFile(creds.ActivationKeyFile, []byte(name+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(creds.OrgFile, []byte("org-"+name+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		credentials = append(credentials, creds)
+	}
+	return credentials
+}
+
+func Test_PairCredentials(t *testing.T) {
+	g := NewWithT(t)
+
+	credentials, err := PairCredentials([]string{"key1", "key2"}, []string{"org1", "org2"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(credentials).To(Equal([]Credentials{{"key1", "org1"}, {"key2", "org2"}}))
+
+	_, err = PairCredentials([]string{"key1", "key2"}, []string{"org1"})
+	g.Expect(err).To(MatchError("got 2 activation keys but 1 orgs, they must be paired"))
+}
+
+func Test_CredentialsWithFallbacks(t *testing.T) {
+	g := NewWithT(t)
+
+	credentials, err := CredentialsWithFallbacks("key", "org", []string{"key1", "key2"}, []string{"org1", "org2"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(credentials).To(Equal([]Credentials{{"key", "org"}, {"key1", "org1"}, {"key2", "org2"}}))
+
+	credentials, err = CredentialsWithFallbacks("key", "org", nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(credentials).To(Equal([]Credentials{{"key", "org"}}))
+
+	_, err = CredentialsWithFallbacks("key", "org", []string{"key1"}, nil)
+	g.Expect(err).To(MatchError("got 1 activation keys but 0 orgs, they must be paired"))
+}
+
+func Test_Session_Register(t *testing.T) {
+	t.Run("should fall back to the next credentials and record the session", func(t *testing.T) {
+		g := NewWithT(t)
+		credentials := writeCredentials(t, "primary", "fallback")
+		sm := &mockSubscriptionManager{registerErrs: map[string]error{"primary": errors.New("expired key")}}
+		session := &Session{SubscriptionManager: sm, StateFile: filepath.Join(t.TempDir(), "state", "rhsm.json")}
+
+		g.Expect(session.Register(credentials)).To(Succeed())
+
+		g.Expect(sm.registered).To(HaveLen(2))
+		g.Expect(*sm.registered[1]).To(Equal(cliwrappers.SubscriptionManagerRegisterParams{
+			Org: "org-fallback", ActivationKey: "fallback", Force: true,
+		}))
+		g.Expect(session.Registered).To(Equal(&credentials[1]))
+
+		state, err := os.ReadFile(session.StateFile)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(state)).To(ContainSubstring(credentials[1].ActivationKeyFile))
+		g.Expect(string(state)).ToNot(ContainSubstring(`"fallback"`))
+
+		session.Unregister()
+		g.Expect(sm.unregistered).To(Equal(1))
+		g.Expect(session.StateFile).ToNot(BeAnExistingFile())
+		session.Unregister()
+		g.Expect(sm.unregistered).To(Equal(1), "should unregister only once")
+	})
+
+	t.Run("should report all failures", func(t *testing.T) {
+		g := NewWithT(t)
+		credentials := writeCredentials(t, "first", "second")
+		sm := &mockSubscriptionManager{registerErrs: map[string]error{
+			"first":  errors.New("invalid key"),
+			"second": errors.New("network timeout"),
+		}}
+		session := &Session{SubscriptionManager: sm, StateFile: filepath.Join(t.TempDir(), "rhsm.json")}
+
+		err := session.Register(credentials)
+
+		g.Expect(err).To(MatchError(ContainSubstring("invalid key (activation key " + credentials[0].ActivationKeyFile + ")")))
+		g.Expect(err).To(MatchError(ContainSubstring("network timeout (activation key " + credentials[1].ActivationKeyFile + ")")))
+		g.Expect(session.Registered).To(BeNil())
+		g.Expect(session.StateFile).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should unregister a leaked session first", func(t *testing.T) {
+		g := NewWithT(t)
+		stateFile := filepath.Join(t.TempDir(), "rhsm.json")
+		g.Expect(os.WriteFile(stateFile, []byte(`{"activation_key_file": "/old/key", "pid": 99999999}`), 0644)).To(Succeed())
+		sm := &mockSubscriptionManager{}
+		session := &Session{SubscriptionManager: sm, StateFile: stateFile}
+
+		g.Expect(session.Register(writeCredentials(t, "key"))).To(Succeed())
+
+		g.Expect(sm.unregistered).To(Equal(1))
+		g.Expect(sm.registered).To(HaveLen(1))
+		g.Expect(stateFile).To(BeAnExistingFile())
+	})
+}
+
+func Test_Session_CleanupLeaked(t *testing.T) {
+	g := NewWithT(t)
+	sm := &mockSubscriptionManager{}
+	session := &Session{SubscriptionManager: sm, StateFile: filepath.Join(t.TempDir(), "rhsm.json")}
+
+	g.Expect(session.CleanupLeaked()).To(Succeed())
+	g.Expect(sm.unregistered).To(Equal(0), "should not unregister without a leaked session")
+
+	g.Expect(os.WriteFile(session.StateFile, []byte("not json"), 0644)).To(Succeed())
+	g.Expect(session.CleanupLeaked()).To(Succeed())
+	g.Expect(sm.unregistered).To(Equal(1))
+	g.Expect(session.StateFile).ToNot(BeAnExistingFile())
+
+	// the parent process (go test) is still running
+	activeState := fmt.Sprintf(`{"activation_key_file": "/other/key", "pid": %d}`, os.Getppid())
+	g.Expect(os.WriteFile(session.StateFile, []byte(activeState), 0644)).To(Succeed())
+	g.Expect(session.CleanupLeaked()).To(MatchError(ErrSessionActive))
+	g.Expect(sm.unregistered).To(Equal(1), "should not unregister a session that is still in use")
+	g.Expect(session.StateFile).To(BeAnExistingFile())
+
+	g.Expect(os.WriteFile(session.StateFile, []byte(`{"activation_key_file": "/old/key", "pid": 99999999}`), 0644)).To(Succeed())
+	g.Expect(session.CleanupLeaked()).To(Succeed())
+	g.Expect(sm.unregistered).To(Equal(2))
+	g.Expect(session.StateFile).ToNot(BeAnExistingFile())
+}
+
+func writeTestCert(t *testing.T, path string, notBefore, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "entitlement"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	content = append(content, pem.EncodeToMemory(&pem.Block{Type: "ENTITLEMENT DATA", Bytes: []byte("data")})...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_CheckEntitlementCerts(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should accept valid certificates and skip keys", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeTestCert(t, filepath.Join(dir, "123.pem"), now.AddDate(0, -1, 0), now.AddDate(1, 0, 0))
+		g.Expect(os.WriteFile(filepath.Join(dir, "123-key.pem"), []byte("not a certificate"), 0600)).To(Succeed())
+
+		g.Expect(CheckEntitlementCerts(dir, now)).To(Succeed())
+	})
+
+	t.Run("should reject expired and not yet valid certificates", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		writeTestCert(t, filepath.Join(dir, "expired.pem"), now.AddDate(-1, 0, 0), now.AddDate(0, 0, -1))
+		writeTestCert(t, filepath.Join(dir, "future.pem"), now.AddDate(0, 0, 1), now.AddDate(1, 0, 0))
+
+		err := CheckEntitlementCerts(dir, now)
+		g.Expect(err).To(MatchError(ContainSubstring("expired.pem expired at 2026-05-31T00:00:00Z")))
+		g.Expect(err).To(MatchError(ContainSubstring("future.pem is not valid before 2026-06-02T00:00:00Z")))
+	})
+}
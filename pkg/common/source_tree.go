@@ -0,0 +1,500 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	l "github.com/konflux-ci/konflux-build-cli/pkg/logger"
+)
+
+// Severity of a source tree finding.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	// SeverityOff disables a rule.
+	SeverityOff Severity = "off"
+)
+
+// Names of the built-in source tree rules, used in findings and to override severities in a policy.
+const (
+	RuleSymlinkEscape = "symlink-escape"
+	RuleDeviceFile    = "device-file"
+	RuleSetuid        = "setuid"
+	RuleCaseCollision = "case-collision"
+	RuleFileSize      = "file-size"
+	RuleForbiddenPath = "forbidden-path"
+	RuleSecret        = "secret"
+)
+
+var defaultRuleSeverities = map[string]Severity{
+	RuleSymlinkEscape: SeverityError,
+	RuleDeviceFile:    SeverityError,
+	RuleSetuid:        SeverityWarning,
+	RuleCaseCollision: SeverityWarning,
+	RuleFileSize:      SeverityError,
+	RuleForbiddenPath: SeverityError,
+	RuleSecret:        SeverityError,
+}
+
+// DefaultSecretPatterns are used by the secret rule when the policy enables them.
+var DefaultSecretPatterns = []string{
+	`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`,
+	`\bAKIA[0-9A-Z]{16}\b`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	`\bglpat-[A-Za-z0-9_-]{20,}\b`,
+	`\bxox[abpr]-[A-Za-z0-9-]{10,}\b`,
+}
+
+// Files bigger than this are not searched for secrets.
+const maxSecretScanFileSize = 1024 * 1024
+
+// Number of leading bytes checked for NUL to detect binary files.
+const binaryDetectionSize = 8000
+
+// SourceTreePolicy configures the checks run on a source tree, see NewSourceTreeScanner.
+// The symlink-escape, device-file, setuid and case-collision rules are always enabled
+// unless turned off in Severities, the other rules are enabled by their settings.
+type SourceTreePolicy struct {
+	// Maximum size of a regular file in bytes, 0 means no limit.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+	// Glob patterns of paths that must not be present. Patterns without a slash
+	// match the name of an entry at any depth, others match the path relative to the root.
+	ForbiddenPaths []string `json:"forbiddenPaths,omitempty"`
+	// Regular expressions matched against each line of text files.
+	SecretPatterns []string `json:"secretPatterns,omitempty"`
+	// Also use DefaultSecretPatterns.
+	DefaultSecretPatterns bool `json:"defaultSecretPatterns,omitempty"`
+	// Rule name => severity, overrides the default severity of a rule.
+	Severities map[string]Severity `json:"severities,omitempty"`
+}
+
+// LoadSourceTreePolicy reads a JSON encoded SourceTreePolicy from a file.
+func LoadSourceTreePolicy(policyPath string) (*SourceTreePolicy, error) {
+	content, err := os.ReadFile(policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source tree policy: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	policy := &SourceTreePolicy{}
+	if err := decoder.Decode(policy); err != nil {
+		return nil, fmt.Errorf("failed to parse source tree policy %s: %w", policyPath, err)
+	}
+	return policy, nil
+}
+
+// SourceTreeEntry is a file, directory or other filesystem entry visited by the scanner.
+type SourceTreeEntry struct {
+	// Path of the entry as walked, i.e. joined with the scanned directory
+	Path string
+	// Slash separated path relative to the scanned directory
+	RelPath string
+	// Resolved path of the scanned directory
+	Root ResolvedPath
+	// Lstat info of the entry
+	Info fs.FileInfo
+}
+
+// SourceTreeViolation is reported by a rule for an entry.
+type SourceTreeViolation struct {
+	Message string
+	// Line number for content based rules, 0 otherwise
+	Line int
+}
+
+// SourceTreeRule checks entries of a source tree.
+type SourceTreeRule interface {
+	Name() string
+	// Check returns the violations found for the entry. Returning fs.SkipDir
+	// for a directory skips its content for all rules.
+	Check(entry SourceTreeEntry) ([]SourceTreeViolation, error)
+}
+
+// SourceTreeFinding is a rule violation as it appears in the report.
+type SourceTreeFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	Line     int      `json:"line,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// SourceTreeReport contains the findings of a source tree scan.
+type SourceTreeReport struct {
+	Root     string              `json:"root"`
+	Errors   int                 `json:"errors"`
+	Warnings int                 `json:"warnings"`
+	Findings []SourceTreeFinding `json:"findings"`
+}
+
+// Err returns an error if the report contains findings with the error severity.
+func (r *SourceTreeReport) Err() error {
+	if r.Errors == 0 {
+		return nil
+	}
+	var rules []string
+	seen := map[string]bool{}
+	for _, finding := range r.Findings {
+		if finding.Severity == SeverityError && !seen[finding.Rule] {
+			seen[finding.Rule] = true
+			rules = append(rules, finding.Rule)
+		}
+	}
+	return fmt.Errorf("found %d source tree policy violation(s): %s", r.Errors, strings.Join(rules, ", "))
+}
+
+// WriteFile writes the report as JSON to the given path.
+func (r *SourceTreeReport) WriteFile(reportPath string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reportPath, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write source tree report: %w", err)
+	}
+	return nil
+}
+
+type scannerRule struct {
+	rule     SourceTreeRule
+	severity Severity
+}
+
+// SourceTreeScanner walks a directory tree and runs the configured rules on every entry.
+type SourceTreeScanner struct {
+	// Slash separated paths relative to the scanned directory that are not walked, e.g. .git
+	SkipDirs []string
+
+	rules []scannerRule
+}
+
+// NewSourceTreeScanner creates a scanner with the built-in rules configured by the policy.
+func NewSourceTreeScanner(policy *SourceTreePolicy) (*SourceTreeScanner, error) {
+	if policy == nil {
+		policy = &SourceTreePolicy{}
+	}
+
+	for name, severity := range policy.Severities {
+		if _, ok := defaultRuleSeverities[name]; !ok {
+			return nil, fmt.Errorf("unknown source tree rule %q", name)
+		}
+		if severity != SeverityError && severity != SeverityWarning && severity != SeverityOff {
+			return nil, fmt.Errorf("invalid severity %q for source tree rule %s", severity, name)
+		}
+	}
+
+	rules := []SourceTreeRule{symlinkEscapeRule{}, deviceFileRule{}, setuidRule{}, &caseCollisionRule{}}
+	if policy.MaxFileSize < 0 {
+		return nil, fmt.Errorf("invalid maximum file size %d", policy.MaxFileSize)
+	}
+	if policy.MaxFileSize > 0 {
+		rules = append(rules, fileSizeRule{maxSize: policy.MaxFileSize})
+	}
+	if len(policy.ForbiddenPaths) > 0 {
+		for _, pattern := range policy.ForbiddenPaths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid forbidden path pattern %q: %w", pattern, err)
+			}
+		}
+		rules = append(rules, forbiddenPathRule{patterns: policy.ForbiddenPaths})
+	}
+	secretPatterns := append([]string{}, policy.SecretPatterns...)
+	if policy.DefaultSecretPatterns {
+		secretPatterns = append(secretPatterns, DefaultSecretPatterns...)
+	}
+	if len(secretPatterns) > 0 {
+		rule := secretRule{}
+		for _, pattern := range secretPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid secret pattern %q: %w", pattern, err)
+			}
+			rule.patterns = append(rule.patterns, re)
+		}
+		rules = append(rules, rule)
+	}
+
+	scanner := &SourceTreeScanner{}
+	for _, rule := range rules {
+		severity, ok := policy.Severities[rule.Name()]
+		if !ok {
+			severity = defaultRuleSeverities[rule.Name()]
+		}
+		scanner.AddRule(rule, severity)
+	}
+	return scanner, nil
+}
+
+// AddRule adds a rule to the scanner. Rules with the off severity are ignored.
+func (s *SourceTreeScanner) AddRule(rule SourceTreeRule, severity Severity) {
+	if severity == SeverityOff {
+		return
+	}
+	s.rules = append(s.rules, scannerRule{rule: rule, severity: severity})
+}
+
+// Scan walks the directory and returns the findings of all rules. Findings are logged
+// as they are found, the returned error only reports failures to perform the scan.
+func (s *SourceTreeScanner) Scan(dir string) (*SourceTreeReport, error) {
+	l.Logger.Debugf("Checking source tree %s", dir)
+
+	root, err := ResolvePath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory path: %w", err)
+	}
+
+	report := &SourceTreeReport{Root: dir, Findings: []SourceTreeFinding{}}
+
+	err = filepath.WalkDir(dir, func(entryPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, entryPath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && s.isSkipped(rel) {
+			return fs.SkipDir
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := SourceTreeEntry{Path: entryPath, RelPath: rel, Root: root, Info: info}
+
+		skipDir := false
+		for _, r := range s.rules {
+			violations, err := r.rule.Check(entry)
+			if errors.Is(err, fs.SkipDir) {
+				skipDir = true
+			} else if err != nil {
+				return fmt.Errorf("%s rule failed on %s: %w", r.rule.Name(), rel, err)
+			}
+			for _, violation := range violations {
+				report.add(SourceTreeFinding{
+					Rule:     r.rule.Name(),
+					Severity: r.severity,
+					Path:     rel,
+					Line:     violation.Line,
+					Message:  violation.Message,
+				})
+			}
+		}
+		if skipDir && d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	l.Logger.Debugf("Source tree check finished with %d error(s) and %d warning(s)", report.Errors, report.Warnings)
+	return report, nil
+}
+
+func (s *SourceTreeScanner) isSkipped(rel string) bool {
+	for _, skipDir := range s.SkipDirs {
+		if rel == path.Clean(skipDir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *SourceTreeReport) add(finding SourceTreeFinding) {
+	location := finding.Path
+	if finding.Line > 0 {
+		location = fmt.Sprintf("%s:%d", finding.Path, finding.Line)
+	}
+	if finding.Severity == SeverityError {
+		r.Errors++
+		l.Logger.Errorf("[%s] %s: %s", finding.Rule, location, finding.Message)
+	} else {
+		r.Warnings++
+		l.Logger.Warnf("[%s] %s: %s", finding.Rule, location, finding.Message)
+	}
+	r.Findings = append(r.Findings, finding)
+}
+
+// symlinkEscapeRule reports broken symlinks and symlinks pointing outside the root.
+type symlinkEscapeRule struct{}
+
+func (symlinkEscapeRule) Name() string { return RuleSymlinkEscape }
+
+func (symlinkEscapeRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	if entry.Info.Mode()&os.ModeSymlink == 0 {
+		return nil, nil
+	}
+	resolvedTarget, err := ResolvePath(entry.Path)
+	if err != nil {
+		return []SourceTreeViolation{{Message: "broken symlink"}}, nil
+	}
+	if !resolvedTarget.IsRelativeTo(entry.Root) {
+		return []SourceTreeViolation{{Message: fmt.Sprintf("symlink points outside directory: %s", resolvedTarget)}}, nil
+	}
+	return nil, nil
+}
+
+// deviceFileRule reports block and character devices.
+type deviceFileRule struct{}
+
+func (deviceFileRule) Name() string { return RuleDeviceFile }
+
+func (deviceFileRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	mode := entry.Info.Mode()
+	switch {
+	case mode&os.ModeCharDevice != 0:
+		return []SourceTreeViolation{{Message: "character device"}}, nil
+	case mode&os.ModeDevice != 0:
+		return []SourceTreeViolation{{Message: "block device"}}, nil
+	}
+	return nil, nil
+}
+
+// setuidRule reports regular files with the setuid or setgid bit.
+type setuidRule struct{}
+
+func (setuidRule) Name() string { return RuleSetuid }
+
+func (setuidRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	mode := entry.Info.Mode()
+	if !mode.IsRegular() {
+		return nil, nil
+	}
+	var bits []string
+	if mode&os.ModeSetuid != 0 {
+		bits = append(bits, "setuid")
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits = append(bits, "setgid")
+	}
+	if len(bits) == 0 {
+		return nil, nil
+	}
+	return []SourceTreeViolation{{Message: fmt.Sprintf("file has the %s bit set", strings.Join(bits, " and "))}}, nil
+}
+
+// caseCollisionRule reports paths that differ only in case and would overwrite
+// each other on case-insensitive filesystems.
+type caseCollisionRule struct {
+	// lowercased path => first path seen
+	seen map[string]string
+}
+
+func (*caseCollisionRule) Name() string { return RuleCaseCollision }
+
+func (r *caseCollisionRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	if r.seen == nil {
+		r.seen = make(map[string]string)
+	}
+	key := strings.ToLower(entry.RelPath)
+	if other, ok := r.seen[key]; ok {
+		return []SourceTreeViolation{{Message: fmt.Sprintf("collides with %s on case-insensitive filesystems", other)}}, nil
+	}
+	r.seen[key] = entry.RelPath
+	return nil, nil
+}
+
+// fileSizeRule reports regular files bigger than the limit.
+type fileSizeRule struct {
+	maxSize int64
+}
+
+func (fileSizeRule) Name() string { return RuleFileSize }
+
+func (r fileSizeRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	if !entry.Info.Mode().IsRegular() || entry.Info.Size() <= r.maxSize {
+		return nil, nil
+	}
+	return []SourceTreeViolation{{
+		Message: fmt.Sprintf("file size %d bytes exceeds the limit of %d bytes", entry.Info.Size(), r.maxSize),
+	}}, nil
+}
+
+// forbiddenPathRule reports entries matching any of the patterns, see SourceTreePolicy.ForbiddenPaths.
+// The content of forbidden directories is not scanned.
+type forbiddenPathRule struct {
+	patterns []string
+}
+
+func (forbiddenPathRule) Name() string { return RuleForbiddenPath }
+
+func (r forbiddenPathRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	for _, pattern := range r.patterns {
+		subject := entry.RelPath
+		if !strings.Contains(pattern, "/") {
+			subject = path.Base(entry.RelPath)
+		}
+		// Patterns are validated in NewSourceTreeScanner
+		if matched, _ := path.Match(pattern, subject); !matched {
+			continue
+		}
+		violations := []SourceTreeViolation{{Message: fmt.Sprintf("path matches forbidden pattern %q", pattern)}}
+		if entry.Info.IsDir() {
+			return violations, fs.SkipDir
+		}
+		return violations, nil
+	}
+	return nil, nil
+}
+
+// secretRule reports lines of text files matching any of the patterns.
+// The matched text itself is never included in the findings.
+type secretRule struct {
+	patterns []*regexp.Regexp
+}
+
+func (secretRule) Name() string { return RuleSecret }
+
+func (r secretRule) Check(entry SourceTreeEntry) ([]SourceTreeViolation, error) {
+	if !entry.Info.Mode().IsRegular() || entry.Info.Size() > maxSecretScanFileSize {
+		return nil, nil
+	}
+	content, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(content[:min(len(content), binaryDetectionSize)], 0) >= 0 {
+		return nil, nil
+	}
+	return r.scanContent(bytes.NewReader(content))
+}
+
+func (r secretRule) scanContent(content io.Reader) ([]SourceTreeViolation, error) {
+	var violations []SourceTreeViolation
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 64*1024), maxSecretScanFileSize)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		for _, re := range r.patterns {
+			if re.Match(scanner.Bytes()) {
+				violations = append(violations, SourceTreeViolation{
+					Message: fmt.Sprintf("line matches secret pattern %q", re.String()),
+					Line:    lineno,
+				})
+				break
+			}
+		}
+	}
+	return violations, scanner.Err()
+}